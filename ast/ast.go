@@ -1,6 +1,9 @@
 package ast
 
+import "github.com/dreblang/core/token"
+
 type Node interface {
 	TokenLiteral() string
+	Pos() token.Position
 	String() string
 }
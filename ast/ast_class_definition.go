@@ -14,10 +14,11 @@ type ClassDefinition struct {
 
 func (sd *ClassDefinition) statementNode()       {}
 func (sd *ClassDefinition) TokenLiteral() string { return sd.Token.Literal }
+func (sd *ClassDefinition) Pos() token.Position  { return sd.Token.Pos }
 func (sd *ClassDefinition) String() string {
 	var out bytes.Buffer
 
-	out.WriteString("Class ")
+	out.WriteString("class ")
 	out.WriteString(sd.Name.String())
 	out.WriteString(" {\n")
 
@@ -31,24 +32,37 @@ func (sd *ClassDefinition) String() string {
 }
 
 func (sd *ClassDefinition) ConvertToFunc() []Statement {
+	// Every generated node points back at the 'class' keyword
+	pos := sd.Token.Pos
+	scopeName := sd.Name.Value + "Class"
+
 	return []Statement{
 		&LetStatement{
-			Name: sd.Name,
+			Token: token.Token{Type: token.Let, Literal: "let", Pos: pos},
+			Name:  sd.Name,
 			Value: &FunctionLiteral{
+				Token:      token.Token{Type: token.Function, Literal: "fn", Pos: pos},
+				Name:       sd.Name.Value,
 				Parameters: []*Identifier{},
 				Body: &BlockStatement{
+					Token: sd.Block.Token,
 					Statements: []Statement{
 						&ScopeDefinition{
+							Token: token.Token{Type: token.Scope, Literal: "scope", Pos: pos},
 							Name: &Identifier{
-								Value: sd.Name.Value + "Class",
+								Token: token.Token{Type: token.Identifier, Literal: scopeName, Pos: pos},
+								Value: scopeName,
 							},
 							Block: &BlockStatement{
+								Token:      sd.Block.Token,
 								Statements: append([]Statement{}, sd.Block.Statements...),
 							},
 						},
 						&ReturnStatement{
+							Token: token.Token{Type: token.Return, Literal: "return", Pos: pos},
 							ReturnValue: &Identifier{
-								Value: sd.Name.Value + "Class",
+								Token: token.Token{Type: token.Identifier, Literal: scopeName, Pos: pos},
+								Value: scopeName,
 							},
 						},
 					},
@@ -0,0 +1,14 @@
+package ast
+
+import "github.com/dreblang/core/token"
+
+// Comment is a // line comment. The parser collects comments on the Program
+// instead of the statements they appear between.
+type Comment struct {
+	Token token.Token // the // token
+	Text  string      // the text after //
+}
+
+func (c *Comment) TokenLiteral() string { return c.Token.Literal }
+func (c *Comment) Pos() token.Position  { return c.Token.Pos }
+func (c *Comment) String() string       { return token.DoubleSlash + c.Text }
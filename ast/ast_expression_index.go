@@ -18,13 +18,28 @@ type IndexExpression struct {
 
 func (ie *IndexExpression) expressionNode()      {}
 func (ie *IndexExpression) TokenLiteral() string { return ie.Token.Literal }
+func (ie *IndexExpression) Pos() token.Position  { return ie.Token.Pos }
 func (ie *IndexExpression) String() string {
 	var out bytes.Buffer
 
 	out.WriteString(token.LeftParen)
 	out.WriteString(ie.Left.String())
 	out.WriteString(token.LeftBracket)
-	out.WriteString(ie.Index.String())
+	if ie.Index != nil {
+		out.WriteString(ie.Index.String())
+	}
+	if ie.HasUpper {
+		out.WriteString(token.Comma)
+		if ie.IndexUpper != nil {
+			out.WriteString(ie.IndexUpper.String())
+		}
+	}
+	if ie.HasSkip {
+		out.WriteString(token.Comma)
+		if ie.IndexSkip != nil {
+			out.WriteString(ie.IndexSkip.String())
+		}
+	}
 	out.WriteString(token.RightBracket)
 	out.WriteString(token.RightParen)
 
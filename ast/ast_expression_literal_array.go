@@ -10,10 +10,12 @@ import (
 type ArrayLiteral struct {
 	Token    token.Token // the '[' token
 	Elements []Expression
+	End      token.Position // the closing ']' token
 }
 
 func (al *ArrayLiteral) expressionNode()      {}
 func (al *ArrayLiteral) TokenLiteral() string { return al.Token.Literal }
+func (al *ArrayLiteral) Pos() token.Position  { return al.Token.Pos }
 func (al *ArrayLiteral) String() string {
 	var out bytes.Buffer
 
@@ -11,4 +11,5 @@ type FloatLiteral struct {
 
 func (il *FloatLiteral) expressionNode()      {}
 func (il *FloatLiteral) TokenLiteral() string { return il.Token.Literal }
+func (il *FloatLiteral) Pos() token.Position  { return il.Token.Pos }
 func (il *FloatLiteral) String() string       { return il.Token.Literal }
@@ -10,24 +10,45 @@ import (
 type FunctionLiteral struct {
 	Token      token.Token // The 'fn' token
 	Parameters []*Identifier
-	Body       *BlockStatement
+	// Nil, or the annotation of each parameter with nil for the ones
+	// without
+	ParameterTypes []*TypeAnnotation
+	ReturnType     *TypeAnnotation
+	Body           *BlockStatement
+	Name           string // Set when the literal is bound to a name, used for stack traces
 }
 
 func (fl *FunctionLiteral) expressionNode()      {}
 func (fl *FunctionLiteral) TokenLiteral() string { return fl.Token.Literal }
+func (fl *FunctionLiteral) Pos() token.Position  { return fl.Token.Pos }
 func (fl *FunctionLiteral) String() string {
 	var out bytes.Buffer
 
 	var params []string
-	for _, p := range fl.Parameters {
-		params = append(params, p.String())
+	for i, p := range fl.Parameters {
+		param := p.String()
+		if t := fl.ParameterType(i); t != nil {
+			param += token.Colon + " " + t.String()
+		}
+		params = append(params, param)
 	}
 
 	out.WriteString(fl.TokenLiteral())
 	out.WriteString(token.LeftParen)
 	out.WriteString(strings.Join(params, token.Comma+" "))
 	out.WriteString(token.RightParen)
+	if fl.ReturnType != nil {
+		out.WriteString(" " + token.Arrow + " " + fl.ReturnType.String() + " ")
+	}
 	out.WriteString(fl.Body.String())
 
 	return out.String()
 }
+
+// ParameterType returns the annotation of parameter i, or nil.
+func (fl *FunctionLiteral) ParameterType(i int) *TypeAnnotation {
+	if i < len(fl.ParameterTypes) {
+		return fl.ParameterTypes[i]
+	}
+	return nil
+}
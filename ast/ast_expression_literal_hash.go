@@ -10,16 +10,19 @@ import (
 type HashLiteral struct {
 	Token token.Token // The '{' token
 	Pairs map[Expression]Expression
+	Keys  []Expression   // Keys of Pairs in source order
+	End   token.Position // The closing '}' token
 }
 
 func (hl *HashLiteral) expressionNode()      {}
 func (hl *HashLiteral) TokenLiteral() string { return hl.Token.Literal }
+func (hl *HashLiteral) Pos() token.Position  { return hl.Token.Pos }
 func (hl *HashLiteral) String() string {
 	var out bytes.Buffer
 
 	var pairs []string
-	for key, value := range hl.Pairs {
-		pairs = append(pairs, key.String()+token.Colon+value.String())
+	for _, key := range hl.Keys {
+		pairs = append(pairs, key.String()+token.Colon+hl.Pairs[key].String())
 	}
 
 	out.WriteString(token.LeftBrace)
@@ -14,6 +14,7 @@ type LoopExpression struct {
 
 func (le *LoopExpression) expressionNode()      {}
 func (le *LoopExpression) TokenLiteral() string { return le.Token.Literal }
+func (le *LoopExpression) Pos() token.Position  { return le.Token.Pos }
 func (le *LoopExpression) String() string {
 	var out bytes.Buffer
 
@@ -0,0 +1,17 @@
+package ast
+
+import (
+	"github.com/dreblang/core/token"
+)
+
+type ThrowExpression struct {
+	Token token.Token // The 'throw' token
+	Value Expression
+}
+
+func (te *ThrowExpression) expressionNode()      {}
+func (te *ThrowExpression) TokenLiteral() string { return te.Token.Literal }
+func (te *ThrowExpression) Pos() token.Position  { return te.Token.Pos }
+func (te *ThrowExpression) String() string {
+	return te.TokenLiteral() + " " + te.Value.String()
+}
@@ -0,0 +1,40 @@
+package ast
+
+import (
+	"bytes"
+
+	"github.com/dreblang/core/token"
+)
+
+type TryExpression struct {
+	Token     token.Token // The 'try' token
+	Block     *BlockStatement
+	Parameter *Identifier // Optional name the caught error is bound to
+	Catch     *BlockStatement
+	Finally   *BlockStatement
+}
+
+func (te *TryExpression) expressionNode()      {}
+func (te *TryExpression) TokenLiteral() string { return te.Token.Literal }
+func (te *TryExpression) Pos() token.Position  { return te.Token.Pos }
+func (te *TryExpression) String() string {
+	var out bytes.Buffer
+
+	out.WriteString("try ")
+	out.WriteString(te.Block.String())
+
+	if te.Catch != nil {
+		out.WriteString(" catch ")
+		if te.Parameter != nil {
+			out.WriteString(token.LeftParen + te.Parameter.String() + token.RightParen + " ")
+		}
+		out.WriteString(te.Catch.String())
+	}
+
+	if te.Finally != nil {
+		out.WriteString(" finally ")
+		out.WriteString(te.Finally.String())
+	}
+
+	return out.String()
+}
@@ -1,9 +1,14 @@
 package ast
 
-import "bytes"
+import (
+	"bytes"
+
+	"github.com/dreblang/core/token"
+)
 
 type Program struct {
 	Statements []Statement
+	Comments   []*Comment // in source order
 }
 
 func (p *Program) TokenLiteral() string {
@@ -14,6 +19,13 @@ func (p *Program) TokenLiteral() string {
 	}
 }
 
+func (p *Program) Pos() token.Position {
+	if len(p.Statements) > 0 {
+		return p.Statements[0].Pos()
+	}
+	return token.Position{}
+}
+
 func (p *Program) String() string {
 	var out bytes.Buffer
 
@@ -14,6 +14,7 @@ type ScopeDefinition struct {
 
 func (sd *ScopeDefinition) statementNode()       {}
 func (sd *ScopeDefinition) TokenLiteral() string { return sd.Token.Literal }
+func (sd *ScopeDefinition) Pos() token.Position  { return sd.Token.Pos }
 func (sd *ScopeDefinition) String() string {
 	var out bytes.Buffer
 
@@ -9,10 +9,12 @@ import (
 type BlockStatement struct {
 	Token      token.Token // the { token
 	Statements []Statement
+	End        token.Position // the closing } token
 }
 
 func (bs *BlockStatement) statementNode()       {}
 func (bs *BlockStatement) TokenLiteral() string { return bs.Token.Literal }
+func (bs *BlockStatement) Pos() token.Position  { return bs.Token.Pos }
 func (bs *BlockStatement) String() string {
 	var out bytes.Buffer
 
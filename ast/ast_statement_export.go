@@ -13,6 +13,7 @@ type ExportStatement struct {
 
 func (rs *ExportStatement) statementNode()       {}
 func (rs *ExportStatement) TokenLiteral() string { return rs.Token.Literal }
+func (rs *ExportStatement) Pos() token.Position  { return rs.Token.Pos }
 func (rs *ExportStatement) String() string {
 	var out bytes.Buffer
 
@@ -1,6 +1,8 @@
 package ast
 
 import (
+	"bytes"
+	"fmt"
 	"math/rand"
 
 	"github.com/dreblang/core/token"
@@ -15,8 +17,23 @@ type IterStatement struct {
 
 func (is *IterStatement) statementNode()       {}
 func (ls *IterStatement) TokenLiteral() string { return ls.Token.Literal }
+func (ls *IterStatement) Pos() token.Position  { return ls.Token.Pos }
 func (ls *IterStatement) String() string {
-	return ""
+	var out bytes.Buffer
+
+	out.WriteString("iter ")
+	out.WriteString(ls.Identifier.String())
+	out.WriteString(" over ")
+	out.WriteString(ls.Expression.String())
+	out.WriteString(" {\n")
+
+	for _, s := range ls.Statements.Statements {
+		out.WriteString(s.String() + "\n")
+	}
+
+	out.WriteString("}\n")
+
+	return out.String()
 }
 
 func RandStringBytes(n int) string {
@@ -31,14 +48,23 @@ func RandStringBytes(n int) string {
 func (is *IterStatement) ConvertToLoop() []Statement {
 	stmts := []Statement{}
 
-	identName := RandStringBytes(16)
-	ident := &Identifier{Value: identName}
+	// Every generated node points back at the 'iter' keyword
+	pos := is.Token.Pos
+
+	// The counter is named after the position of the statement, so nested
+	// statements get their own and the name is the same on every compile.
+	// Scripts can't write names starting with $, and debuggers hide them.
+	identName := fmt.Sprintf("$iter%d:%d", pos.Line, pos.Column)
+	ident := &Identifier{
+		Token: token.Token{Type: token.Identifier, Literal: identName, Pos: pos},
+		Value: identName,
+	}
 
 	stmt1 := &LetStatement{
-		Token: token.Token{Type: token.Let},
+		Token: token.Token{Type: token.Let, Literal: "let", Pos: pos},
 		Name:  ident,
 		Value: &IntegerLiteral{
-			Token: token.Token{Type: token.Int, Literal: "0"},
+			Token: token.Token{Type: token.Int, Literal: "0", Pos: pos},
 			Value: 0,
 		},
 	}
@@ -47,8 +73,10 @@ func (is *IterStatement) ConvertToLoop() []Statement {
 	consequence = append(
 		consequence,
 		&LetStatement{
-			Name: is.Identifier,
+			Token: token.Token{Type: token.Let, Literal: "let", Pos: pos},
+			Name:  is.Identifier,
 			Value: &IndexExpression{
+				Token:    token.Token{Type: token.LeftBracket, Literal: token.LeftBracket, Pos: pos},
 				Left:     is.Expression,
 				Index:    ident,
 				HasSkip:  false,
@@ -60,12 +88,14 @@ func (is *IterStatement) ConvertToLoop() []Statement {
 	consequence = append(
 		consequence,
 		&LetStatement{
-			Name: ident,
+			Token: token.Token{Type: token.Let, Literal: "let", Pos: pos},
+			Name:  ident,
 			Value: &InfixExpression{
+				Token:    token.Token{Type: token.Plus, Literal: token.Plus, Pos: pos},
 				Left:     ident,
 				Operator: token.Plus,
 				Right: &IntegerLiteral{
-					Token: token.Token{Type: token.Int, Literal: "1"},
+					Token: token.Token{Type: token.Int, Literal: "1", Pos: pos},
 					Value: 1,
 				},
 			},
@@ -73,17 +103,24 @@ func (is *IterStatement) ConvertToLoop() []Statement {
 	)
 
 	stmt2 := &ExpressionStatement{
+		Token: is.Token,
 		Expression: &LoopExpression{
-			Token: token.Token{Type: token.Loop},
+			Token: token.Token{Type: token.Loop, Literal: "loop", Pos: pos},
 			Condition: &InfixExpression{
-				Left:     &Identifier{Value: identName},
+				Token:    token.Token{Type: token.LessThan, Literal: token.LessThan, Pos: pos},
+				Left:     ident,
 				Operator: token.LessThan,
 				Right: &CallExpression{
-					Function:  &Identifier{Value: "len"},
+					Token: token.Token{Type: token.LeftParen, Literal: token.LeftParen, Pos: pos},
+					Function: &Identifier{
+						Token: token.Token{Type: token.Identifier, Literal: "len", Pos: pos},
+						Value: "len",
+					},
 					Arguments: []Expression{is.Expression},
 				},
 			},
 			Consequence: &BlockStatement{
+				Token:      is.Statements.Token,
 				Statements: consequence,
 			},
 		},
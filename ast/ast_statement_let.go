@@ -9,16 +9,21 @@ import (
 type LetStatement struct {
 	Token token.Token // the token.Let token
 	Name  *Identifier
+	Type  *TypeAnnotation // nil if the binding is not annotated
 	Value Expression
 }
 
 func (ls *LetStatement) statementNode()       {}
 func (ls *LetStatement) TokenLiteral() string { return ls.Token.Literal }
+func (ls *LetStatement) Pos() token.Position  { return ls.Token.Pos }
 func (ls *LetStatement) String() string {
 	var out bytes.Buffer
 
 	out.WriteString(ls.TokenLiteral() + " ")
 	out.WriteString(ls.Name.String())
+	if ls.Type != nil {
+		out.WriteString(token.Colon + " " + ls.Type.String())
+	}
 	out.WriteString(" = ")
 
 	if ls.Value != nil {
@@ -13,6 +13,7 @@ type LoadStatement struct {
 
 func (rs *LoadStatement) statementNode()       {}
 func (rs *LoadStatement) TokenLiteral() string { return rs.Token.Literal }
+func (rs *LoadStatement) Pos() token.Position  { return rs.Token.Pos }
 func (rs *LoadStatement) String() string {
 	var out bytes.Buffer
 
@@ -0,0 +1,15 @@
+package ast
+
+import "github.com/dreblang/core/token"
+
+// TypeAnnotation is the declared type of a binding, parameter or return
+// value, as in "let a: int = 1". Annotations are checked by the types
+// package and ignored by the compiler.
+type TypeAnnotation struct {
+	Token token.Token // the type name
+	Name  string
+}
+
+func (ta *TypeAnnotation) TokenLiteral() string { return ta.Token.Literal }
+func (ta *TypeAnnotation) Pos() token.Position  { return ta.Token.Pos }
+func (ta *TypeAnnotation) String() string       { return ta.Name }
@@ -27,6 +27,8 @@ let loopfunc = fn(n) {
 loopfunc(50000000)
 `
 
+var engine = flag.String("engine", "vm", "use 'vm' or 'register'")
+
 func main() {
 	proffd, _ := os.Create("cpu.prof")
 	pprof.StartCPUProfile(proffd)
@@ -41,29 +43,51 @@ func main() {
 	p := parser.New(l)
 	program := p.ParseProgram()
 
-	comp := compiler.New()
-	err := comp.Compile(program)
-	if err != nil {
-		fmt.Printf("compiler error: %s", err)
-		return
-	}
+	if *engine == "register" {
+		comp := compiler.NewRegister()
+		err := comp.Compile(program)
+		if err != nil {
+			fmt.Printf("compiler error: %s", err)
+			return
+		}
 
-	machine := vm.New(comp.Bytecode())
+		machine := vm.NewRegister(comp.Bytecode())
 
-	start := time.Now()
+		start := time.Now()
 
-	err = machine.Run()
-	if err != nil {
-		fmt.Printf("vm error: %s", err)
-		return
-	}
+		err = machine.Run()
+		if err != nil {
+			fmt.Printf("vm error: %s", err)
+			return
+		}
+
+		duration = time.Since(start)
+		result = machine.Result()
+	} else {
+		comp := compiler.New()
+		err := comp.Compile(program)
+		if err != nil {
+			fmt.Printf("compiler error: %s", err)
+			return
+		}
 
-	duration = time.Since(start)
-	result = machine.LastPoppedStackElem()
+		machine := vm.New(comp.Bytecode())
+
+		start := time.Now()
+
+		err = machine.Run()
+		if err != nil {
+			fmt.Printf("vm error: %s", err)
+			return
+		}
+
+		duration = time.Since(start)
+		result = machine.LastPoppedStackElem()
+	}
 
 	fmt.Printf(
 		"engine=%s, result=%s, duration=%s\n",
-		"vm",
+		*engine,
 		result.Inspect(),
 		duration)
 }
@@ -0,0 +1,9 @@
+package main
+
+import (
+	"github.com/dreblang/core/pkg/drebdap"
+)
+
+func main() {
+	drebdap.Main()
+}
@@ -0,0 +1,9 @@
+package main
+
+import (
+	"github.com/dreblang/core/pkg/drebfmt"
+)
+
+func main() {
+	drebfmt.Main()
+}
@@ -0,0 +1,9 @@
+package main
+
+import (
+	"github.com/dreblang/core/pkg/dreblint"
+)
+
+func main() {
+	dreblint.Main()
+}
@@ -0,0 +1,9 @@
+package main
+
+import (
+	"github.com/dreblang/core/pkg/dreblsp"
+)
+
+func main() {
+	dreblsp.Main()
+}
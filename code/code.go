@@ -4,6 +4,7 @@ import (
 	"bytes"
 	"encoding/binary"
 	"fmt"
+	"sort"
 
 	"github.com/dreblang/core/token"
 )
@@ -52,6 +53,72 @@ var definitions = map[Opcode]*Definition{
 	OpExport:         {"OpExport", []int{}},
 	OpScope:          {"OpScope", []int{}},
 	OpScopeResolve:   {"OpScopeResolve", []int{}},
+	OpTry:            {"OpTry", []int{2}},
+	OpThrow:          {"OpThrow", []int{}},
+
+	OpLessThan:      {"OpLessThan", []int{}},
+	OpLessOrEqual:   {"OpLessOrEqual", []int{}},
+	OpIndexSimple:   {"OpIndexSimple", []int{}},
+	OpAddLocalConst: {"OpAddLocalConst", []int{1, 2}},
+
+	OpJumpIfGreaterOrEqual: {"OpJumpIfGreaterOrEqual", []int{2}},
+	OpJumpIfGreaterThan:    {"OpJumpIfGreaterThan", []int{2}},
+	OpJumpIfLessOrEqual:    {"OpJumpIfLessOrEqual", []int{2}},
+	OpJumpIfLessThan:       {"OpJumpIfLessThan", []int{2}},
+
+	OpTailCall: {"OpTailCall", []int{1}},
+
+	OpRegLoadConst:  {"OpRegLoadConst", []int{2, 2}},
+	OpRegLoadTrue:   {"OpRegLoadTrue", []int{2}},
+	OpRegLoadFalse:  {"OpRegLoadFalse", []int{2}},
+	OpRegLoadNull:   {"OpRegLoadNull", []int{2}},
+	OpRegMove:       {"OpRegMove", []int{2, 2}},
+	OpRegGetGlobal:  {"OpRegGetGlobal", []int{2, 2}},
+	OpRegSetGlobal:  {"OpRegSetGlobal", []int{2, 2}},
+	OpRegGetFree:    {"OpRegGetFree", []int{2, 1}},
+	OpRegSetFree:    {"OpRegSetFree", []int{1, 2}},
+	OpRegGetBuiltin: {"OpRegGetBuiltin", []int{2, 1}},
+
+	OpRegAdd:            {"OpRegAdd", []int{2, 2, 2}},
+	OpRegSub:            {"OpRegSub", []int{2, 2, 2}},
+	OpRegMul:            {"OpRegMul", []int{2, 2, 2}},
+	OpRegDiv:            {"OpRegDiv", []int{2, 2, 2}},
+	OpRegMod:            {"OpRegMod", []int{2, 2, 2}},
+	OpRegAddConst:       {"OpRegAddConst", []int{2, 2, 2}},
+	OpRegEqual:          {"OpRegEqual", []int{2, 2, 2}},
+	OpRegNotEqual:       {"OpRegNotEqual", []int{2, 2, 2}},
+	OpRegGreaterThan:    {"OpRegGreaterThan", []int{2, 2, 2}},
+	OpRegGreaterOrEqual: {"OpRegGreaterOrEqual", []int{2, 2, 2}},
+	OpRegLessThan:       {"OpRegLessThan", []int{2, 2, 2}},
+	OpRegLessOrEqual:    {"OpRegLessOrEqual", []int{2, 2, 2}},
+	OpRegMinus:          {"OpRegMinus", []int{2, 2}},
+	OpRegBang:           {"OpRegBang", []int{2, 2}},
+
+	OpRegJump:                 {"OpRegJump", []int{2}},
+	OpRegJumpNotTruthy:        {"OpRegJumpNotTruthy", []int{2, 2}},
+	OpRegJumpIfGreaterOrEqual: {"OpRegJumpIfGreaterOrEqual", []int{2, 2, 2}},
+	OpRegJumpIfGreaterThan:    {"OpRegJumpIfGreaterThan", []int{2, 2, 2}},
+	OpRegJumpIfLessOrEqual:    {"OpRegJumpIfLessOrEqual", []int{2, 2, 2}},
+	OpRegJumpIfLessThan:       {"OpRegJumpIfLessThan", []int{2, 2, 2}},
+
+	OpRegArray:        {"OpRegArray", []int{2, 2, 2}},
+	OpRegHash:         {"OpRegHash", []int{2, 2, 2}},
+	OpRegIndex:        {"OpRegIndex", []int{2, 2, 2}},
+	OpRegIndexRange:   {"OpRegIndexRange", []int{2, 2, 2}},
+	OpRegIndexSet:     {"OpRegIndexSet", []int{2, 2, 2}},
+	OpRegMember:       {"OpRegMember", []int{2, 2, 2}},
+	OpRegMemberSet:    {"OpRegMemberSet", []int{2, 2, 2, 2}},
+	OpRegScopeResolve: {"OpRegScopeResolve", []int{2, 2, 2}},
+
+	OpRegCall:       {"OpRegCall", []int{2, 2, 1}},
+	OpRegTailCall:   {"OpRegTailCall", []int{2, 2, 1}},
+	OpRegReturn:     {"OpRegReturn", []int{2}},
+	OpRegReturnNull: {"OpRegReturnNull", []int{}},
+	OpRegClosure:    {"OpRegClosure", []int{2, 2, 2, 1}},
+	OpRegScope:      {"OpRegScope", []int{2}},
+	OpRegExport:     {"OpRegExport", []int{2, 2}},
+	OpRegThrow:      {"OpRegThrow", []int{2}},
+	OpRegResult:     {"OpRegResult", []int{2}},
 }
 
 var OpCodeToOperatorMap = map[Opcode]string{
@@ -62,12 +129,25 @@ var OpCodeToOperatorMap = map[Opcode]string{
 	OpMod:            token.Percent,
 	OpGreaterThan:    token.GreaterThan,
 	OpGreaterOrEqual: token.GreaterOrEqual,
+	OpLessThan:       token.LessThan,
+	OpLessOrEqual:    token.LessOrEqual,
 	OpEqual:          token.Equal,
 	OpNotEqual:       token.NotEqual,
 }
 
 type Instructions []byte
 
+// IsJump reports whether the operand of op is the offset of the instruction
+// it may jump to.
+func IsJump(op Opcode) bool {
+	switch op {
+	case OpJump, OpJumpNotTruthy, OpJumpIfGreaterOrEqual, OpJumpIfGreaterThan, OpJumpIfLessOrEqual, OpJumpIfLessThan,
+		OpRegJump, OpRegJumpNotTruthy, OpRegJumpIfGreaterOrEqual, OpRegJumpIfGreaterThan, OpRegJumpIfLessOrEqual, OpRegJumpIfLessThan:
+		return true
+	}
+	return false
+}
+
 func Lookup(op byte) (*Definition, error) {
 	def, ok := definitions[Opcode(op)]
 	if !ok {
@@ -160,14 +240,40 @@ func (ins Instructions) fmtInstruction(def *Definition, operands []int) string {
 		return fmt.Sprintf("ERROR: operand len %d does not match defined %d\n", len(operands), operandCount)
 	}
 
-	switch operandCount {
-	case 0:
-		return def.Name
-	case 1:
-		return fmt.Sprintf("%s %d", def.Name, operands[0])
-	case 2:
-		return fmt.Sprintf("%s %d %d", def.Name, operands[0], operands[1])
+	text := def.Name
+	for _, o := range operands {
+		text += fmt.Sprintf(" %d", o)
 	}
+	return text
+}
+
+// LineEntry maps the instruction starting at Offset, and every instruction
+// following it up to the next entry, to a source position.
+type LineEntry struct {
+	Offset int
+	Pos    token.Position
+}
+
+type LineTable []LineEntry
+
+// Lookup returns the source position of the instruction at offset.
+func (lt LineTable) Lookup(offset int) token.Position {
+	idx := sort.Search(len(lt), func(i int) bool {
+		return lt[i].Offset > offset
+	})
+	if idx == 0 {
+		return token.Position{}
+	}
+	return lt[idx-1].Pos
+}
 
-	return fmt.Sprintf("ERROR: unhandled operandCount for %s\n", def.Name)
+// Handler protects the instructions in [Start, End). When an error is raised
+// inside the range, execution continues at Target with the stack restored to
+// the depth recorded by the OpTry for Slot. In register code there is no
+// OpTry and the error is stored in register Slot instead.
+type Handler struct {
+	Start  int
+	End    int
+	Target int
+	Slot   int
 }
@@ -54,4 +54,91 @@ const (
 	OpExport
 	OpScope
 	OpScopeResolve
+
+	OpTry
+	OpThrow
+
+	// Specialized forms of the generic instructions above, emitted when the
+	// operands allow
+	OpLessThan
+	OpLessOrEqual
+	OpIndexSimple
+	OpAddLocalConst
+
+	// A comparison fused with the OpJumpNotTruthy that follows it. Each
+	// jumps unless the comparison it replaces holds, so
+	// OpJumpIfGreaterOrEqual jumps unless left < right.
+	OpJumpIfGreaterOrEqual
+	OpJumpIfGreaterThan
+	OpJumpIfLessOrEqual
+	OpJumpIfLessThan
+
+	// OpTailCall is an OpCall directly followed by a return. A closure
+	// called this way replaces the frame of the caller.
+	OpTailCall
+
+	// Instructions of the register VM. Operands are registers of the current
+	// frame, the first being the destination, except where noted: K is a
+	// constant, G a global, F a free variable and T a jump target.
+	OpRegLoadConst // A K
+	OpRegLoadTrue
+	OpRegLoadFalse
+	OpRegLoadNull
+	OpRegMove
+	OpRegGetGlobal // A G
+	OpRegSetGlobal // G B
+	OpRegGetFree   // A F
+	OpRegSetFree   // F B
+	OpRegGetBuiltin
+
+	OpRegAdd
+	OpRegSub
+	OpRegMul
+	OpRegDiv
+	OpRegMod
+	OpRegAddConst // A B K
+	OpRegEqual
+	OpRegNotEqual
+	OpRegGreaterThan
+	OpRegGreaterOrEqual
+	OpRegLessThan
+	OpRegLessOrEqual
+	OpRegMinus
+	OpRegBang
+
+	// Jumps take the target first. The conditional ones are the register
+	// forms of OpJumpNotTruthy and the fused comparisons.
+	OpRegJump
+	OpRegJumpNotTruthy
+	OpRegJumpIfGreaterOrEqual
+	OpRegJumpIfGreaterThan
+	OpRegJumpIfLessOrEqual
+	OpRegJumpIfLessThan
+
+	// A B N builds from the N registers starting at B
+	OpRegArray
+	OpRegHash
+
+	// The index, upper bound, whether there is one, skip and whether there is
+	// one of OpRegIndexRange and OpRegIndexSet are in the five registers
+	// starting at C
+	OpRegIndex
+	OpRegIndexRange
+	OpRegIndexSet // A C value
+	OpRegMember
+	OpRegMemberSet // A object name value
+	OpRegScopeResolve
+
+	// A B N calls B with the N registers following it
+	OpRegCall
+	OpRegTailCall // A B N, an OpRegCall followed by OpRegReturn A
+	OpRegReturn
+	OpRegReturnNull
+	OpRegClosure // A K B N, with the free variables in the N registers starting at B
+	OpRegScope
+	OpRegExport // K B
+	OpRegThrow
+
+	// OpRegResult records the value of a top level expression statement
+	OpRegResult
 )
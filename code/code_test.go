@@ -1,6 +1,10 @@
 package code
 
-import "testing"
+import (
+	"testing"
+
+	"github.com/dreblang/core/token"
+)
 
 func TestMake(t *testing.T) {
 	tests := []struct {
@@ -12,6 +16,7 @@ func TestMake(t *testing.T) {
 		{OpAdd, []int{}, []byte{byte(OpAdd)}},
 		{OpGetLocal, []int{255}, []byte{byte(OpGetLocal), 255}},
 		{OpClosure, []int{65534, 255}, []byte{byte(OpClosure), 255, 254, 255}},
+		{OpAddLocalConst, []int{255, 65534}, []byte{byte(OpAddLocalConst), 255, 255, 254}},
 	}
 
 	for _, tt := range tests {
@@ -87,3 +92,34 @@ func TestReadOperands(t *testing.T) {
 		}
 	}
 }
+
+func TestLineTableLookup(t *testing.T) {
+	lines := LineTable{
+		{Offset: 0, Pos: token.Position{Line: 1, Column: 1}},
+		{Offset: 3, Pos: token.Position{Line: 2, Column: 5}},
+		{Offset: 7, Pos: token.Position{Line: 4, Column: 1}},
+	}
+
+	tests := []struct {
+		offset       int
+		expectedLine int
+	}{
+		{0, 1},
+		{2, 1},
+		{3, 2},
+		{6, 2},
+		{7, 4},
+		{100, 4},
+	}
+
+	for _, tt := range tests {
+		pos := lines.Lookup(tt.offset)
+		if pos.Line != tt.expectedLine {
+			t.Errorf("wrong line for offset %d. want=%d, got=%d", tt.offset, tt.expectedLine, pos.Line)
+		}
+	}
+
+	if pos := (LineTable{}).Lookup(0); pos.IsValid() {
+		t.Errorf("empty line table returned a valid position: %s", pos)
+	}
+}
@@ -0,0 +1,392 @@
+package compiler
+
+import (
+	"fmt"
+	"io/ioutil"
+	"sort"
+
+	"github.com/dreblang/core/ast"
+	"github.com/dreblang/core/lexer"
+	"github.com/dreblang/core/parser"
+	"github.com/dreblang/core/token"
+)
+
+type Severity int
+
+const (
+	SeverityError Severity = iota
+	SeverityWarning
+)
+
+func (s Severity) String() string {
+	if s == SeverityWarning {
+		return "warning"
+	}
+	return "error"
+}
+
+// Diagnostic is a problem Check found in a program. Errors would make the
+// program fail to compile or run, warnings point at likely mistakes.
+type Diagnostic struct {
+	Pos      token.Position
+	Severity Severity
+	Msg      string
+}
+
+func (d Diagnostic) String() string {
+	return fmt.Sprintf("%s: %s: %s", d.Pos, d.Severity, d.Msg)
+}
+
+// binding is a name the checker has seen defined
+type binding struct {
+	name    string
+	pos     token.Position
+	builtin bool
+	used    bool
+
+	// How many times a value is bound to the name, and the function literal
+	// of the last one
+	assignments int
+	fn          *ast.FunctionLiteral
+}
+
+type checkScope struct {
+	outer *checkScope
+	names map[string]*binding
+}
+
+type checker struct {
+	c     *Compiler
+	scope *checkScope
+
+	diagnostics []Diagnostic
+	// Bindings made by let inside a function or scope body, which must be
+	// read somewhere
+	locals []*binding
+	calls  []checkedCall
+	// Set while walking loaded modules, whose definitions are needed but
+	// whose problems are not ours to report
+	quiet int
+}
+
+type checkedCall struct {
+	callee *binding
+	call   *ast.CallExpression
+}
+
+// Check looks for every problem in node without compiling it: undefined
+// names, calls to functions with the wrong number of arguments, unused let
+// bindings, lets and parameters shadowing outer names and code after a
+// return. Names are resolved the way Compile would, starting from the
+// symbols the compiler already has. Diagnostics are sorted by position.
+func (c *Compiler) Check(node ast.Node) []Diagnostic {
+	ch := &checker{c: c, scope: &checkScope{names: map[string]*binding{}}}
+	ch.node(node)
+
+	for _, b := range ch.locals {
+		if !b.used {
+			ch.warn(b.pos, "%s declared and not used", b.name)
+		}
+	}
+	for _, call := range ch.calls {
+		fn := call.callee.fn
+		if call.callee.assignments == 1 && fn != nil && len(call.call.Arguments) != len(fn.Parameters) {
+			ch.error(call.call.Pos(), "wrong number of arguments to %s: want=%d, got=%d",
+				call.callee.name, len(fn.Parameters), len(call.call.Arguments))
+		}
+	}
+
+	sort.SliceStable(ch.diagnostics, func(i, j int) bool {
+		a, b := ch.diagnostics[i].Pos, ch.diagnostics[j].Pos
+		return a.Line < b.Line || a.Line == b.Line && a.Column < b.Column
+	})
+	return ch.diagnostics
+}
+
+func (ch *checker) node(node ast.Node) {
+	switch node := node.(type) {
+	case *ast.Program:
+		ch.statements(node.Statements)
+
+	case *ast.BlockStatement:
+		ch.statements(node.Statements)
+
+	case *ast.ExpressionStatement:
+		ch.node(node.Expression)
+
+	case *ast.LetStatement:
+		// The compiler defines the name first, so functions can call
+		// themselves
+		b := ch.define(node.Name, "let")
+		if ch.scope.outer != nil && ch.quiet == 0 && b.pos == node.Name.Pos() {
+			ch.locals = append(ch.locals, b)
+		}
+		ch.node(node.Value)
+		ch.bind(b, node.Value)
+
+	case *ast.ReturnStatement:
+		ch.node(node.ReturnValue)
+
+	case *ast.ExportStatement:
+		ch.use(node.Identifier)
+
+	case *ast.LoadStatement:
+		ch.load(node.Identifier)
+
+	case *ast.ScopeDefinition:
+		ch.enter()
+		ch.node(node.Block)
+		ch.leave()
+		ch.bind(ch.define(node.Name, ""), nil)
+
+	case *ast.ClassDefinition:
+		// A class is a function returning a scope
+		b := ch.define(node.Name, "")
+		ch.bind(b, nil)
+		ch.enter()
+		ch.enter()
+		ch.node(node.Block)
+		ch.leave()
+		ch.leave()
+
+	case *ast.IterStatement:
+		ch.node(node.Expression)
+		ch.bind(ch.define(node.Identifier, ""), nil)
+		ch.node(node.Statements)
+
+	case *ast.Identifier:
+		ch.use(node)
+
+	case *ast.InfixExpression:
+		if node.Operator == token.Assign {
+			ch.assign(node)
+			return
+		}
+		ch.node(node.Left)
+		if node.Operator != token.Dot && node.Operator != token.DoubleColon {
+			ch.node(node.Right)
+		}
+
+	case *ast.PrefixExpression:
+		ch.node(node.Right)
+
+	case *ast.IfExpression:
+		ch.node(node.Condition)
+		ch.node(node.Consequence)
+		if node.Alternative != nil {
+			ch.node(node.Alternative)
+		}
+
+	case *ast.LoopExpression:
+		ch.node(node.Condition)
+		ch.node(node.Consequence)
+
+	case *ast.TryExpression:
+		ch.node(node.Block)
+		if node.Parameter != nil {
+			ch.bind(ch.define(node.Parameter, ""), nil)
+		}
+		if node.Catch != nil {
+			ch.node(node.Catch)
+		}
+		if node.Finally != nil {
+			ch.node(node.Finally)
+		}
+
+	case *ast.ThrowExpression:
+		ch.node(node.Value)
+
+	case *ast.IndexExpression:
+		ch.index(node)
+
+	case *ast.CallExpression:
+		ch.node(node.Function)
+		for _, arg := range node.Arguments {
+			ch.node(arg)
+		}
+		if ident, ok := node.Function.(*ast.Identifier); ok && ch.quiet == 0 {
+			if b, _ := ch.lookup(ident.Value); b != nil {
+				ch.calls = append(ch.calls, checkedCall{callee: b, call: node})
+			}
+		}
+
+	case *ast.FunctionLiteral:
+		ch.enter()
+		for _, p := range node.Parameters {
+			ch.bind(ch.define(p, "parameter"), nil)
+		}
+		ch.node(node.Body)
+		ch.leave()
+
+	case *ast.ArrayLiteral:
+		for _, el := range node.Elements {
+			ch.node(el)
+		}
+
+	case *ast.HashLiteral:
+		for _, k := range node.Keys {
+			// The compiler turns keys it can't compile into strings
+			if ident, ok := k.(*ast.Identifier); ok {
+				if b, _ := ch.lookup(ident.Value); b != nil {
+					b.used = true
+				}
+			} else {
+				ch.node(k)
+			}
+			ch.node(node.Pairs[k])
+		}
+	}
+}
+
+// statements checks a list of statements, flagging the first one that
+// follows a return.
+func (ch *checker) statements(stmts []ast.Statement) {
+	for i, stmt := range stmts {
+		if i > 0 {
+			if _, ok := stmts[i-1].(*ast.ReturnStatement); ok {
+				ch.warn(stmt.Pos(), "unreachable code")
+			}
+		}
+		ch.node(stmt)
+	}
+}
+
+func (ch *checker) assign(node *ast.InfixExpression) {
+	switch left := node.Left.(type) {
+	case *ast.Identifier:
+		b := ch.define(left, "")
+		ch.node(node.Right)
+		ch.bind(b, node.Right)
+
+	case *ast.IndexExpression:
+		ch.node(node.Right)
+		ch.index(left)
+
+	case *ast.InfixExpression:
+		ch.node(node.Right)
+		if left.Operator == token.Dot {
+			ch.node(left.Left)
+		}
+
+	default:
+		ch.node(node.Right)
+	}
+}
+
+func (ch *checker) index(node *ast.IndexExpression) {
+	ch.node(node.Left)
+	for _, e := range []ast.Expression{node.Index, node.IndexUpper, node.IndexSkip} {
+		if e != nil {
+			ch.node(e)
+		}
+	}
+}
+
+// load defines the name of a native module, or the globals of a source one.
+func (ch *checker) load(ident *ast.Identifier) {
+	m := ident.Value
+	if !ch.c.sandbox.allows(m) {
+		ch.error(ident.Pos(), "module %s is not allowed", m)
+		return
+	}
+	if _, ok := coreModules[m]; ok || ch.c.SearchPlugin(m) != nil {
+		ch.bind(ch.define(ident, ""), nil)
+		return
+	}
+
+	sourceFile := ch.c.SearchSource(m)
+	if sourceFile == nil {
+		ch.error(ident.Pos(), "failed to load module %s", m)
+		return
+	}
+
+	text, _ := ioutil.ReadFile(*sourceFile)
+	p := parser.New(lexer.NewWithFilename(string(text), *sourceFile))
+	program := p.ParseProgram()
+
+	ch.quiet++
+	ch.statements(program.Statements)
+	ch.quiet--
+}
+
+func (ch *checker) enter() {
+	ch.scope = &checkScope{outer: ch.scope, names: map[string]*binding{}}
+}
+
+func (ch *checker) leave() {
+	ch.scope = ch.scope.outer
+}
+
+// lookup finds the binding name resolves to and the scope defining it.
+// Names the checker hasn't seen are looked up in the compiler's symbols.
+func (ch *checker) lookup(name string) (*binding, *checkScope) {
+	root := ch.scope
+	for s := ch.scope; s != nil; s = s.outer {
+		if b, ok := s.names[name]; ok {
+			return b, s
+		}
+		root = s
+	}
+
+	for t := ch.c.symbolTable; t != nil; t = t.Outer {
+		if symbol, ok := t.store[name]; ok {
+			pos, _ := t.definitions[name]
+			b := &binding{name: name, pos: pos, builtin: symbol.Scope == BuiltinScope, used: true}
+			root.names[name] = b
+			return b, root
+		}
+	}
+	return nil, nil
+}
+
+// define returns the binding ident names, reusing the one it resolves to if
+// any, as the compiler does. Declarations of the given kind that reuse an
+// outer name are reported.
+func (ch *checker) define(ident *ast.Identifier, kind string) *binding {
+	b, scope := ch.lookup(ident.Value)
+	if b == nil {
+		b = &binding{name: ident.Value, pos: ident.Pos()}
+		ch.scope.names[ident.Value] = b
+		return b
+	}
+
+	if kind != "" {
+		switch {
+		case b.builtin:
+			ch.warn(ident.Pos(), "%s %s shadows the builtin of the same name", kind, ident.Value)
+		case scope != ch.scope && b.pos.IsValid():
+			ch.warn(ident.Pos(), "%s %s shadows the declaration at %s", kind, ident.Value, b.pos)
+		}
+	}
+	return b
+}
+
+// bind records that value was bound to b.
+func (ch *checker) bind(b *binding, value ast.Expression) {
+	b.assignments++
+	b.fn, _ = value.(*ast.FunctionLiteral)
+}
+
+func (ch *checker) use(ident *ast.Identifier) {
+	b, _ := ch.lookup(ident.Value)
+	if b == nil {
+		ch.error(ident.Pos(), "undefined variable %s", ident.Value)
+		return
+	}
+	b.used = true
+}
+
+func (ch *checker) error(pos token.Position, format string, args ...interface{}) {
+	ch.report(pos, SeverityError, format, args...)
+}
+
+func (ch *checker) warn(pos token.Position, format string, args ...interface{}) {
+	ch.report(pos, SeverityWarning, format, args...)
+}
+
+func (ch *checker) report(pos token.Position, severity Severity, format string, args ...interface{}) {
+	if ch.quiet > 0 {
+		return
+	}
+	ch.diagnostics = append(ch.diagnostics, Diagnostic{Pos: pos, Severity: severity, Msg: fmt.Sprintf(format, args...)})
+}
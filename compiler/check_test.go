@@ -0,0 +1,84 @@
+package compiler
+
+import (
+	"strings"
+	"testing"
+)
+
+func TestCheck(t *testing.T) {
+	tests := []struct {
+		input    string
+		expected []string
+	}{
+		{"let a = 1; let f = fn(x) { x + a }; f(a)", nil},
+		{
+			"a + b; let f = fn() { c }; export d",
+			[]string{
+				"1:1: error: undefined variable a",
+				"1:5: error: undefined variable b",
+				"1:23: error: undefined variable c",
+				"1:35: error: undefined variable d",
+			},
+		},
+		{
+			"let add = fn(a, b) { a + b }; add(1); add(1, 2); add(1, 2, 3)",
+			[]string{
+				"1:34: error: wrong number of arguments to add: want=2, got=1",
+				"1:53: error: wrong number of arguments to add: want=2, got=3",
+			},
+		},
+		// Functions bound more than once may change arity
+		{"let f = fn(a) { a }; f = fn(a, b) { a + b }; f(1, 2)", nil},
+		{"let fact = fn(n) { if (n < 2) { 1 } else { n * fact(n - 1, 1) } }; fact(3)",
+			[]string{"1:52: error: wrong number of arguments to fact: want=1, got=2"}},
+		{
+			"let f = fn() { let a = 1; let b = 2; b = 3; 4 }; f()",
+			[]string{
+				"1:20: warning: a declared and not used",
+				"1:31: warning: b declared and not used",
+			},
+		},
+		// Globals may be used by whoever loads the file
+		{"let a = 1;", nil},
+		{"scope s { let hidden = 1; let shown = 2; export shown; }",
+			[]string{"1:15: warning: hidden declared and not used"}},
+		{
+			"let x = 1; let f = fn(x) { let len = x; len }; f(x)",
+			[]string{
+				"1:23: warning: parameter x shadows the declaration at 1:5",
+				"1:32: warning: let len shadows the builtin of the same name",
+			},
+		},
+		{
+			"let f = fn() { return 1; 2; 3 }; f()",
+			[]string{"1:26: warning: unreachable code"},
+		},
+		{"let h = { key: 1 }; h", nil},
+		{"iter i over [1, 2] { print(i) }; try { 1 } catch (e) { e }", nil},
+		{"class A { a = 0; get = fn() { a + 1 }; export get; }; A().get()", nil},
+		{"load nosuchmodule", []string{"1:6: error: failed to load module nosuchmodule"}},
+	}
+
+	for _, tt := range tests {
+		program := parse(tt.input)
+		diagnostics := New().Check(program)
+
+		got := []string{}
+		for _, d := range diagnostics {
+			got = append(got, d.String())
+		}
+		if strings.Join(got, "\n") != strings.Join(tt.expected, "\n") {
+			t.Errorf("wrong diagnostics for %q.\nwant=%q\ngot =%q", tt.input, tt.expected, got)
+		}
+	}
+}
+
+func TestCheckWithState(t *testing.T) {
+	symbolTable := NewSymbolTable()
+	symbolTable.Define("answer")
+
+	diagnostics := NewWithState(symbolTable, nil).Check(parse("answer + question"))
+	if len(diagnostics) != 1 || diagnostics[0].Msg != "undefined variable question" {
+		t.Errorf("wrong diagnostics. got=%v", diagnostics)
+	}
+}
@@ -3,7 +3,7 @@ package compiler
 import (
 	"fmt"
 	"io/ioutil"
-	"log"
+	"math"
 	"os"
 	"path"
 	"plugin"
@@ -26,11 +26,38 @@ type Compiler struct {
 	symbolTable         *SymbolTable
 	scopes              []CompilationScope
 	scopeIndex          int
+
+	// Source position of the node being compiled, recorded in the line table
+	// of every emitted instruction
+	pos token.Position
+
+	recordResolutions bool
+	resolutions       []Resolution
+
+	optimize bool
+	sandbox  *Sandbox
+}
+
+// Resolution links an identifier in the source to the symbol it names.
+type Resolution struct {
+	Pos        token.Position
+	Name       string
+	Symbol     Symbol
+	Definition token.Position
 }
 
 type Bytecode struct {
 	Instructions code.Instructions
 	Constants    []object.Object
+	Lines        code.LineTable
+	Handlers     []code.Handler
+
+	// Debug info: global symbol names indexed by slot
+	GlobalNames []string
+
+	// Registers is the number of registers the top level uses in bytecode
+	// compiled for the register VM, and zero otherwise
+	Registers int
 }
 
 type EmittedInstruction struct {
@@ -42,6 +69,13 @@ type CompilationScope struct {
 	instructions        code.Instructions
 	lastInstruction     EmittedInstruction
 	previousInstruction EmittedInstruction
+	lines               code.LineTable
+	handlers            []code.Handler
+	numTrySlots         int
+
+	// Finally blocks of the enclosing try expressions, innermost last. They
+	// are inlined before every return leaving the try.
+	finally []*ast.BlockStatement
 }
 
 func New() *Compiler {
@@ -73,6 +107,14 @@ func NewWithState(s *SymbolTable, constants []object.Object) *Compiler {
 }
 
 func (c *Compiler) Compile(node ast.Node) error {
+	if node != nil {
+		if pos := node.Pos(); pos.IsValid() {
+			prev := c.pos
+			c.pos = pos
+			defer func() { c.pos = prev }()
+		}
+	}
+
 	switch node := node.(type) {
 	case *ast.Program:
 		for _, s := range node.Statements {
@@ -90,36 +132,18 @@ func (c *Compiler) Compile(node ast.Node) error {
 		c.emit(code.OpPop)
 
 	case *ast.InfixExpression:
-		if node.Operator == "<" {
-			err := c.Compile(node.Right)
-			if err != nil {
-				return err
+		if c.optimize {
+			if obj := constant(node); obj != nil {
+				c.emitConstant(obj)
+				return nil
 			}
+		}
 
-			err = c.Compile(node.Left)
-			if err != nil {
-				return err
-			}
-			c.emit(code.OpGreaterThan)
-			return nil
-		} else if node.Operator == "<=" {
-			err := c.Compile(node.Right)
-			if err != nil {
-				return err
-			}
-
-			err = c.Compile(node.Left)
-			if err != nil {
-				return err
-			}
-			c.emit(code.OpGreaterOrEqual)
-			return nil
-
-		} else if node.Operator == "=" {
+		if node.Operator == "=" {
 			var symbol Symbol
 			switch leftNode := node.Left.(type) {
 			case *ast.Identifier:
-				symbol = c.symbolTable.Define(leftNode.String())
+				symbol = c.define(leftNode)
 			}
 			err := c.Compile(node.Right)
 			if err != nil {
@@ -173,6 +197,10 @@ func (c *Compiler) Compile(node ast.Node) error {
 			return nil
 		}
 
+		if c.compileAddLocalConst(node) {
+			return nil
+		}
+
 		err := c.Compile(node.Left)
 		if err != nil {
 			return err
@@ -198,6 +226,10 @@ func (c *Compiler) Compile(node ast.Node) error {
 			c.emit(code.OpGreaterThan)
 		case ">=":
 			c.emit(code.OpGreaterOrEqual)
+		case "<":
+			c.emit(code.OpLessThan)
+		case "<=":
+			c.emit(code.OpLessOrEqual)
 		case "==":
 			c.emit(code.OpEqual)
 		case "!=":
@@ -207,9 +239,16 @@ func (c *Compiler) Compile(node ast.Node) error {
 		case "::":
 			c.emit(code.OpScopeResolve)
 		default:
-			return fmt.Errorf("unknown operator %s", node.Operator)
+			return fmt.Errorf("%s: unknown operator %s", node.Pos(), node.Operator)
 		}
 	case *ast.PrefixExpression:
+		if c.optimize {
+			if obj := constant(node); obj != nil {
+				c.emitConstant(obj)
+				return nil
+			}
+		}
+
 		err := c.Compile(node.Right)
 		if err != nil {
 			return err
@@ -221,18 +260,21 @@ func (c *Compiler) Compile(node ast.Node) error {
 		case "-":
 			c.emit(code.OpMinus)
 		default:
-			return fmt.Errorf("unknown operator %s", node.Operator)
+			return fmt.Errorf("%s: unknown operator %s", node.Pos(), node.Operator)
 		}
 
 	case *ast.IfExpression:
-		err := c.Compile(node.Condition)
+		if c.optimize {
+			if cond := constant(node.Condition); cond != nil {
+				return c.compileConstantIf(node, cond)
+			}
+		}
+
+		jumpNotTruthyPos, err := c.compileCondition(node.Condition)
 		if err != nil {
 			return err
 		}
 
-		// Emit an `OpJumpNotTruthy` with a bogus value
-		jumpNotTruthyPos := c.emit(code.OpJumpNotTruthy, 9999)
-
 		err = c.Compile(node.Consequence)
 		if err != nil {
 			return err
@@ -265,15 +307,33 @@ func (c *Compiler) Compile(node ast.Node) error {
 		c.changeOperand(jumpPos, afterAlternative)
 
 	case *ast.LoopExpression:
+		if c.optimize {
+			if cond := constant(node.Condition); cond != nil {
+				if !truthy(cond) {
+					if err := c.discard(node.Consequence); err != nil {
+						return err
+					}
+					c.emit(code.OpNull)
+					return nil
+				}
+
+				blockStart := len(c.currentInstructions())
+				err := c.Compile(node.Consequence)
+				if err != nil {
+					return err
+				}
+				c.emit(code.OpJump, blockStart)
+				c.emit(code.OpNull)
+				return nil
+			}
+		}
+
 		blockStart := len(c.currentInstructions())
-		err := c.Compile(node.Condition)
+		jumpNotTruthyPos, err := c.compileCondition(node.Condition)
 		if err != nil {
 			return err
 		}
 
-		// Emit an `OpJumpNotTruthy` with a bogus value
-		jumpNotTruthyPos := c.emit(code.OpJumpNotTruthy, 9999)
-
 		err = c.Compile(node.Consequence)
 		if err != nil {
 			return err
@@ -284,12 +344,24 @@ func (c *Compiler) Compile(node ast.Node) error {
 		afterConsequencePos := len(c.currentInstructions())
 		c.changeOperand(jumpNotTruthyPos, afterConsequencePos)
 
+		// A finished loop evaluates to null
+		c.emit(code.OpNull)
+
 	case *ast.IndexExpression:
 		err := c.Compile(node.Left)
 		if err != nil {
 			return err
 		}
 
+		if node.Index != nil && !node.HasUpper && !node.HasSkip {
+			err = c.Compile(node.Index)
+			if err != nil {
+				return err
+			}
+			c.emit(code.OpIndexSimple)
+			return nil
+		}
+
 		if node.Index != nil {
 			err = c.Compile(node.Index)
 			if err != nil {
@@ -344,8 +416,24 @@ func (c *Compiler) Compile(node ast.Node) error {
 			}
 		}
 
+	case *ast.IterStatement:
+		for _, s := range node.ConvertToLoop() {
+			err := c.Compile(s)
+			if err != nil {
+				return err
+			}
+		}
+
+	case *ast.ClassDefinition:
+		for _, s := range node.ConvertToFunc() {
+			err := c.Compile(s)
+			if err != nil {
+				return err
+			}
+		}
+
 	case *ast.LetStatement:
-		symbol := c.symbolTable.Define(node.Name.Value)
+		symbol := c.define(node.Name)
 		err := c.Compile(node.Value)
 		if err != nil {
 			return err
@@ -359,12 +447,31 @@ func (c *Compiler) Compile(node ast.Node) error {
 			return err
 		}
 
+		err = c.compilePendingFinally()
+		if err != nil {
+			return err
+		}
+
 		c.emit(code.OpReturnValue)
 
+	case *ast.TryExpression:
+		err := c.compileTryExpression(node)
+		if err != nil {
+			return err
+		}
+
+	case *ast.ThrowExpression:
+		err := c.Compile(node.Value)
+		if err != nil {
+			return err
+		}
+
+		c.emit(code.OpThrow)
+
 	case *ast.Identifier:
-		symbol, ok := c.symbolTable.Resolve(node.Value)
+		symbol, ok := c.resolve(node)
 		if !ok {
-			return fmt.Errorf("undefined variable %s", node.Value)
+			return fmt.Errorf("%s: undefined variable %s", node.Pos(), node.Value)
 		}
 
 		c.loadSymbol(symbol)
@@ -432,7 +539,7 @@ func (c *Compiler) Compile(node ast.Node) error {
 		c.enterScope()
 
 		for _, p := range node.Parameters {
-			c.symbolTable.Define(p.Value)
+			c.define(p)
 		}
 
 		err := c.Compile(node.Body)
@@ -449,16 +556,34 @@ func (c *Compiler) Compile(node ast.Node) error {
 
 		freeSymbols := c.symbolTable.FreeSymbols
 		numLocals := c.symbolTable.numDefinitions
+		localNames := c.symbolTable.DefinedNames()
+		freeNames := c.symbolTable.FreeNames()
+		lines := c.scopes[c.scopeIndex].lines
+		handlers := c.scopes[c.scopeIndex].handlers
 		instructions := c.leaveScope()
+		if c.optimize {
+			instructions, lines, handlers = optimizeInstructions(instructions, lines, handlers)
+		}
+		markTailCalls(instructions, handlers)
 
 		for _, s := range freeSymbols {
 			c.loadSymbol(s)
 		}
 
+		name := node.Name
+		if name == "" {
+			name = "<anonymous>"
+		}
+
 		compiledFn := &object.CompiledFunction{
 			Instructions:  instructions,
 			NumLocals:     numLocals,
 			NumParameters: len(node.Parameters),
+			Name:          name,
+			Lines:         lines,
+			Handlers:      handlers,
+			LocalNames:    localNames,
+			FreeNames:     freeNames,
 		}
 
 		fnIndex := c.addConstant(compiledFn)
@@ -476,47 +601,194 @@ func (c *Compiler) Compile(node ast.Node) error {
 
 		freeSymbols := c.symbolTable.FreeSymbols
 		numLocals := c.symbolTable.numDefinitions
+		localNames := c.symbolTable.DefinedNames()
+		freeNames := c.symbolTable.FreeNames()
+		lines := c.scopes[c.scopeIndex].lines
+		handlers := c.scopes[c.scopeIndex].handlers
 		instructions := c.leaveScope()
+		if c.optimize {
+			instructions, lines, handlers = optimizeInstructions(instructions, lines, handlers)
+		}
 
 		compiledFn := &object.CompiledFunction{
 			Instructions:  instructions,
 			NumLocals:     numLocals,
 			NumParameters: 0,
+			Name:          node.Name.Value,
+			Lines:         lines,
+			Handlers:      handlers,
+			LocalNames:    localNames,
+			FreeNames:     freeNames,
 		}
 		fnIndex := c.addConstant(compiledFn)
 		c.emit(code.OpClosure, fnIndex, len(freeSymbols))
 		c.emit(code.OpCall, 0)
 
-		symbol := c.symbolTable.Define(node.Name.Value)
+		symbol := c.define(node.Name)
 		c.saveSymbol(symbol)
 
 	case *ast.ExportStatement:
 		nameConst := c.addConstant(&object.String{Value: node.Identifier.Value})
 		c.emit(code.OpConstant, nameConst)
-		symbol, ok := c.symbolTable.Resolve(node.Identifier.Value)
+		symbol, ok := c.resolve(node.Identifier)
 		if !ok {
-			return fmt.Errorf("undefined variable %s", node.Identifier.Value)
+			return fmt.Errorf("%s: undefined variable %s", node.Identifier.Pos(), node.Identifier.Value)
 		}
 		c.loadSymbol(symbol)
 		c.emit(code.OpExport)
 
 	case *ast.LoadStatement:
-		c.loadModule(node.Identifier.Value)
+		err := c.loadModule(node.Identifier)
+		if err != nil {
+			return err
+		}
 
 	default:
-		fmt.Println("Unknown ast type!", node)
+		return fmt.Errorf("%s: cannot compile %T", c.pos, node)
 	}
 
 	return nil
 }
 
 func (c *Compiler) Bytecode() *Bytecode {
+	instructions := c.currentInstructions()
+	lines := c.scopes[c.scopeIndex].lines
+	handlers := c.scopes[c.scopeIndex].handlers
+	if c.optimize {
+		instructions, lines, handlers = optimizeInstructions(instructions, lines, handlers)
+	}
+
 	return &Bytecode{
-		Instructions: c.currentInstructions(),
+		Instructions: instructions,
 		Constants:    c.constants,
+		Lines:        lines,
+		Handlers:     handlers,
+		GlobalNames:  c.symbolTable.DefinedNames(),
 	}
 }
 
+func (c *Compiler) compileTryExpression(node *ast.TryExpression) error {
+	scope := &c.scopes[c.scopeIndex]
+	slot := scope.numTrySlots
+	scope.numTrySlots++
+
+	c.emit(code.OpTry, slot)
+
+	if node.Finally != nil {
+		c.scopes[c.scopeIndex].finally = append(c.scopes[c.scopeIndex].finally, node.Finally)
+	}
+
+	tryStart := len(c.currentInstructions())
+	err := c.compileBlockValue(node.Block)
+	if err != nil {
+		return err
+	}
+	tryEnd := len(c.currentInstructions())
+
+	// Emit an `OpJump` with a bogus value
+	jumpPos := c.emit(code.OpJump, 9999)
+
+	handlers := []code.Handler{}
+	protectedStart, protectedEnd := tryStart, tryEnd
+
+	if node.Catch != nil {
+		catchStart := len(c.currentInstructions())
+		handlers = append(handlers, code.Handler{Start: tryStart, End: tryEnd, Target: catchStart, Slot: slot})
+
+		// The raised error is on top of the stack
+		if node.Parameter != nil {
+			symbol := c.define(node.Parameter)
+			c.saveSymbol(symbol)
+		}
+		c.emit(code.OpPop)
+
+		err = c.compileBlockValue(node.Catch)
+		if err != nil {
+			return err
+		}
+		protectedStart, protectedEnd = catchStart, len(c.currentInstructions())
+	}
+
+	afterCatchPos := len(c.currentInstructions())
+	c.changeOperand(jumpPos, afterCatchPos)
+
+	if node.Finally != nil {
+		finally := c.scopes[c.scopeIndex].finally
+		c.scopes[c.scopeIndex].finally = finally[:len(finally)-1]
+
+		err = c.Compile(node.Finally)
+		if err != nil {
+			return err
+		}
+
+		// Emit an `OpJump` with a bogus value
+		endJumpPos := c.emit(code.OpJump, 9999)
+
+		// Run the finally block for an error escaping the try (or catch)
+		// block and raise it again
+		finallyStart := len(c.currentInstructions())
+		handlers = append(handlers, code.Handler{Start: protectedStart, End: protectedEnd, Target: finallyStart, Slot: slot})
+
+		err = c.Compile(node.Finally)
+		if err != nil {
+			return err
+		}
+		c.emit(code.OpThrow)
+
+		c.changeOperand(endJumpPos, len(c.currentInstructions()))
+	}
+
+	c.scopes[c.scopeIndex].handlers = append(c.scopes[c.scopeIndex].handlers, handlers...)
+	return nil
+}
+
+// compileBlockValue compiles a block so it leaves its value on the stack, the
+// same way the branches of an if expression do.
+func (c *Compiler) compileBlockValue(block *ast.BlockStatement) error {
+	if len(block.Statements) == 0 {
+		c.emit(code.OpNull)
+		return nil
+	}
+
+	err := c.Compile(block)
+	if err != nil {
+		return err
+	}
+
+	if c.lastInstructionIs(code.OpPop) {
+		c.removeLastPop()
+	}
+	return nil
+}
+
+// compilePendingFinally inlines the finally blocks of every try expression
+// the current return statement leaves. The return value is parked in a hidden
+// symbol, so the finally blocks can't clobber it.
+func (c *Compiler) compilePendingFinally() error {
+	finally := c.scopes[c.scopeIndex].finally
+	if len(finally) == 0 {
+		return nil
+	}
+
+	symbol := c.symbolTable.Define(fmt.Sprintf("$return%d", c.scopeIndex))
+	c.saveSymbol(symbol)
+	c.emit(code.OpPop)
+
+	for i := len(finally) - 1; i >= 0; i-- {
+		c.scopes[c.scopeIndex].finally = finally[:i]
+
+		err := c.Compile(finally[i])
+		if err != nil {
+			c.scopes[c.scopeIndex].finally = finally
+			return err
+		}
+	}
+	c.scopes[c.scopeIndex].finally = finally
+
+	c.loadSymbol(symbol)
+	return nil
+}
+
 func (c *Compiler) searchFile(fname string) *string {
 	// Check in current working directory
 	d, _ := os.Getwd()
@@ -538,7 +810,12 @@ func (c *Compiler) searchFile(fname string) *string {
 	return nil
 }
 
+// SearchPlugin returns the path of the native plugin for module m, or nil
+// if there is none or the compiler is sandboxed.
 func (c *Compiler) SearchPlugin(m string) *string {
+	if c.sandbox != nil {
+		return nil
+	}
 	return c.searchFile(m + ".so")
 }
 
@@ -548,7 +825,7 @@ func (c *Compiler) SearchSource(m string) *string {
 
 func (c *Compiler) addConstant(obj object.Object) int {
 	for idx, v := range c.constants {
-		if obj.Equals(v) {
+		if sameConstant(obj, v) {
 			return idx
 		}
 	}
@@ -556,6 +833,19 @@ func (c *Compiler) addConstant(obj object.Object) int {
 	return len(c.constants) - 1
 }
 
+// sameConstant reports whether a and b can share a slot in the constant pool.
+// Equals holds for an Integer and a Float of the same value, and for 0.0 and
+// -0.0, but they print differently.
+func sameConstant(a, b object.Object) bool {
+	if a.Type() != b.Type() {
+		return false
+	}
+	if a, ok := a.(*object.Float); ok {
+		return math.Float64bits(a.Value) == math.Float64bits(b.(*object.Float).Value)
+	}
+	return a.Equals(b)
+}
+
 func (c *Compiler) emit(op code.Opcode, operands ...int) int {
 	ins := code.Make(op, operands...)
 	pos := c.addInstruction(ins)
@@ -565,15 +855,111 @@ func (c *Compiler) emit(op code.Opcode, operands ...int) int {
 	return pos
 }
 
+// fusedJumps maps comparison operators to the jump that replaces them
+// together with the OpJumpNotTruthy following them.
+var fusedJumps = map[string]code.Opcode{
+	token.LessThan:       code.OpJumpIfGreaterOrEqual,
+	token.LessOrEqual:    code.OpJumpIfGreaterThan,
+	token.GreaterThan:    code.OpJumpIfLessOrEqual,
+	token.GreaterOrEqual: code.OpJumpIfLessThan,
+}
+
+// compileCondition compiles cond followed by a jump taken if it isn't
+// truthy, and returns the position of the jump. Comparisons are fused with
+// the jump.
+func (c *Compiler) compileCondition(cond ast.Expression) (int, error) {
+	var fused code.Opcode
+	infix, ok := cond.(*ast.InfixExpression)
+	if ok {
+		fused, ok = fusedJumps[infix.Operator]
+	}
+	if !ok {
+		err := c.Compile(cond)
+		if err != nil {
+			return 0, err
+		}
+
+		// Emit an `OpJumpNotTruthy` with a bogus value
+		return c.emit(code.OpJumpNotTruthy, 9999), nil
+	}
+
+	err := c.Compile(infix.Left)
+	if err != nil {
+		return 0, err
+	}
+	err = c.Compile(infix.Right)
+	if err != nil {
+		return 0, err
+	}
+
+	// Errors raised by the comparison point at its operator
+	prev := c.pos
+	c.pos = infix.Pos()
+	pos := c.emit(fused, 9999)
+	c.pos = prev
+	return pos, nil
+}
+
+// compileAddLocalConst compiles a local plus a literal to a single
+// OpAddLocalConst. It reports false if node isn't of that form.
+func (c *Compiler) compileAddLocalConst(node *ast.InfixExpression) bool {
+	if node.Operator != token.Plus {
+		return false
+	}
+	ident, ok := node.Left.(*ast.Identifier)
+	if !ok {
+		return false
+	}
+
+	var value object.Object
+	switch right := node.Right.(type) {
+	case *ast.IntegerLiteral:
+		value = &object.Integer{Value: right.Value}
+	case *ast.FloatLiteral:
+		value = &object.Float{Value: right.Value}
+	case *ast.StringLiteral:
+		value = &object.String{Value: right.Value}
+	default:
+		return false
+	}
+
+	symbol, ok := c.symbolTable.Resolve(ident.Value)
+	if !ok || symbol.Scope != LocalScope {
+		return false
+	}
+
+	c.resolve(ident)
+	c.emit(code.OpAddLocalConst, symbol.Index, c.addConstant(value))
+	return true
+}
+
 func (c *Compiler) addInstruction(ins []byte) int {
 	posNewInstruction := len(c.currentInstructions())
 	updatedInstructions := append(c.currentInstructions(), ins...)
 
 	c.scopes[c.scopeIndex].instructions = updatedInstructions
+	c.addLine(posNewInstruction)
 
 	return posNewInstruction
 }
 
+func (c *Compiler) addLine(offset int) {
+	lines := c.scopes[c.scopeIndex].lines
+	if len(lines) > 0 && lines[len(lines)-1].Pos == c.pos {
+		return
+	}
+
+	c.scopes[c.scopeIndex].lines = append(lines, code.LineEntry{Offset: offset, Pos: c.pos})
+}
+
+func (c *Compiler) truncateLines(offset int) {
+	lines := c.scopes[c.scopeIndex].lines
+	for len(lines) > 0 && lines[len(lines)-1].Offset >= offset {
+		lines = lines[:len(lines)-1]
+	}
+	c.scopes[c.scopeIndex].lines = lines
+}
+
 func (c *Compiler) setLastInstruction(op code.Opcode, pos int) {
 	previous := c.scopes[c.scopeIndex].lastInstruction
 	last := EmittedInstruction{Opcode: op, Position: pos}
@@ -603,6 +989,7 @@ func (c *Compiler) removeLastPop() {
 
 	c.scopes[c.scopeIndex].instructions = new
 	c.scopes[c.scopeIndex].lastInstruction = previous
+	c.truncateLines(last.Position)
 }
 
 func (c *Compiler) replaceInstruction(pos int, newInstruction []byte) {
@@ -652,7 +1039,45 @@ func (c *Compiler) replaceLastPopWithReturn() {
 	c.scopes[c.scopeIndex].lastInstruction.Opcode = code.OpReturnValue
 }
 
-func (c *Compiler) loadModule(m string) {
+// markTailCalls turns every OpCall whose result is returned right away into
+// an OpTailCall, unless a handler protects the call and so needs the frame to
+// stay around.
+func markTailCalls(ins code.Instructions, handlers []code.Handler) {
+	callWidth := len(code.Make(code.OpCall, 0))
+
+	for i := 0; i < len(ins); {
+		def, err := code.Lookup(ins[i])
+		if err != nil {
+			return
+		}
+		_, read := code.ReadOperands(def, ins[i+1:])
+
+		if code.Opcode(ins[i]) == code.OpCall && !protected(i, handlers) {
+			next := i + callWidth
+			// An if expression jumps from the end of its consequence
+			if next < len(ins) && code.Opcode(ins[next]) == code.OpJump {
+				next = int(code.ReadUint16(ins[next+1:]))
+			}
+			if next < len(ins) && code.Opcode(ins[next]) == code.OpReturnValue {
+				ins[i] = byte(code.OpTailCall)
+			}
+		}
+		i += 1 + read
+	}
+}
+
+func protected(pos int, handlers []code.Handler) bool {
+	for _, h := range handlers {
+		if h.Start <= pos && pos < h.End {
+			return true
+		}
+	}
+	return false
+}
+
+// loadNativeModule resolves m against the core modules and then the
+// plugin search paths. It returns nil if no native module is found.
+func (c *Compiler) loadNativeModule(m string) *object.Scope {
 	var scope *object.Scope
 	if loader, ok := coreModules[m]; ok {
 		scope = loader()
@@ -663,39 +1088,92 @@ func (c *Compiler) loadModule(m string) {
 		plg, err := plugin.Open(*pluginFile)
 		if err != nil {
 			fmt.Println("Plugin error: ", err)
-			return
+			return nil
 		}
 		sym, err := plg.Lookup("Load")
 		if err != nil {
 			fmt.Println("Lookup error: ", err)
-			return
+			return nil
 		}
 		scope = sym.(func() *object.Scope)()
 	}
 
+	if scope != nil {
+		// The module name is what serialized bytecode uses to load it again
+		scope.Name = m
+	}
+	return scope
+}
+
+func (c *Compiler) loadModule(ident *ast.Identifier) error {
+	m := ident.Value
+	if !c.sandbox.allows(m) {
+		return fmt.Errorf("%s: module %s is not allowed", ident.Pos(), m)
+	}
+	scope := c.loadNativeModule(m)
+
 	if sourceFile := c.SearchSource(m); scope == nil && sourceFile != nil {
 		text, _ := ioutil.ReadFile(*sourceFile)
-		l := lexer.New(string(text))
+		l := lexer.NewWithFilename(string(text), *sourceFile)
 		p := parser.New(l)
 		program := p.ParseProgram()
 
-		err := c.Compile(program)
-		if err != nil {
-			log.Fatalln("Compile error:", err)
+		if len(p.Errors()) != 0 {
+			return fmt.Errorf("%s: failed to parse module %s: %s", ident.Pos(), m, p.Errors()[0])
 		}
-		return
+		return c.Compile(program)
 	}
 
 	if scope == nil {
-		log.Fatalf("Failed to load scope: [%s], Exiting...\n", m)
+		return fmt.Errorf("%s: failed to load module %s", ident.Pos(), m)
 	}
 
 	si := c.addConstant(scope)
 
 	c.emit(code.OpConstant, si)
-	ssym := c.symbolTable.Define(m)
+	ssym := c.define(ident)
 	c.saveSymbol(ssym)
 	c.emit(code.OpPop)
+	return nil
+}
+
+// RecordResolutions makes the compiler keep the symbol of every identifier
+// it compiles, for tools such as the language server.
+func (c *Compiler) RecordResolutions() {
+	c.recordResolutions = true
+}
+
+// Resolutions returns the identifiers resolved so far, in compile order.
+func (c *Compiler) Resolutions() []Resolution {
+	return c.resolutions
+}
+
+func (c *Compiler) define(ident *ast.Identifier) Symbol {
+	symbol := c.symbolTable.DefineAt(ident.Value, ident.Pos())
+	c.recordResolution(ident, symbol)
+	return symbol
+}
+
+func (c *Compiler) resolve(ident *ast.Identifier) (Symbol, bool) {
+	symbol, ok := c.symbolTable.Resolve(ident.Value)
+	if ok {
+		c.recordResolution(ident, symbol)
+	}
+	return symbol, ok
+}
+
+func (c *Compiler) recordResolution(ident *ast.Identifier, symbol Symbol) {
+	if !c.recordResolutions {
+		return
+	}
+
+	def, _ := c.symbolTable.Definition(ident.Value)
+	c.resolutions = append(c.resolutions, Resolution{
+		Pos:        ident.Pos(),
+		Name:       ident.Value,
+		Symbol:     symbol,
+		Definition: def,
+	})
 }
 
 func (c *Compiler) loadSymbol(s Symbol) {
@@ -82,7 +82,7 @@ func TestIntegerArithmetic(t *testing.T) {
 			expectedInstructions: []code.Instructions{
 				code.Make(code.OpConstant, 0),
 				code.Make(code.OpConstant, 1),
-				code.Make(code.OpGreaterThan),
+				code.Make(code.OpLessThan),
 				code.Make(code.OpPop),
 			},
 		}, {
@@ -225,6 +225,80 @@ func TestConditionals(t *testing.T) {
 	runCompilerTests(t, tests)
 }
 
+func TestSpecializedInstructions(t *testing.T) {
+	tests := []compilerTestCase{
+		{
+			input:             `if (1 <= 2) { 10 }`,
+			expectedConstants: []interface{}{1, 2, 10},
+			expectedInstructions: []code.Instructions{
+				// 0000
+				code.Make(code.OpConstant, 0),
+				// 0003
+				code.Make(code.OpConstant, 1),
+				// 0006
+				code.Make(code.OpJumpIfGreaterThan, 15),
+				// 0009
+				code.Make(code.OpConstant, 2),
+				// 0012
+				code.Make(code.OpJump, 16),
+				// 0015
+				code.Make(code.OpNull),
+				// 0016
+				code.Make(code.OpPop),
+			},
+		},
+		{
+			input:             `let i = 0; loop (i >= 1) { i }`,
+			expectedConstants: []interface{}{0, 1},
+			expectedInstructions: []code.Instructions{
+				// 0000
+				code.Make(code.OpConstant, 0),
+				// 0003
+				code.Make(code.OpSetGlobal, 0),
+				// 0006
+				code.Make(code.OpGetGlobal, 0),
+				// 0009
+				code.Make(code.OpConstant, 1),
+				// 0012
+				code.Make(code.OpJumpIfLessThan, 22),
+				// 0015
+				code.Make(code.OpGetGlobal, 0),
+				// 0018
+				code.Make(code.OpPop),
+				// 0019
+				code.Make(code.OpJump, 6),
+				// 0022
+				code.Make(code.OpNull),
+				// 0023
+				code.Make(code.OpPop),
+			},
+		},
+		{
+			input: `fn(a) { a + 1; 1 + a; a + "s" }`,
+			expectedConstants: []interface{}{
+				1,
+				"s",
+				[]code.Instructions{
+					code.Make(code.OpAddLocalConst, 0, 0),
+					code.Make(code.OpPop),
+					code.Make(code.OpConstant, 0),
+					code.Make(code.OpGetLocal, 0),
+					code.Make(code.OpAdd),
+					code.Make(code.OpPop),
+					code.Make(code.OpAddLocalConst, 0, 1),
+					code.Make(code.OpReturnValue),
+				},
+			},
+			expectedInstructions: []code.Instructions{
+				code.Make(code.OpClosure, 2, 0),
+				code.Make(code.OpPop),
+			},
+		},
+	}
+
+	runCompilerTests(t, tests)
+}
+
 func TestGlobalLetStatements(t *testing.T) {
 	tests := []compilerTestCase{
 		{
@@ -391,7 +465,7 @@ func TestIndexExpressions(t *testing.T) {
 	tests := []compilerTestCase{
 		{
 			input:             "[1, 2, 3][1 + 1]",
-			expectedConstants: []interface{}{1, 2, 3, object.NullObject, object.False},
+			expectedConstants: []interface{}{1, 2, 3},
 			expectedInstructions: []code.Instructions{
 				code.Make(code.OpConstant, 0),
 				code.Make(code.OpConstant, 1),
@@ -400,17 +474,13 @@ func TestIndexExpressions(t *testing.T) {
 				code.Make(code.OpConstant, 0),
 				code.Make(code.OpConstant, 0),
 				code.Make(code.OpAdd),
-				code.Make(code.OpConstant, 3),
-				code.Make(code.OpConstant, 4),
-				code.Make(code.OpConstant, 0),
-				code.Make(code.OpConstant, 4),
-				code.Make(code.OpIndex),
+				code.Make(code.OpIndexSimple),
 				code.Make(code.OpPop),
 			},
 		},
 		{
 			input:             "{1: 2}[2 - 1]",
-			expectedConstants: []interface{}{1, 2, object.NullObject, object.False},
+			expectedConstants: []interface{}{1, 2},
 			expectedInstructions: []code.Instructions{
 				code.Make(code.OpConstant, 0),
 				code.Make(code.OpConstant, 1),
@@ -418,10 +488,23 @@ func TestIndexExpressions(t *testing.T) {
 				code.Make(code.OpConstant, 1),
 				code.Make(code.OpConstant, 0),
 				code.Make(code.OpSub),
+				code.Make(code.OpIndexSimple),
+				code.Make(code.OpPop),
+			},
+		},
+		{
+			input:             "[1, 2, 3][1,]",
+			expectedConstants: []interface{}{1, 2, 3, object.NullObject, object.True, object.False},
+			expectedInstructions: []code.Instructions{
+				code.Make(code.OpConstant, 0),
+				code.Make(code.OpConstant, 1),
 				code.Make(code.OpConstant, 2),
-				code.Make(code.OpConstant, 3),
+				code.Make(code.OpArray, 3),
 				code.Make(code.OpConstant, 0),
 				code.Make(code.OpConstant, 3),
+				code.Make(code.OpConstant, 4),
+				code.Make(code.OpConstant, 0),
+				code.Make(code.OpConstant, 5),
 				code.Make(code.OpIndex),
 				code.Make(code.OpPop),
 			},
@@ -822,7 +905,7 @@ func TestBuiltins(t *testing.T) {
 				[]code.Instructions{
 					code.Make(code.OpGetBuiltin, 0),
 					code.Make(code.OpArray, 0),
-					code.Make(code.OpCall, 1),
+					code.Make(code.OpTailCall, 1),
 					code.Make(code.OpReturnValue),
 				},
 			},
@@ -963,6 +1046,248 @@ func TestClosure(t *testing.T) {
 	runCompilerTests(t, tests)
 }
 
+func TestCompileErrorPositions(t *testing.T) {
+	tests := []struct {
+		input    string
+		expected string
+	}{
+		{"let a = 1;\nlet b = a + c;", "main.dreb:2:13: undefined variable c"},
+		{"let f = fn() {\n\treturn x\n};", "main.dreb:2:9: undefined variable x"},
+		{"scope s {\n export y;\n}", "main.dreb:2:9: undefined variable y"},
+	}
+
+	for _, tt := range tests {
+		l := lexer.NewWithFilename(tt.input, "main.dreb")
+		p := parser.New(l)
+		program := p.ParseProgram()
+
+		compiler := New()
+		err := compiler.Compile(program)
+		if err == nil {
+			t.Fatalf("expected compiler error for %q", tt.input)
+		}
+
+		if err.Error() != tt.expected {
+			t.Errorf("wrong compiler error. want=%q, got=%q", tt.expected, err.Error())
+		}
+	}
+}
+
+func TestTryExpressions(t *testing.T) {
+	tests := []compilerTestCase{
+		{
+			input:             `try { 1 } catch (e) { e }`,
+			expectedConstants: []interface{}{1},
+			expectedInstructions: []code.Instructions{
+				// 0000
+				code.Make(code.OpTry, 0),
+				// 0003
+				code.Make(code.OpConstant, 0),
+				// 0006
+				code.Make(code.OpJump, 16),
+				// 0009
+				code.Make(code.OpSetGlobal, 0),
+				// 0012
+				code.Make(code.OpPop),
+				// 0013
+				code.Make(code.OpGetGlobal, 0),
+				// 0016
+				code.Make(code.OpPop),
+			},
+		},
+		{
+			input:             `try { throw 1 } finally { 2 }`,
+			expectedConstants: []interface{}{1, 2},
+			expectedInstructions: []code.Instructions{
+				// 0000
+				code.Make(code.OpTry, 0),
+				// 0003
+				code.Make(code.OpConstant, 0),
+				// 0006
+				code.Make(code.OpThrow),
+				// 0007
+				code.Make(code.OpJump, 10),
+				// 0010
+				code.Make(code.OpConstant, 1),
+				// 0013
+				code.Make(code.OpPop),
+				// 0014
+				code.Make(code.OpJump, 22),
+				// 0017
+				code.Make(code.OpConstant, 1),
+				// 0020
+				code.Make(code.OpPop),
+				// 0021
+				code.Make(code.OpThrow),
+				// 0022
+				code.Make(code.OpPop),
+			},
+		},
+	}
+
+	runCompilerTests(t, tests)
+
+	compiler := New()
+	err := compiler.Compile(parse(`try { try { 1 } catch { 2 } } catch { 3 } finally { 4 }`))
+	if err != nil {
+		t.Fatalf("compiler error: %s", err)
+	}
+
+	expected := []code.Handler{
+		{Start: 6, End: 9, Target: 12, Slot: 1},
+		{Start: 3, End: 16, Target: 19, Slot: 0},
+		{Start: 19, End: 23, Target: 30, Slot: 0},
+	}
+
+	handlers := compiler.Bytecode().Handlers
+	if len(handlers) != len(expected) {
+		t.Fatalf("wrong number of handlers. want=%d, got=%d", len(expected), len(handlers))
+	}
+	for i, h := range expected {
+		if handlers[i] != h {
+			t.Errorf("wrong handler at %d. want=%+v, got=%+v", i, h, handlers[i])
+		}
+	}
+}
+
+func TestTailCalls(t *testing.T) {
+	tests := []compilerTestCase{
+		{
+			input: `fn(f) { return f(1) }`,
+			expectedConstants: []interface{}{
+				1,
+				[]code.Instructions{
+					code.Make(code.OpGetLocal, 0),
+					code.Make(code.OpConstant, 0),
+					code.Make(code.OpTailCall, 1),
+					code.Make(code.OpReturnValue),
+				},
+			},
+			expectedInstructions: []code.Instructions{
+				code.Make(code.OpClosure, 1, 0),
+				code.Make(code.OpPop),
+			},
+		},
+		{
+			// The handler needs the frame of a protected call
+			input: `fn(f) { try { f() } catch (e) { 0 } }`,
+			expectedConstants: []interface{}{
+				0,
+				[]code.Instructions{
+					code.Make(code.OpTry, 0),
+					code.Make(code.OpGetLocal, 0),
+					code.Make(code.OpCall, 0),
+					code.Make(code.OpJump, 16),
+					code.Make(code.OpSetLocal, 1),
+					code.Make(code.OpPop),
+					code.Make(code.OpConstant, 0),
+					code.Make(code.OpReturnValue),
+				},
+			},
+			expectedInstructions: []code.Instructions{
+				code.Make(code.OpClosure, 1, 0),
+				code.Make(code.OpPop),
+			},
+		},
+	}
+
+	runCompilerTests(t, tests)
+}
+
+func TestLineTables(t *testing.T) {
+	input := `let a = 1;
+let add = fn(x, y) {
+	let z = x;
+	z + y
+};
+add(a, 2);`
+
+	compiler := New()
+	err := compiler.Compile(parse(input))
+	if err != nil {
+		t.Fatalf("compiler error: %s", err)
+	}
+	bytecode := compiler.Bytecode()
+
+	fn, ok := bytecode.Constants[1].(*object.CompiledFunction)
+	if !ok {
+		t.Fatalf("constant is not CompiledFunction. got=%T", bytecode.Constants[1])
+	}
+
+	if fn.Name != "add" {
+		t.Errorf("function has wrong name. want=%q, got=%q", "add", fn.Name)
+	}
+
+	// OpGetLocal 0, OpSetLocal 2, OpGetLocal 2, OpGetLocal 1, OpAdd, OpReturnValue
+	fnTests := []struct {
+		offset int
+		line   int
+	}{
+		{0, 3},
+		{2, 3},
+		{4, 4},
+		{8, 4},
+		{9, 4},
+	}
+	for _, tt := range fnTests {
+		if pos := fn.Lines.Lookup(tt.offset); pos.Line != tt.line {
+			t.Errorf("wrong line for function offset %d. want=%d, got=%d", tt.offset, tt.line, pos.Line)
+		}
+	}
+
+	last := len(bytecode.Instructions) - 1
+	if pos := bytecode.Lines.Lookup(last); pos.Line != 6 {
+		t.Errorf("wrong line for main offset %d. want=%d, got=%d", last, 6, pos.Line)
+	}
+	if pos := bytecode.Lines.Lookup(0); pos.Line != 1 {
+		t.Errorf("wrong line for main offset 0. want=%d, got=%d", 1, pos.Line)
+	}
+}
+
+func TestResolutions(t *testing.T) {
+	input := `let a = 1;
+let f = fn(x) {
+	x + a
+};
+f(len([]));`
+
+	l := lexer.NewWithFilename(input, "main.dreb")
+	program := parser.New(l).ParseProgram()
+
+	compiler := New()
+	compiler.RecordResolutions()
+	if err := compiler.Compile(program); err != nil {
+		t.Fatalf("compiler error: %s", err)
+	}
+
+	expected := []struct {
+		name       string
+		pos        string
+		scope      SymbolScope
+		definition string
+	}{
+		{"a", "main.dreb:1:5", GlobalScope, "main.dreb:1:5"},
+		{"f", "main.dreb:2:5", GlobalScope, "main.dreb:2:5"},
+		{"x", "main.dreb:2:12", LocalScope, "main.dreb:2:12"},
+		{"x", "main.dreb:3:2", LocalScope, "main.dreb:2:12"},
+		{"a", "main.dreb:3:6", GlobalScope, "main.dreb:1:5"},
+		{"f", "main.dreb:5:1", GlobalScope, "main.dreb:2:5"},
+		{"len", "main.dreb:5:3", BuiltinScope, "-"},
+	}
+
+	resolutions := compiler.Resolutions()
+	if len(resolutions) != len(expected) {
+		t.Fatalf("wrong number of resolutions. want=%d, got=%d (%+v)", len(expected), len(resolutions), resolutions)
+	}
+
+	for i, tt := range expected {
+		r := resolutions[i]
+		if r.Name != tt.name || r.Pos.String() != tt.pos || r.Symbol.Scope != tt.scope || r.Definition.String() != tt.definition {
+			t.Errorf("wrong resolution %d. want=%+v, got=%+v", i, tt, r)
+		}
+	}
+}
+
 func testInstructions(expected []code.Instructions, actual code.Instructions) error {
 	concatted := concatInstructions(expected)
 
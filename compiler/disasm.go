@@ -0,0 +1,172 @@
+package compiler
+
+import (
+	"bytes"
+	"fmt"
+	"strings"
+
+	"github.com/dreblang/core/code"
+	"github.com/dreblang/core/object"
+)
+
+// Disassemble returns a listing of the constant pool followed by the
+// instructions of the main program and of every function in the pool.
+// Operands are annotated with constant values, jump targets and symbol
+// names where the debug info has them.
+func (b *Bytecode) Disassemble() string {
+	var out bytes.Buffer
+
+	out.WriteString("constants:\n")
+	for i, obj := range b.Constants {
+		fmt.Fprintf(&out, "  %04d %-16s %s\n", i, obj.Type(), describeConstant(obj))
+	}
+
+	d := &disassembler{out: &out, bytecode: b}
+	fmt.Fprintf(&out, "\n<main>: globals=%d\n", len(b.GlobalNames))
+	d.function(b.mainFunction())
+	for i, obj := range b.Constants {
+		if fn, ok := obj.(*object.CompiledFunction); ok {
+			fmt.Fprintf(&out, "\n%s (constant %d): params=%d locals=%d free=%d\n",
+				fn.Name, i, fn.NumParameters, fn.NumLocals, len(fn.FreeNames))
+			d.function(fn)
+		}
+	}
+
+	return out.String()
+}
+
+func describeConstant(obj object.Object) string {
+	switch obj := obj.(type) {
+	case *object.String:
+		return fmt.Sprintf("%q", obj.Value)
+	case *object.CompiledFunction:
+		return fmt.Sprintf("fn %s/%d", obj.Name, obj.NumParameters)
+	case *object.Scope:
+		return "module " + obj.Name
+	default:
+		return obj.Inspect()
+	}
+}
+
+type disassembler struct {
+	out      *bytes.Buffer
+	bytecode *Bytecode
+}
+
+func (d *disassembler) function(fn *object.CompiledFunction) {
+	ins := fn.Instructions
+	lastLine := 0
+	for i := 0; i < len(ins); {
+		def, err := code.Lookup(ins[i])
+		if err != nil {
+			fmt.Fprintf(d.out, "        %04d ERROR: %s\n", i, err)
+			break
+		}
+		operands, read := code.ReadOperands(def, ins[i+1:])
+
+		line := ""
+		if pos := fn.Lines.Lookup(i); pos.IsValid() && pos.Line != lastLine {
+			line = fmt.Sprint(pos.Line)
+			lastLine = pos.Line
+		}
+
+		text := def.Name
+		for _, o := range operands {
+			text += fmt.Sprintf(" %d", o)
+		}
+
+		comment := d.comment(code.Opcode(ins[i]), operands, fn)
+		if comment != "" {
+			text = fmt.Sprintf("%-22s ; %s", text, comment)
+		}
+		fmt.Fprintf(d.out, "  %5s %04d %s\n", line, i, text)
+
+		i += 1 + read
+	}
+
+	if len(fn.Handlers) > 0 {
+		d.out.WriteString("  handlers:\n")
+		for _, h := range fn.Handlers {
+			fmt.Fprintf(d.out, "    [%04d, %04d) -> %04d slot %d\n", h.Start, h.End, h.Target, h.Slot)
+		}
+	}
+}
+
+func (d *disassembler) comment(op code.Opcode, operands []int, fn *object.CompiledFunction) string {
+	if code.IsJump(op) {
+		return fmt.Sprintf("-> %04d", operands[0])
+	}
+
+	switch op {
+	case code.OpConstant:
+		if operands[0] < len(d.bytecode.Constants) {
+			return describeConstant(d.bytecode.Constants[operands[0]])
+		}
+	case code.OpGetGlobal, code.OpSetGlobal:
+		return nameAt(d.bytecode.GlobalNames, operands[0])
+	case code.OpGetLocal, code.OpSetLocal:
+		return nameAt(fn.LocalNames, operands[0])
+	case code.OpAddLocalConst:
+		if operands[1] < len(d.bytecode.Constants) {
+			return fmt.Sprintf("%s + %s", nameAt(fn.LocalNames, operands[0]), describeConstant(d.bytecode.Constants[operands[1]]))
+		}
+	case code.OpGetFree, code.OpSetFree:
+		return nameAt(fn.FreeNames, operands[0])
+	case code.OpGetBuiltin:
+		if operands[0] < len(object.Builtins) {
+			return object.Builtins[operands[0]].Name
+		}
+	case code.OpClosure:
+		if operands[0] < len(d.bytecode.Constants) {
+			return fmt.Sprintf("%s, %d free", describeConstant(d.bytecode.Constants[operands[0]]), operands[1])
+		}
+	case code.OpRegLoadConst:
+		return d.constant(operands[1])
+	case code.OpRegAddConst:
+		return "+ " + d.constant(operands[2])
+	case code.OpRegGetGlobal:
+		return nameAt(d.bytecode.GlobalNames, operands[1])
+	case code.OpRegSetGlobal:
+		return nameAt(d.bytecode.GlobalNames, operands[0])
+	case code.OpRegGetFree:
+		return nameAt(fn.FreeNames, operands[1])
+	case code.OpRegSetFree:
+		return nameAt(fn.FreeNames, operands[0])
+	case code.OpRegGetBuiltin:
+		if operands[1] < len(object.Builtins) {
+			return object.Builtins[operands[1]].Name
+		}
+	case code.OpRegClosure:
+		if operands[1] < len(d.bytecode.Constants) {
+			return fmt.Sprintf("%s, %d free", d.constant(operands[1]), operands[3])
+		}
+	case code.OpRegExport:
+		return d.constant(operands[0])
+	case code.OpTry:
+		targets := []string{}
+		for _, h := range fn.Handlers {
+			if h.Slot == operands[0] {
+				targets = append(targets, fmt.Sprintf("%04d", h.Target))
+			}
+		}
+		if len(targets) > 0 {
+			return "handlers -> " + strings.Join(targets, ", ")
+		}
+	}
+	return ""
+}
+
+// constant describes constant i, or returns "" if it is out of range.
+func (d *disassembler) constant(i int) string {
+	if i < len(d.bytecode.Constants) {
+		return describeConstant(d.bytecode.Constants[i])
+	}
+	return ""
+}
+
+func nameAt(names []string, i int) string {
+	if i < len(names) {
+		return names[i]
+	}
+	return ""
+}
@@ -0,0 +1,73 @@
+package compiler
+
+import (
+	"testing"
+)
+
+func TestDisassemble(t *testing.T) {
+	input := `let a = 1;
+let add = fn(x) {
+	let f = fn(y) { x + y + a };
+	if (x > 1) { f(2) } else { len("a") }
+};
+try { add(a) } catch (e) { e }`
+
+	expected := `constants:
+  0000 Integer          1
+  0001 CompiledFunction fn f/1
+  0002 Integer          2
+  0003 String           "a"
+  0004 CompiledFunction fn add/1
+
+<main>: globals=3
+      1 0000 OpConstant 0           ; 1
+        0003 OpSetGlobal 0          ; a
+      2 0006 OpClosure 4 0          ; fn add/1, 0 free
+        0010 OpSetGlobal 1          ; add
+      6 0013 OpTry 0                ; handlers -> 0027
+        0016 OpGetGlobal 1          ; add
+        0019 OpGetGlobal 0          ; a
+        0022 OpCall 1
+        0024 OpJump 34              ; -> 0034
+        0027 OpSetGlobal 2          ; e
+        0030 OpPop
+        0031 OpGetGlobal 2          ; e
+        0034 OpPop
+  handlers:
+    [0016, 0024) -> 0027 slot 0
+
+f (constant 1): params=1 locals=1 free=1
+      3 0000 OpGetFree 0            ; x
+        0002 OpGetLocal 0           ; y
+        0004 OpAdd
+        0005 OpGetGlobal 0          ; a
+        0008 OpAdd
+        0009 OpReturnValue
+
+add (constant 4): params=1 locals=2 free=0
+      3 0000 OpGetLocal 0           ; x
+        0002 OpClosure 1 1          ; fn f/1, 1 free
+        0006 OpSetLocal 1           ; f
+      4 0008 OpGetLocal 0           ; x
+        0010 OpConstant 0           ; 1
+        0013 OpJumpIfLessOrEqual 26 ; -> 0026
+        0016 OpGetLocal 1           ; f
+        0018 OpConstant 2           ; 2
+        0021 OpTailCall 1
+        0023 OpJump 33              ; -> 0033
+        0026 OpGetBuiltin 0         ; len
+        0028 OpConstant 3           ; "a"
+        0031 OpTailCall 1
+        0033 OpReturnValue
+`
+
+	compiler := New()
+	if err := compiler.Compile(parse(input)); err != nil {
+		t.Fatalf("compiler error: %s", err)
+	}
+
+	actual := compiler.Bytecode().Disassemble()
+	if actual != expected {
+		t.Errorf("wrong disassembly.\nwant=\n%s\ngot=\n%s", expected, actual)
+	}
+}
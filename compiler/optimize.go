@@ -0,0 +1,309 @@
+package compiler
+
+import (
+	"github.com/dreblang/core/ast"
+	"github.com/dreblang/core/code"
+	"github.com/dreblang/core/object"
+	"github.com/dreblang/core/token"
+)
+
+// Optimize makes the compiler fold constant expressions, leave out branches
+// that can't be taken and code that can't be reached, and thread jumps to
+// jumps.
+func (c *Compiler) Optimize() {
+	c.optimize = true
+}
+
+// constant evaluates expr if it only involves literals, the same way the VM
+// would. It returns nil if expr isn't constant or evaluating it fails.
+func constant(expr ast.Expression) object.Object {
+	switch expr := expr.(type) {
+	case *ast.IntegerLiteral:
+		return &object.Integer{Value: expr.Value}
+	case *ast.FloatLiteral:
+		return &object.Float{Value: expr.Value}
+	case *ast.StringLiteral:
+		return &object.String{Value: expr.Value}
+	case *ast.Boolean:
+		return object.NativeBoolToBooleanObject(expr.Value)
+
+	case *ast.PrefixExpression:
+		right := constant(expr.Right)
+		switch {
+		case right == nil:
+			return nil
+		case expr.Operator == token.Bang:
+			return object.NativeBoolToBooleanObject(right == object.False)
+		case expr.Operator != token.Minus:
+			return nil
+		}
+
+		switch right := right.(type) {
+		case *object.Integer:
+			return &object.Integer{Value: -right.Value}
+		case *object.Float:
+			return &object.Float{Value: -right.Value}
+		}
+
+	case *ast.InfixExpression:
+		switch expr.Operator {
+		case token.Plus, token.Minus, token.Asterisk, token.Slash, token.Percent,
+			token.Equal, token.NotEqual, token.GreaterThan, token.GreaterOrEqual,
+			token.LessThan, token.LessOrEqual:
+		default:
+			return nil
+		}
+
+		left, right := constant(expr.Left), constant(expr.Right)
+		if left == nil || right == nil {
+			return nil
+		}
+
+		op := expr.Operator
+		// Integer division by zero is left to fail at run time
+		if divisor, ok := right.(*object.Integer); ok && divisor.Value == 0 && (op == token.Slash || op == token.Percent) {
+			if _, ok := left.(*object.Integer); ok {
+				return nil
+			}
+		}
+
+		result := left.(object.InfixOperatorObject).InfixOperation(op, right)
+		switch result.(type) {
+		case *object.Integer, *object.Float, *object.String, *object.Boolean:
+			return result
+		}
+	}
+	return nil
+}
+
+// emitConstant emits the instruction that loads a folded constant.
+func (c *Compiler) emitConstant(obj object.Object) {
+	switch obj {
+	case object.True:
+		c.emit(code.OpTrue)
+	case object.False:
+		c.emit(code.OpFalse)
+	default:
+		c.emit(code.OpConstant, c.addConstant(obj))
+	}
+}
+
+// truthy mirrors the VM's idea of truth for constants.
+func truthy(obj object.Object) bool {
+	if b, ok := obj.(*object.Boolean); ok {
+		return b.Value
+	}
+	return true
+}
+
+// compileConstantIf compiles an if expression whose condition is constant to
+// just the branch that is taken.
+func (c *Compiler) compileConstantIf(node *ast.IfExpression, cond object.Object) error {
+	taken, skipped := node.Consequence, node.Alternative
+	if !truthy(cond) {
+		taken, skipped = skipped, taken
+	}
+
+	if skipped != nil {
+		err := c.discard(skipped)
+		if err != nil {
+			return err
+		}
+	}
+
+	if taken == nil {
+		c.emit(code.OpNull)
+		return nil
+	}
+	return c.compileBlockValue(taken)
+}
+
+// discard compiles node for the names it declares and throws its
+// instructions away, so code that is left out still resolves the same way.
+func (c *Compiler) discard(node ast.Node) error {
+	scope := &c.scopes[c.scopeIndex]
+	start := len(scope.instructions)
+	last, previous := scope.lastInstruction, scope.previousInstruction
+	handlers, trySlots := len(scope.handlers), scope.numTrySlots
+	constants := len(c.constants)
+
+	err := c.Compile(node)
+	if err != nil {
+		return err
+	}
+
+	scope = &c.scopes[c.scopeIndex]
+	scope.instructions = scope.instructions[:start]
+	scope.lastInstruction, scope.previousInstruction = last, previous
+	scope.handlers, scope.numTrySlots = scope.handlers[:handlers], trySlots
+	c.constants = c.constants[:constants]
+	c.truncateLines(start)
+	return nil
+}
+
+// fallsThrough reports whether execution can continue with the instruction
+// following op.
+func fallsThrough(op code.Opcode) bool {
+	switch op {
+	case code.OpJump, code.OpReturnValue, code.OpReturn, code.OpThrow:
+		return false
+	}
+	return true
+}
+
+// optimizeInstructions threads jumps to jumps and removes the instructions
+// that can't be reached, keeping the line table, handlers and try slots in
+// step.
+func optimizeInstructions(ins code.Instructions, lines code.LineTable, handlers []code.Handler) (code.Instructions, code.LineTable, []code.Handler) {
+	type instruction struct {
+		offset, width int
+		op            code.Opcode
+		operands      []int
+		reachable     bool
+	}
+
+	all := []*instruction{}
+	// Instruction offsets to indexes in all
+	index := map[int]int{}
+	for i := 0; i < len(ins); {
+		def, err := code.Lookup(ins[i])
+		if err != nil {
+			return ins, lines, handlers
+		}
+		operands, read := code.ReadOperands(def, ins[i+1:])
+		in := &instruction{offset: i, width: 1 + read, op: code.Opcode(ins[i]), operands: operands}
+		index[i] = len(all)
+		all = append(all, in)
+		i += in.width
+	}
+
+	// Jump straight to where a chain of unconditional jumps ends up
+	for _, in := range all {
+		if !code.IsJump(in.op) {
+			continue
+		}
+		target := in.operands[0]
+		for seen := 0; seen < len(all); seen++ {
+			j, ok := index[target]
+			if !ok || all[j].op != code.OpJump || all[j].operands[0] == target {
+				break
+			}
+			target = all[j].operands[0]
+		}
+		in.operands[0] = target
+	}
+
+	// Mark what can be reached from the entry point or through a handler
+	// whose protected range can be reached
+	var mark func(i int)
+	mark = func(i int) {
+		for ; i < len(all) && !all[i].reachable; i++ {
+			in := all[i]
+			in.reachable = true
+			if code.IsJump(in.op) {
+				if j, ok := index[in.operands[0]]; ok {
+					mark(j)
+				}
+			}
+			if !fallsThrough(in.op) {
+				return
+			}
+		}
+	}
+	mark(0)
+	for changed := true; changed; {
+		changed = false
+		for _, h := range handlers {
+			j, ok := index[h.Target]
+			if !ok || all[j].reachable {
+				continue
+			}
+			for _, in := range all {
+				if in.reachable && in.offset >= h.Start && in.offset < h.End {
+					mark(j)
+					changed = true
+					break
+				}
+			}
+		}
+	}
+
+	keep := make([]bool, len(handlers))
+	for i, h := range handlers {
+		j, ok := index[h.Target]
+		keep[i] = ok && all[j].reachable
+	}
+
+	// Number the slots of the handlers kept from 0 again, since a frame has
+	// room for as many slots as its function has handlers. An OpTry whose
+	// handlers are all gone has nothing to record.
+	slots := map[int]int{}
+	for i, h := range handlers {
+		if _, ok := slots[h.Slot]; keep[i] && !ok {
+			slots[h.Slot] = len(slots)
+		}
+	}
+	for _, in := range all {
+		if in.op == code.OpTry {
+			slot, ok := slots[in.operands[0]]
+			in.operands[0] = slot
+			in.reachable = in.reachable && ok
+		}
+	}
+
+	// A jump to the instruction that follows it anyway does nothing
+	next := len(ins)
+	for i := len(all) - 1; i >= 0; i-- {
+		in := all[i]
+		if !in.reachable {
+			continue
+		}
+		if in.op == code.OpJump && in.operands[0] == next {
+			in.reachable = false
+			continue
+		}
+		next = in.offset
+	}
+
+	// Old offsets map to the new offset of the first instruction kept at or
+	// after them
+	moved := map[int]int{}
+	offset := 0
+	for _, in := range all {
+		moved[in.offset] = offset
+		if in.reachable {
+			offset += in.width
+		}
+	}
+	moved[len(ins)] = offset
+
+	out := code.Instructions{}
+	newLines := code.LineTable{}
+	for _, in := range all {
+		if !in.reachable {
+			continue
+		}
+		if code.IsJump(in.op) {
+			in.operands[0] = moved[in.operands[0]]
+		}
+		pos := lines.Lookup(in.offset)
+		if len(newLines) == 0 || newLines[len(newLines)-1].Pos != pos {
+			newLines = append(newLines, code.LineEntry{Offset: len(out), Pos: pos})
+		}
+		out = append(out, code.Make(in.op, in.operands...)...)
+	}
+
+	newHandlers := []code.Handler{}
+	for i, h := range handlers {
+		if !keep[i] {
+			continue
+		}
+		newHandlers = append(newHandlers, code.Handler{
+			Start:  moved[h.Start],
+			End:    moved[h.End],
+			Target: moved[h.Target],
+			Slot:   slots[h.Slot],
+		})
+	}
+	return out, newLines, newHandlers
+}
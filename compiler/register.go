@@ -0,0 +1,1238 @@
+package compiler
+
+import (
+	"encoding/binary"
+	"fmt"
+	"io/ioutil"
+	"sort"
+
+	"github.com/dreblang/core/ast"
+	"github.com/dreblang/core/code"
+	"github.com/dreblang/core/lexer"
+	"github.com/dreblang/core/object"
+	"github.com/dreblang/core/parser"
+	"github.com/dreblang/core/token"
+)
+
+// Temporaries are numbered from tempBase while their function is compiled,
+// and renumbered to follow its locals once the number of locals is known.
+const tempBase = 0x8000
+
+// RegisterCompiler compiles programs for the experimental register VM. It
+// resolves names with the same symbol tables as Compiler: the locals of a
+// function are its first registers, followed by the temporaries holding
+// intermediate values.
+type RegisterCompiler struct {
+	constants   []object.Object
+	symbolTable *SymbolTable
+	scopes      []*registerScope
+
+	// Source position of the node being compiled
+	pos token.Position
+
+	optimize bool
+	sandbox  *Sandbox
+}
+
+type registerScope struct {
+	instructions code.Instructions
+	lines        code.LineTable
+	handlers     []code.Handler
+	lastOpcode   code.Opcode
+
+	// Temporaries in use and the most ever in use at once
+	temps    int
+	maxTemps int
+
+	finally []*ast.BlockStatement
+}
+
+func NewRegister() *RegisterCompiler {
+	return &RegisterCompiler{
+		constants:   []object.Object{},
+		symbolTable: NewSymbolTable(),
+		scopes:      []*registerScope{{}},
+	}
+}
+
+// Optimize makes the compiler fold constant expressions and leave out
+// branches that can't be taken.
+func (c *RegisterCompiler) Optimize() {
+	c.optimize = true
+}
+
+// Compile compiles a program or a single statement of one.
+func (c *RegisterCompiler) Compile(node ast.Node) error {
+	switch node := node.(type) {
+	case *ast.Program:
+		return c.statements(node.Statements)
+	case ast.Statement:
+		return c.statement(node)
+	default:
+		return fmt.Errorf("%s: cannot compile %T", c.pos, node)
+	}
+}
+
+func (c *RegisterCompiler) Bytecode() *Bytecode {
+	scope := c.scope()
+	registers := scope.maxTemps
+	if registers == 0 {
+		// Register bytecode is told apart by having registers
+		registers = 1
+	}
+
+	return &Bytecode{
+		Instructions: renumberTemps(scope.instructions, 0),
+		Constants:    c.constants,
+		Lines:        scope.lines,
+		Handlers:     renumberHandlers(scope.handlers, 0),
+		GlobalNames:  c.symbolTable.DefinedNames(),
+		Registers:    registers,
+	}
+}
+
+func (c *RegisterCompiler) statements(statements []ast.Statement) error {
+	for _, s := range statements {
+		err := c.statement(s)
+		if err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func (c *RegisterCompiler) statement(node ast.Statement) error {
+	defer c.at(node)()
+	defer c.release(c.scope().temps)
+
+	switch node := node.(type) {
+	case *ast.ExpressionStatement:
+		var r int
+		var err error
+		if infix, ok := node.Expression.(*ast.InfixExpression); ok && infix.Operator == "=" {
+			// The value is where it was assigned, no need to copy it
+			r, err = c.assignment(infix)
+		} else {
+			r, err = c.value(node.Expression)
+		}
+		if err != nil {
+			return err
+		}
+		if len(c.scopes) == 1 {
+			c.emit(code.OpRegResult, r)
+		}
+
+	case *ast.BlockStatement:
+		return c.statements(node.Statements)
+
+	case *ast.IterStatement:
+		return c.statements(node.ConvertToLoop())
+
+	case *ast.ClassDefinition:
+		return c.statements(node.ConvertToFunc())
+
+	case *ast.LetStatement:
+		symbol := c.define(node.Name)
+		_, err := c.assign(symbol, node.Value)
+		return err
+
+	case *ast.ReturnStatement:
+		return c.returnStatement(node)
+
+	case *ast.ScopeDefinition:
+		c.enterScope()
+
+		err := c.statements(node.Block.Statements)
+		if err != nil {
+			return err
+		}
+		t := c.temp()
+		c.emit(code.OpRegScope, t)
+		c.emit(code.OpRegReturn, t)
+
+		fn, free := c.leaveScope(node.Name.Value, 0)
+		cl := c.temp()
+		c.closure(fn, free, cl)
+		c.emit(code.OpRegCall, cl, cl, 0)
+
+		symbol := c.define(node.Name)
+		c.store(symbol, cl)
+
+	case *ast.ExportStatement:
+		symbol, ok := c.resolve(node.Identifier)
+		if !ok {
+			return fmt.Errorf("%s: undefined variable %s", node.Identifier.Pos(), node.Identifier.Value)
+		}
+		r := c.register(symbol)
+		c.emit(code.OpRegExport, c.addConstant(&object.String{Value: node.Identifier.Value}), r)
+
+	case *ast.LoadStatement:
+		return c.loadModule(node.Identifier)
+
+	default:
+		return fmt.Errorf("%s: cannot compile %T", c.pos, node)
+	}
+
+	return nil
+}
+
+func (c *RegisterCompiler) returnStatement(node *ast.ReturnStatement) error {
+	if node.ReturnValue == nil {
+		err := c.pendingFinally()
+		if err != nil {
+			return err
+		}
+		c.emit(code.OpRegReturnNull)
+		return nil
+	}
+
+	r, err := c.value(node.ReturnValue)
+	if err != nil {
+		return err
+	}
+
+	// A finally block may assign to the variable returned
+	if len(c.scope().finally) > 0 && r < tempBase {
+		t := c.temp()
+		c.emit(code.OpRegMove, t, r)
+		r = t
+	}
+	err = c.pendingFinally()
+	if err != nil {
+		return err
+	}
+
+	c.emit(code.OpRegReturn, r)
+	return nil
+}
+
+// pendingFinally inlines the finally blocks of every try expression the
+// current return statement leaves.
+func (c *RegisterCompiler) pendingFinally() error {
+	scope := c.scope()
+	finally := scope.finally
+	for i := len(finally) - 1; i >= 0; i-- {
+		scope.finally = finally[:i]
+
+		err := c.statements(finally[i].Statements)
+		if err != nil {
+			scope.finally = finally
+			return err
+		}
+	}
+	scope.finally = finally
+	return nil
+}
+
+// value compiles node and returns the register holding its value. Locals
+// are used where they are, anything else is compiled to a temporary.
+func (c *RegisterCompiler) value(node ast.Expression) (int, error) {
+	if ident, ok := node.(*ast.Identifier); ok {
+		symbol, ok := c.resolve(ident)
+		if ok && symbol.Scope == LocalScope {
+			return symbol.Index, nil
+		}
+	}
+
+	t := c.temp()
+	return t, c.expression(node, t)
+}
+
+// operand is value for an operand evaluated before the expressions in
+// later. A local is copied if they might assign to it.
+func (c *RegisterCompiler) operand(node ast.Expression, later ...ast.Expression) (int, error) {
+	r, err := c.value(node)
+	if err != nil || r >= tempBase || !mayAssign(later...) {
+		return r, err
+	}
+
+	t := c.temp()
+	c.emit(code.OpRegMove, t, r)
+	return t, nil
+}
+
+// mayAssign reports whether evaluating any of exprs might assign to a
+// variable of the function being compiled.
+func mayAssign(exprs ...ast.Expression) bool {
+	for _, expr := range exprs {
+		switch expr := expr.(type) {
+		case nil, *ast.Identifier, *ast.IntegerLiteral, *ast.FloatLiteral, *ast.StringLiteral, *ast.Boolean, *ast.FunctionLiteral:
+			continue
+		case *ast.PrefixExpression:
+			if !mayAssign(expr.Right) {
+				continue
+			}
+		case *ast.InfixExpression:
+			if expr.Operator != "=" && !mayAssign(expr.Left, expr.Right) {
+				continue
+			}
+		case *ast.CallExpression:
+			if !mayAssign(expr.Function) && !mayAssign(expr.Arguments...) {
+				continue
+			}
+		case *ast.IndexExpression:
+			if !mayAssign(expr.Left, expr.Index, expr.IndexUpper, expr.IndexSkip) {
+				continue
+			}
+		case *ast.ArrayLiteral:
+			if !mayAssign(expr.Elements...) {
+				continue
+			}
+		}
+		return true
+	}
+	return false
+}
+
+// expression compiles node so its value ends up in register dst.
+func (c *RegisterCompiler) expression(node ast.Expression, dst int) error {
+	defer c.at(node)()
+	defer c.release(c.scope().temps)
+
+	switch node := node.(type) {
+	case *ast.InfixExpression:
+		if c.optimize {
+			if obj := constant(node); obj != nil {
+				c.loadConstant(obj, dst)
+				return nil
+			}
+		}
+		if node.Operator == "=" {
+			r, err := c.assignment(node)
+			if err != nil {
+				return err
+			}
+			c.move(dst, r)
+			return nil
+		}
+		return c.infix(node, dst)
+
+	case *ast.PrefixExpression:
+		if c.optimize {
+			if obj := constant(node); obj != nil {
+				c.loadConstant(obj, dst)
+				return nil
+			}
+		}
+
+		r, err := c.value(node.Right)
+		if err != nil {
+			return err
+		}
+
+		switch node.Operator {
+		case "!":
+			c.emit(code.OpRegBang, dst, r)
+		case "-":
+			c.emit(code.OpRegMinus, dst, r)
+		default:
+			return fmt.Errorf("%s: unknown operator %s", node.Pos(), node.Operator)
+		}
+
+	case *ast.IfExpression:
+		return c.ifExpression(node, dst)
+
+	case *ast.LoopExpression:
+		return c.loopExpression(node, dst)
+
+	case *ast.IndexExpression:
+		left, err := c.operand(node.Left, node.Index, node.IndexUpper, node.IndexSkip)
+		if err != nil {
+			return err
+		}
+
+		if node.Index != nil && !node.HasUpper && !node.HasSkip {
+			index, err := c.value(node.Index)
+			if err != nil {
+				return err
+			}
+			c.emit(code.OpRegIndex, dst, left, index)
+			return nil
+		}
+
+		index, err := c.indexOperands(node)
+		if err != nil {
+			return err
+		}
+		c.emit(code.OpRegIndexRange, dst, left, index)
+
+	case *ast.CallExpression:
+		// The callee can share the register of the result if no other
+		// temporary follows it
+		fn := dst
+		if dst != c.nextTemp()-1 {
+			fn = c.temp()
+		}
+		err := c.expression(node.Function, fn)
+		if err != nil {
+			return err
+		}
+
+		for _, a := range node.Arguments {
+			err := c.expression(a, c.temp())
+			if err != nil {
+				return err
+			}
+		}
+
+		c.emit(code.OpRegCall, dst, fn, len(node.Arguments))
+
+	case *ast.TryExpression:
+		return c.tryExpression(node, dst)
+
+	case *ast.ThrowExpression:
+		r, err := c.value(node.Value)
+		if err != nil {
+			return err
+		}
+		c.emit(code.OpRegThrow, r)
+
+	case *ast.Identifier:
+		symbol, ok := c.resolve(node)
+		if !ok {
+			return fmt.Errorf("%s: undefined variable %s", node.Pos(), node.Value)
+		}
+		c.load(symbol, dst)
+
+	case *ast.IntegerLiteral:
+		c.loadConstant(&object.Integer{Value: node.Value}, dst)
+
+	case *ast.FloatLiteral:
+		c.loadConstant(&object.Float{Value: node.Value}, dst)
+
+	case *ast.Boolean:
+		c.loadConstant(object.NativeBoolToBooleanObject(node.Value), dst)
+
+	case *ast.StringLiteral:
+		c.loadConstant(&object.String{Value: node.Value}, dst)
+
+	case *ast.ArrayLiteral:
+		base := c.nextTemp()
+		for _, el := range node.Elements {
+			err := c.expression(el, c.temp())
+			if err != nil {
+				return err
+			}
+		}
+
+		c.emit(code.OpRegArray, dst, base, len(node.Elements))
+
+	case *ast.HashLiteral:
+		keys := []ast.Expression{}
+		for k := range node.Pairs {
+			keys = append(keys, k)
+		}
+		sort.Slice(keys, func(i, j int) bool {
+			return keys[i].String() < keys[j].String()
+		})
+
+		base := c.nextTemp()
+		for _, k := range keys {
+			key := c.temp()
+			start := len(c.scope().instructions)
+			err := c.expression(k, key)
+			if err != nil {
+				// Keys that don't compile, such as undefined names, are
+				// taken as strings
+				c.truncate(start)
+				c.loadConstant(&object.String{Value: k.String()}, key)
+			}
+
+			err = c.expression(node.Pairs[k], c.temp())
+			if err != nil {
+				return err
+			}
+		}
+
+		c.emit(code.OpRegHash, dst, base, len(node.Pairs)*2)
+
+	case *ast.FunctionLiteral:
+		c.enterScope()
+
+		for _, p := range node.Parameters {
+			c.define(p)
+		}
+
+		err := c.functionBody(node.Body.Statements)
+		if err != nil {
+			return err
+		}
+
+		name := node.Name
+		if name == "" {
+			name = "<anonymous>"
+		}
+		fn, free := c.leaveScope(name, len(node.Parameters))
+		c.closure(fn, free, dst)
+
+	default:
+		return fmt.Errorf("%s: cannot compile %T", c.pos, node)
+	}
+
+	return nil
+}
+
+// registerInfixOps maps the binary operators to their instructions.
+var registerInfixOps = map[string]code.Opcode{
+	token.Plus:           code.OpRegAdd,
+	token.Minus:          code.OpRegSub,
+	token.Asterisk:       code.OpRegMul,
+	token.Slash:          code.OpRegDiv,
+	token.Percent:        code.OpRegMod,
+	token.GreaterThan:    code.OpRegGreaterThan,
+	token.GreaterOrEqual: code.OpRegGreaterOrEqual,
+	token.LessThan:       code.OpRegLessThan,
+	token.LessOrEqual:    code.OpRegLessOrEqual,
+	token.Equal:          code.OpRegEqual,
+	token.NotEqual:       code.OpRegNotEqual,
+	".":                  code.OpRegMember,
+	"::":                 code.OpRegScopeResolve,
+}
+
+func (c *RegisterCompiler) infix(node *ast.InfixExpression, dst int) error {
+	op, ok := registerInfixOps[node.Operator]
+	if !ok {
+		return fmt.Errorf("%s: unknown operator %s", node.Pos(), node.Operator)
+	}
+
+	left, err := c.operand(node.Left, node.Right)
+	if err != nil {
+		return err
+	}
+
+	if op == code.OpRegAdd {
+		if k, ok := c.literal(node.Right); ok {
+			c.emit(code.OpRegAddConst, dst, left, k)
+			return nil
+		}
+	}
+
+	right, err := c.value(node.Right)
+	if err != nil {
+		return err
+	}
+
+	c.emit(op, dst, left, right)
+	return nil
+}
+
+// literal adds a number or string literal to the constants and returns its
+// index. It reports false if expr isn't one.
+func (c *RegisterCompiler) literal(expr ast.Expression) (int, bool) {
+	switch expr := expr.(type) {
+	case *ast.IntegerLiteral:
+		return c.addConstant(&object.Integer{Value: expr.Value}), true
+	case *ast.FloatLiteral:
+		return c.addConstant(&object.Float{Value: expr.Value}), true
+	case *ast.StringLiteral:
+		return c.addConstant(&object.String{Value: expr.Value}), true
+	}
+	return 0, false
+}
+
+// assignment compiles an assignment and returns the register holding its
+// value.
+func (c *RegisterCompiler) assignment(node *ast.InfixExpression) (int, error) {
+	switch left := node.Left.(type) {
+	case *ast.Identifier:
+		return c.assign(c.define(left), node.Right)
+
+	case *ast.IndexExpression:
+		value, err := c.operand(node.Right, left)
+		if err != nil {
+			return 0, err
+		}
+		obj, err := c.operand(left.Left, left.Index, left.IndexUpper, left.IndexSkip)
+		if err != nil {
+			return 0, err
+		}
+		index, err := c.indexOperands(left)
+		if err != nil {
+			return 0, err
+		}
+		c.emit(code.OpRegIndexSet, obj, index, value)
+		return value, nil
+
+	case *ast.InfixExpression:
+		if left.Operator != "." {
+			return c.value(node.Right)
+		}
+
+		value, err := c.operand(node.Right, left.Left)
+		if err != nil {
+			return 0, err
+		}
+		result, name := c.temp(), c.temp()
+		c.loadConstant(&object.String{Value: left.Right.String()}, name)
+		obj, err := c.value(left.Left)
+		if err != nil {
+			return 0, err
+		}
+		c.emit(code.OpRegMemberSet, result, obj, name, value)
+		return result, nil
+
+	default:
+		return c.value(node.Right)
+	}
+}
+
+// assign compiles value into the variable of symbol and returns the
+// register holding the value.
+func (c *RegisterCompiler) assign(symbol Symbol, value ast.Expression) (int, error) {
+	if symbol.Scope == LocalScope && writesLast(value) {
+		return symbol.Index, c.expression(value, symbol.Index)
+	}
+
+	r, err := c.value(value)
+	if err != nil {
+		return 0, err
+	}
+	c.store(symbol, r)
+	return r, nil
+}
+
+// writesLast reports whether the code of expr writes its destination only
+// after it is done reading variables, so it can be compiled straight into
+// the variable it is assigned to.
+func writesLast(expr ast.Expression) bool {
+	switch expr := expr.(type) {
+	case *ast.IfExpression, *ast.LoopExpression, *ast.TryExpression:
+		return false
+	case *ast.InfixExpression:
+		return expr.Operator != "="
+	}
+	return true
+}
+
+// indexOperands compiles the index, upper bound and skip of node to five
+// consecutive registers and returns the first.
+func (c *RegisterCompiler) indexOperands(node *ast.IndexExpression) (int, error) {
+	index, upper, hasUpper, skip, hasSkip := c.temp(), c.temp(), c.temp(), c.temp(), c.temp()
+
+	if node.Index != nil {
+		err := c.expression(node.Index, index)
+		if err != nil {
+			return 0, err
+		}
+	} else {
+		c.loadConstant(&object.Integer{}, index)
+	}
+
+	if node.IndexUpper != nil {
+		err := c.expression(node.IndexUpper, upper)
+		if err != nil {
+			return 0, err
+		}
+	} else {
+		c.emit(code.OpRegLoadNull, upper)
+	}
+	c.loadConstant(object.NativeBoolToBooleanObject(node.HasUpper), hasUpper)
+
+	if node.IndexSkip != nil {
+		err := c.expression(node.IndexSkip, skip)
+		if err != nil {
+			return 0, err
+		}
+	} else {
+		c.loadConstant(&object.Integer{Value: 1}, skip)
+	}
+	c.loadConstant(object.NativeBoolToBooleanObject(node.HasSkip), hasSkip)
+
+	return index, nil
+}
+
+func (c *RegisterCompiler) ifExpression(node *ast.IfExpression, dst int) error {
+	if c.optimize {
+		if cond := constant(node.Condition); cond != nil {
+			taken, skipped := node.Consequence, node.Alternative
+			if !truthy(cond) {
+				taken, skipped = skipped, taken
+			}
+			if skipped != nil {
+				err := c.discard(skipped)
+				if err != nil {
+					return err
+				}
+			}
+			if taken == nil {
+				c.emit(code.OpRegLoadNull, dst)
+				return nil
+			}
+			return c.blockValue(taken, dst)
+		}
+	}
+
+	jumpNotTruthy, err := c.condition(node.Condition)
+	if err != nil {
+		return err
+	}
+
+	err = c.blockValue(node.Consequence, dst)
+	if err != nil {
+		return err
+	}
+
+	// Emit an `OpRegJump` with a bogus value
+	jump := c.emit(code.OpRegJump, 9999)
+	c.patchJump(jumpNotTruthy)
+
+	if node.Alternative == nil {
+		c.emit(code.OpRegLoadNull, dst)
+	} else {
+		err := c.blockValue(node.Alternative, dst)
+		if err != nil {
+			return err
+		}
+	}
+
+	c.patchJump(jump)
+	return nil
+}
+
+func (c *RegisterCompiler) loopExpression(node *ast.LoopExpression, dst int) error {
+	if c.optimize {
+		if cond := constant(node.Condition); cond != nil {
+			if !truthy(cond) {
+				err := c.discard(node.Consequence)
+				if err != nil {
+					return err
+				}
+				c.emit(code.OpRegLoadNull, dst)
+				return nil
+			}
+
+			start := len(c.scope().instructions)
+			err := c.statements(node.Consequence.Statements)
+			if err != nil {
+				return err
+			}
+			c.emit(code.OpRegJump, start)
+			return nil
+		}
+	}
+
+	start := len(c.scope().instructions)
+	jumpNotTruthy, err := c.condition(node.Condition)
+	if err != nil {
+		return err
+	}
+
+	err = c.statements(node.Consequence.Statements)
+	if err != nil {
+		return err
+	}
+
+	c.emit(code.OpRegJump, start)
+	c.patchJump(jumpNotTruthy)
+	c.emit(code.OpRegLoadNull, dst)
+	return nil
+}
+
+// registerFusedJumps maps comparison operators to the jump taken unless
+// they hold.
+var registerFusedJumps = map[string]code.Opcode{
+	token.LessThan:       code.OpRegJumpIfGreaterOrEqual,
+	token.LessOrEqual:    code.OpRegJumpIfGreaterThan,
+	token.GreaterThan:    code.OpRegJumpIfLessOrEqual,
+	token.GreaterOrEqual: code.OpRegJumpIfLessThan,
+}
+
+// condition compiles cond followed by a jump taken if it isn't truthy, and
+// returns the position of the jump.
+func (c *RegisterCompiler) condition(cond ast.Expression) (int, error) {
+	defer c.release(c.scope().temps)
+
+	var fused code.Opcode
+	infix, ok := cond.(*ast.InfixExpression)
+	if ok {
+		fused, ok = registerFusedJumps[infix.Operator]
+	}
+	if !ok {
+		r, err := c.value(cond)
+		if err != nil {
+			return 0, err
+		}
+
+		// Emit an `OpRegJumpNotTruthy` with a bogus value
+		return c.emit(code.OpRegJumpNotTruthy, 9999, r), nil
+	}
+
+	left, err := c.operand(infix.Left, infix.Right)
+	if err != nil {
+		return 0, err
+	}
+	right, err := c.value(infix.Right)
+	if err != nil {
+		return 0, err
+	}
+
+	// Errors raised by the comparison point at its operator
+	defer c.at(infix)()
+	return c.emit(fused, 9999, left, right), nil
+}
+
+func (c *RegisterCompiler) tryExpression(node *ast.TryExpression, dst int) error {
+	// The register the raised error is stored in
+	errReg := c.temp()
+	scope := c.scope()
+
+	if node.Finally != nil {
+		scope.finally = append(scope.finally, node.Finally)
+	}
+
+	tryStart := len(scope.instructions)
+	err := c.blockValue(node.Block, dst)
+	if err != nil {
+		return err
+	}
+	tryEnd := len(scope.instructions)
+
+	// Emit an `OpRegJump` with a bogus value
+	jump := c.emit(code.OpRegJump, 9999)
+
+	handlers := []code.Handler{}
+	protectedStart, protectedEnd := tryStart, tryEnd
+
+	if node.Catch != nil {
+		catchStart := len(scope.instructions)
+		handlers = append(handlers, code.Handler{Start: tryStart, End: tryEnd, Target: catchStart, Slot: errReg})
+
+		if node.Parameter != nil {
+			c.store(c.define(node.Parameter), errReg)
+		}
+
+		err = c.blockValue(node.Catch, dst)
+		if err != nil {
+			return err
+		}
+		protectedStart, protectedEnd = catchStart, len(scope.instructions)
+	}
+
+	c.patchJump(jump)
+
+	if node.Finally != nil {
+		scope.finally = scope.finally[:len(scope.finally)-1]
+
+		err = c.statements(node.Finally.Statements)
+		if err != nil {
+			return err
+		}
+
+		// Emit an `OpRegJump` with a bogus value
+		endJump := c.emit(code.OpRegJump, 9999)
+
+		// Run the finally block for an error escaping the try (or catch)
+		// block and raise it again
+		finallyStart := len(scope.instructions)
+		handlers = append(handlers, code.Handler{Start: protectedStart, End: protectedEnd, Target: finallyStart, Slot: errReg})
+
+		err = c.statements(node.Finally.Statements)
+		if err != nil {
+			return err
+		}
+		c.emit(code.OpRegThrow, errReg)
+
+		c.patchJump(endJump)
+	}
+
+	scope.handlers = append(scope.handlers, handlers...)
+	return nil
+}
+
+// blockValue compiles a block so its value ends up in dst, the same way the
+// branches of an if expression do.
+func (c *RegisterCompiler) blockValue(block *ast.BlockStatement, dst int) error {
+	n := len(block.Statements)
+	if n == 0 {
+		c.emit(code.OpRegLoadNull, dst)
+		return nil
+	}
+
+	err := c.statements(block.Statements[:n-1])
+	if err != nil {
+		return err
+	}
+
+	last, ok := block.Statements[n-1].(*ast.ExpressionStatement)
+	if !ok {
+		err := c.statement(block.Statements[n-1])
+		if err != nil {
+			return err
+		}
+		c.emit(code.OpRegLoadNull, dst)
+		return nil
+	}
+
+	defer c.at(last)()
+	return c.expression(last.Expression, dst)
+}
+
+// functionBody compiles the statements of a function, returning the value
+// of the last one if it is an expression.
+func (c *RegisterCompiler) functionBody(statements []ast.Statement) error {
+	n := len(statements)
+	var last *ast.ExpressionStatement
+	ok := false
+	if n > 0 {
+		last, ok = statements[n-1].(*ast.ExpressionStatement)
+	}
+	if !ok {
+		err := c.statements(statements)
+		if err != nil {
+			return err
+		}
+		if c.scope().lastOpcode != code.OpRegReturn || len(c.scope().instructions) == 0 {
+			c.emit(code.OpRegReturnNull)
+		}
+		return nil
+	}
+
+	err := c.statements(statements[:n-1])
+	if err != nil {
+		return err
+	}
+
+	defer c.at(last)()
+	r, err := c.value(last.Expression)
+	if err != nil {
+		return err
+	}
+	c.emit(code.OpRegReturn, r)
+	return nil
+}
+
+// closure emits the instruction creating a closure of fn in dst, capturing
+// the free symbols.
+func (c *RegisterCompiler) closure(fn *object.CompiledFunction, free []Symbol, dst int) {
+	defer c.release(c.scope().temps)
+
+	base := c.nextTemp()
+	for _, s := range free {
+		c.load(s, c.temp())
+	}
+	c.emit(code.OpRegClosure, dst, c.addConstant(fn), base, len(free))
+}
+
+func (c *RegisterCompiler) loadModule(ident *ast.Identifier) error {
+	m := ident.Value
+	if !c.sandbox.allows(m) {
+		return fmt.Errorf("%s: module %s is not allowed", ident.Pos(), m)
+	}
+	loader := &Compiler{sandbox: c.sandbox}
+	scope := loader.loadNativeModule(m)
+
+	if sourceFile := loader.SearchSource(m); scope == nil && sourceFile != nil {
+		text, _ := ioutil.ReadFile(*sourceFile)
+		p := parser.New(lexer.NewWithFilename(string(text), *sourceFile))
+		program := p.ParseProgram()
+
+		if len(p.Errors()) != 0 {
+			return fmt.Errorf("%s: failed to parse module %s: %s", ident.Pos(), m, p.Errors()[0])
+		}
+		return c.statements(program.Statements)
+	}
+
+	if scope == nil {
+		return fmt.Errorf("%s: failed to load module %s", ident.Pos(), m)
+	}
+
+	t := c.temp()
+	c.loadConstant(scope, t)
+	c.store(c.define(ident), t)
+	return nil
+}
+
+// discard compiles node for the names it declares and throws its
+// instructions away.
+func (c *RegisterCompiler) discard(node *ast.BlockStatement) error {
+	start := len(c.scope().instructions)
+	handlers := len(c.scope().handlers)
+	constants := len(c.constants)
+
+	err := c.statements(node.Statements)
+	if err != nil {
+		return err
+	}
+
+	c.truncate(start)
+	c.scope().handlers = c.scope().handlers[:handlers]
+	c.constants = c.constants[:constants]
+	return nil
+}
+
+// truncate throws away the instructions from offset on.
+func (c *RegisterCompiler) truncate(offset int) {
+	scope := c.scope()
+	scope.instructions = scope.instructions[:offset]
+
+	lines := scope.lines
+	for len(lines) > 0 && lines[len(lines)-1].Offset >= offset {
+		lines = lines[:len(lines)-1]
+	}
+	scope.lines = lines
+}
+
+func (c *RegisterCompiler) load(s Symbol, dst int) {
+	switch s.Scope {
+	case GlobalScope:
+		c.emit(code.OpRegGetGlobal, dst, s.Index)
+	case LocalScope:
+		c.move(dst, s.Index)
+	case BuiltinScope:
+		c.emit(code.OpRegGetBuiltin, dst, s.Index)
+	case FreeScope:
+		c.emit(code.OpRegGetFree, dst, s.Index)
+	}
+}
+
+func (c *RegisterCompiler) store(s Symbol, src int) {
+	switch s.Scope {
+	case GlobalScope:
+		c.emit(code.OpRegSetGlobal, s.Index, src)
+	case LocalScope:
+		c.move(s.Index, src)
+	case FreeScope:
+		c.emit(code.OpRegSetFree, s.Index, src)
+	}
+}
+
+// register returns the register holding the value of s, loading it to a
+// temporary unless s is a local.
+func (c *RegisterCompiler) register(s Symbol) int {
+	if s.Scope == LocalScope {
+		return s.Index
+	}
+	t := c.temp()
+	c.load(s, t)
+	return t
+}
+
+func (c *RegisterCompiler) move(dst, src int) {
+	if dst != src {
+		c.emit(code.OpRegMove, dst, src)
+	}
+}
+
+func (c *RegisterCompiler) loadConstant(obj object.Object, dst int) {
+	switch obj {
+	case object.True:
+		c.emit(code.OpRegLoadTrue, dst)
+	case object.False:
+		c.emit(code.OpRegLoadFalse, dst)
+	default:
+		c.emit(code.OpRegLoadConst, dst, c.addConstant(obj))
+	}
+}
+
+func (c *RegisterCompiler) addConstant(obj object.Object) int {
+	for idx, v := range c.constants {
+		if sameConstant(obj, v) {
+			return idx
+		}
+	}
+	c.constants = append(c.constants, obj)
+	return len(c.constants) - 1
+}
+
+func (c *RegisterCompiler) emit(op code.Opcode, operands ...int) int {
+	scope := c.scope()
+	pos := len(scope.instructions)
+	scope.instructions = append(scope.instructions, code.Make(op, operands...)...)
+	scope.lastOpcode = op
+
+	if len(scope.lines) == 0 || scope.lines[len(scope.lines)-1].Pos != c.pos {
+		scope.lines = append(scope.lines, code.LineEntry{Offset: pos, Pos: c.pos})
+	}
+	return pos
+}
+
+// patchJump makes the jump at pos jump to the next instruction.
+func (c *RegisterCompiler) patchJump(pos int) {
+	ins := c.scope().instructions
+	binary.BigEndian.PutUint16(ins[pos+1:], uint16(len(ins)))
+}
+
+// at makes node the position of the instructions emitted until the
+// returned function is called.
+func (c *RegisterCompiler) at(node ast.Node) func() {
+	prev := c.pos
+	if pos := node.Pos(); pos.IsValid() {
+		c.pos = pos
+	}
+	return func() { c.pos = prev }
+}
+
+func (c *RegisterCompiler) scope() *registerScope {
+	return c.scopes[len(c.scopes)-1]
+}
+
+func (c *RegisterCompiler) temp() int {
+	scope := c.scope()
+	t := tempBase + scope.temps
+	scope.temps++
+	if scope.temps > scope.maxTemps {
+		scope.maxTemps = scope.temps
+	}
+	return t
+}
+
+// nextTemp returns the temporary temp allocates next.
+func (c *RegisterCompiler) nextTemp() int {
+	return tempBase + c.scope().temps
+}
+
+// release frees the temporaries allocated after the first mark of them.
+func (c *RegisterCompiler) release(mark int) {
+	c.scope().temps = mark
+}
+
+func (c *RegisterCompiler) enterScope() {
+	c.scopes = append(c.scopes, &registerScope{})
+	c.symbolTable = NewEnclosedSymbolTable(c.symbolTable)
+}
+
+// leaveScope finishes the function compiled in the current scope and
+// returns it along with the symbols it captures.
+func (c *RegisterCompiler) leaveScope(name string, numParameters int) (*object.CompiledFunction, []Symbol) {
+	scope := c.scope()
+	numLocals := c.symbolTable.numDefinitions
+
+	fn := &object.CompiledFunction{
+		Instructions:  renumberTemps(scope.instructions, numLocals),
+		NumLocals:     numLocals + scope.maxTemps,
+		NumParameters: numParameters,
+		Name:          name,
+		Lines:         scope.lines,
+		Handlers:      renumberHandlers(scope.handlers, numLocals),
+		LocalNames:    c.symbolTable.DefinedNames(),
+		FreeNames:     c.symbolTable.FreeNames(),
+	}
+	markRegisterTailCalls(fn.Instructions, fn.Handlers)
+	free := c.symbolTable.FreeSymbols
+
+	c.scopes = c.scopes[:len(c.scopes)-1]
+	c.symbolTable = c.symbolTable.Outer
+	return fn, free
+}
+
+func (c *RegisterCompiler) define(ident *ast.Identifier) Symbol {
+	return c.symbolTable.DefineAt(ident.Value, ident.Pos())
+}
+
+func (c *RegisterCompiler) resolve(ident *ast.Identifier) (Symbol, bool) {
+	return c.symbolTable.Resolve(ident.Value)
+}
+
+// registerOperands tells which operands of the register instructions name
+// registers.
+var registerOperands = map[code.Opcode][]bool{
+	code.OpRegLoadConst:  {true, false},
+	code.OpRegLoadTrue:   {true},
+	code.OpRegLoadFalse:  {true},
+	code.OpRegLoadNull:   {true},
+	code.OpRegMove:       {true, true},
+	code.OpRegGetGlobal:  {true, false},
+	code.OpRegSetGlobal:  {false, true},
+	code.OpRegGetFree:    {true, false},
+	code.OpRegSetFree:    {false, true},
+	code.OpRegGetBuiltin: {true, false},
+
+	code.OpRegAdd:            {true, true, true},
+	code.OpRegSub:            {true, true, true},
+	code.OpRegMul:            {true, true, true},
+	code.OpRegDiv:            {true, true, true},
+	code.OpRegMod:            {true, true, true},
+	code.OpRegAddConst:       {true, true, false},
+	code.OpRegEqual:          {true, true, true},
+	code.OpRegNotEqual:       {true, true, true},
+	code.OpRegGreaterThan:    {true, true, true},
+	code.OpRegGreaterOrEqual: {true, true, true},
+	code.OpRegLessThan:       {true, true, true},
+	code.OpRegLessOrEqual:    {true, true, true},
+	code.OpRegMinus:          {true, true},
+	code.OpRegBang:           {true, true},
+
+	code.OpRegJump:                 {false},
+	code.OpRegJumpNotTruthy:        {false, true},
+	code.OpRegJumpIfGreaterOrEqual: {false, true, true},
+	code.OpRegJumpIfGreaterThan:    {false, true, true},
+	code.OpRegJumpIfLessOrEqual:    {false, true, true},
+	code.OpRegJumpIfLessThan:       {false, true, true},
+
+	code.OpRegArray:        {true, true, false},
+	code.OpRegHash:         {true, true, false},
+	code.OpRegIndex:        {true, true, true},
+	code.OpRegIndexRange:   {true, true, true},
+	code.OpRegIndexSet:     {true, true, true},
+	code.OpRegMember:       {true, true, true},
+	code.OpRegMemberSet:    {true, true, true, true},
+	code.OpRegScopeResolve: {true, true, true},
+
+	code.OpRegCall:       {true, true, false},
+	code.OpRegTailCall:   {true, true, false},
+	code.OpRegReturn:     {true},
+	code.OpRegReturnNull: {},
+	code.OpRegClosure:    {true, false, true, false},
+	code.OpRegScope:      {true},
+	code.OpRegExport:     {false, true},
+	code.OpRegThrow:      {true},
+	code.OpRegResult:     {true},
+}
+
+// renumberTemps returns a copy of ins with the temporaries placed after
+// numLocals locals.
+func renumberTemps(ins code.Instructions, numLocals int) code.Instructions {
+	out := make(code.Instructions, 0, len(ins))
+	for i := 0; i < len(ins); {
+		op := code.Opcode(ins[i])
+		def, err := code.Lookup(ins[i])
+		if err != nil {
+			return append(out, ins[i:]...)
+		}
+		operands, read := code.ReadOperands(def, ins[i+1:])
+
+		for j, isRegister := range registerOperands[op] {
+			if isRegister && operands[j] >= tempBase {
+				operands[j] += numLocals - tempBase
+			}
+		}
+		out = append(out, code.Make(op, operands...)...)
+		i += 1 + read
+	}
+	return out
+}
+
+// markRegisterTailCalls turns every OpRegCall whose result is returned right
+// away into an OpRegTailCall, unless a handler protects the call.
+func markRegisterTailCalls(ins code.Instructions, handlers []code.Handler) {
+	callWidth := len(code.Make(code.OpRegCall, 0, 0, 0))
+
+	for i := 0; i < len(ins); {
+		def, err := code.Lookup(ins[i])
+		if err != nil {
+			return
+		}
+		_, read := code.ReadOperands(def, ins[i+1:])
+
+		if code.Opcode(ins[i]) == code.OpRegCall && !protected(i, handlers) {
+			next := i + callWidth
+			if next < len(ins) && code.Opcode(ins[next]) == code.OpRegJump {
+				next = int(code.ReadUint16(ins[next+1:]))
+			}
+			if next < len(ins) && code.Opcode(ins[next]) == code.OpRegReturn &&
+				code.ReadUint16(ins[i+1:]) == code.ReadUint16(ins[next+1:]) {
+				ins[i] = byte(code.OpRegTailCall)
+			}
+		}
+		i += 1 + read
+	}
+}
+
+func renumberHandlers(handlers []code.Handler, numLocals int) []code.Handler {
+	out := make([]code.Handler, len(handlers))
+	for i, h := range handlers {
+		h.Slot += numLocals - tempBase
+		out[i] = h
+	}
+	return out
+}
@@ -0,0 +1,90 @@
+package compiler
+
+import (
+	"testing"
+
+	"github.com/dreblang/core/code"
+)
+
+func TestRegisterCompiler(t *testing.T) {
+	tests := []compilerTestCase{
+		{
+			input:             `let a = 1; let b = a + 2; b * a`,
+			expectedConstants: []interface{}{1, 2},
+			expectedInstructions: []code.Instructions{
+				code.Make(code.OpRegLoadConst, 0, 0),
+				code.Make(code.OpRegSetGlobal, 0, 0),
+				code.Make(code.OpRegGetGlobal, 1, 0),
+				code.Make(code.OpRegAddConst, 0, 1, 1),
+				code.Make(code.OpRegSetGlobal, 1, 0),
+				code.Make(code.OpRegGetGlobal, 1, 1),
+				code.Make(code.OpRegGetGlobal, 2, 0),
+				code.Make(code.OpRegMul, 0, 1, 2),
+				code.Make(code.OpRegResult, 0),
+			},
+		},
+		{
+			// Locals live in registers, the call arguments follow the callee,
+			// which shares its register with the result
+			input: `let f = fn(x) { let y = x + 1; y }; f(2)`,
+			expectedConstants: []interface{}{
+				1,
+				[]code.Instructions{
+					code.Make(code.OpRegAddConst, 1, 0, 0),
+					code.Make(code.OpRegReturn, 1),
+				},
+				2,
+			},
+			expectedInstructions: []code.Instructions{
+				code.Make(code.OpRegClosure, 0, 1, 1, 0),
+				code.Make(code.OpRegSetGlobal, 0, 0),
+				code.Make(code.OpRegGetGlobal, 0, 0),
+				code.Make(code.OpRegLoadConst, 1, 2),
+				code.Make(code.OpRegCall, 0, 0, 1),
+				code.Make(code.OpRegResult, 0),
+			},
+		},
+		{
+			input: `let f = fn(n) { let i = 0; loop (i < n) { i = i + 1 } }`,
+			expectedConstants: []interface{}{
+				0,
+				1,
+				[]code.Instructions{
+					code.Make(code.OpRegLoadConst, 1, 0),
+					code.Make(code.OpRegJumpIfGreaterOrEqual, 22, 1, 0),
+					code.Make(code.OpRegAddConst, 1, 1, 1),
+					code.Make(code.OpRegJump, 5),
+					code.Make(code.OpRegLoadNull, 2),
+					code.Make(code.OpRegReturn, 2),
+				},
+			},
+			expectedInstructions: []code.Instructions{
+				code.Make(code.OpRegClosure, 0, 2, 1, 0),
+				code.Make(code.OpRegSetGlobal, 0, 0),
+			},
+		},
+	}
+
+	for _, tt := range tests {
+		compiler := NewRegister()
+		err := compiler.Compile(parse(tt.input))
+		if err != nil {
+			t.Fatalf("compiler error: %s", err)
+		}
+
+		bytecode := compiler.Bytecode()
+		if bytecode.Registers == 0 {
+			t.Errorf("register bytecode has no registers")
+		}
+
+		err = testInstructions(tt.expectedInstructions, bytecode.Instructions)
+		if err != nil {
+			t.Fatalf("testInstructions failed: %s", err)
+		}
+
+		err = testConstants(t, tt.expectedConstants, bytecode.Constants)
+		if err != nil {
+			t.Fatalf("testConstants failed: %s", err)
+		}
+	}
+}
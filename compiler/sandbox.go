@@ -0,0 +1,27 @@
+package compiler
+
+import "slices"
+
+// Sandbox restricts what a compiler loads when compiling untrusted source.
+// Native plugins are never loaded in a sandbox.
+type Sandbox struct {
+	// Modules are the names of the modules scripts may load, either core
+	// modules or source modules on the search path.
+	Modules []string
+}
+
+// allows reports whether scripts may load module m. Without a sandbox they
+// may load anything.
+func (s *Sandbox) allows(m string) bool {
+	return s == nil || slices.Contains(s.Modules, m)
+}
+
+// UseSandbox restricts the modules the compiler loads to those s allows.
+func (c *Compiler) UseSandbox(s Sandbox) {
+	c.sandbox = &s
+}
+
+// UseSandbox restricts the modules the compiler loads to those s allows.
+func (c *RegisterCompiler) UseSandbox(s Sandbox) {
+	c.sandbox = &s
+}
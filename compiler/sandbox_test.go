@@ -0,0 +1,65 @@
+package compiler
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/dreblang/core/object"
+)
+
+func TestSandbox(t *testing.T) {
+	RegisterLib("sandboxcore", func() *object.Scope {
+		return &object.Scope{Exports: map[string]object.Object{}}
+	})
+
+	// A plugin and a source module on the search path
+	dir := t.TempDir()
+	if err := os.WriteFile(filepath.Join(dir, "native.so"), nil, 0o644); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.WriteFile(filepath.Join(dir, "util.dreb"), []byte("let x = 1;"), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	t.Setenv("DREB_PATH", dir)
+
+	sandbox := Sandbox{Modules: []string{"sandboxcore", "util", "native"}}
+
+	tests := []struct {
+		input    string
+		expected string
+	}{
+		{`load sandboxcore; sandboxcore`, ""},
+		{`load util; x`, ""},
+		{`load missing`, "1:6: module missing is not allowed"},
+		// Plugins can't be found, let alone opened
+		{`load native`, "1:6: failed to load module native"},
+	}
+
+	for _, tt := range tests {
+		comp := New()
+		comp.UseSandbox(sandbox)
+		testSandboxError(t, "stack", tt.expected, comp.Compile(parse(tt.input)))
+
+		reg := NewRegister()
+		reg.UseSandbox(sandbox)
+		testSandboxError(t, "register", tt.expected, reg.Compile(parse(tt.input)))
+	}
+
+	if New().SearchPlugin("native") == nil {
+		t.Errorf("plugin not found without a sandbox")
+	}
+}
+
+func testSandboxError(t *testing.T, name, expected string, err error) {
+	t.Helper()
+
+	switch {
+	case expected == "" && err != nil:
+		t.Errorf("compiler error (%s): %s", name, err)
+	case expected != "" && err == nil:
+		t.Errorf("expected error (%s) %q. got none", name, expected)
+	case expected != "" && err.Error() != expected:
+		t.Errorf("wrong error (%s). want=%q, got=%q", name, expected, err)
+	}
+}
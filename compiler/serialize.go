@@ -0,0 +1,412 @@
+package compiler
+
+import (
+	"bytes"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"math"
+
+	"github.com/dreblang/core/code"
+	"github.com/dreblang/core/object"
+	"github.com/dreblang/core/token"
+)
+
+// BytecodeMagic starts every serialized Bytecode file.
+const BytecodeMagic = "DREB"
+
+// BytecodeVersion is bumped whenever the serialized layout or the
+// instruction set changes.
+const BytecodeVersion uint16 = 5
+
+// Constant tags in the serialized constant pool.
+const (
+	tagNull byte = iota
+	tagInteger
+	tagFloat
+	tagString
+	tagBytes
+	tagBoolean
+	tagFunction
+	tagModule
+)
+
+// ErrNotBytecode is returned when data lacks the bytecode magic.
+var ErrNotBytecode = errors.New("not a dreblang bytecode file")
+
+// IsBytecode reports whether data starts with the bytecode magic.
+func IsBytecode(data []byte) bool {
+	return bytes.HasPrefix(data, []byte(BytecodeMagic))
+}
+
+// MarshalBinary encodes b in the versioned bytecode format.
+//
+// Native modules in the constant pool are stored by name and loaded again
+// by Unmarshal.
+func (b *Bytecode) MarshalBinary() ([]byte, error) {
+	w := &bytecodeWriter{}
+	w.buf.WriteString(BytecodeMagic)
+	w.uint16(BytecodeVersion)
+	w.uint32(b.Registers)
+
+	w.function(b.mainFunction())
+
+	w.uint32(len(b.Constants))
+	for i, obj := range b.Constants {
+		if err := w.constant(obj); err != nil {
+			return nil, fmt.Errorf("constant %d: %w", i, err)
+		}
+	}
+
+	return w.buf.Bytes(), nil
+}
+
+// UnmarshalBinary decodes data produced by MarshalBinary into b.
+func (b *Bytecode) UnmarshalBinary(data []byte) error {
+	return b.unmarshal(data, nil)
+}
+
+// unmarshal decodes data into b, loading only the modules sandbox allows.
+func (b *Bytecode) unmarshal(data []byte, sandbox *Sandbox) error {
+	if !IsBytecode(data) {
+		return ErrNotBytecode
+	}
+
+	r := &bytecodeReader{data: data, off: len(BytecodeMagic), sandbox: sandbox}
+	if version := r.uint16(); r.err == nil && version != BytecodeVersion {
+		return fmt.Errorf("unsupported bytecode version %d (want %d)", version, BytecodeVersion)
+	}
+
+	registers := r.uint32()
+
+	main := r.function()
+
+	count := r.count()
+	if r.err != nil {
+		return r.err
+	}
+	constants := make([]object.Object, 0, count)
+	for i := 0; i < count; i++ {
+		obj := r.constant()
+		if r.err != nil {
+			return fmt.Errorf("constant %d: %w", i, r.err)
+		}
+		constants = append(constants, obj)
+	}
+	if r.err == nil && r.off != len(r.data) {
+		r.err = fmt.Errorf("%d trailing bytes", len(r.data)-r.off)
+	}
+	if r.err != nil {
+		return r.err
+	}
+
+	loaded := Bytecode{
+		Instructions: main.Instructions,
+		Constants:    constants,
+		Lines:        main.Lines,
+		Handlers:     main.Handlers,
+		GlobalNames:  main.LocalNames,
+		Registers:    registers,
+	}
+	if err := loaded.verify(); err != nil {
+		return err
+	}
+
+	*b = loaded
+	return nil
+}
+
+// Unmarshal decodes serialized bytecode. The instructions of every function
+// are verified, so corrupt bytecode fails here rather than in the VM.
+func Unmarshal(data []byte) (*Bytecode, error) {
+	b := &Bytecode{}
+	if err := b.UnmarshalBinary(data); err != nil {
+		return nil, err
+	}
+	return b, nil
+}
+
+// UnmarshalWithSandbox decodes and verifies serialized bytecode like
+// Unmarshal, failing if it loads a module s doesn't allow. Native plugins are
+// never loaded.
+func UnmarshalWithSandbox(data []byte, s Sandbox) (*Bytecode, error) {
+	b := &Bytecode{}
+	if err := b.unmarshal(data, &s); err != nil {
+		return nil, err
+	}
+	return b, nil
+}
+
+// mainFunction wraps the top level of b so it serializes like any other
+// function, with the global names stored as its local names.
+func (b *Bytecode) mainFunction() *object.CompiledFunction {
+	return &object.CompiledFunction{
+		Instructions: b.Instructions,
+		Lines:        b.Lines,
+		Handlers:     b.Handlers,
+		LocalNames:   b.GlobalNames,
+	}
+}
+
+type bytecodeWriter struct {
+	buf bytes.Buffer
+}
+
+func (w *bytecodeWriter) uint16(v uint16) {
+	var b [2]byte
+	binary.BigEndian.PutUint16(b[:], v)
+	w.buf.Write(b[:])
+}
+
+func (w *bytecodeWriter) uint32(v int) {
+	var b [4]byte
+	binary.BigEndian.PutUint32(b[:], uint32(v))
+	w.buf.Write(b[:])
+}
+
+func (w *bytecodeWriter) uint64(v uint64) {
+	var b [8]byte
+	binary.BigEndian.PutUint64(b[:], v)
+	w.buf.Write(b[:])
+}
+
+func (w *bytecodeWriter) bytes(v []byte) {
+	w.uint32(len(v))
+	w.buf.Write(v)
+}
+
+func (w *bytecodeWriter) string(v string) {
+	w.uint32(len(v))
+	w.buf.WriteString(v)
+}
+
+func (w *bytecodeWriter) strings(v []string) {
+	w.uint32(len(v))
+	for _, s := range v {
+		w.string(s)
+	}
+}
+
+func (w *bytecodeWriter) function(fn *object.CompiledFunction) {
+	w.bytes(fn.Instructions)
+
+	w.uint32(len(fn.Lines))
+	for _, entry := range fn.Lines {
+		w.uint32(entry.Offset)
+		w.string(entry.Pos.Filename)
+		w.uint32(entry.Pos.Line)
+		w.uint32(entry.Pos.Column)
+	}
+
+	w.uint32(len(fn.Handlers))
+	for _, h := range fn.Handlers {
+		w.uint32(h.Start)
+		w.uint32(h.End)
+		w.uint32(h.Target)
+		w.uint32(h.Slot)
+	}
+
+	w.strings(fn.LocalNames)
+	w.strings(fn.FreeNames)
+}
+
+func (w *bytecodeWriter) constant(obj object.Object) error {
+	switch obj := obj.(type) {
+	case *object.Null:
+		w.buf.WriteByte(tagNull)
+	case *object.Integer:
+		w.buf.WriteByte(tagInteger)
+		w.uint64(uint64(obj.Value))
+	case *object.Float:
+		w.buf.WriteByte(tagFloat)
+		w.uint64(math.Float64bits(obj.Value))
+	case *object.String:
+		w.buf.WriteByte(tagString)
+		w.string(obj.Value)
+	case *object.Bytes:
+		w.buf.WriteByte(tagBytes)
+		w.bytes(obj.Value)
+	case *object.Boolean:
+		w.buf.WriteByte(tagBoolean)
+		if obj.Value {
+			w.buf.WriteByte(1)
+		} else {
+			w.buf.WriteByte(0)
+		}
+	case *object.CompiledFunction:
+		w.buf.WriteByte(tagFunction)
+		w.string(obj.Name)
+		w.uint32(obj.NumLocals)
+		w.uint32(obj.NumParameters)
+		w.function(obj)
+	case *object.Scope:
+		if obj.Name == "" {
+			return errors.New("cannot serialize unnamed scope")
+		}
+		w.buf.WriteByte(tagModule)
+		w.string(obj.Name)
+	default:
+		return fmt.Errorf("cannot serialize constant of type %s", obj.Type())
+	}
+	return nil
+}
+
+type bytecodeReader struct {
+	data    []byte
+	off     int
+	err     error
+	sandbox *Sandbox
+}
+
+func (r *bytecodeReader) next(n int) []byte {
+	if r.err != nil {
+		return nil
+	}
+	if n < 0 || len(r.data)-r.off < n {
+		r.err = errors.New("unexpected end of bytecode")
+		return nil
+	}
+	b := r.data[r.off : r.off+n]
+	r.off += n
+	return b
+}
+
+func (r *bytecodeReader) byte() byte {
+	b := r.next(1)
+	if b == nil {
+		return 0
+	}
+	return b[0]
+}
+
+func (r *bytecodeReader) uint16() uint16 {
+	b := r.next(2)
+	if b == nil {
+		return 0
+	}
+	return binary.BigEndian.Uint16(b)
+}
+
+func (r *bytecodeReader) uint32() int {
+	b := r.next(4)
+	if b == nil {
+		return 0
+	}
+	return int(binary.BigEndian.Uint32(b))
+}
+
+func (r *bytecodeReader) uint64() uint64 {
+	b := r.next(8)
+	if b == nil {
+		return 0
+	}
+	return binary.BigEndian.Uint64(b)
+}
+
+// count reads the number of items that follow. Every item takes at least a
+// byte, so a count larger than the bytes left is corrupt and fails here,
+// before anything is allocated for it.
+func (r *bytecodeReader) count() int {
+	n := r.uint32()
+	if r.err == nil && n > len(r.data)-r.off {
+		r.err = fmt.Errorf("count %d exceeds the %d bytes left", n, len(r.data)-r.off)
+		return 0
+	}
+	return n
+}
+
+func (r *bytecodeReader) bytes() []byte {
+	b := r.next(r.uint32())
+	if b == nil {
+		return nil
+	}
+	return append([]byte{}, b...)
+}
+
+func (r *bytecodeReader) string() string {
+	return string(r.next(r.uint32()))
+}
+
+func (r *bytecodeReader) strings() []string {
+	var v []string
+	for n := r.count(); r.err == nil && n > 0; n-- {
+		v = append(v, r.string())
+	}
+	return v
+}
+
+// function reads the body of a function; the header fields are left to the
+// caller.
+func (r *bytecodeReader) function() *object.CompiledFunction {
+	fn := &object.CompiledFunction{Instructions: r.bytes()}
+
+	for n := r.count(); r.err == nil && n > 0; n-- {
+		entry := code.LineEntry{Offset: r.uint32()}
+		entry.Pos = token.Position{
+			Filename: r.string(),
+			Line:     r.uint32(),
+			Column:   r.uint32(),
+		}
+		fn.Lines = append(fn.Lines, entry)
+	}
+
+	for n := r.count(); r.err == nil && n > 0; n-- {
+		fn.Handlers = append(fn.Handlers, code.Handler{
+			Start:  r.uint32(),
+			End:    r.uint32(),
+			Target: r.uint32(),
+			Slot:   r.uint32(),
+		})
+	}
+
+	fn.LocalNames = r.strings()
+	fn.FreeNames = r.strings()
+	return fn
+}
+
+func (r *bytecodeReader) constant() object.Object {
+	switch tag := r.byte(); tag {
+	case tagNull:
+		return object.NullObject
+	case tagInteger:
+		return &object.Integer{Value: int64(r.uint64())}
+	case tagFloat:
+		return &object.Float{Value: math.Float64frombits(r.uint64())}
+	case tagString:
+		return &object.String{Value: r.string()}
+	case tagBytes:
+		return &object.Bytes{Value: r.bytes()}
+	case tagBoolean:
+		return object.NativeBoolToBooleanObject(r.byte() != 0)
+	case tagFunction:
+		name := r.string()
+		numLocals := r.uint32()
+		numParameters := r.uint32()
+		fn := r.function()
+		fn.Name = name
+		fn.NumLocals = numLocals
+		fn.NumParameters = numParameters
+		return fn
+	case tagModule:
+		name := r.string()
+		if r.err != nil {
+			return nil
+		}
+		if !r.sandbox.allows(name) {
+			r.err = fmt.Errorf("module %s is not allowed", name)
+			return nil
+		}
+		c := New()
+		c.sandbox = r.sandbox
+		scope := c.loadNativeModule(name)
+		if scope == nil {
+			r.err = fmt.Errorf("failed to load module %s", name)
+		}
+		return scope
+	default:
+		if r.err == nil {
+			r.err = fmt.Errorf("unknown constant tag %d", tag)
+		}
+		return nil
+	}
+}
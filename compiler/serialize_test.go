@@ -0,0 +1,355 @@
+package compiler
+
+import (
+	"bytes"
+	"encoding/binary"
+	"math"
+	"strings"
+	"testing"
+
+	"github.com/dreblang/core/code"
+	"github.com/dreblang/core/lexer"
+	"github.com/dreblang/core/object"
+	"github.com/dreblang/core/parser"
+)
+
+func TestBytecodeRoundTrip(t *testing.T) {
+	input := `let a = [1, 2, 3];
+let half = 0.5;
+let greet = fn(name) { "hello " + name };
+let outer = fn(x) {
+	let inner = fn(y) { x + y };
+	try { inner(1) } catch (e) { e.message } finally { half }
+};
+a[0, 2];
+a[,, -1];
+outer(greet("dreb"));`
+
+	p := parser.New(lexer.NewWithFilename(input, "main.dreb"))
+	program := p.ParseProgram()
+	if len(p.Errors()) != 0 {
+		t.Fatalf("parser errors: %v", p.Errors())
+	}
+
+	compiler := New()
+	if err := compiler.Compile(program); err != nil {
+		t.Fatalf("compiler error: %s", err)
+	}
+	registerCompiler := NewRegister()
+	if err := registerCompiler.Compile(program); err != nil {
+		t.Fatalf("register compiler error: %s", err)
+	}
+
+	for _, bytecode := range []*Bytecode{compiler.Bytecode(), registerCompiler.Bytecode()} {
+		data, err := bytecode.MarshalBinary()
+		if err != nil {
+			t.Fatalf("MarshalBinary failed: %s", err)
+		}
+		if !IsBytecode(data) {
+			t.Fatalf("serialized bytecode does not start with magic")
+		}
+
+		loaded, err := Unmarshal(data)
+		if err != nil {
+			t.Fatalf("Unmarshal failed: %s", err)
+		}
+		testBytecodeEqual(t, bytecode, loaded)
+
+		again, err := loaded.MarshalBinary()
+		if err != nil {
+			t.Fatalf("MarshalBinary of loaded bytecode failed: %s", err)
+		}
+		if !bytes.Equal(data, again) {
+			t.Errorf("serialization is not stable")
+		}
+	}
+}
+
+func TestBytecodeConstantKinds(t *testing.T) {
+	bytecode := &Bytecode{
+		Instructions: code.Make(code.OpConstant, 0),
+		Constants: []object.Object{
+			&object.Integer{Value: -42},
+			&object.Float{Value: 3.25},
+			&object.String{Value: "str"},
+			&object.Bytes{Value: []byte{0, 1, 255}},
+			object.True,
+			object.False,
+			object.NullObject,
+			&object.CompiledFunction{
+				Instructions:  append(code.Make(code.OpTry, 0), code.Make(code.OpReturn)...),
+				NumLocals:     2,
+				NumParameters: 1,
+				Name:          "fn",
+				Handlers:      []code.Handler{{Start: 3, End: 4, Target: 4, Slot: 0}},
+				LocalNames:    []string{"a", "b"},
+				FreeNames:     []string{"c"},
+			},
+		},
+	}
+
+	data, err := bytecode.MarshalBinary()
+	if err != nil {
+		t.Fatalf("MarshalBinary failed: %s", err)
+	}
+	loaded, err := Unmarshal(data)
+	if err != nil {
+		t.Fatalf("Unmarshal failed: %s", err)
+	}
+	testBytecodeEqual(t, bytecode, loaded)
+
+	if loaded.Constants[4] != object.True || loaded.Constants[5] != object.False {
+		t.Errorf("booleans are not the shared objects")
+	}
+	if loaded.Constants[6] != object.NullObject {
+		t.Errorf("null is not the shared object")
+	}
+}
+
+func TestUnmarshalErrors(t *testing.T) {
+	bytecode := &Bytecode{
+		Instructions: code.Make(code.OpConstant, 0),
+		Constants:    []object.Object{&object.String{Value: "value"}},
+	}
+	data, err := bytecode.MarshalBinary()
+	if err != nil {
+		t.Fatalf("MarshalBinary failed: %s", err)
+	}
+
+	badVersion := append([]byte{}, data...)
+	badVersion[len(BytecodeMagic)+1]++
+
+	// An empty main function followed by a count of 2^32-1 constants, and
+	// the same count for the line entries of the main function
+	header := binary.BigEndian.AppendUint16([]byte(BytecodeMagic), BytecodeVersion)
+	hugeConstants := append(append([]byte{}, header...), make([]byte, 24)...)
+	hugeConstants = binary.BigEndian.AppendUint32(hugeConstants, math.MaxUint32)
+	hugeLines := append(append([]byte{}, header...), make([]byte, 8)...)
+	hugeLines = binary.BigEndian.AppendUint32(hugeLines, math.MaxUint32)
+
+	tests := []struct {
+		data     []byte
+		expected string
+	}{
+		{[]byte("let a = 1;"), "not a dreblang bytecode file"},
+		{badVersion, "unsupported bytecode version 6 (want 5)"},
+		{data[:len(data)-1], "constant 0: unexpected end of bytecode"},
+		{append(append([]byte{}, data...), 0), "1 trailing bytes"},
+		{hugeConstants, "count 4294967295 exceeds the 0 bytes left"},
+		{hugeLines, "count 4294967295 exceeds the 0 bytes left"},
+	}
+
+	for _, tt := range tests {
+		_, err := Unmarshal(tt.data)
+		if err == nil {
+			t.Errorf("expected error %q, got none", tt.expected)
+			continue
+		}
+		if err.Error() != tt.expected {
+			t.Errorf("wrong error. want=%q, got=%q", tt.expected, err.Error())
+		}
+	}
+
+	_, err = (&Bytecode{Constants: []object.Object{&object.Array{}}}).MarshalBinary()
+	if err == nil || !strings.Contains(err.Error(), "cannot serialize constant of type Array") {
+		t.Errorf("wrong marshal error. got=%v", err)
+	}
+}
+
+func TestUnmarshalCorruptInstructions(t *testing.T) {
+	badFunction := &object.CompiledFunction{Instructions: code.Instructions{255}}
+
+	tests := []struct {
+		bytecode *Bytecode
+		expected string
+	}{
+		{
+			&Bytecode{Instructions: code.Make(code.OpConstant, 500)},
+			"main function: offset 0: OpConstant: constant 500 out of range",
+		},
+		{
+			&Bytecode{Instructions: code.Instructions{255}},
+			"main function: offset 0: unknown opcode 255",
+		},
+		{
+			&Bytecode{Instructions: code.Make(code.OpRegLoadNull, 0)},
+			"main function: offset 0: unknown opcode 52",
+		},
+		{
+			&Bytecode{Instructions: code.Make(code.OpConstant, 0)[:2], Constants: []object.Object{object.NullObject}},
+			"main function: offset 0: OpConstant: missing operands",
+		},
+		{
+			&Bytecode{Instructions: code.Make(code.OpJump, 1)},
+			"main function: offset 0: OpJump: offset 1 is not an instruction",
+		},
+		{
+			&Bytecode{Instructions: code.Make(code.OpJump, 500)},
+			"main function: offset 0: OpJump: offset 500 is not an instruction",
+		},
+		{
+			&Bytecode{Instructions: code.Make(code.OpClosure, 0, 0), Constants: []object.Object{object.NullObject}},
+			"main function: offset 0: OpClosure: constant 0 is not a function",
+		},
+		{
+			&Bytecode{Instructions: code.Make(code.OpNull), Handlers: []code.Handler{{Start: 0, End: 10, Target: 1}}},
+			"main function: handler 0: offset 10 is not an instruction",
+		},
+		{
+			&Bytecode{Instructions: code.Make(code.OpNull), Handlers: []code.Handler{{Start: 1, End: 0, Target: 1}}},
+			"main function: handler 0: start 1 is after end 0",
+		},
+		{
+			&Bytecode{Constants: []object.Object{badFunction}},
+			"constant 0: offset 0: unknown opcode 255",
+		},
+		{
+			&Bytecode{Instructions: code.Make(code.OpRegExport, 0, 0), Constants: []object.Object{object.NullObject}, Registers: 1},
+			"main function: offset 0: OpRegExport: constant 0 is not a String",
+		},
+		{
+			&Bytecode{Instructions: code.Make(code.OpGetLocal, 0)},
+			"main function: offset 0: OpGetLocal: local 0 out of range",
+		},
+		{
+			&Bytecode{Instructions: code.Make(code.OpGetGlobal, 1), GlobalNames: []string{"a"}},
+			"main function: offset 0: OpGetGlobal: global 1 out of range",
+		},
+		{
+			&Bytecode{Instructions: code.Make(code.OpGetFree, 0)},
+			"main function: offset 0: OpGetFree: free variable 0 out of range",
+		},
+		{
+			&Bytecode{Instructions: code.Make(code.OpGetBuiltin, 255)},
+			"main function: offset 0: OpGetBuiltin: builtin 255 out of range",
+		},
+		{
+			&Bytecode{Instructions: code.Make(code.OpClosure, 0, 2), Constants: []object.Object{&object.CompiledFunction{}}},
+			"main function: offset 0: OpClosure: 2 free variables for a function with 0",
+		},
+		{
+			&Bytecode{Instructions: code.Make(code.OpArray, 500)},
+			"main function: offset 0: OpArray: pops 500 values from a stack of 0",
+		},
+		{
+			&Bytecode{Instructions: concatInstructions([]code.Instructions{code.Make(code.OpNull), code.Make(code.OpHash, 1)})},
+			"main function: offset 1: OpHash: odd number of keys and values 1",
+		},
+		{
+			&Bytecode{Instructions: code.Make(code.OpTry, 0)},
+			"main function: offset 0: OpTry: slot 0 out of range",
+		},
+		{
+			&Bytecode{Instructions: code.Make(code.OpNull), Handlers: []code.Handler{{Start: 0, End: 1, Target: 1, Slot: 3}}},
+			"main function: handler 0: no OpTry for slot 3",
+		},
+		{
+			&Bytecode{Instructions: code.Make(code.OpRegLoadNull, 1), Registers: 1},
+			"main function: offset 0: OpRegLoadNull: register 1 out of range",
+		},
+		{
+			&Bytecode{Instructions: code.Make(code.OpRegCall, 0, 0, 3), Registers: 2},
+			"main function: offset 0: OpRegCall: register 3 out of range",
+		},
+		{
+			&Bytecode{Instructions: code.Make(code.OpRegReturnNull), Handlers: []code.Handler{{Start: 0, End: 1, Target: 1, Slot: 4}}, Registers: 1},
+			"main function: handler 0: register 4 out of range",
+		},
+		{
+			&Bytecode{Constants: []object.Object{&object.CompiledFunction{NumParameters: 1}}},
+			"constant 0: 1 parameters but 0 locals",
+		},
+	}
+
+	for i, tt := range tests {
+		data, err := tt.bytecode.MarshalBinary()
+		if err != nil {
+			t.Fatalf("tests[%d] - MarshalBinary failed: %s", i, err)
+		}
+		_, err = Unmarshal(data)
+		if err == nil || err.Error() != tt.expected {
+			t.Errorf("tests[%d] - wrong error. want=%q, got=%v", i, tt.expected, err)
+		}
+		_, err = UnmarshalWithSandbox(data, Sandbox{})
+		if err == nil || err.Error() != tt.expected {
+			t.Errorf("tests[%d] - wrong error in a sandbox. want=%q, got=%v", i, tt.expected, err)
+		}
+	}
+}
+
+func TestUnmarshalWithSandboxHugeCount(t *testing.T) {
+	// 34 bytes claiming 2^32-1 constants after an empty main function
+	data := binary.BigEndian.AppendUint16([]byte(BytecodeMagic), BytecodeVersion)
+	data = append(data, make([]byte, 24)...)
+	data = binary.BigEndian.AppendUint32(data, math.MaxUint32)
+
+	_, err := UnmarshalWithSandbox(data, Sandbox{})
+	if err == nil || err.Error() != "count 4294967295 exceeds the 0 bytes left" {
+		t.Errorf("wrong error. got=%v", err)
+	}
+}
+
+func testBytecodeEqual(t *testing.T, expected, actual *Bytecode) {
+	t.Helper()
+
+	testFunctionEqual(t, "<main>", expected.mainFunction(), actual.mainFunction())
+	if actual.Registers != expected.Registers {
+		t.Errorf("wrong number of registers. want=%d, got=%d", expected.Registers, actual.Registers)
+	}
+
+	if len(actual.Constants) != len(expected.Constants) {
+		t.Fatalf("wrong number of constants. want=%d, got=%d", len(expected.Constants), len(actual.Constants))
+	}
+	for i, want := range expected.Constants {
+		got := actual.Constants[i]
+		if got.Type() != want.Type() {
+			t.Errorf("constant %d has wrong type. want=%s, got=%s", i, want.Type(), got.Type())
+			continue
+		}
+		switch want := want.(type) {
+		case *object.CompiledFunction:
+			testFunctionEqual(t, want.Name, want, got.(*object.CompiledFunction))
+		default:
+			if got.Inspect() != want.Inspect() {
+				t.Errorf("constant %d wrong. want=%s, got=%s", i, want.Inspect(), got.Inspect())
+			}
+		}
+	}
+}
+
+func testFunctionEqual(t *testing.T, name string, expected, actual *object.CompiledFunction) {
+	t.Helper()
+
+	if actual.Instructions.String() != expected.Instructions.String() {
+		t.Errorf("%s: wrong instructions.\nwant=%q\ngot =%q", name, expected.Instructions, actual.Instructions)
+	}
+	if actual.Name != expected.Name || actual.NumLocals != expected.NumLocals || actual.NumParameters != expected.NumParameters {
+		t.Errorf("%s: wrong function header. want=%q/%d/%d, got=%q/%d/%d", name,
+			expected.Name, expected.NumLocals, expected.NumParameters,
+			actual.Name, actual.NumLocals, actual.NumParameters)
+	}
+	if len(actual.Lines) != len(expected.Lines) {
+		t.Errorf("%s: wrong number of line entries. want=%d, got=%d", name, len(expected.Lines), len(actual.Lines))
+	} else {
+		for i, entry := range expected.Lines {
+			if actual.Lines[i] != entry {
+				t.Errorf("%s: wrong line entry %d. want=%+v, got=%+v", name, i, entry, actual.Lines[i])
+			}
+		}
+	}
+	if strings.Join(actual.LocalNames, ",") != strings.Join(expected.LocalNames, ",") {
+		t.Errorf("%s: wrong local names. want=%v, got=%v", name, expected.LocalNames, actual.LocalNames)
+	}
+	if strings.Join(actual.FreeNames, ",") != strings.Join(expected.FreeNames, ",") {
+		t.Errorf("%s: wrong free names. want=%v, got=%v", name, expected.FreeNames, actual.FreeNames)
+	}
+	if len(actual.Handlers) != len(expected.Handlers) {
+		t.Errorf("%s: wrong number of handlers. want=%d, got=%d", name, len(expected.Handlers), len(actual.Handlers))
+	} else {
+		for i, h := range expected.Handlers {
+			if actual.Handlers[i] != h {
+				t.Errorf("%s: wrong handler %d. want=%+v, got=%+v", name, i, h, actual.Handlers[i])
+			}
+		}
+	}
+}
@@ -1,6 +1,9 @@
 package compiler
 
-import "github.com/dreblang/core/object"
+import (
+	"github.com/dreblang/core/object"
+	"github.com/dreblang/core/token"
+)
 
 type SymbolScope string
 
@@ -23,13 +26,16 @@ type SymbolTable struct {
 	store          map[string]Symbol
 	numDefinitions int
 
+	// Source positions of the symbols defined through DefineAt
+	definitions map[string]token.Position
+
 	FreeSymbols []Symbol
 }
 
 func NewSymbolTable() *SymbolTable {
 	s := make(map[string]Symbol)
 	free := []Symbol{}
-	st := &SymbolTable{store: s, FreeSymbols: free}
+	st := &SymbolTable{store: s, FreeSymbols: free, definitions: map[string]token.Position{}}
 
 	for i, v := range object.Builtins {
 		st.DefineBuiltin(i, v.Name)
@@ -61,6 +67,32 @@ func (s *SymbolTable) Define(name string) Symbol {
 	return symbol
 }
 
+// DefineAt defines name like Define, and records pos as its definition if
+// the name is new.
+func (s *SymbolTable) DefineAt(name string, pos token.Position) Symbol {
+	before := s.numDefinitions
+	symbol := s.Define(name)
+	if s.numDefinitions > before {
+		s.definitions[name] = pos
+	}
+	return symbol
+}
+
+// Definition returns where the symbol that name resolves to from s was
+// defined. Builtins and symbols defined without a position report false.
+func (s *SymbolTable) Definition(name string) (token.Position, bool) {
+	for t := s; t != nil; t = t.Outer {
+		symbol, ok := t.store[name]
+		if !ok || symbol.Scope == FreeScope {
+			continue
+		}
+
+		pos, ok := t.definitions[name]
+		return pos, ok
+	}
+	return token.Position{}, false
+}
+
 func (s *SymbolTable) DefineBuiltin(index int, name string) Symbol {
 	symbol := Symbol{Name: name, Index: index, Scope: BuiltinScope}
 	s.store[name] = symbol
@@ -94,3 +126,28 @@ func (s *SymbolTable) defineFree(original Symbol) Symbol {
 	s.store[original.Name] = symbol
 	return symbol
 }
+
+// DefinedNames returns the names of the symbols defined in s, indexed by
+// their global or local slot.
+func (s *SymbolTable) DefinedNames() []string {
+	names := make([]string, s.numDefinitions)
+	for name, symbol := range s.store {
+		if symbol.Scope != GlobalScope && symbol.Scope != LocalScope {
+			continue
+		}
+		if symbol.Index < len(names) {
+			names[symbol.Index] = name
+		}
+	}
+	return names
+}
+
+// FreeNames returns the names of the free symbols of s, indexed by their
+// free slot.
+func (s *SymbolTable) FreeNames() []string {
+	names := make([]string, len(s.FreeSymbols))
+	for i, symbol := range s.FreeSymbols {
+		names[i] = symbol.Name
+	}
+	return names
+}
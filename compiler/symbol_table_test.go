@@ -1,6 +1,10 @@
 package compiler
 
-import "testing"
+import (
+	"testing"
+
+	"github.com/dreblang/core/token"
+)
 
 func TestDefine(t *testing.T) {
 	expected := map[string]Symbol{
@@ -292,3 +296,32 @@ func TestResolveUnresolvableFree(t *testing.T) {
 		}
 	}
 }
+
+func TestDefinition(t *testing.T) {
+	global := NewSymbolTable()
+	global.DefineAt("a", token.Position{Filename: "main.dreb", Line: 1, Column: 5})
+	global.Define("b")
+
+	local := NewEnclosedSymbolTable(global)
+	local.DefineAt("c", token.Position{Filename: "main.dreb", Line: 2, Column: 3})
+
+	inner := NewEnclosedSymbolTable(local)
+	inner.Resolve("c")
+
+	expected := map[string]string{
+		"a": "main.dreb:1:5",
+		"c": "main.dreb:2:3",
+	}
+	for name, pos := range expected {
+		result, ok := inner.Definition(name)
+		if !ok || result.String() != pos {
+			t.Errorf("wrong definition for %s. want=%s, got=%s (%t)", name, pos, result, ok)
+		}
+	}
+
+	for _, name := range []string{"b", "len", "d"} {
+		if pos, ok := inner.Definition(name); ok {
+			t.Errorf("%s has a definition, but was expected not to. got=%s", name, pos)
+		}
+	}
+}
@@ -0,0 +1,419 @@
+package compiler
+
+import (
+	"fmt"
+
+	"github.com/dreblang/core/code"
+	"github.com/dreblang/core/object"
+)
+
+// verify checks the instructions of every function in b, so bytecode loaded
+// from a corrupt or hostile file fails to load instead of crashing the VM
+// running it.
+func (b *Bytecode) verify() error {
+	main := b.mainFunction()
+	main.NumLocals = b.Registers
+	if err := b.verifyFunction(main); err != nil {
+		return fmt.Errorf("main function: %w", err)
+	}
+
+	for i, obj := range b.Constants {
+		if fn, ok := obj.(*object.CompiledFunction); ok {
+			if err := b.verifyFunction(fn); err != nil {
+				return fmt.Errorf("constant %d: %w", i, err)
+			}
+		}
+	}
+	return nil
+}
+
+// instruction is a decoded instruction of a function being verified.
+type instruction struct {
+	ip       int
+	op       code.Opcode
+	def      *code.Definition
+	operands []int
+	next     int
+}
+
+type verifier struct {
+	b  *Bytecode
+	fn *object.CompiledFunction
+
+	instructions []instruction
+	// Index in instructions of the instruction starting at each offset, -1
+	// inside an instruction
+	at []int
+}
+
+func (b *Bytecode) verifyFunction(fn *object.CompiledFunction) error {
+	if fn.NumParameters > fn.NumLocals {
+		return fmt.Errorf("%d parameters but %d locals", fn.NumParameters, fn.NumLocals)
+	}
+
+	v := &verifier{b: b, fn: fn}
+	if err := v.decode(); err != nil {
+		return err
+	}
+
+	for _, in := range v.instructions {
+		err := v.operands(in)
+		if err == nil && b.Registers > 0 {
+			err = v.registers(in)
+		}
+		if err != nil {
+			return fmt.Errorf("offset %d: %s: %w", in.ip, in.def.Name, err)
+		}
+	}
+
+	for i, h := range fn.Handlers {
+		if err := v.handler(h); err != nil {
+			return fmt.Errorf("handler %d: %w", i, err)
+		}
+	}
+
+	if b.Registers > 0 {
+		return nil
+	}
+	return v.depths()
+}
+
+// decode splits the instructions of the function, which must all be of the
+// VM the bytecode is for and have all their operands.
+func (v *verifier) decode() error {
+	ins := v.fn.Instructions
+	register := v.b.Registers > 0
+
+	v.at = make([]int, len(ins)+1)
+	for ip := 0; ip < len(ins); {
+		op := code.Opcode(ins[ip])
+		def, err := code.Lookup(ins[ip])
+		if err != nil || (op >= code.OpRegLoadConst) != register {
+			return fmt.Errorf("offset %d: unknown opcode %d", ip, op)
+		}
+
+		width := 0
+		for _, w := range def.OperandWidths {
+			width += w
+		}
+		if ip+1+width > len(ins) {
+			return fmt.Errorf("offset %d: %s: missing operands", ip, def.Name)
+		}
+
+		operands, _ := code.ReadOperands(def, ins[ip+1:])
+		v.at[ip] = len(v.instructions)
+		v.instructions = append(v.instructions, instruction{ip: ip, op: op, def: def, operands: operands, next: ip + 1 + width})
+
+		for i := ip + 1; i <= ip+width; i++ {
+			v.at[i] = -1
+		}
+		ip += 1 + width
+	}
+	v.at[len(ins)] = len(v.instructions)
+	return nil
+}
+
+// operands checks the constants, variables and jump targets an instruction
+// refers to.
+func (v *verifier) operands(in instruction) error {
+	o := in.operands
+
+	switch in.op {
+	case code.OpConstant:
+		return v.constant(o[0])
+	case code.OpGetLocal, code.OpSetLocal:
+		return v.local(o[0])
+	case code.OpAddLocalConst:
+		if err := v.local(o[0]); err != nil {
+			return err
+		}
+		return v.constant(o[1])
+	case code.OpGetGlobal, code.OpSetGlobal:
+		return v.global(o[0])
+	case code.OpGetFree, code.OpSetFree:
+		return v.free(o[0])
+	case code.OpGetBuiltin:
+		return v.builtin(o[0])
+	case code.OpClosure:
+		return v.closure(o[0], o[1])
+	case code.OpHash:
+		if o[0]%2 != 0 {
+			return fmt.Errorf("odd number of keys and values %d", o[0])
+		}
+	case code.OpTry:
+		if o[0] >= len(v.fn.Handlers) {
+			return fmt.Errorf("slot %d out of range", o[0])
+		}
+
+	case code.OpRegLoadConst:
+		return v.constant(o[1])
+	case code.OpRegGetGlobal:
+		return v.global(o[1])
+	case code.OpRegSetGlobal:
+		return v.global(o[0])
+	case code.OpRegGetFree:
+		return v.free(o[1])
+	case code.OpRegSetFree:
+		return v.free(o[0])
+	case code.OpRegGetBuiltin:
+		return v.builtin(o[1])
+	case code.OpRegAddConst:
+		return v.constant(o[2])
+	case code.OpRegHash:
+		if o[2]%2 != 0 {
+			return fmt.Errorf("odd number of keys and values %d", o[2])
+		}
+	case code.OpRegClosure:
+		return v.closure(o[1], o[3])
+	case code.OpRegExport:
+		if err := v.constant(o[0]); err != nil {
+			return err
+		}
+		if _, ok := v.b.Constants[o[0]].(*object.String); !ok {
+			return fmt.Errorf("constant %d is not a String", o[0])
+		}
+	}
+
+	if code.IsJump(in.op) {
+		return v.target(o[0])
+	}
+	return nil
+}
+
+// registers checks that the registers an instruction of register code uses
+// are in the frame of the function.
+func (v *verifier) registers(in instruction) error {
+	o := in.operands
+
+	var err error
+	check := func(first, n int) {
+		if err == nil && first+n > v.fn.NumLocals {
+			err = fmt.Errorf("register %d out of range", first+n-1)
+		}
+	}
+
+	switch in.op {
+	case code.OpRegLoadConst, code.OpRegLoadTrue, code.OpRegLoadFalse, code.OpRegLoadNull,
+		code.OpRegGetGlobal, code.OpRegGetFree, code.OpRegGetBuiltin,
+		code.OpRegReturn, code.OpRegScope, code.OpRegThrow, code.OpRegResult:
+		check(o[0], 1)
+	case code.OpRegSetGlobal, code.OpRegSetFree, code.OpRegExport, code.OpRegJumpNotTruthy:
+		check(o[1], 1)
+	case code.OpRegMove, code.OpRegMinus, code.OpRegBang, code.OpRegAddConst:
+		check(o[0], 1)
+		check(o[1], 1)
+	case code.OpRegAdd, code.OpRegSub, code.OpRegMul, code.OpRegDiv, code.OpRegMod,
+		code.OpRegEqual, code.OpRegNotEqual, code.OpRegGreaterThan, code.OpRegGreaterOrEqual,
+		code.OpRegLessThan, code.OpRegLessOrEqual,
+		code.OpRegIndex, code.OpRegMember, code.OpRegScopeResolve:
+		check(o[0], 1)
+		check(o[1], 1)
+		check(o[2], 1)
+	case code.OpRegJumpIfGreaterOrEqual, code.OpRegJumpIfGreaterThan, code.OpRegJumpIfLessOrEqual, code.OpRegJumpIfLessThan:
+		check(o[1], 1)
+		check(o[2], 1)
+	case code.OpRegArray, code.OpRegHash:
+		check(o[0], 1)
+		check(o[1], o[2])
+	case code.OpRegIndexRange:
+		check(o[0], 1)
+		check(o[1], 1)
+		check(o[2], 5)
+	case code.OpRegIndexSet:
+		check(o[0], 1)
+		check(o[1], 5)
+		check(o[2], 1)
+	case code.OpRegMemberSet:
+		check(o[0], 1)
+		check(o[1], 1)
+		check(o[2], 1)
+		check(o[3], 1)
+	case code.OpRegCall, code.OpRegTailCall:
+		check(o[0], 1)
+		check(o[1], 1+o[2])
+	case code.OpRegClosure:
+		check(o[0], 1)
+		check(o[2], o[3])
+	}
+	return err
+}
+
+// handler checks that h protects and jumps to instructions of the function,
+// and that its slot is one the function has.
+func (v *verifier) handler(h code.Handler) error {
+	if h.Start > h.End {
+		return fmt.Errorf("start %d is after end %d", h.Start, h.End)
+	}
+	for _, offset := range []int{h.Start, h.End, h.Target} {
+		if err := v.target(offset); err != nil {
+			return err
+		}
+	}
+
+	if v.b.Registers > 0 {
+		if h.Slot >= v.fn.NumLocals {
+			return fmt.Errorf("register %d out of range", h.Slot)
+		}
+		return nil
+	}
+	for _, in := range v.instructions {
+		if in.op == code.OpTry && in.operands[0] == h.Slot {
+			return nil
+		}
+	}
+	return fmt.Errorf("no OpTry for slot %d", h.Slot)
+}
+
+// depths follows every path through stack code, failing if an instruction
+// may pop more values than the function has pushed. Errors raised in a
+// handler's range continue at its target with the depth of the OpTry for
+// its slot and the error pushed.
+func (v *verifier) depths() error {
+	if len(v.instructions) == 0 {
+		return nil
+	}
+
+	// Lowest depth each instruction is reached with, -1 if it isn't
+	depths := make([]int, len(v.instructions))
+	for i := range depths {
+		depths[i] = -1
+	}
+
+	var pending []int
+	reach := func(offset, depth int) {
+		i := v.at[offset]
+		if i == len(v.instructions) {
+			return
+		}
+		if depths[i] < 0 || depth < depths[i] {
+			depths[i] = depth
+			pending = append(pending, i)
+		}
+	}
+
+	reach(0, 0)
+	for len(pending) > 0 {
+		i := pending[len(pending)-1]
+		pending = pending[:len(pending)-1]
+
+		in := v.instructions[i]
+		pops, pushes := stackEffect(in)
+		if pops > depths[i] {
+			return fmt.Errorf("offset %d: %s: pops %d values from a stack of %d", in.ip, in.def.Name, pops, depths[i])
+		}
+		depth := depths[i] - pops + pushes
+
+		switch {
+		case in.op == code.OpJump:
+			reach(in.operands[0], depth)
+			continue
+		case code.IsJump(in.op):
+			reach(in.operands[0], depth)
+		case in.op == code.OpTry:
+			for _, h := range v.fn.Handlers {
+				if h.Slot == in.operands[0] {
+					reach(h.Target, depth+1)
+				}
+			}
+		case in.op == code.OpReturnValue, in.op == code.OpReturn, in.op == code.OpThrow:
+			continue
+		}
+		reach(in.next, depth)
+	}
+	return nil
+}
+
+// stackEffect returns the number of values an instruction of stack code
+// pops and pushes.
+func stackEffect(in instruction) (pops, pushes int) {
+	switch in.op {
+	case code.OpConstant, code.OpTrue, code.OpFalse, code.OpNull,
+		code.OpGetGlobal, code.OpGetLocal, code.OpGetFree, code.OpGetBuiltin,
+		code.OpAddLocalConst, code.OpScope:
+		return 0, 1
+	case code.OpPop, code.OpJumpNotTruthy, code.OpReturnValue, code.OpThrow:
+		return 1, 0
+	case code.OpSetGlobal, code.OpSetLocal, code.OpSetFree, code.OpMinus, code.OpBang:
+		return 1, 1
+	case code.OpAdd, code.OpSub, code.OpMul, code.OpDiv, code.OpMod,
+		code.OpEqual, code.OpNotEqual, code.OpGreaterThan, code.OpGreaterOrEqual,
+		code.OpLessThan, code.OpLessOrEqual,
+		code.OpIndexSimple, code.OpMember, code.OpScopeResolve:
+		return 2, 1
+	case code.OpJumpIfGreaterOrEqual, code.OpJumpIfGreaterThan, code.OpJumpIfLessOrEqual, code.OpJumpIfLessThan,
+		code.OpExport:
+		return 2, 0
+	case code.OpMemberSet:
+		return 3, 1
+	case code.OpIndex:
+		return 6, 1
+	case code.OpIndexSet:
+		return 7, 1
+	case code.OpArray, code.OpHash:
+		return in.operands[0], 1
+	case code.OpClosure:
+		return in.operands[1], 1
+	case code.OpCall, code.OpTailCall:
+		return in.operands[0] + 1, 1
+	}
+	return 0, 0
+}
+
+func (v *verifier) constant(i int) error {
+	if i >= len(v.b.Constants) {
+		return fmt.Errorf("constant %d out of range", i)
+	}
+	return nil
+}
+
+// closure checks that constant i is a function with numFree free variables.
+func (v *verifier) closure(i, numFree int) error {
+	if err := v.constant(i); err != nil {
+		return err
+	}
+	fn, ok := v.b.Constants[i].(*object.CompiledFunction)
+	if !ok {
+		return fmt.Errorf("constant %d is not a function", i)
+	}
+	if numFree != len(fn.FreeNames) {
+		return fmt.Errorf("%d free variables for a function with %d", numFree, len(fn.FreeNames))
+	}
+	return nil
+}
+
+func (v *verifier) local(i int) error {
+	if i >= v.fn.NumLocals {
+		return fmt.Errorf("local %d out of range", i)
+	}
+	return nil
+}
+
+func (v *verifier) global(i int) error {
+	if i >= len(v.b.GlobalNames) {
+		return fmt.Errorf("global %d out of range", i)
+	}
+	return nil
+}
+
+func (v *verifier) free(i int) error {
+	if i >= len(v.fn.FreeNames) {
+		return fmt.Errorf("free variable %d out of range", i)
+	}
+	return nil
+}
+
+func (v *verifier) builtin(i int) error {
+	if i >= len(object.Builtins) {
+		return fmt.Errorf("builtin %d out of range", i)
+	}
+	return nil
+}
+
+// target checks that offset is the start of an instruction, or the end of
+// the function.
+func (v *verifier) target(offset int) error {
+	if offset < 0 || offset >= len(v.at) || v.at[offset] < 0 {
+		return fmt.Errorf("offset %d is not an instruction", offset)
+	}
+	return nil
+}
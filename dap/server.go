@@ -0,0 +1,456 @@
+// Package dap implements a Debug Adapter Protocol server on top of the
+// debugger package, so editors can debug Dreblang scripts.
+package dap
+
+import (
+	"bufio"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"path/filepath"
+	"sort"
+	"sync"
+
+	"github.com/dreblang/core/code"
+	"github.com/dreblang/core/compiler"
+	"github.com/dreblang/core/debugger"
+	"github.com/dreblang/core/lexer"
+	"github.com/dreblang/core/object"
+	"github.com/dreblang/core/parser"
+	"github.com/dreblang/core/vm"
+)
+
+// ThreadID is the id of the only thread a Dreblang program has.
+const ThreadID = 1
+
+var stopReasons = map[debugger.Reason]string{
+	debugger.EntryReason:      "entry",
+	debugger.BreakpointReason: "breakpoint",
+	debugger.StepReason:       "step",
+}
+
+// Server runs a single debug session over a pair of streams.
+type Server struct {
+	in  *bufio.Reader
+	out io.Writer
+
+	// Guards writes to out and seq
+	writeMu sync.Mutex
+	seq     int
+
+	debugger *debugger.Debugger
+	// Source lines that have code, by absolute file path
+	lines   map[string]map[int]bool
+	started bool
+	done    chan struct{}
+
+	// Guards stopped and refs, which the VM goroutine sets when it stops
+	mu      sync.Mutex
+	stopped bool
+	refs    []func() []Variable
+	resume  chan debugger.Action
+}
+
+// NewServer returns a server reading requests from in and writing
+// responses and events to out.
+func NewServer(in io.Reader, out io.Writer) *Server {
+	return &Server{
+		in:     bufio.NewReader(in),
+		out:    out,
+		done:   make(chan struct{}),
+		resume: make(chan debugger.Action),
+	}
+}
+
+// Serve handles requests until the client disconnects or in is closed.
+func (s *Server) Serve() error {
+	for {
+		msg, err := ReadMessage(s.in)
+		if err == io.EOF {
+			return nil
+		}
+		if err != nil {
+			return err
+		}
+		if msg.Type != "request" {
+			continue
+		}
+
+		body, err := s.handle(msg)
+		if err := s.respond(msg, body, err); err != nil {
+			return err
+		}
+
+		switch msg.Command {
+		case "launch":
+			if err == nil {
+				s.Event("initialized", nil)
+			}
+		case "disconnect":
+			return nil
+		}
+	}
+}
+
+// Event sends an event to the client. It is safe to call from any
+// goroutine.
+func (s *Server) Event(event string, body interface{}) error {
+	msg := &Message{Type: "event", Event: event}
+	return s.send(msg, body)
+}
+
+// Output forwards program output to the client.
+func (s *Server) Output(category, text string) error {
+	return s.Event("output", OutputEventBody{Category: category, Output: text})
+}
+
+func (s *Server) respond(req *Message, body interface{}, err error) error {
+	msg := &Message{
+		Type:       "response",
+		RequestSeq: req.Seq,
+		Command:    req.Command,
+		Success:    err == nil,
+	}
+	if err != nil {
+		msg.Message = err.Error()
+		body = nil
+	}
+	return s.send(msg, body)
+}
+
+func (s *Server) send(msg *Message, body interface{}) error {
+	if body != nil {
+		data, err := json.Marshal(body)
+		if err != nil {
+			return err
+		}
+		msg.Body = data
+	}
+
+	s.writeMu.Lock()
+	defer s.writeMu.Unlock()
+
+	s.seq++
+	msg.Seq = s.seq
+	return WriteMessage(s.out, msg)
+}
+
+func (s *Server) handle(msg *Message) (interface{}, error) {
+	switch msg.Command {
+	case "initialize":
+		return Capabilities{SupportsConfigurationDoneRequest: true}, nil
+
+	case "launch":
+		args := LaunchArguments{}
+		if err := json.Unmarshal(msg.Arguments, &args); err != nil {
+			return nil, err
+		}
+		return nil, s.launch(args)
+
+	case "setBreakpoints":
+		args := SetBreakpointsArguments{}
+		if err := json.Unmarshal(msg.Arguments, &args); err != nil {
+			return nil, err
+		}
+		return s.setBreakpoints(args)
+
+	case "configurationDone":
+		return nil, s.start()
+
+	case "threads":
+		return ThreadsResponseBody{Threads: []Thread{{ID: ThreadID, Name: "main"}}}, nil
+
+	case "stackTrace":
+		return s.stackTrace()
+
+	case "scopes":
+		args := ScopesArguments{}
+		if err := json.Unmarshal(msg.Arguments, &args); err != nil {
+			return nil, err
+		}
+		return s.scopes(args)
+
+	case "variables":
+		args := VariablesArguments{}
+		if err := json.Unmarshal(msg.Arguments, &args); err != nil {
+			return nil, err
+		}
+		return s.variables(args)
+
+	case "continue":
+		return ContinueResponseBody{AllThreadsContinued: true}, s.resumeWith(debugger.Continue)
+	case "next":
+		return nil, s.resumeWith(debugger.StepOver)
+	case "stepIn":
+		return nil, s.resumeWith(debugger.StepIn)
+	case "stepOut":
+		return nil, s.resumeWith(debugger.StepOut)
+
+	case "disconnect":
+		if s.resumeWith(debugger.Terminate) == nil {
+			<-s.done
+		}
+		return nil, nil
+
+	default:
+		return nil, fmt.Errorf("unsupported command %s", msg.Command)
+	}
+}
+
+func (s *Server) launch(args LaunchArguments) error {
+	if s.debugger != nil {
+		return errors.New("already launched")
+	}
+
+	text, err := ioutil.ReadFile(args.Program)
+	if err != nil {
+		return err
+	}
+
+	p := parser.New(lexer.NewWithFilename(string(text), args.Program))
+	program := p.ParseProgram()
+	if len(p.Errors()) != 0 {
+		return fmt.Errorf("parse error: %s", p.Errors()[0])
+	}
+
+	comp := compiler.New()
+	if err := comp.Compile(program); err != nil {
+		return fmt.Errorf("compile error: %s", err)
+	}
+	bytecode := comp.Bytecode()
+
+	s.lines = map[string]map[int]bool{}
+	s.addLines(bytecode.Lines)
+	for _, c := range bytecode.Constants {
+		if fn, ok := c.(*object.CompiledFunction); ok {
+			s.addLines(fn.Lines)
+		}
+	}
+
+	s.debugger = debugger.New(vm.New(bytecode))
+	s.debugger.StopOnEntry = args.StopOnEntry
+	s.debugger.OnStop = s.onStop
+	return nil
+}
+
+func (s *Server) addLines(lines []code.LineEntry) {
+	for _, entry := range lines {
+		file := absPath(entry.Pos.Filename)
+		if s.lines[file] == nil {
+			s.lines[file] = map[int]bool{}
+		}
+		s.lines[file][entry.Pos.Line] = true
+	}
+}
+
+func (s *Server) setBreakpoints(args SetBreakpointsArguments) (interface{}, error) {
+	if s.debugger == nil {
+		return nil, errors.New("not launched")
+	}
+
+	file := absPath(args.Source.Path)
+	s.debugger.ClearBreakpoints(file)
+
+	body := SetBreakpointsResponseBody{Breakpoints: []Breakpoint{}}
+	for _, sbp := range args.Breakpoints {
+		bp := Breakpoint{Line: sbp.Line, Source: args.Source}
+		if s.lines[file][sbp.Line] {
+			s.debugger.SetBreakpoint(file, sbp.Line)
+			bp.Verified = true
+		} else {
+			bp.Message = "no code on this line"
+		}
+		body.Breakpoints = append(body.Breakpoints, bp)
+	}
+	return body, nil
+}
+
+func (s *Server) start() error {
+	if s.debugger == nil {
+		return errors.New("not launched")
+	}
+	if s.started {
+		return nil
+	}
+	s.started = true
+
+	go func() {
+		defer close(s.done)
+
+		exitCode := 0
+		err := s.debugger.Run()
+		if err != nil && err != debugger.ErrTerminated {
+			exitCode = 1
+			msg := fmt.Sprintf("Runtime error: %s\n", err)
+			if rerr, ok := err.(*vm.RuntimeError); ok {
+				msg = fmt.Sprintf("Runtime error: %s: %s\n%s", rerr.Kind, rerr.Message, rerr.StackTrace())
+			}
+			s.Output("stderr", msg)
+		}
+
+		s.Event("exited", ExitedEventBody{ExitCode: exitCode})
+		s.Event("terminated", nil)
+	}()
+	return nil
+}
+
+// onStop runs on the VM goroutine and blocks it until the client resumes
+func (s *Server) onStop(d *debugger.Debugger, stop debugger.Stop) debugger.Action {
+	s.mu.Lock()
+	s.stopped = true
+	s.refs = nil
+	s.mu.Unlock()
+
+	s.Event("stopped", StoppedEventBody{
+		Reason:            stopReasons[stop.Reason],
+		ThreadID:          ThreadID,
+		AllThreadsStopped: true,
+	})
+
+	return <-s.resume
+}
+
+func (s *Server) resumeWith(action debugger.Action) error {
+	s.mu.Lock()
+	if !s.stopped {
+		s.mu.Unlock()
+		return errors.New("not stopped")
+	}
+	s.stopped = false
+	s.mu.Unlock()
+
+	s.resume <- action
+	return nil
+}
+
+// frames returns the active frames while the VM is stopped
+func (s *Server) frames() ([]*vm.Frame, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if !s.stopped {
+		return nil, errors.New("not stopped")
+	}
+	return s.debugger.VM().Frames(), nil
+}
+
+func (s *Server) stackTrace() (interface{}, error) {
+	frames, err := s.frames()
+	if err != nil {
+		return nil, err
+	}
+
+	body := StackTraceResponseBody{StackFrames: []StackFrame{}, TotalFrames: len(frames)}
+	for i, frame := range frames {
+		pos := frame.Pos()
+		body.StackFrames = append(body.StackFrames, StackFrame{
+			ID:     i + 1,
+			Name:   frame.Function().Name,
+			Source: Source{Name: filepath.Base(pos.Filename), Path: absPath(pos.Filename)},
+			Line:   pos.Line,
+			Column: pos.Column,
+		})
+	}
+	return body, nil
+}
+
+func (s *Server) scopes(args ScopesArguments) (interface{}, error) {
+	frames, err := s.frames()
+	if err != nil {
+		return nil, err
+	}
+	if args.FrameID < 1 || args.FrameID > len(frames) {
+		return nil, fmt.Errorf("unknown frame %d", args.FrameID)
+	}
+	frame := frames[args.FrameID-1]
+	machine := s.debugger.VM()
+
+	body := ScopesResponseBody{Scopes: []Scope{}}
+	if len(frame.Function().LocalNames) > 0 {
+		body.Scopes = append(body.Scopes, Scope{
+			Name:               "Locals",
+			VariablesReference: s.addRef(func() []Variable { return s.convert(machine.Locals(frame)) }),
+		})
+	}
+	if len(frame.Function().FreeNames) > 0 {
+		body.Scopes = append(body.Scopes, Scope{
+			Name:               "Closure",
+			VariablesReference: s.addRef(func() []Variable { return s.convert(machine.FreeVariables(frame)) }),
+		})
+	}
+	body.Scopes = append(body.Scopes, Scope{
+		Name:               "Globals",
+		VariablesReference: s.addRef(func() []Variable { return s.convert(machine.Globals()) }),
+	})
+	return body, nil
+}
+
+func (s *Server) variables(args VariablesArguments) (interface{}, error) {
+	if _, err := s.frames(); err != nil {
+		return nil, err
+	}
+
+	s.mu.Lock()
+	ref := args.VariablesReference
+	if ref < 1 || ref > len(s.refs) {
+		s.mu.Unlock()
+		return nil, fmt.Errorf("unknown variables reference %d", ref)
+	}
+	load := s.refs[ref-1]
+	s.mu.Unlock()
+
+	return VariablesResponseBody{Variables: load()}, nil
+}
+
+// addRef registers a lazily loaded variable list and returns its reference
+func (s *Server) addRef(load func() []Variable) int {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	s.refs = append(s.refs, load)
+	return len(s.refs)
+}
+
+func (s *Server) convert(vars []vm.Variable) []Variable {
+	result := []Variable{}
+	for _, v := range vars {
+		result = append(result, s.variable(v.Name, v.Value))
+	}
+	return result
+}
+
+// variable describes value, giving arrays and hashes a reference to
+// their elements
+func (s *Server) variable(name string, value object.Object) Variable {
+	v := Variable{Name: name, Value: value.Inspect(), Type: string(value.Type())}
+
+	switch value := value.(type) {
+	case *object.Array:
+		v.VariablesReference = s.addRef(func() []Variable {
+			elements := []Variable{}
+			for i, el := range value.Elements {
+				elements = append(elements, s.variable(fmt.Sprintf("[%d]", i), el))
+			}
+			return elements
+		})
+	case *object.Hash:
+		v.VariablesReference = s.addRef(func() []Variable {
+			pairs := []Variable{}
+			for _, pair := range value.Pairs {
+				pairs = append(pairs, s.variable(pair.Key.Inspect(), pair.Value))
+			}
+			sort.Slice(pairs, func(i, j int) bool { return pairs[i].Name < pairs[j].Name })
+			return pairs
+		})
+	}
+	return v
+}
+
+func absPath(file string) string {
+	if abs, err := filepath.Abs(file); err == nil {
+		return abs
+	}
+	return filepath.Clean(file)
+}
@@ -0,0 +1,272 @@
+package dap
+
+import (
+	"encoding/json"
+	"io"
+	"io/ioutil"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/dreblang/core/internal/framing/framingtest"
+)
+
+const program = `let total = 0;
+let add = fn(a, b) {
+	let s = a + b;
+	s
+};
+let list = [1, 2];
+total = add(total, 5);
+total = add(total, list[1]);
+total`
+
+type client struct {
+	*framingtest.Client
+	t      *testing.T
+	seq    int
+	events []*Message
+}
+
+func newClient(t *testing.T) (*client, chan error) {
+	c, done := framingtest.NewClient(t, func(in io.Reader, out io.Writer) error {
+		return NewServer(in, out).Serve()
+	})
+	return &client{Client: c, t: t}, done
+}
+
+func (c *client) next() *Message {
+	c.t.Helper()
+
+	msg := &Message{}
+	c.Next(msg)
+	return msg
+}
+
+// request sends a request and returns its response, queueing any events
+// that arrive first
+func (c *client) request(command string, args interface{}, body interface{}) *Message {
+	c.t.Helper()
+
+	c.seq++
+	req := &Message{Seq: c.seq, Type: "request", Command: command}
+	if args != nil {
+		data, _ := json.Marshal(args)
+		req.Arguments = data
+	}
+	c.Send(req)
+
+	for {
+		msg := c.next()
+		if msg.Type == "event" {
+			c.events = append(c.events, msg)
+			continue
+		}
+		if msg.RequestSeq != req.Seq || msg.Command != command {
+			c.t.Fatalf("unexpected response %+v to %s", msg, command)
+		}
+		if body != nil && msg.Success {
+			if err := json.Unmarshal(msg.Body, body); err != nil {
+				c.t.Fatalf("bad %s response body: %s", command, err)
+			}
+		}
+		return msg
+	}
+}
+
+func (c *client) event(name string, body interface{}) {
+	c.t.Helper()
+
+	for {
+		var msg *Message
+		if len(c.events) > 0 {
+			msg, c.events = c.events[0], c.events[1:]
+		} else {
+			msg = c.next()
+		}
+		if msg.Type != "event" || msg.Event != name {
+			continue
+		}
+		if body != nil {
+			if err := json.Unmarshal(msg.Body, body); err != nil {
+				c.t.Fatalf("bad %s event body: %s", name, err)
+			}
+		}
+		return
+	}
+}
+
+func (c *client) stopped(reason string, line int) {
+	c.t.Helper()
+
+	stopped := StoppedEventBody{}
+	c.event("stopped", &stopped)
+	if stopped.Reason != reason {
+		c.t.Errorf("wrong stop reason. want=%q, got=%q", reason, stopped.Reason)
+	}
+
+	trace := StackTraceResponseBody{}
+	c.request("stackTrace", StackTraceArguments{ThreadID: ThreadID}, &trace)
+	if len(trace.StackFrames) == 0 || trace.StackFrames[0].Line != line {
+		c.t.Errorf("stopped at wrong line. want=%d, got=%+v", line, trace.StackFrames)
+	}
+}
+
+func (c *client) variables(ref int) map[string]Variable {
+	c.t.Helper()
+
+	body := VariablesResponseBody{}
+	resp := c.request("variables", VariablesArguments{VariablesReference: ref}, &body)
+	if !resp.Success {
+		c.t.Fatalf("variables failed: %s", resp.Message)
+	}
+	vars := map[string]Variable{}
+	for _, v := range body.Variables {
+		vars[v.Name] = v
+	}
+	return vars
+}
+
+func TestSession(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "main.dreb")
+	if err := ioutil.WriteFile(path, []byte(program), 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	c, done := newClient(t)
+
+	if resp := c.request("initialize", nil, nil); !resp.Success {
+		t.Fatalf("initialize failed: %s", resp.Message)
+	}
+	if resp := c.request("launch", LaunchArguments{Program: path}, nil); !resp.Success {
+		t.Fatalf("launch failed: %s", resp.Message)
+	}
+	c.event("initialized", nil)
+
+	bps := SetBreakpointsResponseBody{}
+	c.request("setBreakpoints", SetBreakpointsArguments{
+		Source:      Source{Path: path},
+		Breakpoints: []SourceBreakpoint{{Line: 3}, {Line: 5}},
+	}, &bps)
+	if len(bps.Breakpoints) != 2 || !bps.Breakpoints[0].Verified || bps.Breakpoints[1].Verified {
+		t.Errorf("wrong breakpoint verification. got=%+v", bps.Breakpoints)
+	}
+
+	if resp := c.request("next", nil, nil); resp.Success {
+		t.Errorf("next succeeded before the program started")
+	}
+
+	c.request("configurationDone", nil, nil)
+	c.stopped("breakpoint", 3)
+
+	trace := StackTraceResponseBody{}
+	c.request("stackTrace", StackTraceArguments{ThreadID: ThreadID}, &trace)
+	if trace.TotalFrames != 2 || trace.StackFrames[0].Name != "add" || trace.StackFrames[1].Name != "<main>" {
+		t.Fatalf("wrong stack trace. got=%+v", trace)
+	}
+	if trace.StackFrames[1].Line != 7 || trace.StackFrames[0].Source.Path != path {
+		t.Errorf("wrong caller frame. got=%+v", trace.StackFrames[1])
+	}
+
+	scopes := ScopesResponseBody{}
+	c.request("scopes", ScopesArguments{FrameID: 1}, &scopes)
+	if len(scopes.Scopes) != 2 || scopes.Scopes[0].Name != "Locals" || scopes.Scopes[1].Name != "Globals" {
+		t.Fatalf("wrong scopes. got=%+v", scopes.Scopes)
+	}
+
+	locals := c.variables(scopes.Scopes[0].VariablesReference)
+	if locals["a"].Value != "0" || locals["b"].Value != "5" || locals["b"].Type != "Integer" {
+		t.Errorf("wrong locals. got=%+v", locals)
+	}
+
+	globals := c.variables(scopes.Scopes[1].VariablesReference)
+	list, ok := globals["list"]
+	if !ok || list.VariablesReference == 0 {
+		t.Fatalf("list is not expandable. got=%+v", globals)
+	}
+	elements := c.variables(list.VariablesReference)
+	if elements["[1]"].Value != "2" {
+		t.Errorf("wrong list elements. got=%+v", elements)
+	}
+
+	c.request("next", nil, nil)
+	c.stopped("step", 4)
+
+	c.request("next", nil, nil)
+	c.stopped("step", 7)
+
+	c.request("stepIn", nil, nil)
+	c.stopped("step", 8)
+
+	c.request("stepIn", nil, nil)
+	c.stopped("breakpoint", 3)
+
+	// Clearing breakpoints lets the program run to completion
+	c.request("setBreakpoints", SetBreakpointsArguments{Source: Source{Path: path}}, nil)
+	c.request("continue", nil, &ContinueResponseBody{})
+
+	exited := ExitedEventBody{ExitCode: -1}
+	c.event("exited", &exited)
+	if exited.ExitCode != 0 {
+		t.Errorf("wrong exit code. got=%d", exited.ExitCode)
+	}
+	c.event("terminated", nil)
+
+	c.request("disconnect", nil, nil)
+	if err := <-done; err != nil {
+		t.Errorf("server error: %s", err)
+	}
+}
+
+func TestStopOnEntryAndDisconnect(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "main.dreb")
+	if err := ioutil.WriteFile(path, []byte(program), 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	c, done := newClient(t)
+	c.request("initialize", nil, nil)
+	c.request("launch", LaunchArguments{Program: path, StopOnEntry: true}, nil)
+	c.request("configurationDone", nil, nil)
+	c.stopped("entry", 1)
+
+	c.request("disconnect", nil, nil)
+	c.event("terminated", nil)
+	if err := <-done; err != nil {
+		t.Errorf("server error: %s", err)
+	}
+}
+
+func TestLaunchErrors(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "main.dreb")
+	if err := ioutil.WriteFile(path, []byte("let = 1;"), 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	c, _ := newClient(t)
+	c.request("initialize", nil, nil)
+
+	resp := c.request("launch", LaunchArguments{Program: path}, nil)
+	if resp.Success {
+		t.Fatalf("launch of invalid program succeeded")
+	}
+	expected := "parse error: " + path + ":1:5: expected next token to be Identifier, got = instead"
+	if resp.Message != expected {
+		t.Errorf("wrong launch error.\nwant=%q\ngot =%q", expected, resp.Message)
+	}
+
+	if resp := c.request("evaluate", nil, nil); resp.Success || resp.Message != "unsupported command evaluate" {
+		t.Errorf("wrong response to unsupported command. got=%+v", resp)
+	}
+}
+
+func TestBadContentLength(t *testing.T) {
+	for _, length := range []string{"-1", "0", "x", "4294967296"} {
+		in := strings.NewReader("Content-Length: " + length + "\r\n\r\n{}")
+		err := NewServer(in, io.Discard).Serve()
+		expected := `invalid Content-Length: "` + length + `"`
+		if err == nil || err.Error() != expected {
+			t.Errorf("wrong error for Content-Length %s. want=%q, got=%v", length, expected, err)
+		}
+	}
+}
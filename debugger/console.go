@@ -0,0 +1,241 @@
+package debugger
+
+import (
+	"bufio"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"strconv"
+	"strings"
+
+	"github.com/dreblang/core/token"
+	"github.com/dreblang/core/vm"
+)
+
+const Prompt = "(drebdbg) "
+
+const consoleHelp = `commands:
+  break|b [file:]line    set a breakpoint
+  delete|d [file:]line   remove a breakpoint
+  breakpoints            list breakpoints
+  continue|c             resume execution
+  step|s                 step into the next line
+  next|n                 step over the next line
+  out|o                  step out of the current function
+  backtrace|bt           print the call stack
+  frame|f n              select frame n of the backtrace
+  locals                 print the variables of the selected frame
+  globals                print the global variables
+  print|p name           print a variable
+  list|l                 print the source around the current line
+  quit|q                 stop the program
+`
+
+// Console is a line based frontend for a Debugger.
+type Console struct {
+	d       *Debugger
+	in      *bufio.Scanner
+	out     io.Writer
+	file    string
+	stop    Stop
+	frame   int
+	sources map[string][]string
+}
+
+// NewConsole makes d read commands from in whenever execution stops.
+// Breakpoints given without a file name refer to file.
+func NewConsole(d *Debugger, file string, in io.Reader, out io.Writer) *Console {
+	c := &Console{
+		d:       d,
+		in:      bufio.NewScanner(in),
+		out:     out,
+		file:    file,
+		sources: map[string][]string{},
+	}
+	d.OnStop = c.onStop
+	return c
+}
+
+func (c *Console) onStop(d *Debugger, stop Stop) Action {
+	c.stop = stop
+	c.frame = 0
+
+	fmt.Fprintf(c.out, "Stopped (%s) in %s at %s\n", stop.Reason, stop.Frame.Function().Name, stop.Pos)
+	c.printLine(stop.Pos, true)
+
+	for {
+		fmt.Fprint(c.out, Prompt)
+		if !c.in.Scan() {
+			return Terminate
+		}
+
+		fields := strings.Fields(c.in.Text())
+		if len(fields) == 0 {
+			continue
+		}
+
+		if action, ok := c.command(fields[0], fields[1:]); ok {
+			return action
+		}
+	}
+}
+
+// command runs one console command. It reports true with the action to
+// take when the command resumes execution.
+func (c *Console) command(name string, args []string) (Action, bool) {
+	switch name {
+	case "continue", "c":
+		return Continue, true
+	case "step", "s":
+		return StepIn, true
+	case "next", "n":
+		return StepOver, true
+	case "out", "o":
+		return StepOut, true
+	case "quit", "q":
+		return Terminate, true
+
+	case "break", "b", "delete", "d":
+		if len(args) != 1 {
+			fmt.Fprintf(c.out, "usage: %s [file:]line\n", name)
+			break
+		}
+		file, line, err := c.parseLocation(args[0])
+		if err != nil {
+			fmt.Fprintln(c.out, err)
+			break
+		}
+		if name == "break" || name == "b" {
+			c.d.SetBreakpoint(file, line)
+			fmt.Fprintf(c.out, "Breakpoint set at %s:%d\n", file, line)
+		} else if c.d.ClearBreakpoint(file, line) {
+			fmt.Fprintf(c.out, "Breakpoint removed at %s:%d\n", file, line)
+		} else {
+			fmt.Fprintf(c.out, "No breakpoint at %s:%d\n", file, line)
+		}
+
+	case "breakpoints":
+		for _, bp := range c.d.Breakpoints() {
+			fmt.Fprintf(c.out, "%s:%d\n", bp.File, bp.Line)
+		}
+
+	case "backtrace", "bt":
+		for i, frame := range c.d.VM().Frames() {
+			marker := " "
+			if i == c.frame {
+				marker = "*"
+			}
+			fmt.Fprintf(c.out, "%s#%d %s (%s)\n", marker, i, frame.Function().Name, frame.Pos())
+		}
+
+	case "frame", "f":
+		frames := c.d.VM().Frames()
+		n := -1
+		if len(args) == 1 {
+			n, _ = strconv.Atoi(args[0])
+		}
+		if n < 0 || n >= len(frames) {
+			fmt.Fprintf(c.out, "usage: frame n (0-%d)\n", len(frames)-1)
+			break
+		}
+		c.frame = n
+		fmt.Fprintf(c.out, "#%d %s (%s)\n", n, frames[n].Function().Name, frames[n].Pos())
+
+	case "locals":
+		frame := c.selectedFrame()
+		c.printVariables(c.d.VM().Locals(frame))
+		c.printVariables(c.d.VM().FreeVariables(frame))
+
+	case "globals":
+		c.printVariables(c.d.VM().Globals())
+
+	case "print", "p":
+		if len(args) != 1 {
+			fmt.Fprintln(c.out, "usage: print name")
+			break
+		}
+		if v, ok := c.lookup(args[0]); ok {
+			fmt.Fprintf(c.out, "%s = %s\n", v.Name, v.Value.Inspect())
+		} else {
+			fmt.Fprintf(c.out, "No variable named %s\n", args[0])
+		}
+
+	case "list", "l":
+		pos := c.selectedFrame().Pos()
+		for line := pos.Line - 3; line <= pos.Line+3; line++ {
+			c.printLine(token.Position{Filename: pos.Filename, Line: line}, line == pos.Line)
+		}
+
+	case "help", "h":
+		fmt.Fprint(c.out, consoleHelp)
+
+	default:
+		fmt.Fprintf(c.out, "Unknown command %s, try help\n", name)
+	}
+
+	return Continue, false
+}
+
+func (c *Console) selectedFrame() *vm.Frame {
+	frames := c.d.VM().Frames()
+	if c.frame < len(frames) {
+		return frames[c.frame]
+	}
+	return c.stop.Frame
+}
+
+// lookup resolves name the way the compiler would from the selected frame:
+// locals, then free variables, then globals
+func (c *Console) lookup(name string) (vm.Variable, bool) {
+	machine := c.d.VM()
+	frame := c.selectedFrame()
+
+	scopes := [][]vm.Variable{machine.Locals(frame), machine.FreeVariables(frame), machine.Globals()}
+	for _, vars := range scopes {
+		for _, v := range vars {
+			if v.Name == name {
+				return v, true
+			}
+		}
+	}
+	return vm.Variable{}, false
+}
+
+func (c *Console) printVariables(vars []vm.Variable) {
+	for _, v := range vars {
+		fmt.Fprintf(c.out, "%s = %s\n", v.Name, v.Value.Inspect())
+	}
+}
+
+func (c *Console) parseLocation(loc string) (string, int, error) {
+	file := c.file
+	if i := strings.LastIndex(loc, ":"); i >= 0 {
+		file, loc = loc[:i], loc[i+1:]
+	}
+
+	line, err := strconv.Atoi(loc)
+	if err != nil || line <= 0 {
+		return "", 0, fmt.Errorf("invalid line number %q", loc)
+	}
+	return file, line, nil
+}
+
+func (c *Console) printLine(pos token.Position, current bool) {
+	lines, ok := c.sources[pos.Filename]
+	if !ok {
+		if text, err := ioutil.ReadFile(pos.Filename); err == nil {
+			lines = strings.Split(string(text), "\n")
+		}
+		c.sources[pos.Filename] = lines
+	}
+
+	if pos.Line < 1 || pos.Line > len(lines) {
+		return
+	}
+
+	marker := " "
+	if current {
+		marker = ">"
+	}
+	fmt.Fprintf(c.out, "%s %4d  %s\n", marker, pos.Line, lines[pos.Line-1])
+}
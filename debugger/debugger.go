@@ -0,0 +1,217 @@
+// Package debugger implements breakpoints and stepping on top of the VM
+// hooks. Frontends such as drebdbg decide what to do whenever execution
+// stops through the OnStop callback.
+package debugger
+
+import (
+	"errors"
+	"path/filepath"
+	"sort"
+
+	"github.com/dreblang/core/object"
+	"github.com/dreblang/core/token"
+	"github.com/dreblang/core/vm"
+)
+
+// Action tells the debugger how to resume after a stop.
+type Action int
+
+const (
+	Continue Action = iota
+	StepIn
+	StepOver
+	StepOut
+	Terminate
+)
+
+// Reason explains why execution stopped.
+type Reason string
+
+const (
+	EntryReason      Reason = "entry"
+	BreakpointReason Reason = "breakpoint"
+	StepReason       Reason = "step"
+)
+
+// ErrTerminated is returned from Run when OnStop asked to terminate.
+var ErrTerminated = errors.New("debugger: terminated")
+
+// Stop describes a point where execution paused.
+type Stop struct {
+	Reason Reason
+	Frame  *vm.Frame
+	Pos    token.Position
+}
+
+// Breakpoint is a source line to stop at.
+type Breakpoint struct {
+	File string
+	Line int
+}
+
+type lineState struct {
+	frame *vm.Frame
+	line  int
+	ip    int
+}
+
+// Debugger drives a VM through its hooks. Tail calls keep their frames while
+// a debugger is attached, so stepping over `return f(x)` runs f to the end.
+type Debugger struct {
+	// OnStop is called whenever execution pauses and blocks the VM until it
+	// returns how to resume.
+	OnStop func(d *Debugger, stop Stop) Action
+
+	// StopOnEntry pauses before the first line runs.
+	StopOnEntry bool
+
+	vm          *vm.VM
+	breakpoints map[Breakpoint]bool
+	paths       map[string]string
+
+	action    Action
+	stepDepth int
+
+	// Last line executed at each frame depth
+	lines []lineState
+}
+
+// New attaches a debugger to machine.
+func New(machine *vm.VM) *Debugger {
+	d := &Debugger{
+		vm:          machine,
+		breakpoints: map[Breakpoint]bool{},
+		paths:       map[string]string{},
+		action:      Continue,
+	}
+	machine.SetHook(d)
+	return d
+}
+
+// VM returns the machine being debugged.
+func (d *Debugger) VM() *vm.VM {
+	return d.vm
+}
+
+// Run runs the VM until it finishes, fails or is terminated.
+func (d *Debugger) Run() error {
+	if d.StopOnEntry {
+		d.action = StepIn
+	}
+	return d.vm.Run()
+}
+
+// SetBreakpoint adds a breakpoint at file:line.
+func (d *Debugger) SetBreakpoint(file string, line int) {
+	d.breakpoints[Breakpoint{File: cleanPath(file), Line: line}] = true
+}
+
+// ClearBreakpoint removes the breakpoint at file:line and reports whether
+// there was one.
+func (d *Debugger) ClearBreakpoint(file string, line int) bool {
+	bp := Breakpoint{File: cleanPath(file), Line: line}
+	ok := d.breakpoints[bp]
+	delete(d.breakpoints, bp)
+	return ok
+}
+
+// ClearBreakpoints removes every breakpoint in file.
+func (d *Debugger) ClearBreakpoints(file string) {
+	file = cleanPath(file)
+	for bp := range d.breakpoints {
+		if bp.File == file {
+			delete(d.breakpoints, bp)
+		}
+	}
+}
+
+// Breakpoints returns the breakpoints sorted by file and line.
+func (d *Debugger) Breakpoints() []Breakpoint {
+	bps := make([]Breakpoint, 0, len(d.breakpoints))
+	for bp := range d.breakpoints {
+		bps = append(bps, bp)
+	}
+	sort.Slice(bps, func(i, j int) bool {
+		if bps[i].File != bps[j].File {
+			return bps[i].File < bps[j].File
+		}
+		return bps[i].Line < bps[j].Line
+	})
+	return bps
+}
+
+func (d *Debugger) BeforeInstruction(machine *vm.VM, frame *vm.Frame) error {
+	depth := machine.Depth()
+	pos := frame.Pos()
+
+	for len(d.lines) < depth {
+		d.lines = append(d.lines, lineState{})
+	}
+	state := &d.lines[depth-1]
+	newLine := state.frame != frame || state.line != pos.Line || frame.IP() < state.ip
+	*state = lineState{frame: frame, line: pos.Line, ip: frame.IP()}
+
+	var reason Reason
+	switch {
+	case d.action == StepOut && depth < d.stepDepth:
+		reason = StepReason
+	case d.action == StepOver && depth < d.stepDepth:
+		reason = StepReason
+	case !newLine || !pos.IsValid():
+		return nil
+	case d.hasBreakpoint(pos):
+		reason = BreakpointReason
+	case d.action == StepIn && d.StopOnEntry && machine.Depth() == 1 && frame.IP() == 0:
+		reason = EntryReason
+	case d.action == StepIn:
+		reason = StepReason
+	case d.action == StepOver && depth <= d.stepDepth:
+		reason = StepReason
+	default:
+		return nil
+	}
+
+	if d.OnStop == nil {
+		return nil
+	}
+
+	d.action = d.OnStop(d, Stop{Reason: reason, Frame: frame, Pos: pos})
+	d.stepDepth = depth
+	if d.action == Terminate {
+		return ErrTerminated
+	}
+	return nil
+}
+
+func (d *Debugger) hasBreakpoint(pos token.Position) bool {
+	if len(d.breakpoints) == 0 {
+		return false
+	}
+
+	file, ok := d.paths[pos.Filename]
+	if !ok {
+		file = cleanPath(pos.Filename)
+		d.paths[pos.Filename] = file
+	}
+	return d.breakpoints[Breakpoint{File: file, Line: pos.Line}]
+}
+
+func (d *Debugger) OnCall(machine *vm.VM, frame *vm.Frame) error {
+	return nil
+}
+
+func (d *Debugger) OnReturn(machine *vm.VM, frame *vm.Frame, value object.Object) error {
+	// Forget the returning frame so a later call at the same depth starts
+	// on a new line
+	if depth := machine.Depth(); depth < len(d.lines) {
+		d.lines = d.lines[:depth]
+	}
+	return nil
+}
+
+func cleanPath(file string) string {
+	if abs, err := filepath.Abs(file); err == nil {
+		return abs
+	}
+	return filepath.Clean(file)
+}
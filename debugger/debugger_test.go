@@ -0,0 +1,246 @@
+package debugger
+
+import (
+	"bytes"
+	"fmt"
+	"strings"
+	"testing"
+
+	"github.com/dreblang/core/compiler"
+	"github.com/dreblang/core/lexer"
+	"github.com/dreblang/core/parser"
+	"github.com/dreblang/core/vm"
+)
+
+const program = `let total = 0;
+let add = fn(a, b) {
+	let s = a + b;
+	s
+};
+let i = 0;
+loop (i < 3) {
+	total = add(total, i);
+	i = i + 1;
+}
+total`
+
+func newDebugger(t *testing.T, input string) *Debugger {
+	t.Helper()
+
+	p := parser.New(lexer.NewWithFilename(input, "main.dreb"))
+	prog := p.ParseProgram()
+	if len(p.Errors()) != 0 {
+		t.Fatalf("parser errors: %v", p.Errors())
+	}
+
+	comp := compiler.New()
+	if err := comp.Compile(prog); err != nil {
+		t.Fatalf("compiler error: %s", err)
+	}
+
+	return New(vm.New(comp.Bytecode()))
+}
+
+// script answers stops with actions in order and records where it stopped
+func script(actions ...Action) (*[]string, func(*Debugger, Stop) Action) {
+	stops := []string{}
+	return &stops, func(d *Debugger, stop Stop) Action {
+		stops = append(stops, fmt.Sprintf("%s %s:%d", stop.Reason, stop.Frame.Function().Name, stop.Pos.Line))
+		if len(actions) == 0 {
+			return Continue
+		}
+		action := actions[0]
+		actions = actions[1:]
+		return action
+	}
+}
+
+func TestDebuggerStops(t *testing.T) {
+	tests := []struct {
+		name        string
+		breakpoints []int
+		entry       bool
+		actions     []Action
+		expected    []string
+	}{
+		{
+			name:        "breakpoint hit every iteration",
+			breakpoints: []int{3},
+			expected:    []string{"breakpoint add:3", "breakpoint add:3", "breakpoint add:3"},
+		},
+		{
+			name:     "step over from entry",
+			entry:    true,
+			actions:  []Action{StepOver, StepOver, StepOver, StepOver, StepOver, StepOver},
+			expected: []string{"entry <main>:1", "step <main>:2", "step <main>:6", "step <main>:7", "step <main>:8", "step <main>:9", "step <main>:7"},
+		},
+		{
+			name:        "step in and out",
+			breakpoints: []int{8},
+			actions:     []Action{StepIn, StepIn, StepOut, Terminate},
+			expected:    []string{"breakpoint <main>:8", "step add:3", "step add:4", "step <main>:8"},
+		},
+		{
+			name:        "step over a call",
+			breakpoints: []int{8},
+			actions:     []Action{StepOver, Terminate},
+			expected:    []string{"breakpoint <main>:8", "step <main>:9"},
+		},
+		{
+			name:        "step over return",
+			breakpoints: []int{4},
+			actions:     []Action{StepOver, Terminate},
+			expected:    []string{"breakpoint add:4", "step <main>:8"},
+		},
+	}
+
+	for _, tt := range tests {
+		d := newDebugger(t, program)
+		d.StopOnEntry = tt.entry
+		for _, line := range tt.breakpoints {
+			d.SetBreakpoint("main.dreb", line)
+		}
+
+		var stops *[]string
+		stops, d.OnStop = script(tt.actions...)
+
+		err := d.Run()
+		if err != nil && err != ErrTerminated {
+			t.Fatalf("%s: run error: %s", tt.name, err)
+		}
+
+		if strings.Join(*stops, "\n") != strings.Join(tt.expected, "\n") {
+			t.Errorf("%s: wrong stops.\nwant=%q\ngot =%q", tt.name, tt.expected, *stops)
+		}
+	}
+}
+
+func TestDebuggerTailCall(t *testing.T) {
+	input := `let inc = fn(x) {
+	let y = x + 1;
+	y
+};
+let next = fn(x) {
+	return inc(x)
+};
+let r = next(1);
+r`
+
+	tests := []struct {
+		name     string
+		actions  []Action
+		expected []string
+	}{
+		{
+			name:     "step over a tail call",
+			actions:  []Action{StepOver, StepOver, Terminate},
+			expected: []string{"breakpoint next:6", "step <main>:8", "step <main>:9"},
+		},
+		{
+			name:     "step in a tail call",
+			actions:  []Action{StepIn, StepOver, StepOver, Terminate},
+			expected: []string{"breakpoint next:6", "step inc:2", "step inc:3", "step next:6"},
+		},
+		{
+			name:     "step out of a tail call",
+			actions:  []Action{StepIn, StepOut, Terminate},
+			expected: []string{"breakpoint next:6", "step inc:2", "step next:6"},
+		},
+	}
+
+	for _, tt := range tests {
+		d := newDebugger(t, input)
+		d.SetBreakpoint("main.dreb", 6)
+
+		var stops *[]string
+		stops, d.OnStop = script(tt.actions...)
+
+		err := d.Run()
+		if err != nil && err != ErrTerminated {
+			t.Fatalf("%s: run error: %s", tt.name, err)
+		}
+
+		if strings.Join(*stops, "\n") != strings.Join(tt.expected, "\n") {
+			t.Errorf("%s: wrong stops.\nwant=%q\ngot =%q", tt.name, tt.expected, *stops)
+		}
+	}
+}
+
+func TestDebuggerTerminate(t *testing.T) {
+	d := newDebugger(t, program)
+	d.StopOnEntry = true
+	_, d.OnStop = script(Terminate)
+
+	if err := d.Run(); err != ErrTerminated {
+		t.Fatalf("wrong error. want=%v, got=%v", ErrTerminated, err)
+	}
+}
+
+func TestBreakpoints(t *testing.T) {
+	d := newDebugger(t, program)
+	d.SetBreakpoint("main.dreb", 8)
+	d.SetBreakpoint("main.dreb", 3)
+	d.SetBreakpoint("other.dreb", 1)
+
+	if len(d.Breakpoints()) != 3 {
+		t.Fatalf("wrong number of breakpoints. got=%v", d.Breakpoints())
+	}
+	if !d.ClearBreakpoint("main.dreb", 8) || d.ClearBreakpoint("main.dreb", 8) {
+		t.Errorf("ClearBreakpoint did not report removal correctly")
+	}
+	d.ClearBreakpoints("other.dreb")
+
+	bps := d.Breakpoints()
+	if len(bps) != 1 || bps[0].Line != 3 || !strings.HasSuffix(bps[0].File, "main.dreb") {
+		t.Errorf("wrong breakpoints. got=%v", bps)
+	}
+}
+
+func TestConsole(t *testing.T) {
+	d := newDebugger(t, program)
+	d.StopOnEntry = true
+
+	in := strings.NewReader(strings.Join([]string{
+		"b 3",
+		"c",
+		"locals",
+		"bt",
+		"f 1",
+		"p total",
+		"p nothing",
+		"d 3",
+		"o",
+		"globals",
+		"q",
+	}, "\n"))
+	var out bytes.Buffer
+	NewConsole(d, "main.dreb", in, &out)
+
+	if err := d.Run(); err != ErrTerminated {
+		t.Fatalf("wrong error. want=%v, got=%v", ErrTerminated, err)
+	}
+
+	expected := []string{
+		"Stopped (entry) in <main> at main.dreb:1:13",
+		"(drebdbg) Breakpoint set at main.dreb:3",
+		"(drebdbg) Stopped (breakpoint) in add at main.dreb:3:10",
+		"(drebdbg) a = 0",
+		"b = 0",
+		"s = null",
+		"(drebdbg) *#0 add (main.dreb:3:10)",
+		" #1 <main> (main.dreb:8:13)",
+		"(drebdbg) #1 <main> (main.dreb:8:13)",
+		"(drebdbg) total = 0",
+		"(drebdbg) No variable named nothing",
+		"(drebdbg) Breakpoint removed at main.dreb:3",
+		"(drebdbg) Stopped (step) in <main> at main.dreb:8:8",
+		"(drebdbg) total = 0",
+		"add = Closure[",
+	}
+	actual := strings.Split(out.String(), "\n")
+	for i, want := range expected {
+		if i >= len(actual) || !strings.HasPrefix(actual[i], want) {
+			t.Fatalf("wrong console output at line %d.\nwant=%q\ngot:\n%s", i, want, out.String())
+		}
+	}
+}
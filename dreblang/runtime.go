@@ -0,0 +1,197 @@
+// Package dreblang embeds Dreblang in Go programs. A Runtime evaluates
+// source with state that persists between evaluations, and converts values
+// between Go and Dreblang on the way in and out with object.FromGo and
+// object.ToGo.
+package dreblang
+
+import (
+	"context"
+	"errors"
+	"fmt"
+
+	"github.com/dreblang/core/ast"
+	"github.com/dreblang/core/compiler"
+	"github.com/dreblang/core/lexer"
+	"github.com/dreblang/core/object"
+	"github.com/dreblang/core/parser"
+	"github.com/dreblang/core/vm"
+)
+
+// Options configure a Runtime.
+type Options struct {
+	// Config configures the VM of every evaluation and call. Zero fields
+	// take their value from vm.DefaultConfig.
+	Config vm.Config
+
+	// Sandbox, if set, restricts the modules scripts may load.
+	Sandbox *compiler.Sandbox
+}
+
+// Runtime evaluates Dreblang source. Globals defined by one evaluation, or
+// with Set, are visible to the next, like in the REPL. A Runtime must not be
+// used by several goroutines at once.
+type Runtime struct {
+	options Options
+
+	symbolTable *compiler.SymbolTable
+	constants   []object.Object
+	globals     []object.Object
+}
+
+// New returns a Runtime with no globals defined yet.
+func New(options Options) *Runtime {
+	return &Runtime{
+		options:     options,
+		symbolTable: compiler.NewSymbolTable(),
+		constants:   []object.Object{},
+		globals:     []object.Object{},
+	}
+}
+
+// Eval evaluates src and returns the value of its last expression
+// statement, converted to Go.
+func (rt *Runtime) Eval(src string) (any, error) {
+	return rt.EvalContext(context.Background(), src)
+}
+
+// EvalContext is Eval stopping once ctx is done.
+func (rt *Runtime) EvalContext(ctx context.Context, src string) (result any, err error) {
+	defer recoverPanic(&err)
+
+	p := parser.New(lexer.New(src))
+	program := p.ParseProgram()
+	if len(p.Errors()) != 0 {
+		errs := make([]error, len(p.Errors()))
+		for i, err := range p.Errors() {
+			errs[i] = err
+		}
+		return nil, errors.Join(errs...)
+	}
+
+	comp := compiler.NewWithState(rt.symbolTable, rt.constants)
+	if rt.options.Sandbox != nil {
+		comp.UseSandbox(*rt.options.Sandbox)
+	}
+	if err := comp.Compile(program); err != nil {
+		return nil, err
+	}
+	bytecode := comp.Bytecode()
+	rt.constants = bytecode.Constants
+
+	machine := vm.NewWithConfigAndGlobalsStore(bytecode, rt.options.Config, rt.globals)
+	err = machine.RunContext(ctx)
+	rt.globals = machine.GlobalsStore()
+	if err != nil {
+		return nil, err
+	}
+
+	if n := len(program.Statements); n == 0 {
+		return nil, nil
+	} else if _, ok := program.Statements[n-1].(*ast.ExpressionStatement); !ok {
+		return nil, nil
+	}
+	return toGo(machine.LastPoppedStackElem())
+}
+
+// Get returns the value of the global name, converted to Go. Values with no
+// Go counterpart, such as functions, are returned as object.Object.
+func (rt *Runtime) Get(name string) (any, error) {
+	obj, err := rt.global(name)
+	if err != nil {
+		return nil, err
+	}
+	return toGo(obj)
+}
+
+// Set defines the global name, or changes its value, to value converted to
+// Dreblang.
+func (rt *Runtime) Set(name string, value any) error {
+	obj, err := object.FromGo(value)
+	if err != nil {
+		return fmt.Errorf("%s: %w", name, err)
+	}
+
+	symbol := rt.symbolTable.Define(name)
+	if symbol.Scope != compiler.GlobalScope {
+		return fmt.Errorf("%s: cannot redefine a builtin", name)
+	}
+
+	for len(rt.globals) <= symbol.Index {
+		rt.globals = append(rt.globals, nil)
+	}
+	rt.globals[symbol.Index] = obj
+	return nil
+}
+
+// Call calls the function in the global name with args converted to
+// Dreblang, and returns its result converted to Go.
+func (rt *Runtime) Call(name string, args ...any) (any, error) {
+	return rt.CallContext(context.Background(), name, args...)
+}
+
+// CallContext is Call stopping once ctx is done.
+func (rt *Runtime) CallContext(ctx context.Context, name string, args ...any) (result any, err error) {
+	defer recoverPanic(&err)
+
+	fn, err := rt.global(name)
+	if err != nil {
+		return nil, err
+	}
+
+	objs := make([]object.Object, len(args))
+	for i, arg := range args {
+		if objs[i], err = object.FromGo(arg); err != nil {
+			return nil, fmt.Errorf("argument %d: %w", i+1, err)
+		}
+	}
+
+	machine := vm.NewWithConfigAndGlobalsStore(&compiler.Bytecode{Constants: rt.constants}, rt.options.Config, rt.globals)
+	obj, err := machine.CallContext(ctx, fn, objs...)
+	rt.globals = machine.GlobalsStore()
+	if err != nil {
+		return nil, err
+	}
+	return toGo(obj)
+}
+
+// GetInto stores the value of the global name in the Go value target points
+// to, converted with object.ToGo.
+func (rt *Runtime) GetInto(name string, target any) error {
+	obj, err := rt.global(name)
+	if err != nil {
+		return err
+	}
+	if err := object.ToGo(obj, target); err != nil {
+		return fmt.Errorf("%s: %w", name, err)
+	}
+	return nil
+}
+
+// global returns the value of the global name.
+func (rt *Runtime) global(name string) (object.Object, error) {
+	symbol, ok := rt.symbolTable.Resolve(name)
+	if !ok || symbol.Scope != compiler.GlobalScope {
+		return nil, fmt.Errorf("undefined variable %s", name)
+	}
+	if symbol.Index >= len(rt.globals) || rt.globals[symbol.Index] == nil {
+		return vm.Null, nil
+	}
+	return rt.globals[symbol.Index], nil
+}
+
+// recoverPanic turns a panic of the VM, or of a Go function the script
+// called, into the error of the evaluation or call that caused it.
+func recoverPanic(err *error) {
+	if r := recover(); r != nil {
+		*err = fmt.Errorf("internal error: %v", r)
+	}
+}
+
+// toGo converts obj to its natural Go value.
+func toGo(obj object.Object) (any, error) {
+	var value any
+	if err := object.ToGo(obj, &value); err != nil {
+		return nil, err
+	}
+	return value, nil
+}
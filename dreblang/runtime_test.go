@@ -0,0 +1,294 @@
+package dreblang
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"reflect"
+	"testing"
+	"time"
+
+	"github.com/dreblang/core/compiler"
+	"github.com/dreblang/core/object"
+	"github.com/dreblang/core/vm"
+)
+
+func TestEval(t *testing.T) {
+	rt := New(Options{})
+
+	// Each evaluation sees the globals of the ones before
+	tests := []struct {
+		input    string
+		expected any
+	}{
+		{`let a = 1;`, nil},
+		{`a + 2`, int64(3)},
+		{`let f = fn(x) { x * a }; f(2.5)`, 2.5},
+		{`[a, "b", true, if (false) { 1 }]`, []any{int64(1), "b", true, nil}},
+		{`{"a": a}`, map[any]any{"a": int64(1)}},
+		{``, nil},
+	}
+
+	for _, tt := range tests {
+		result, err := rt.Eval(tt.input)
+		if err != nil {
+			t.Fatalf("eval error for %q: %s", tt.input, err)
+		}
+		if !reflect.DeepEqual(result, tt.expected) {
+			t.Errorf("wrong result for %q. want=%#v, got=%#v", tt.input, tt.expected, result)
+		}
+	}
+}
+
+func TestGetSet(t *testing.T) {
+	rt := New(Options{})
+
+	values := map[string]any{
+		"name": "world",
+		"n":    41,
+		"xs":   []any{1, "a"},
+		"h":    map[string]any{"k": 1.5},
+		"raw":  []byte("ab"),
+		"ok":   true,
+		"none": nil,
+		"same": &object.Builtin{Fn: func(vm object.VM, args ...object.Object) object.Object { return args[0] }},
+	}
+	for name, value := range values {
+		if err := rt.Set(name, value); err != nil {
+			t.Fatalf("set error for %s: %s", name, err)
+		}
+	}
+
+	tests := []struct {
+		input    string
+		expected any
+	}{
+		{`"hello " + name`, "hello world"},
+		{`n + 1`, int64(42)},
+		{`xs[1]`, "a"},
+		{`h["k"]`, 1.5},
+		{`len(xs) + same(1)`, int64(3)},
+	}
+	for _, tt := range tests {
+		result, err := rt.Eval(tt.input)
+		if err != nil {
+			t.Fatalf("eval error for %q: %s", tt.input, err)
+		}
+		if !reflect.DeepEqual(result, tt.expected) {
+			t.Errorf("wrong result for %q. want=%#v, got=%#v", tt.input, tt.expected, result)
+		}
+	}
+
+	if _, err := rt.Eval(`n = n + 1; let m = 7;`); err != nil {
+		t.Fatalf("eval error: %s", err)
+	}
+	gets := map[string]any{
+		"n":    int64(42),
+		"m":    int64(7),
+		"xs":   []any{int64(1), "a"},
+		"h":    map[any]any{"k": 1.5},
+		"raw":  []byte("ab"),
+		"ok":   true,
+		"none": nil,
+	}
+	for name, expected := range gets {
+		value, err := rt.Get(name)
+		if err != nil {
+			t.Fatalf("get error for %s: %s", name, err)
+		}
+		if !reflect.DeepEqual(value, expected) {
+			t.Errorf("wrong value for %s. want=%#v, got=%#v", name, expected, value)
+		}
+	}
+}
+
+func TestGoValues(t *testing.T) {
+	type point struct {
+		X, Y int
+		Tag  string `dreb:"tag,omitempty"`
+	}
+
+	rt := New(Options{})
+	values := map[string]any{
+		"origin": point{X: 1, Y: 2},
+		"scale":  func(p point, k int) point { return point{X: p.X * k, Y: p.Y * k, Tag: "scaled"} },
+		"parse": func(s string) (int, error) {
+			if s == "" {
+				return 0, errors.New("empty")
+			}
+			return len(s), nil
+		},
+	}
+	for name, value := range values {
+		if err := rt.Set(name, value); err != nil {
+			t.Fatalf("set error for %s: %s", name, err)
+		}
+	}
+
+	if _, err := rt.Eval(`let p = scale(origin, 3); let n = parse("abc"); let e = try { parse("") } catch (e) { e.message }`); err != nil {
+		t.Fatalf("eval error: %s", err)
+	}
+
+	var p point
+	if err := rt.GetInto("p", &p); err != nil {
+		t.Fatalf("get error: %s", err)
+	}
+	if p != (point{X: 3, Y: 6, Tag: "scaled"}) {
+		t.Errorf("wrong point. got=%+v", p)
+	}
+
+	var n int
+	var e string
+	if err := rt.GetInto("n", &n); err != nil || n != 3 {
+		t.Errorf("wrong n. got=%d (%v)", n, err)
+	}
+	if err := rt.GetInto("e", &e); err != nil || e != "empty" {
+		t.Errorf("wrong e. got=%q (%v)", e, err)
+	}
+	if err := rt.GetInto("p", &n); err == nil || err.Error() != "p: cannot convert Hash to int" {
+		t.Errorf("wrong error. got=%v", err)
+	}
+}
+
+func TestCall(t *testing.T) {
+	rt := New(Options{})
+	_, err := rt.Eval(`
+let total = 0;
+let add = fn(n) { total = total + n; total };
+let fail = fn() { throw "boom" };
+`)
+	if err != nil {
+		t.Fatalf("eval error: %s", err)
+	}
+
+	for _, n := range []int{5, 10} {
+		if _, err := rt.Call("add", n); err != nil {
+			t.Fatalf("call error: %s", err)
+		}
+	}
+	total, err := rt.Get("total")
+	if err != nil {
+		t.Fatalf("get error: %s", err)
+	}
+	if total != int64(15) {
+		t.Errorf("wrong total. want=15, got=%#v", total)
+	}
+
+	// Functions can be passed around as objects
+	add, err := rt.Get("add")
+	if err != nil {
+		t.Fatalf("get error: %s", err)
+	}
+	if err := rt.Set("inc", add); err != nil {
+		t.Fatalf("set error: %s", err)
+	}
+	result, err := rt.Call("inc", 1)
+	if err != nil {
+		t.Fatalf("call error: %s", err)
+	}
+	if result != int64(16) {
+		t.Errorf("wrong result. want=16, got=%#v", result)
+	}
+
+	_, err = rt.Call("fail")
+	var rerr *vm.RuntimeError
+	if !errors.As(err, &rerr) || rerr.Message != "boom" {
+		t.Errorf("expected RuntimeError boom. got=%T (%+v)", err, err)
+	}
+}
+
+func TestCallContext(t *testing.T) {
+	rt := New(Options{})
+	if _, err := rt.Eval(`let spin = fn() { loop (true) {} }; let id = fn(x) { x }`); err != nil {
+		t.Fatalf("eval error: %s", err)
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Millisecond)
+	defer cancel()
+	_, err := rt.CallContext(ctx, "spin")
+	if !errors.Is(err, context.DeadlineExceeded) {
+		t.Errorf("expected deadline exceeded. got=%T (%+v)", err, err)
+	}
+
+	result, err := rt.CallContext(context.Background(), "id", "x")
+	if err != nil || result != "x" {
+		t.Errorf("wrong result. got=%#v (%v)", result, err)
+	}
+}
+
+func TestRuntimePanics(t *testing.T) {
+	rt := New(Options{})
+	if err := rt.Set("explode", func() { panic("boom") }); err != nil {
+		t.Fatalf("set error: %s", err)
+	}
+	if _, err := rt.Eval(`let f = fn() { explode() }; let n = 1;`); err != nil {
+		t.Fatalf("eval error: %s", err)
+	}
+
+	if _, err := rt.Eval(`explode()`); err == nil || err.Error() != "internal error: boom" {
+		t.Errorf("wrong eval error. got=%v", err)
+	}
+	if _, err := rt.Call("f"); err == nil || err.Error() != "internal error: boom" {
+		t.Errorf("wrong call error. got=%v", err)
+	}
+
+	// The runtime is still usable
+	if result, err := rt.Eval(`n + 1`); err != nil || result != int64(2) {
+		t.Errorf("wrong result. got=%#v (%v)", result, err)
+	}
+}
+
+func TestRuntimeErrors(t *testing.T) {
+	rt := New(Options{})
+
+	tests := []struct {
+		run      func() error
+		expected string
+	}{
+		{func() error { _, err := rt.Eval(`let = 1`); return err }, "1:5: expected next token to be Identifier, got = instead"},
+		{func() error { _, err := rt.Eval(`b`); return err }, "1:1: undefined variable b"},
+		{func() error { _, err := rt.Eval(`1 + "a"`); return err }, "type mismatch: Integer + String"},
+		{func() error { _, err := rt.Get("missing"); return err }, "undefined variable missing"},
+		{func() error { _, err := rt.Call("missing"); return err }, "undefined variable missing"},
+		{func() error { return rt.Set("print", 1) }, "print: cannot redefine a builtin"},
+		{func() error { return rt.Set("c", make(chan int)) }, "c: cannot convert chan int"},
+		{func() error { var n int; return rt.GetInto("missing", &n) }, "undefined variable missing"},
+		{func() error { _, err := rt.Eval(`let a = [1]; a[0] = a; a`); return err }, "[0]: cannot convert cyclic Array"},
+	}
+
+	for i, tt := range tests {
+		err := tt.run()
+		if err == nil {
+			t.Errorf("test %d: expected error %q. got none", i, tt.expected)
+			continue
+		}
+		if err.Error() != tt.expected {
+			t.Errorf("test %d: wrong error. want=%q, got=%q", i, tt.expected, err)
+		}
+	}
+}
+
+func TestOptions(t *testing.T) {
+	var out bytes.Buffer
+	rt := New(Options{
+		Config:  vm.Config{Output: &out, MaxInstructions: 1000},
+		Sandbox: &compiler.Sandbox{},
+	})
+
+	if _, err := rt.Eval(`print("hi")`); err != nil {
+		t.Fatalf("eval error: %s", err)
+	}
+	if out.String() != "hi\n" {
+		t.Errorf("wrong output. got=%q", out.String())
+	}
+
+	if _, err := rt.Eval(`load os`); err == nil || err.Error() != "1:6: module os is not allowed" {
+		t.Errorf("expected sandbox error. got=%v", err)
+	}
+
+	_, err := rt.Eval(`loop (true) {}`)
+	var rerr *vm.RuntimeError
+	if !errors.As(err, &rerr) || rerr.Kind != object.InstructionLimitError {
+		t.Errorf("expected instruction limit error. got=%T (%+v)", err, err)
+	}
+}
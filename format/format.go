@@ -0,0 +1,29 @@
+// Package format prints Dreblang source in its canonical layout. The output
+// parses to the same program, keeps every comment and formats to itself.
+package format
+
+import (
+	"errors"
+	"strings"
+
+	"github.com/dreblang/core/lexer"
+	"github.com/dreblang/core/parser"
+)
+
+// Source formats the program in src. Syntax errors are returned, one per
+// line, with positions in filename.
+func Source(filename string, src []byte) ([]byte, error) {
+	p := parser.New(lexer.NewWithFilename(string(src), filename))
+	program := p.ParseProgram()
+	if len(p.Errors()) != 0 {
+		msgs := make([]string, len(p.Errors()))
+		for i, err := range p.Errors() {
+			msgs[i] = err.Error()
+		}
+		return nil, errors.New(strings.Join(msgs, "\n"))
+	}
+
+	pr := newPrinter(string(src), program.Comments)
+	pr.program(program)
+	return pr.out.Bytes(), nil
+}
@@ -0,0 +1,164 @@
+package format
+
+import (
+	"testing"
+
+	"github.com/dreblang/core/lexer"
+	"github.com/dreblang/core/parser"
+)
+
+func TestSource(t *testing.T) {
+	tests := []struct {
+		input    string
+		expected string
+	}{
+		{"", ""},
+		{"let a=1+2*3", "let a = 1 + 2 * 3;\n"},
+		{"let a = (1 + 2) * 3; a - (b - c); (a - b) - c", "let a = (1 + 2) * 3;\na - (b - c);\na - b - c;\n"},
+		{"-(a + b); -a.b; (-a)(1); !(a == b); --a", "-(a + b);\n-a.b;\n(-a)(1);\n!(a == b);\n--a;\n"},
+		{"a.b(1).c[0]::d; (a + b)(1); (throw a)(1)", "a.b(1).c[0]::d;\n(a + b)(1);\n(throw a)(1);\n"},
+		{"a[1,3]; a[,,2]; a[1,]; a[,2]; a[1,,2]", "a[1, 3];\na[,, 2];\na[1,];\na[, 2];\na[1,, 2];\n"},
+		{`"say \"hi\""; 'it''s'; ` + "`a\nb`", "\"say \\\"hi\\\"\";\n\"it\";\n\"s\";\n`a\nb`;\n"},
+		{"let f = fn(x,y){ return x+y }", "let f = fn(x, y) {\n\treturn x + y;\n};\n"},
+		{"let f = fn() {}; [ ]; {}", "let f = fn() {};\n[];\n{};\n"},
+		{"let n:int=1; let f = fn(a:int, b)->bool { a }", "let n: int = 1;\nlet f = fn(a: int, b) -> bool {\n\ta;\n};\n"},
+		{"let h = {\"a\": 1, b: [1,2]}", "let h = {\"a\": 1, b: [1, 2]};\n"},
+		{
+			"load math\nscope s { let x = 1; export x }",
+			"load math;\nscope s {\n\tlet x = 1;\n\texport x;\n}\n",
+		},
+		{
+			"class A { a = 0; get = fn() { a } ; export get; }",
+			"class A {\n\ta = 0;\n\tget = fn() {\n\t\ta;\n\t};\n\texport get;\n}\n",
+		},
+		{"iter i over [1, 2] { print(i) }", "iter i over [1, 2] {\n\tprint(i);\n}\n"},
+		{
+			"if (a) { 1 } else { 2 }\nloop (a < 3) { a = a + 1 }",
+			"if (a) {\n\t1;\n} else {\n\t2;\n}\nloop (a < 3) {\n\ta = a + 1;\n}\n",
+		},
+		{
+			"try { throw \"x\" } catch (e) { e } finally { 1 }\ntry { 1 } catch { 2 }",
+			"try {\n\tthrow \"x\";\n} catch (e) {\n\te;\n} finally {\n\t1;\n}\ntry {\n\t1;\n} catch {\n\t2;\n}\n",
+		},
+		// A statement starting with - after a block would be parsed as infix
+		{"if (a) { 1 };\n-b", "if (a) {\n\t1;\n};\n-b;\n"},
+		{"loop (a) { 1 };\n(-b)(1)", "loop (a) {\n\t1;\n};\n(-b)(1);\n"},
+		// Blank lines are kept, but never more than one
+		{"let a = 1;\n\n\n\nlet b = 2;\nlet c = 3;", "let a = 1;\n\nlet b = 2;\nlet c = 3;\n"},
+		{"let f = fn() {\n\n\tlet a = 1;\n\n\ta\n}", "let f = fn() {\n\tlet a = 1;\n\n\ta;\n};\n"},
+	}
+
+	for _, tt := range tests {
+		output, err := Source("", []byte(tt.input))
+		if err != nil {
+			t.Errorf("format error for %q: %s", tt.input, err)
+			continue
+		}
+		if string(output) != tt.expected {
+			t.Errorf("wrong output for %q.\nwant=%q\ngot =%q", tt.input, tt.expected, output)
+		}
+	}
+}
+
+func TestComments(t *testing.T) {
+	tests := []struct {
+		input    string
+		expected string
+	}{
+		{"// only a comment", "// only a comment\n"},
+		{"let a = 1; //trailing   \n// own line\nlet b = 2", "let a = 1; //trailing\n// own line\nlet b = 2;\n"},
+		{"// header\n\nlet a = 1;\n\n// footer", "// header\n\nlet a = 1;\n\n// footer\n"},
+		{
+			"let f = fn() { // opens\n\t// first\n\ta\n\t// last\n}",
+			"let f = fn() { // opens\n\t// first\n\ta;\n\t// last\n};\n",
+		},
+		{"let f = fn() {\n// nothing yet\n}", "let f = fn() {\n\t// nothing yet\n};\n"},
+		{
+			"let h = {\n  \"x\": 1, // first\n  // own line\n  \"y\": 2 // last\n}",
+			"let h = {\n\t\"x\": 1, // first\n\t// own line\n\t\"y\": 2 // last\n};\n",
+		},
+		// Comments inside single line expressions move after the statement
+		{"let a = 1 + // one\n2;\nlet b = 3;", "let a = 1 + 2; // one\nlet b = 3;\n"},
+		{"let a = [1, // one\n2];", "let a = [\n\t1, // one\n\t2\n];\n"},
+	}
+
+	for _, tt := range tests {
+		output, err := Source("", []byte(tt.input))
+		if err != nil {
+			t.Errorf("format error for %q: %s", tt.input, err)
+			continue
+		}
+		if string(output) != tt.expected {
+			t.Errorf("wrong output for %q.\nwant=%q\ngot =%q", tt.input, tt.expected, output)
+		}
+	}
+}
+
+const program = `// Every construct the formatter knows about
+load math
+
+let a = 1+2*3;   // trailing
+let s = "he said \"hi\"";let r = 'single';
+let raw = ` + "`line one\nline two`" + `;
+let add = fn(x,y){ return x+y }
+scope util {
+    // helper
+    double = fn(x) { x * 2 };
+    export double;
+}
+class Counter { count = 0; inc = fn() { count = count + 1 }; export inc }
+iter i over [1,2,3] { print(i) }
+let arr = [1, 2, 3, 4];
+print(arr[1, 3], arr[,,2], arr[1,], arr[, 2], arr[1,,2], arr[0]);
+let big = {
+  "x": 1, // first
+  // own line
+  "y": {a: [1,
+  2]}
+}
+let t = try { throw "x" } catch (e) { e.message } finally { print("done") }
+if (a > 1) { print("big") } else { print("small") }
+loop (a < 10) { a = a + 1 }
+let m = -(a.b) + (-a).b;
+let c = a.b(1).c[0]::d + (a + b)(1) * !(a == b);
+let k = (a = b) = c;
+let g = a = (b = c);
+let x = 1.50 + 010 - 0.5;
+`
+
+func TestRoundTrip(t *testing.T) {
+	first, err := Source("", []byte(program))
+	if err != nil {
+		t.Fatalf("format error: %s", err)
+	}
+
+	if parse(t, program) != parse(t, string(first)) {
+		t.Errorf("formatting changed the program.\nbefore=%s\nafter =%s", parse(t, program), parse(t, string(first)))
+	}
+
+	second, err := Source("", first)
+	if err != nil {
+		t.Fatalf("format error: %s", err)
+	}
+	if string(first) != string(second) {
+		t.Errorf("formatting is not idempotent.\nfirst:\n%s\nsecond:\n%s", first, second)
+	}
+}
+
+func TestSyntaxErrors(t *testing.T) {
+	_, err := Source("main.dreb", []byte("let = 1;\nlet b = ;"))
+	expected := "main.dreb:1:5: expected next token to be Identifier, got = instead\n" +
+		"main.dreb:2:9: no prefix parse function for ; found"
+	if err == nil || err.Error() != expected {
+		t.Errorf("wrong error.\nwant=%q\ngot =%v", expected, err)
+	}
+}
+
+func parse(t *testing.T, input string) string {
+	p := parser.New(lexer.New(input))
+	program := p.ParseProgram()
+	if len(p.Errors()) != 0 {
+		t.Fatalf("parser errors: %v", p.Errors())
+	}
+	return program.String()
+}
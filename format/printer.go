@@ -0,0 +1,454 @@
+package format
+
+import (
+	"bytes"
+	"math"
+	"strconv"
+	"strings"
+	"unicode/utf8"
+
+	"github.com/dreblang/core/ast"
+	"github.com/dreblang/core/parser"
+	"github.com/dreblang/core/token"
+)
+
+// Binds tighter than any operator, for literals and other expressions that
+// never need parentheses
+const atomic = parser.Index + 1
+
+var endOfFile = token.Position{Line: math.MaxInt32}
+
+type printer struct {
+	out    bytes.Buffer
+	indent int
+
+	// Nothing has been written on the current line yet
+	lineStart bool
+	// The current line ends in a comment
+	lineComment bool
+	// At the start of a block, where blank lines are dropped
+	first bool
+
+	// Source lines, to keep blank lines and tell trailing comments apart
+	lines    []string
+	comments []*ast.Comment
+}
+
+func newPrinter(src string, comments []*ast.Comment) *printer {
+	return &printer{
+		lineStart: true,
+		first:     true,
+		lines:     strings.Split(src, "\n"),
+		comments:  comments,
+	}
+}
+
+func (p *printer) write(s string) {
+	if p.lineStart && s != "" {
+		p.out.WriteString(strings.Repeat("\t", p.indent))
+		p.lineStart = false
+	}
+	p.out.WriteString(s)
+}
+
+func (p *printer) newline() {
+	p.out.WriteString("\n")
+	p.lineStart = true
+	p.lineComment = false
+}
+
+// startLine begins a line for something found at pos in the source,
+// keeping a blank line above it if the source had one.
+func (p *printer) startLine(pos token.Position) {
+	if !p.lineStart {
+		p.newline()
+	}
+	if !p.first && p.blankBefore(pos) {
+		p.newline()
+	}
+	p.first = false
+}
+
+func (p *printer) blankBefore(pos token.Position) bool {
+	line := pos.Line - 1
+	return line >= 1 && line <= len(p.lines) && strings.TrimSpace(p.lines[line-1]) == ""
+}
+
+// trailing reports whether c follows code on its line in the source.
+func (p *printer) trailing(c *ast.Comment) bool {
+	pos := c.Pos()
+	if pos.Line < 1 || pos.Line > len(p.lines) {
+		return false
+	}
+	line := p.lines[pos.Line-1]
+	return pos.Column-1 <= len(line) && strings.TrimSpace(line[:pos.Column-1]) != ""
+}
+
+// flush prints the comments that come before pos in the source. Trailing
+// comments stay at the end of the current line, the others get their own.
+func (p *printer) flush(pos token.Position) {
+	for len(p.comments) > 0 && before(p.comments[0].Pos(), pos) {
+		c := p.comments[0]
+		p.comments = p.comments[1:]
+
+		if p.lineStart || p.lineComment || !p.trailing(c) {
+			p.startLine(c.Pos())
+		} else {
+			p.write(" ")
+		}
+		p.write(token.DoubleSlash + strings.TrimRight(c.Text, " \t\r"))
+		p.lineComment = true
+	}
+}
+
+func (p *printer) hasComments(pos token.Position) bool {
+	return len(p.comments) > 0 && before(p.comments[0].Pos(), pos)
+}
+
+func before(a, b token.Position) bool {
+	return a.Line < b.Line || a.Line == b.Line && a.Column < b.Column
+}
+
+// Statements
+
+func (p *printer) program(program *ast.Program) {
+	p.statements(program.Statements, endOfFile)
+	if !p.lineStart {
+		p.newline()
+	}
+}
+
+func (p *printer) statements(stmts []ast.Statement, end token.Position) {
+	for i, stmt := range stmts {
+		p.flush(stmt.Pos())
+		p.startLine(stmt.Pos())
+		p.statement(stmt)
+
+		if needsSemicolon(stmt) || i+1 < len(stmts) && continues(stmts[i+1]) {
+			p.write(token.Semicolon)
+		}
+	}
+	p.flush(end)
+}
+
+// needsSemicolon reports whether stmt is terminated by a semicolon.
+// Statements ending in a block read better without one.
+func needsSemicolon(stmt ast.Statement) bool {
+	switch stmt := stmt.(type) {
+	case *ast.ScopeDefinition, *ast.ClassDefinition, *ast.IterStatement:
+		return false
+	case *ast.ExpressionStatement:
+		switch stmt.Expression.(type) {
+		case *ast.IfExpression, *ast.LoopExpression, *ast.TryExpression:
+			return false
+		}
+	}
+	return true
+}
+
+// continues reports whether stmt starts with a token that would extend an
+// expression before it, so that expression needs its semicolon after all.
+func continues(stmt ast.Statement) bool {
+	es, ok := stmt.(*ast.ExpressionStatement)
+	if !ok {
+		return false
+	}
+
+	p := newPrinter("", nil)
+	p.expression(es.Expression)
+	return strings.IndexAny(p.out.String(), "-([") == 0
+}
+
+func (p *printer) statement(stmt ast.Statement) {
+	switch stmt := stmt.(type) {
+	case *ast.LetStatement:
+		p.write("let " + stmt.Name.Value)
+		if stmt.Type != nil {
+			p.write(": " + stmt.Type.Name)
+		}
+		p.write(" = ")
+		p.expression(stmt.Value)
+
+	case *ast.ReturnStatement:
+		p.write("return ")
+		p.expression(stmt.ReturnValue)
+
+	case *ast.ExportStatement:
+		p.write("export " + stmt.Identifier.Value)
+
+	case *ast.LoadStatement:
+		p.write("load " + stmt.Identifier.Value)
+
+	case *ast.ExpressionStatement:
+		p.expression(stmt.Expression)
+
+	case *ast.ScopeDefinition:
+		p.write("scope " + stmt.Name.Value + " ")
+		p.block(stmt.Block)
+
+	case *ast.ClassDefinition:
+		p.write("class " + stmt.Name.Value + " ")
+		p.block(stmt.Block)
+
+	case *ast.IterStatement:
+		p.write("iter " + stmt.Identifier.Value + " over ")
+		p.expression(stmt.Expression)
+		p.write(" ")
+		p.block(stmt.Statements)
+	}
+}
+
+func (p *printer) block(block *ast.BlockStatement) {
+	if len(block.Statements) == 0 && !p.hasComments(block.End) {
+		p.write("{}")
+		return
+	}
+
+	p.write(token.LeftBrace)
+	p.indent++
+	p.first = true
+	p.statements(block.Statements, block.End)
+	p.indent--
+	p.newline()
+	p.write(token.RightBrace)
+}
+
+// Expressions
+
+func (p *printer) expression(exp ast.Expression) {
+	switch exp := exp.(type) {
+	case *ast.Identifier:
+		p.write(exp.Value)
+	case *ast.IntegerLiteral:
+		p.write(exp.Token.Literal)
+	case *ast.FloatLiteral:
+		p.write(exp.Token.Literal)
+	case *ast.StringLiteral:
+		p.write(quote(exp.Value))
+	case *ast.Boolean:
+		p.write(strconv.FormatBool(exp.Value))
+
+	case *ast.PrefixExpression:
+		p.write(exp.Operator)
+		_, nested := exp.Right.(*ast.PrefixExpression)
+		p.operand(exp.Right, !nested && precedence(exp.Right) <= parser.Prefix)
+
+	case *ast.InfixExpression:
+		prec := parser.Precedence(exp.Operator)
+		p.operand(exp.Left, leftNeedsParens(exp.Left, prec))
+		if isMember(exp) {
+			p.write(exp.Operator)
+			if name, ok := exp.Right.(*ast.StringLiteral); ok {
+				p.write(name.Value)
+			} else {
+				p.expression(exp.Right)
+			}
+			return
+		}
+		p.write(" " + exp.Operator + " ")
+		p.operand(exp.Right, precedence(exp.Right) <= prec)
+
+	case *ast.CallExpression:
+		p.operand(exp.Function, leftNeedsParens(exp.Function, parser.Call))
+		p.write(token.LeftParen)
+		p.list(exp.Arguments)
+		p.write(token.RightParen)
+
+	case *ast.IndexExpression:
+		p.operand(exp.Left, leftNeedsParens(exp.Left, parser.Index))
+		p.write(token.LeftBracket)
+		p.index(exp)
+		p.write(token.RightBracket)
+
+	case *ast.ArrayLiteral:
+		p.write(token.LeftBracket)
+		p.elements(exp.Pos(), exp.End, exp.Elements, func(i int) {
+			p.expression(exp.Elements[i])
+		})
+		p.write(token.RightBracket)
+
+	case *ast.HashLiteral:
+		p.write(token.LeftBrace)
+		p.elements(exp.Pos(), exp.End, exp.Keys, func(i int) {
+			p.expression(exp.Keys[i])
+			p.write(token.Colon + " ")
+			p.expression(exp.Pairs[exp.Keys[i]])
+		})
+		p.write(token.RightBrace)
+
+	case *ast.FunctionLiteral:
+		params := make([]string, len(exp.Parameters))
+		for i, param := range exp.Parameters {
+			params[i] = param.Value
+			if t := exp.ParameterType(i); t != nil {
+				params[i] += ": " + t.Name
+			}
+		}
+		p.write("fn(" + strings.Join(params, token.Comma+" ") + ") ")
+		if exp.ReturnType != nil {
+			p.write("-> " + exp.ReturnType.Name + " ")
+		}
+		p.block(exp.Body)
+
+	case *ast.IfExpression:
+		p.write("if (")
+		p.expression(exp.Condition)
+		p.write(") ")
+		p.block(exp.Consequence)
+		if exp.Alternative != nil {
+			p.write(" else ")
+			p.block(exp.Alternative)
+		}
+
+	case *ast.LoopExpression:
+		p.write("loop (")
+		p.expression(exp.Condition)
+		p.write(") ")
+		p.block(exp.Consequence)
+
+	case *ast.TryExpression:
+		p.write("try ")
+		p.block(exp.Block)
+		if exp.Catch != nil {
+			p.write(" catch ")
+			if exp.Parameter != nil {
+				p.write("(" + exp.Parameter.Value + ") ")
+			}
+			p.block(exp.Catch)
+		}
+		if exp.Finally != nil {
+			p.write(" finally ")
+			p.block(exp.Finally)
+		}
+
+	case *ast.ThrowExpression:
+		p.write("throw ")
+		p.expression(exp.Value)
+	}
+}
+
+func (p *printer) operand(exp ast.Expression, parens bool) {
+	if parens {
+		p.write(token.LeftParen)
+		p.expression(exp)
+		p.write(token.RightParen)
+		return
+	}
+	p.expression(exp)
+}
+
+func (p *printer) list(exps []ast.Expression) {
+	for i, exp := range exps {
+		if i > 0 {
+			p.write(token.Comma + " ")
+		}
+		p.expression(exp)
+	}
+}
+
+// elements prints the comma separated items of a literal between open and
+// end. They go one per line, with the comments between them, if the first
+// one started on a new line or there are comments inside.
+func (p *printer) elements(open, end token.Position, items []ast.Expression, print func(int)) {
+	multiline := len(items) > 0 && (start(items[0]).Line > open.Line ||
+		p.hasComments(end) && before(open, p.comments[0].Pos()))
+
+	if !multiline {
+		for i := range items {
+			if i > 0 {
+				p.write(token.Comma + " ")
+			}
+			print(i)
+		}
+		return
+	}
+
+	p.indent++
+	p.first = true
+	for i, item := range items {
+		pos := start(item)
+		p.flush(pos)
+		p.startLine(pos)
+		print(i)
+		if i+1 < len(items) {
+			p.write(token.Comma)
+		}
+	}
+	p.flush(end)
+	p.indent--
+	p.newline()
+}
+
+// index prints the inside of a[i], a[i, j] or a[i, j, k], any part of which
+// may be left out.
+func (p *printer) index(exp *ast.IndexExpression) {
+	if exp.Index != nil {
+		p.expression(exp.Index)
+	}
+	if exp.HasUpper {
+		p.write(token.Comma)
+		if exp.IndexUpper != nil {
+			p.write(" ")
+			p.expression(exp.IndexUpper)
+		}
+	}
+	if exp.HasSkip {
+		p.write(token.Comma)
+		if exp.IndexSkip != nil {
+			p.write(" ")
+			p.expression(exp.IndexSkip)
+		}
+	}
+}
+
+// precedence returns how tightly exp holds together as an operand.
+func precedence(exp ast.Expression) int {
+	switch exp := exp.(type) {
+	case *ast.InfixExpression:
+		return parser.Precedence(exp.Operator)
+	case *ast.PrefixExpression:
+		return parser.Prefix
+	case *ast.ThrowExpression:
+		// throw takes everything to its right
+		return parser.Lowest
+	}
+	return atomic
+}
+
+// leftNeedsParens reports whether exp needs parentheses as the left operand
+// of an operator of precedence prec.
+func leftNeedsParens(exp ast.Expression, prec int) bool {
+	// Member access parses like a postfix operator, so chains of members,
+	// calls and indexes group to the left on their own
+	if infix, ok := exp.(*ast.InfixExpression); ok && isMember(infix) && prec >= parser.Dot {
+		return false
+	}
+	return precedence(exp) < prec
+}
+
+func isMember(exp *ast.InfixExpression) bool {
+	return exp.Operator == token.Dot || exp.Operator == token.DoubleColon
+}
+
+// start returns where exp begins in the source, the position of its
+// leftmost operand.
+func start(exp ast.Expression) token.Position {
+	switch exp := exp.(type) {
+	case *ast.InfixExpression:
+		return start(exp.Left)
+	case *ast.CallExpression:
+		return start(exp.Function)
+	case *ast.IndexExpression:
+		return start(exp.Left)
+	}
+	return exp.Pos()
+}
+
+// quote writes s as a string literal, using a raw string for multiline
+// text where it can.
+func quote(s string) string {
+	if strings.Contains(s, "\n") && !strings.ContainsAny(s, "`\r") && utf8.ValidString(s) {
+		return "`" + s + "`"
+	}
+	return strconv.Quote(s)
+}
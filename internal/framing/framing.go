@@ -0,0 +1,40 @@
+// Package framing reads and writes the Content-Length framed JSON messages
+// the debug adapter and language server protocols exchange over stdio.
+package framing
+
+import (
+	"bufio"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/textproto"
+	"strconv"
+)
+
+// MaxLength is the largest message body Read accepts.
+const MaxLength = 16 << 20
+
+// Read reads the body of one message.
+func Read(r *bufio.Reader) (json.RawMessage, error) {
+	header, err := textproto.NewReader(r).ReadMIMEHeader()
+	if err != nil {
+		return nil, err
+	}
+
+	length, err := strconv.Atoi(header.Get("Content-Length"))
+	if err != nil || length <= 0 || length > MaxLength {
+		return nil, fmt.Errorf("invalid Content-Length: %q", header.Get("Content-Length"))
+	}
+
+	body := make([]byte, length)
+	if _, err := io.ReadFull(r, body); err != nil {
+		return nil, err
+	}
+	return body, nil
+}
+
+// Write writes body with its Content-Length header.
+func Write(w io.Writer, body json.RawMessage) error {
+	_, err := fmt.Fprintf(w, "Content-Length: %d\r\n\r\n%s", len(body), body)
+	return err
+}
@@ -0,0 +1,49 @@
+package framing
+
+import (
+	"bufio"
+	"bytes"
+	"strings"
+	"testing"
+)
+
+func TestRoundTrip(t *testing.T) {
+	var buf bytes.Buffer
+	for _, body := range []string{`{"a":1}`, `[]`} {
+		if err := Write(&buf, []byte(body)); err != nil {
+			t.Fatalf("write error: %s", err)
+		}
+	}
+
+	r := bufio.NewReader(&buf)
+	for _, expected := range []string{`{"a":1}`, `[]`} {
+		body, err := Read(r)
+		if err != nil {
+			t.Fatalf("read error: %s", err)
+		}
+		if string(body) != expected {
+			t.Errorf("wrong body. want=%q, got=%q", expected, body)
+		}
+	}
+}
+
+func TestReadErrors(t *testing.T) {
+	tests := []struct {
+		input    string
+		expected string
+	}{
+		{"Content-Length: -1\r\n\r\n{}", `invalid Content-Length: "-1"`},
+		{"Content-Length: 0\r\n\r\n", `invalid Content-Length: "0"`},
+		{"Content-Length: x\r\n\r\n{}", `invalid Content-Length: "x"`},
+		{"Content-Length: 4294967296\r\n\r\n{}", `invalid Content-Length: "4294967296"`},
+		{"Content-Type: json\r\n\r\n{}", `invalid Content-Length: ""`},
+		{"Content-Length: 10\r\n\r\n{}", "unexpected EOF"},
+	}
+
+	for _, tt := range tests {
+		_, err := Read(bufio.NewReader(strings.NewReader(tt.input)))
+		if err == nil || err.Error() != tt.expected {
+			t.Errorf("wrong error for %q. want=%q, got=%v", tt.input, tt.expected, err)
+		}
+	}
+}
@@ -0,0 +1,78 @@
+// Package framingtest provides a client for testing servers that speak
+// framed JSON messages.
+package framingtest
+
+import (
+	"bufio"
+	"encoding/json"
+	"io"
+	"testing"
+	"time"
+
+	"github.com/dreblang/core/internal/framing"
+)
+
+// Client drives a server over pipes the way an editor would.
+type Client struct {
+	t    testing.TB
+	w    io.Writer
+	msgs chan json.RawMessage
+}
+
+// NewClient runs serve on one end of a pair of pipes and returns a client
+// on the other end, with a channel receiving the result of serve.
+func NewClient(t testing.TB, serve func(in io.Reader, out io.Writer) error) (*Client, chan error) {
+	serverIn, clientOut := io.Pipe()
+	clientIn, serverOut := io.Pipe()
+
+	done := make(chan error, 1)
+	go func() {
+		done <- serve(serverIn, serverOut)
+		serverOut.Close()
+	}()
+
+	c := &Client{t: t, w: clientOut, msgs: make(chan json.RawMessage, 100)}
+	go func() {
+		r := bufio.NewReader(clientIn)
+		for {
+			body, err := framing.Read(r)
+			if err != nil {
+				close(c.msgs)
+				return
+			}
+			c.msgs <- body
+		}
+	}()
+	return c, done
+}
+
+// Send writes msg to the server.
+func (c *Client) Send(msg any) {
+	c.t.Helper()
+
+	body, err := json.Marshal(msg)
+	if err == nil {
+		err = framing.Write(c.w, body)
+	}
+	if err != nil {
+		c.t.Fatalf("write failed: %s", err)
+	}
+}
+
+// Next decodes the next message from the server into msg, failing the test
+// if none arrives in time.
+func (c *Client) Next(msg any) {
+	c.t.Helper()
+
+	select {
+	case body, ok := <-c.msgs:
+		if !ok {
+			c.t.Fatalf("server closed the connection")
+		}
+		if err := json.Unmarshal(body, msg); err != nil {
+			c.t.Fatalf("bad message %s: %s", body, err)
+		}
+	case <-time.After(5 * time.Second):
+		c.t.Fatalf("timed out waiting for the server")
+	}
+}
@@ -12,10 +12,18 @@ type Lexer struct {
 	position     int
 	nextPosition int
 	ch           byte
+
+	filename string
+	line     int
+	column   int
 }
 
 func New(input string) *Lexer {
-	l := &Lexer{input: input}
+	return NewWithFilename(input, "")
+}
+
+func NewWithFilename(input string, filename string) *Lexer {
+	l := &Lexer{input: input, filename: filename, line: 1}
 	l.readChar()
 	return l
 }
@@ -24,6 +32,7 @@ func (l *Lexer) NextToken() token.Token {
 	var tok token.Token
 
 	l.skipWhitespace()
+	pos := l.currentPosition()
 
 	switch l.ch {
 	case '=':
@@ -38,7 +47,14 @@ func (l *Lexer) NextToken() token.Token {
 	case '+':
 		tok = newToken(token.Plus, l.ch)
 	case '-':
-		tok = newToken(token.Minus, l.ch)
+		if l.peekChar() == '>' {
+			ch := l.ch
+			l.readChar()
+			literal := string(ch) + string(l.ch)
+			tok = token.Token{Type: token.Arrow, Literal: literal}
+		} else {
+			tok = newToken(token.Minus, l.ch)
+		}
 	case '!':
 		if l.peekChar() == '=' {
 			ch := l.ch
@@ -121,6 +137,7 @@ func (l *Lexer) NextToken() token.Token {
 		if isLetter(l.ch) || l.ch == '_' {
 			tok.Literal = l.readIdentifier()
 			tok.Type = token.LookupIdentifierType(tok.Literal)
+			tok.Pos = pos
 			return tok
 		} else if isDigit(l.ch) {
 			literal, isFloat := l.readNumber()
@@ -130,6 +147,7 @@ func (l *Lexer) NextToken() token.Token {
 			} else {
 				tok.Type = token.Int
 			}
+			tok.Pos = pos
 			return tok
 		} else {
 			tok = newToken(token.Illegal, l.ch)
@@ -137,6 +155,7 @@ func (l *Lexer) NextToken() token.Token {
 	}
 
 	l.readChar()
+	tok.Pos = pos
 	return tok
 }
 
@@ -145,6 +164,13 @@ func newToken(tokenType token.TokenType, ch byte) token.Token {
 }
 
 func (l *Lexer) readChar() {
+	if l.ch == '\n' {
+		l.line++
+		l.column = 1
+	} else {
+		l.column++
+	}
+
 	l.ch = l.peekChar()
 	l.position = l.nextPosition
 	l.nextPosition += 1
@@ -250,6 +276,10 @@ func (l *Lexer) skipWhitespace() {
 	}
 }
 
+func (l *Lexer) currentPosition() token.Position {
+	return token.Position{Filename: l.filename, Line: l.line, Column: l.column}
+}
+
 func (l *Lexer) peekChar() byte {
 	if l.nextPosition >= len(l.input) {
 		return 0
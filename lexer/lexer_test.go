@@ -39,6 +39,7 @@ func TestNextToken(t *testing.T) {
 		"esc\"aped"
 		'esc\'aped'
 	` + "`raw\\\\string`" + `
+		-> - >
 		$
 	`
 
@@ -144,6 +145,9 @@ func TestNextToken(t *testing.T) {
 		{token.String, "esc\"aped"},
 		{token.String, "esc'aped"},
 		{token.String, "raw\\\\string"},
+		{token.Arrow, "->"},
+		{token.Minus, "-"},
+		{token.GreaterThan, ">"},
 		{token.Illegal, "$"},
 		{token.EOF, ""},
 	}
@@ -161,3 +165,44 @@ func TestNextToken(t *testing.T) {
 		}
 	}
 }
+
+func TestTokenPositions(t *testing.T) {
+	input := "let a = 5;\n  a + \"x\n y\";\n\tfoo // comment\n"
+
+	tests := []struct {
+		expectedType   token.TokenType
+		expectedLine   int
+		expectedColumn int
+	}{
+		{token.Let, 1, 1},
+		{token.Identifier, 1, 5},
+		{token.Assign, 1, 7},
+		{token.Int, 1, 9},
+		{token.Semicolon, 1, 10},
+		{token.Identifier, 2, 3},
+		{token.Plus, 2, 5},
+		{token.String, 2, 7},
+		{token.Semicolon, 3, 4},
+		{token.Identifier, 4, 2},
+		{token.DoubleSlash, 4, 6},
+		{token.EOF, 5, 1},
+	}
+
+	l := NewWithFilename(input, "test.dreb")
+
+	for i, tt := range tests {
+		tok := l.NextToken()
+		if tok.Type != tt.expectedType {
+			t.Fatalf("tests[%d] - tokenType wrong. expected=%q, got=%q (%q)", i, tt.expectedType, tok.Type, tok.Literal)
+		}
+
+		if tok.Pos.Filename != "test.dreb" {
+			t.Fatalf("tests[%d] - filename wrong. expected=%q, got=%q", i, "test.dreb", tok.Pos.Filename)
+		}
+
+		if tok.Pos.Line != tt.expectedLine || tok.Pos.Column != tt.expectedColumn {
+			t.Fatalf("tests[%d] - position wrong. expected=%d:%d, got=%d:%d", i,
+				tt.expectedLine, tt.expectedColumn, tok.Pos.Line, tok.Pos.Column)
+		}
+	}
+}
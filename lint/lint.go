@@ -0,0 +1,158 @@
+// Package lint finds likely mistakes in Dreblang programs that compile fine.
+// Each check is a Rule; a diagnostic can be silenced with a
+// "// dreblint:ignore" comment on its line or alone on the line above,
+// optionally followed by the names of the rules to ignore.
+package lint
+
+import (
+	"errors"
+	"fmt"
+	"sort"
+	"strings"
+
+	"github.com/dreblang/core/ast"
+	"github.com/dreblang/core/compiler"
+	"github.com/dreblang/core/lexer"
+	"github.com/dreblang/core/parser"
+	"github.com/dreblang/core/token"
+)
+
+const ignoreDirective = "dreblint:ignore"
+
+// Rule is one check. Run inspects the program through the pass and reports
+// what it finds.
+type Rule struct {
+	Name string
+	Doc  string
+	Run  func(p *Pass)
+}
+
+// Rules are the rules dreblint runs by default.
+var Rules = []*Rule{
+	UndeclaredAssign,
+	LenArgument,
+	UndefinedExport,
+	TypeMismatch,
+}
+
+// Lookup returns the rule in Rules called name.
+func Lookup(name string) (*Rule, bool) {
+	for _, rule := range Rules {
+		if rule.Name == name {
+			return rule, true
+		}
+	}
+	return nil, false
+}
+
+// Diagnostic is a problem a rule found at Pos.
+type Diagnostic struct {
+	Pos  token.Position
+	Rule string
+	Msg  string
+}
+
+func (d Diagnostic) String() string {
+	return fmt.Sprintf("%s: %s (%s)", d.Pos, d.Msg, d.Rule)
+}
+
+// Pass is what a rule is given to check one file.
+type Pass struct {
+	Filename string
+	Program  *ast.Program
+
+	rule        *Rule
+	diagnostics []Diagnostic
+}
+
+// Report records a problem at pos.
+func (p *Pass) Report(pos token.Position, format string, args ...interface{}) {
+	p.diagnostics = append(p.diagnostics, Diagnostic{Pos: pos, Rule: p.rule.Name, Msg: fmt.Sprintf(format, args...)})
+}
+
+// Walk calls visit for every node of the program in the order the compiler
+// compiles them, with the names defined up to that node. Definitions made
+// by a node take effect after it is visited.
+func (p *Pass) Walk(visit func(node ast.Node, env *Env)) {
+	w := &walker{visit: visit, env: &Env{Symbols: compiler.NewSymbolTable()}}
+	w.node(p.Program)
+}
+
+// Source runs rules over the program in src and returns their diagnostics,
+// sorted by position. Syntax errors are returned, one per line, with
+// positions in filename.
+func Source(filename string, src []byte, rules []*Rule) ([]Diagnostic, error) {
+	p := parser.New(lexer.NewWithFilename(string(src), filename))
+	program := p.ParseProgram()
+	if len(p.Errors()) != 0 {
+		msgs := make([]string, len(p.Errors()))
+		for i, err := range p.Errors() {
+			msgs[i] = err.Error()
+		}
+		return nil, errors.New(strings.Join(msgs, "\n"))
+	}
+
+	ignored := ignoredLines(string(src), program.Comments)
+
+	diagnostics := []Diagnostic{}
+	for _, rule := range rules {
+		pass := &Pass{Filename: filename, Program: program, rule: rule}
+		rule.Run(pass)
+
+		for _, d := range pass.diagnostics {
+			if !ignored.match(d) {
+				diagnostics = append(diagnostics, d)
+			}
+		}
+	}
+
+	sort.SliceStable(diagnostics, func(i, j int) bool {
+		a, b := diagnostics[i].Pos, diagnostics[j].Pos
+		return a.Line < b.Line || a.Line == b.Line && a.Column < b.Column
+	})
+	return diagnostics, nil
+}
+
+// ignores maps line numbers to the rules ignored on them. An empty list
+// ignores every rule.
+type ignores map[int][]string
+
+func (ig ignores) match(d Diagnostic) bool {
+	rules, ok := ig[d.Pos.Line]
+	if !ok {
+		return false
+	}
+	if len(rules) == 0 {
+		return true
+	}
+	for _, rule := range rules {
+		if rule == d.Rule {
+			return true
+		}
+	}
+	return false
+}
+
+// ignoredLines finds the ignore directives among comments. A directive
+// applies to its own line, and to the next one when it is alone on its line.
+func ignoredLines(src string, comments []*ast.Comment) ignores {
+	lines := strings.Split(src, "\n")
+	ig := ignores{}
+	for _, c := range comments {
+		text := strings.TrimSpace(c.Text)
+		if !strings.HasPrefix(text, ignoreDirective) {
+			continue
+		}
+		rules := strings.FieldsFunc(text[len(ignoreDirective):], func(r rune) bool {
+			return r == ',' || r == ' ' || r == '\t'
+		})
+
+		line := c.Pos().Line
+		ig[line] = rules
+		if line <= len(lines) && c.Pos().Column <= len(lines[line-1]) &&
+			strings.TrimSpace(lines[line-1][:c.Pos().Column-1]) == "" {
+			ig[line+1] = rules
+		}
+	}
+	return ig
+}
@@ -0,0 +1,108 @@
+package lint
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/dreblang/core/ast"
+)
+
+func TestRules(t *testing.T) {
+	tests := []struct {
+		input    string
+		expected []string
+	}{
+		{
+			"let count = 0; let bump = fn() { count = count + 1; total = 1; let n = 0; n = 2 }",
+			[]string{"1:53: assignment to undeclared total defines a new local, declare it with let (undeclared-assign)"},
+		},
+		// Top level and scope assignments are how globals and members are made
+		{"total = 1; scope s { x = 1; export x; }", nil},
+		{"class C { n = 0; inc = fn() { n = n + 1 }; export inc; }", nil},
+		{
+			"len([1]); len(\"s\"); len(x); len(1); len({}); len(fn() {}); len(-1.5); len(1 < 2); len(1, 2)",
+			[]string{
+				"1:33: len does not support Integer (len-argument)",
+				"1:41: len does not support Hash (len-argument)",
+				"1:50: len does not support Closure (len-argument)",
+				"1:64: len does not support Float (len-argument)",
+				"1:77: len does not support Boolean (len-argument)",
+				"1:83: len takes 1 argument, got 2 (len-argument)",
+			},
+		},
+		{"let n: int = \"one\"", []string{"1:5: cannot use string value as int in let n (type-mismatch)"}},
+		{
+			"scope s { let a = 1; b = 2; export a; export b; export c; }\nclass K { export missing; }",
+			[]string{
+				"1:56: c is exported but not defined in scope s (undefined-export)",
+				"2:18: missing is exported but not defined in class K (undefined-export)",
+			},
+		},
+	}
+
+	for _, tt := range tests {
+		diagnostics, err := Source("", []byte(tt.input), Rules)
+		if err != nil {
+			t.Fatalf("lint error: %s", err)
+		}
+		checkDiagnostics(t, tt.input, diagnostics, tt.expected)
+	}
+}
+
+func TestIgnore(t *testing.T) {
+	input := `len(1); // dreblint:ignore
+// dreblint:ignore len-argument
+len(2);
+// dreblint:ignore undefined-export, undeclared-assign
+len(3);
+len(4); // dreblint:ignore
+len(5);
+`
+	diagnostics, err := Source("", []byte(input), Rules)
+	if err != nil {
+		t.Fatalf("lint error: %s", err)
+	}
+	checkDiagnostics(t, input, diagnostics, []string{
+		"5:5: len does not support Integer (len-argument)",
+		"7:5: len does not support Integer (len-argument)",
+	})
+}
+
+func TestCustomRule(t *testing.T) {
+	noFloats := &Rule{
+		Name: "no-floats",
+		Run: func(p *Pass) {
+			p.Walk(func(node ast.Node, env *Env) {
+				if f, ok := node.(*ast.FloatLiteral); ok {
+					p.Report(f.Pos(), "float %s", f.Token.Literal)
+				}
+			})
+		},
+	}
+
+	diagnostics, err := Source("main.dreb", []byte("let a = 1;\nlet b = 2.5;"), []*Rule{noFloats})
+	if err != nil {
+		t.Fatalf("lint error: %s", err)
+	}
+	checkDiagnostics(t, "", diagnostics, []string{"main.dreb:2:9: float 2.5 (no-floats)"})
+}
+
+func TestSyntaxErrors(t *testing.T) {
+	_, err := Source("main.dreb", []byte("let = 1;"), Rules)
+	expected := "main.dreb:1:5: expected next token to be Identifier, got = instead"
+	if err == nil || err.Error() != expected {
+		t.Errorf("wrong error.\nwant=%q\ngot =%v", expected, err)
+	}
+}
+
+func checkDiagnostics(t *testing.T, input string, diagnostics []Diagnostic, expected []string) {
+	t.Helper()
+
+	got := []string{}
+	for _, d := range diagnostics {
+		got = append(got, d.String())
+	}
+	if strings.Join(got, "\n") != strings.Join(expected, "\n") {
+		t.Errorf("wrong diagnostics for %q.\nwant=%q\ngot =%q", input, expected, got)
+	}
+}
@@ -0,0 +1,168 @@
+package lint
+
+import (
+	"github.com/dreblang/core/ast"
+	"github.com/dreblang/core/compiler"
+	"github.com/dreblang/core/object"
+	"github.com/dreblang/core/token"
+	"github.com/dreblang/core/types"
+)
+
+// UndeclaredAssign reports assignments in functions to names that are not
+// defined yet, which quietly create a local instead of updating a global
+// defined later.
+var UndeclaredAssign = &Rule{
+	Name: "undeclared-assign",
+	Doc:  "assignment to an undeclared name inside a function",
+	Run: func(p *Pass) {
+		p.Walk(func(node ast.Node, env *Env) {
+			assign, ok := node.(*ast.InfixExpression)
+			if !ok || assign.Operator != token.Assign || !env.InFunction {
+				return
+			}
+			ident, ok := assign.Left.(*ast.Identifier)
+			if !ok {
+				return
+			}
+			if _, ok := env.Symbols.Resolve(ident.Value); !ok {
+				p.Report(ident.Pos(), "assignment to undeclared %s defines a new local, declare it with let", ident.Value)
+			}
+		})
+	},
+}
+
+// LenArgument reports calls to the len builtin that will fail at run time.
+var LenArgument = &Rule{
+	Name: "len-argument",
+	Doc:  "len called with the wrong number of arguments or an unsupported type",
+	Run: func(p *Pass) {
+		p.Walk(func(node ast.Node, env *Env) {
+			call, ok := node.(*ast.CallExpression)
+			if !ok {
+				return
+			}
+			ident, ok := call.Function.(*ast.Identifier)
+			if !ok || ident.Value != object.BuiltinFuncNameLen {
+				return
+			}
+			if symbol, ok := env.Symbols.Resolve(ident.Value); !ok || symbol.Scope != compiler.BuiltinScope {
+				return
+			}
+
+			if len(call.Arguments) != 1 {
+				p.Report(ident.Pos(), "len takes 1 argument, got %d", len(call.Arguments))
+				return
+			}
+			switch t := staticType(call.Arguments[0]); t {
+			case "", object.ArrayObj, object.StringObj:
+			default:
+				p.Report(call.Arguments[0].Pos(), "len does not support %s", t)
+			}
+		})
+	},
+}
+
+// UndefinedExport reports exports in scope and class bodies of names the
+// body never defines.
+var UndefinedExport = &Rule{
+	Name: "undefined-export",
+	Doc:  "export of a name not defined in its scope or class",
+	Run: func(p *Pass) {
+		p.Walk(func(node ast.Node, env *Env) {
+			var kind string
+			var name *ast.Identifier
+			var body *ast.BlockStatement
+			switch node := node.(type) {
+			case *ast.ScopeDefinition:
+				kind, name, body = "scope", node.Name, node.Block
+			case *ast.ClassDefinition:
+				kind, name, body = "class", node.Name, node.Block
+			default:
+				return
+			}
+
+			defined := map[string]bool{}
+			for _, stmt := range body.Statements {
+				for _, ident := range definedNames(stmt) {
+					defined[ident.Value] = true
+				}
+			}
+			for _, stmt := range body.Statements {
+				if export, ok := stmt.(*ast.ExportStatement); ok && !defined[export.Identifier.Value] {
+					p.Report(export.Identifier.Pos(), "%s is exported but not defined in %s %s",
+						export.Identifier.Value, kind, name.Value)
+				}
+			}
+		})
+	},
+}
+
+// TypeMismatch reports the errors of the type checker, which only has
+// something to say about code with type annotations or mixing literals of
+// the wrong types.
+var TypeMismatch = &Rule{
+	Name: "type-mismatch",
+	Doc:  "value or operation that does not match the types declared or known",
+	Run: func(p *Pass) {
+		for _, err := range types.Check(p.Program) {
+			p.Report(err.Pos, "%s", err.Msg)
+		}
+	},
+}
+
+// definedNames returns the names a statement directly in a block defines.
+func definedNames(stmt ast.Statement) []*ast.Identifier {
+	switch stmt := stmt.(type) {
+	case *ast.LetStatement:
+		return []*ast.Identifier{stmt.Name}
+	case *ast.ScopeDefinition:
+		return []*ast.Identifier{stmt.Name}
+	case *ast.ClassDefinition:
+		return []*ast.Identifier{stmt.Name}
+	case *ast.LoadStatement:
+		return []*ast.Identifier{stmt.Identifier}
+	case *ast.IterStatement:
+		return []*ast.Identifier{stmt.Identifier}
+	case *ast.ExpressionStatement:
+		if assign, ok := stmt.Expression.(*ast.InfixExpression); ok && assign.Operator == token.Assign {
+			if ident, ok := assign.Left.(*ast.Identifier); ok {
+				return []*ast.Identifier{ident}
+			}
+		}
+	}
+	return nil
+}
+
+// staticType returns the object type expr always evaluates to, or "" if it
+// can't tell without running the program.
+func staticType(expr ast.Expression) object.ObjectType {
+	switch expr := expr.(type) {
+	case *ast.IntegerLiteral:
+		return object.IntegerObj
+	case *ast.FloatLiteral:
+		return object.FloatObj
+	case *ast.Boolean:
+		return object.BooleanObj
+	case *ast.StringLiteral:
+		return object.StringObj
+	case *ast.ArrayLiteral:
+		return object.ArrayObj
+	case *ast.HashLiteral:
+		return object.HashObj
+	case *ast.FunctionLiteral:
+		return object.ClosureObj
+	case *ast.PrefixExpression:
+		if expr.Operator == token.Bang {
+			return object.BooleanObj
+		}
+		if t := staticType(expr.Right); t == object.IntegerObj || t == object.FloatObj {
+			return t
+		}
+	case *ast.InfixExpression:
+		switch expr.Operator {
+		case token.Equal, token.NotEqual, token.LessThan, token.GreaterThan, token.LessOrEqual, token.GreaterOrEqual:
+			return object.BooleanObj
+		}
+	}
+	return ""
+}
@@ -0,0 +1,174 @@
+package lint
+
+import (
+	"github.com/dreblang/core/ast"
+	"github.com/dreblang/core/compiler"
+	"github.com/dreblang/core/token"
+)
+
+// Env is what a rule knows about the place of a node in the program.
+type Env struct {
+	// Names defined so far, resolved the way the compiler would
+	Symbols *compiler.SymbolTable
+	// Set in function bodies, as opposed to the top level and scope and
+	// class bodies, whose assignments define members
+	InFunction bool
+}
+
+// walker visits nodes in compile order, defining names the way the
+// compiler does
+type walker struct {
+	visit func(node ast.Node, env *Env)
+	env   *Env
+}
+
+func (w *walker) enter(function bool) {
+	w.env = &Env{Symbols: compiler.NewEnclosedSymbolTable(w.env.Symbols), InFunction: function}
+}
+
+func (w *walker) leave(outer *Env) {
+	w.env = outer
+}
+
+func (w *walker) define(ident *ast.Identifier) {
+	w.env.Symbols.Define(ident.Value)
+}
+
+func (w *walker) nodes(nodes ...ast.Node) {
+	for _, node := range nodes {
+		w.node(node)
+	}
+}
+
+func (w *walker) node(node ast.Node) {
+	if node == nil {
+		return
+	}
+	w.visit(node, w.env)
+
+	switch node := node.(type) {
+	case *ast.Program:
+		for _, s := range node.Statements {
+			w.node(s)
+		}
+
+	case *ast.BlockStatement:
+		for _, s := range node.Statements {
+			w.node(s)
+		}
+
+	case *ast.ExpressionStatement:
+		w.node(node.Expression)
+
+	case *ast.LetStatement:
+		// Defined first, so functions can call themselves
+		w.define(node.Name)
+		w.node(node.Value)
+
+	case *ast.ReturnStatement:
+		w.node(node.ReturnValue)
+
+	case *ast.ExportStatement:
+		w.node(node.Identifier)
+
+	case *ast.LoadStatement:
+		w.define(node.Identifier)
+
+	case *ast.ScopeDefinition:
+		outer := w.env
+		w.enter(false)
+		w.node(node.Block)
+		w.leave(outer)
+		w.define(node.Name)
+
+	case *ast.ClassDefinition:
+		w.define(node.Name)
+		outer := w.env
+		w.enter(false)
+		w.node(node.Block)
+		w.leave(outer)
+
+	case *ast.IterStatement:
+		w.node(node.Expression)
+		w.define(node.Identifier)
+		w.node(node.Statements)
+
+	case *ast.InfixExpression:
+		switch {
+		case node.Operator == token.Assign:
+			if ident, ok := node.Left.(*ast.Identifier); ok {
+				w.define(ident)
+			} else {
+				w.node(node.Left)
+			}
+			w.node(node.Right)
+		case node.Operator == token.Dot || node.Operator == token.DoubleColon:
+			// The right side is a member name
+			w.node(node.Left)
+		default:
+			w.nodes(node.Left, node.Right)
+		}
+
+	case *ast.PrefixExpression:
+		w.node(node.Right)
+
+	case *ast.IfExpression:
+		w.node(node.Condition)
+		w.node(node.Consequence)
+		if node.Alternative != nil {
+			w.node(node.Alternative)
+		}
+
+	case *ast.LoopExpression:
+		w.node(node.Condition)
+		w.node(node.Consequence)
+
+	case *ast.TryExpression:
+		w.node(node.Block)
+		if node.Parameter != nil {
+			w.define(node.Parameter)
+		}
+		if node.Catch != nil {
+			w.node(node.Catch)
+		}
+		if node.Finally != nil {
+			w.node(node.Finally)
+		}
+
+	case *ast.ThrowExpression:
+		w.node(node.Value)
+
+	case *ast.IndexExpression:
+		w.node(node.Left)
+		for _, e := range []ast.Expression{node.Index, node.IndexUpper, node.IndexSkip} {
+			if e != nil {
+				w.node(e)
+			}
+		}
+
+	case *ast.CallExpression:
+		w.node(node.Function)
+		for _, arg := range node.Arguments {
+			w.node(arg)
+		}
+
+	case *ast.FunctionLiteral:
+		outer := w.env
+		w.enter(true)
+		for _, p := range node.Parameters {
+			w.define(p)
+		}
+		w.node(node.Body)
+		w.leave(outer)
+
+	case *ast.ArrayLiteral:
+		for _, el := range node.Elements {
+			w.node(el)
+		}
+
+	case *ast.HashLiteral:
+		for _, k := range node.Keys {
+			w.nodes(k, node.Pairs[k])
+		}
+	}
+}
@@ -0,0 +1,302 @@
+package lsp
+
+import (
+	"fmt"
+	"net/url"
+	"path/filepath"
+	"regexp"
+	"strconv"
+	"strings"
+
+	"github.com/dreblang/core/ast"
+	"github.com/dreblang/core/compiler"
+	"github.com/dreblang/core/lexer"
+	"github.com/dreblang/core/parser"
+	"github.com/dreblang/core/token"
+	"github.com/dreblang/core/types"
+)
+
+var errorPosition = regexp.MustCompile(`^(\d+):(\d+): (.*)$`)
+
+// document is an open file together with what the parser and compiler
+// found in it
+type document struct {
+	uri  string
+	path string
+	text string
+
+	program     *ast.Program
+	diagnostics []Diagnostic
+	resolutions []compiler.Resolution
+	// Function literals bound by let, keyed by the position of the name
+	functions map[token.Position]*ast.FunctionLiteral
+}
+
+func newDocument(uri, text string) *document {
+	d := &document{
+		uri:         uri,
+		path:        uriToPath(uri),
+		text:        text,
+		diagnostics: []Diagnostic{},
+		functions:   map[token.Position]*ast.FunctionLiteral{},
+	}
+
+	p := parser.New(lexer.NewWithFilename(text, d.path))
+	d.program = p.ParseProgram()
+	for _, err := range p.Errors() {
+		start := toPosition(err.Pos)
+		width := len(err.Found.Literal)
+		if width == 0 {
+			width = 1
+		}
+		d.diagnostics = append(d.diagnostics, Diagnostic{
+			Range:    Range{Start: start, End: Position{Line: start.Line, Character: start.Character + width}},
+			Severity: SeverityError,
+			Source:   "parser",
+			Message:  err.Msg,
+		})
+	}
+	if len(p.Errors()) != 0 {
+		// A partial program may have nil nodes the compiler can't handle
+		return d
+	}
+
+	c := compiler.New()
+	c.RecordResolutions()
+	checked := c.Check(d.program)
+	for _, diag := range checked {
+		d.addCheckDiagnostic(diag)
+	}
+	for _, err := range types.Check(d.program) {
+		start := toPosition(err.Pos)
+		d.diagnostics = append(d.diagnostics, Diagnostic{
+			Range:    Range{Start: start, End: Position{Line: start.Line, Character: start.Character + 1}},
+			Severity: SeverityError,
+			Source:   "types",
+			Message:  err.Msg,
+		})
+	}
+	if err := c.Compile(d.program); err != nil && !hasErrors(checked) {
+		// Problems Check can't see, such as modules failing to compile
+		d.addDiagnostic("compiler", err.Error())
+	}
+	lines := strings.Split(text, "\n")
+	for _, r := range c.Resolutions() {
+		// Skip identifiers of loaded modules, which are compiled inline, and
+		// the ones the compiler generates for classes
+		if r.Pos.Filename == d.path && sourceAt(lines, r.Pos, len(r.Name)) == r.Name {
+			d.resolutions = append(d.resolutions, r)
+		}
+	}
+
+	d.collectFunctions(d.program.Statements)
+	return d
+}
+
+// addDiagnostic turns a "file:line:col: message" compile error into a
+// diagnostic.
+// Errors without a position are reported at the start of the file.
+func (d *document) addDiagnostic(source, msg string) {
+	diag := Diagnostic{Severity: SeverityError, Source: source, Message: msg}
+
+	rest := strings.TrimPrefix(msg, d.path+":")
+	if m := errorPosition.FindStringSubmatch(rest); m != nil {
+		line, _ := strconv.Atoi(m[1])
+		col, _ := strconv.Atoi(m[2])
+		start := toPosition(token.Position{Line: line, Column: col})
+		diag.Range = Range{Start: start, End: Position{Line: start.Line, Character: start.Character + 1}}
+		diag.Message = m[3]
+	}
+	d.diagnostics = append(d.diagnostics, diag)
+}
+
+func (d *document) addCheckDiagnostic(diag compiler.Diagnostic) {
+	severity := SeverityError
+	if diag.Severity == compiler.SeverityWarning {
+		severity = SeverityWarning
+	}
+	start := toPosition(diag.Pos)
+	d.diagnostics = append(d.diagnostics, Diagnostic{
+		Range:    Range{Start: start, End: Position{Line: start.Line, Character: start.Character + 1}},
+		Severity: severity,
+		Source:   "compiler",
+		Message:  diag.Msg,
+	})
+}
+
+func hasErrors(diagnostics []compiler.Diagnostic) bool {
+	for _, diag := range diagnostics {
+		if diag.Severity == compiler.SeverityError {
+			return true
+		}
+	}
+	return false
+}
+
+func (d *document) collectFunctions(stmts []ast.Statement) {
+	for _, stmt := range stmts {
+		switch stmt := stmt.(type) {
+		case *ast.LetStatement:
+			if fn, ok := stmt.Value.(*ast.FunctionLiteral); ok {
+				d.functions[stmt.Name.Pos()] = fn
+				d.collectFunctions(fn.Body.Statements)
+			}
+		case *ast.ScopeDefinition:
+			d.collectFunctions(stmt.Block.Statements)
+		case *ast.ClassDefinition:
+			d.collectFunctions(stmt.Block.Statements)
+		}
+	}
+}
+
+// resolutionAt returns the identifier under pos.
+func (d *document) resolutionAt(pos Position) (compiler.Resolution, bool) {
+	for _, r := range d.resolutions {
+		start := toPosition(r.Pos)
+		if start.Line == pos.Line && pos.Character >= start.Character && pos.Character < start.Character+len(r.Name) {
+			return r, true
+		}
+	}
+	return compiler.Resolution{}, false
+}
+
+func (d *document) definition(pos Position) (*Location, bool) {
+	r, ok := d.resolutionAt(pos)
+	if !ok || !r.Definition.IsValid() {
+		return nil, false
+	}
+
+	return &Location{URI: pathToURI(r.Definition.Filename), Range: nameRange(r.Definition, r.Name)}, true
+}
+
+func (d *document) hover(pos Position) (*Hover, bool) {
+	r, ok := d.resolutionAt(pos)
+	if !ok {
+		return nil, false
+	}
+
+	signature := r.Name
+	if fn, ok := d.functions[r.Definition]; ok {
+		params := make([]string, len(fn.Parameters))
+		for i, p := range fn.Parameters {
+			params[i] = p.Value
+			if t := fn.ParameterType(i); t != nil {
+				params[i] += ": " + t.Name
+			}
+		}
+		signature = fmt.Sprintf("fn %s(%s)", r.Name, strings.Join(params, ", "))
+		if fn.ReturnType != nil {
+			signature += " -> " + fn.ReturnType.Name
+		}
+	}
+
+	detail := strings.ToLower(string(r.Symbol.Scope))
+	if r.Definition.IsValid() {
+		detail += fmt.Sprintf(", defined at line %d", r.Definition.Line)
+	}
+
+	rng := nameRange(r.Pos, r.Name)
+	return &Hover{
+		Contents: MarkupContent{Kind: "markdown", Value: fmt.Sprintf("```dreb\n%s\n```\n%s", signature, detail)},
+		Range:    &rng,
+	}, true
+}
+
+// symbols lists the let, scope and class definitions of stmts. Scope and
+// class bodies also list their assignments, which become members.
+func (d *document) symbols(stmts []ast.Statement, members bool) []DocumentSymbol {
+	symbols := []DocumentSymbol{}
+	for _, stmt := range stmts {
+		switch stmt := stmt.(type) {
+		case *ast.LetStatement:
+			symbol := newSymbol(stmt.Name, VariableSymbol, stmt.Pos(), nil)
+			if fn, ok := stmt.Value.(*ast.FunctionLiteral); ok {
+				symbol = newSymbol(stmt.Name, FunctionSymbol, stmt.Pos(), d.symbols(fn.Body.Statements, false))
+			}
+			symbols = append(symbols, symbol)
+
+		case *ast.ScopeDefinition:
+			symbols = append(symbols, newSymbol(stmt.Name, NamespaceSymbol, stmt.Pos(), d.symbols(stmt.Block.Statements, true)))
+
+		case *ast.ClassDefinition:
+			symbols = append(symbols, newSymbol(stmt.Name, ClassSymbol, stmt.Pos(), d.symbols(stmt.Block.Statements, true)))
+
+		case *ast.ExpressionStatement:
+			assign, ok := stmt.Expression.(*ast.InfixExpression)
+			if !members || !ok || assign.Operator != token.Assign {
+				continue
+			}
+			name, ok := assign.Left.(*ast.Identifier)
+			if !ok {
+				continue
+			}
+			kind := VariableSymbol
+			var children []DocumentSymbol
+			if fn, ok := assign.Right.(*ast.FunctionLiteral); ok {
+				kind = FunctionSymbol
+				children = d.symbols(fn.Body.Statements, false)
+			}
+			symbols = append(symbols, newSymbol(name, kind, name.Pos(), children))
+		}
+	}
+	return symbols
+}
+
+// newSymbol makes a symbol spanning from start to the end of its name or
+// of its last child, since the AST doesn't record where nodes end
+func newSymbol(name *ast.Identifier, kind int, start token.Position, children []DocumentSymbol) DocumentSymbol {
+	selection := nameRange(name.Pos(), name.Value)
+	end := selection.End
+	for _, child := range children {
+		if after(child.Range.End, end) {
+			end = child.Range.End
+		}
+	}
+
+	return DocumentSymbol{
+		Name:           name.Value,
+		Kind:           kind,
+		Range:          Range{Start: toPosition(start), End: end},
+		SelectionRange: selection,
+		Children:       children,
+	}
+}
+
+func sourceAt(lines []string, pos token.Position, n int) string {
+	if pos.Line < 1 || pos.Line > len(lines) {
+		return ""
+	}
+	line := lines[pos.Line-1]
+	if pos.Column < 1 || pos.Column-1+n > len(line) {
+		return ""
+	}
+	return line[pos.Column-1 : pos.Column-1+n]
+}
+
+func after(a, b Position) bool {
+	return a.Line > b.Line || a.Line == b.Line && a.Character > b.Character
+}
+
+// toPosition converts a 1-based token position to a 0-based LSP position.
+// Columns count bytes, which matches LSP's UTF-16 units for ASCII source.
+func toPosition(pos token.Position) Position {
+	return Position{Line: pos.Line - 1, Character: pos.Column - 1}
+}
+
+func nameRange(pos token.Position, name string) Range {
+	start := toPosition(pos)
+	return Range{Start: start, End: Position{Line: start.Line, Character: start.Character + len(name)}}
+}
+
+func uriToPath(uri string) string {
+	u, err := url.Parse(uri)
+	if err != nil || u.Scheme != "file" {
+		return uri
+	}
+	return filepath.FromSlash(u.Path)
+}
+
+func pathToURI(path string) string {
+	u := url.URL{Scheme: "file", Path: filepath.ToSlash(path)}
+	return u.String()
+}
@@ -0,0 +1,267 @@
+// Package lsp implements a Language Server Protocol server for Dreblang,
+// giving editors diagnostics, go-to-definition, hover, completion and
+// document symbols.
+package lsp
+
+import (
+	"bufio"
+	"encoding/json"
+	"fmt"
+	"io"
+	"sort"
+	"strings"
+
+	"github.com/dreblang/core/compiler"
+	"github.com/dreblang/core/object"
+)
+
+// Server answers the requests of one editor over a pair of streams.
+type Server struct {
+	in  *bufio.Reader
+	out io.Writer
+
+	documents map[string]*document
+}
+
+// requestError is an error that carries its JSON-RPC code
+type requestError struct {
+	code int
+	err  error
+}
+
+func (e *requestError) Error() string { return e.err.Error() }
+
+// NewServer returns a server reading messages from in and writing
+// responses and notifications to out.
+func NewServer(in io.Reader, out io.Writer) *Server {
+	return &Server{
+		in:        bufio.NewReader(in),
+		out:       out,
+		documents: map[string]*document{},
+	}
+}
+
+// Serve handles messages until the client sends exit or in is closed.
+func (s *Server) Serve() error {
+	for {
+		msg, err := ReadMessage(s.in)
+		if err == io.EOF {
+			return nil
+		}
+		if err != nil {
+			return err
+		}
+
+		if msg.Method == "exit" {
+			return nil
+		}
+
+		result, err := s.handle(msg)
+		if msg.ID == nil {
+			// Notifications get no response
+			continue
+		}
+		if err := s.respond(msg, result, err); err != nil {
+			return err
+		}
+	}
+}
+
+// Notify sends a notification to the client.
+func (s *Server) Notify(method string, params interface{}) error {
+	data, err := json.Marshal(params)
+	if err != nil {
+		return err
+	}
+	return WriteMessage(s.out, &Message{Method: method, Params: data})
+}
+
+func (s *Server) respond(req *Message, result interface{}, err error) error {
+	msg := &Message{ID: req.ID}
+	if err != nil {
+		code := InternalError
+		if rerr, ok := err.(*requestError); ok {
+			code = rerr.code
+		}
+		msg.Error = &ResponseError{Code: code, Message: err.Error()}
+		return WriteMessage(s.out, msg)
+	}
+
+	data, err := json.Marshal(result)
+	if err != nil {
+		return err
+	}
+	msg.Result = data
+	return WriteMessage(s.out, msg)
+}
+
+func (s *Server) handle(msg *Message) (interface{}, error) {
+	switch msg.Method {
+	case "initialize":
+		return InitializeResult{Capabilities: ServerCapabilities{
+			TextDocumentSync:       Full,
+			DefinitionProvider:     true,
+			HoverProvider:          true,
+			CompletionProvider:     &CompletionOptions{TriggerCharacters: []string{"."}},
+			DocumentSymbolProvider: true,
+		}}, nil
+
+	case "initialized", "shutdown":
+		return nil, nil
+
+	case "textDocument/didOpen":
+		params := DidOpenTextDocumentParams{}
+		if err := unmarshalParams(msg, &params); err != nil {
+			return nil, err
+		}
+		return nil, s.update(params.TextDocument.URI, params.TextDocument.Text)
+
+	case "textDocument/didChange":
+		params := DidChangeTextDocumentParams{}
+		if err := unmarshalParams(msg, &params); err != nil {
+			return nil, err
+		}
+		if n := len(params.ContentChanges); n > 0 {
+			return nil, s.update(params.TextDocument.URI, params.ContentChanges[n-1].Text)
+		}
+		return nil, nil
+
+	case "textDocument/didClose":
+		params := DidCloseTextDocumentParams{}
+		if err := unmarshalParams(msg, &params); err != nil {
+			return nil, err
+		}
+		delete(s.documents, params.TextDocument.URI)
+		return nil, s.Notify("textDocument/publishDiagnostics", PublishDiagnosticsParams{
+			URI:         params.TextDocument.URI,
+			Diagnostics: []Diagnostic{},
+		})
+
+	case "textDocument/definition":
+		doc, params, err := s.position(msg)
+		if err != nil {
+			return nil, err
+		}
+		if loc, ok := doc.definition(params.Position); ok {
+			return loc, nil
+		}
+		return nil, nil
+
+	case "textDocument/hover":
+		doc, params, err := s.position(msg)
+		if err != nil {
+			return nil, err
+		}
+		if hover, ok := doc.hover(params.Position); ok {
+			return hover, nil
+		}
+		return nil, nil
+
+	case "textDocument/completion":
+		doc, params, err := s.position(msg)
+		if err != nil {
+			return nil, err
+		}
+		return complete(doc, params.Position), nil
+
+	case "textDocument/documentSymbol":
+		params := DocumentSymbolParams{}
+		if err := unmarshalParams(msg, &params); err != nil {
+			return nil, err
+		}
+		doc, err := s.document(params.TextDocument.URI)
+		if err != nil {
+			return nil, err
+		}
+		return doc.symbols(doc.program.Statements, false), nil
+
+	default:
+		if msg.ID == nil {
+			return nil, nil
+		}
+		return nil, &requestError{code: MethodNotFound, err: fmt.Errorf("unsupported method %s", msg.Method)}
+	}
+}
+
+func unmarshalParams(msg *Message, params interface{}) error {
+	if err := json.Unmarshal(msg.Params, params); err != nil {
+		return &requestError{code: InvalidParams, err: err}
+	}
+	return nil
+}
+
+// update reanalyses a document and publishes its diagnostics.
+func (s *Server) update(uri, text string) error {
+	doc := newDocument(uri, text)
+	s.documents[uri] = doc
+	return s.Notify("textDocument/publishDiagnostics", PublishDiagnosticsParams{
+		URI:         uri,
+		Diagnostics: doc.diagnostics,
+	})
+}
+
+func (s *Server) document(uri string) (*document, error) {
+	doc, ok := s.documents[uri]
+	if !ok {
+		return nil, &requestError{code: InvalidParams, err: fmt.Errorf("document %s is not open", uri)}
+	}
+	return doc, nil
+}
+
+func (s *Server) position(msg *Message) (*document, TextDocumentPositionParams, error) {
+	params := TextDocumentPositionParams{}
+	if err := unmarshalParams(msg, &params); err != nil {
+		return nil, params, err
+	}
+	doc, err := s.document(params.TextDocument.URI)
+	return doc, params, err
+}
+
+// complete offers member names after a dot, and builtins and the global
+// names of the document everywhere else.
+func complete(doc *document, pos Position) []CompletionItem {
+	lines := strings.Split(doc.text, "\n")
+	line := ""
+	if pos.Line < len(lines) {
+		line = lines[pos.Line]
+	}
+	if pos.Character < len(line) {
+		line = line[:pos.Character]
+	}
+	prefix := strings.TrimRight(line, "abcdefghijklmnopqrstuvwxyzABCDEFGHIJKLMNOPQRSTUVWXYZ0123456789_")
+
+	items := []CompletionItem{}
+	if strings.HasSuffix(prefix, ".") {
+		types := map[string][]string{}
+		for t, members := range object.Members {
+			for _, member := range members {
+				types[member] = append(types[member], string(t))
+			}
+		}
+		for member, ts := range types {
+			sort.Strings(ts)
+			items = append(items, CompletionItem{Label: member, Kind: FieldCompletion, Detail: strings.Join(ts, ", ")})
+		}
+	} else {
+		for _, b := range object.Builtins {
+			items = append(items, CompletionItem{Label: b.Name, Kind: FunctionCompletion, Detail: "builtin"})
+		}
+
+		seen := map[string]bool{}
+		for _, r := range doc.resolutions {
+			if seen[r.Name] || r.Symbol.Scope != compiler.GlobalScope {
+				continue
+			}
+			seen[r.Name] = true
+
+			kind := VariableCompletion
+			if _, ok := doc.functions[r.Definition]; ok {
+				kind = FunctionCompletion
+			}
+			items = append(items, CompletionItem{Label: r.Name, Kind: kind, Detail: strings.ToLower(string(r.Symbol.Scope))})
+		}
+	}
+
+	sort.Slice(items, func(i, j int) bool { return items[i].Label < items[j].Label })
+	return items
+}
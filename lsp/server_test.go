@@ -0,0 +1,289 @@
+package lsp
+
+import (
+	"encoding/json"
+	"io"
+	"strings"
+	"testing"
+
+	"github.com/dreblang/core/internal/framing/framingtest"
+)
+
+const uri = "file:///work/main.dreb"
+
+const program = `let total = 0;
+let add = fn(a, b) {
+	let s = a + b;
+	s
+};
+scope util {
+	double = fn(x) { x * 2 };
+	export double;
+}
+class Counter {
+	count = 0;
+}
+let name = "dreb";
+total = add(total, len(name));
+name.up`
+
+type client struct {
+	*framingtest.Client
+	t             *testing.T
+	id            int
+	notifications []*Message
+}
+
+func newClient(t *testing.T) (*client, chan error) {
+	c, done := framingtest.NewClient(t, func(in io.Reader, out io.Writer) error {
+		return NewServer(in, out).Serve()
+	})
+	return &client{Client: c, t: t}, done
+}
+
+func (c *client) next() *Message {
+	c.t.Helper()
+
+	msg := &Message{}
+	c.Next(msg)
+	return msg
+}
+
+func (c *client) send(method string, id *json.RawMessage, params interface{}) {
+	c.t.Helper()
+
+	data, _ := json.Marshal(params)
+	c.Send(&Message{JSONRPC: "2.0", ID: id, Method: method, Params: data})
+}
+
+func (c *client) notify(method string, params interface{}) {
+	c.t.Helper()
+	c.send(method, nil, params)
+}
+
+// request sends a request and decodes its result, queueing any
+// notifications that arrive first
+func (c *client) request(method string, params interface{}, result interface{}) *Message {
+	c.t.Helper()
+
+	c.id++
+	id := json.RawMessage(strings.TrimSpace(string(mustMarshal(c.id))))
+	c.send(method, &id, params)
+
+	for {
+		msg := c.next()
+		if msg.ID == nil {
+			c.notifications = append(c.notifications, msg)
+			continue
+		}
+		if string(*msg.ID) != string(id) {
+			c.t.Fatalf("unexpected response %+v to %s", msg, method)
+		}
+		if result != nil && msg.Error == nil {
+			if err := json.Unmarshal(msg.Result, result); err != nil {
+				c.t.Fatalf("bad %s result: %s", method, err)
+			}
+		}
+		return msg
+	}
+}
+
+func (c *client) diagnostics() PublishDiagnosticsParams {
+	c.t.Helper()
+
+	for {
+		var msg *Message
+		if len(c.notifications) > 0 {
+			msg, c.notifications = c.notifications[0], c.notifications[1:]
+		} else {
+			msg = c.next()
+		}
+		if msg.Method != "textDocument/publishDiagnostics" {
+			continue
+		}
+		params := PublishDiagnosticsParams{}
+		if err := json.Unmarshal(msg.Params, &params); err != nil {
+			c.t.Fatalf("bad diagnostics: %s", err)
+		}
+		return params
+	}
+}
+
+func mustMarshal(v interface{}) []byte {
+	data, err := json.Marshal(v)
+	if err != nil {
+		panic(err)
+	}
+	return data
+}
+
+func at(line, character int) TextDocumentPositionParams {
+	return TextDocumentPositionParams{
+		TextDocument: TextDocumentIdentifier{URI: uri},
+		Position:     Position{Line: line, Character: character},
+	}
+}
+
+func TestSession(t *testing.T) {
+	c, done := newClient(t)
+
+	result := InitializeResult{}
+	c.request("initialize", nil, &result)
+	if !result.Capabilities.DefinitionProvider || result.Capabilities.TextDocumentSync != Full {
+		t.Errorf("wrong capabilities. got=%+v", result.Capabilities)
+	}
+	c.notify("initialized", struct{}{})
+
+	c.notify("textDocument/didOpen", DidOpenTextDocumentParams{
+		TextDocument: TextDocumentItem{URI: uri, Version: 1, Text: program},
+	})
+	if diags := c.diagnostics(); len(diags.Diagnostics) != 0 {
+		t.Errorf("unexpected diagnostics. got=%+v", diags.Diagnostics)
+	}
+
+	// total in "total = add(...)" on line 14
+	loc := Location{}
+	c.request("textDocument/definition", at(13, 1), &loc)
+	if loc.URI != uri || loc.Range.Start != (Position{Line: 0, Character: 4}) {
+		t.Errorf("wrong definition of total. got=%+v", loc)
+	}
+
+	// b in "a + b" resolves to the parameter
+	c.request("textDocument/definition", at(2, 13), &loc)
+	if loc.Range.Start != (Position{Line: 1, Character: 16}) || loc.Range.End != (Position{Line: 1, Character: 17}) {
+		t.Errorf("wrong definition of b. got=%+v", loc)
+	}
+
+	// Builtins have no definition
+	resp := c.request("textDocument/definition", at(13, 20), nil)
+	if resp.Error != nil || string(resp.Result) != "null" {
+		t.Errorf("wrong definition of len. got=%+v", resp)
+	}
+
+	hover := Hover{}
+	c.request("textDocument/hover", at(13, 9), &hover)
+	expected := "```dreb\nfn add(a, b)\n```\nglobal, defined at line 2"
+	if hover.Contents.Value != expected {
+		t.Errorf("wrong hover.\nwant=%q\ngot =%q", expected, hover.Contents.Value)
+	}
+
+	c.request("textDocument/hover", at(13, 20), &hover)
+	if !strings.HasSuffix(hover.Contents.Value, "builtin") {
+		t.Errorf("wrong hover for len. got=%q", hover.Contents.Value)
+	}
+
+	items := []CompletionItem{}
+	c.request("textDocument/completion", at(14, 7), &items)
+	labels := completionLabels(items)
+	if !labels["upper"] || !labels["split"] || labels["len"] {
+		t.Errorf("wrong member completions. got=%+v", items)
+	}
+
+	c.request("textDocument/completion", at(13, 0), &items)
+	labels = completionLabels(items)
+	if !labels["len"] || !labels["print"] || !labels["add"] || !labels["total"] || labels["upper"] || labels["s"] {
+		t.Errorf("wrong completions. got=%+v", items)
+	}
+
+	symbols := []DocumentSymbol{}
+	c.request("textDocument/documentSymbol", DocumentSymbolParams{TextDocument: TextDocumentIdentifier{URI: uri}}, &symbols)
+	expectedSymbols := []struct {
+		name     string
+		kind     int
+		children []string
+	}{
+		{"total", VariableSymbol, nil},
+		{"add", FunctionSymbol, []string{"s"}},
+		{"util", NamespaceSymbol, []string{"double"}},
+		{"Counter", ClassSymbol, []string{"count"}},
+		{"name", VariableSymbol, nil},
+	}
+	if len(symbols) != len(expectedSymbols) {
+		t.Fatalf("wrong number of symbols. want=%d, got=%+v", len(expectedSymbols), symbols)
+	}
+	for i, tt := range expectedSymbols {
+		symbol := symbols[i]
+		if symbol.Name != tt.name || symbol.Kind != tt.kind || len(symbol.Children) != len(tt.children) {
+			t.Errorf("wrong symbol %d. want=%+v, got=%+v", i, tt, symbol)
+			continue
+		}
+		for j, child := range tt.children {
+			if symbol.Children[j].Name != child {
+				t.Errorf("wrong child of %s. want=%s, got=%s", tt.name, child, symbol.Children[j].Name)
+			}
+		}
+	}
+	if symbols[1].Range.End.Line != 2 {
+		t.Errorf("add does not span its body. got=%+v", symbols[1].Range)
+	}
+
+	// Edits are reanalysed and their errors published
+	c.notify("textDocument/didChange", DidChangeTextDocumentParams{
+		TextDocument:   TextDocumentIdentifier{URI: uri},
+		ContentChanges: []TextDocumentContentChangeEvent{{Text: "let a = 1;\nlet = 2;\nb"}},
+	})
+	diags := c.diagnostics()
+	if len(diags.Diagnostics) != 1 {
+		t.Fatalf("wrong number of diagnostics. got=%+v", diags.Diagnostics)
+	}
+	diag := diags.Diagnostics[0]
+	if diag.Source != "parser" || diag.Range.Start != (Position{Line: 1, Character: 4}) ||
+		diag.Message != "expected next token to be Identifier, got = instead" {
+		t.Errorf("wrong parser diagnostic. got=%+v", diag)
+	}
+
+	c.notify("textDocument/didChange", DidChangeTextDocumentParams{
+		TextDocument:   TextDocumentIdentifier{URI: uri},
+		ContentChanges: []TextDocumentContentChangeEvent{{Text: "let a = 1;\na + b"}},
+	})
+	diags = c.diagnostics()
+	if len(diags.Diagnostics) != 1 {
+		t.Fatalf("wrong number of diagnostics. got=%+v", diags.Diagnostics)
+	}
+	diag = diags.Diagnostics[0]
+	if diag.Source != "compiler" || diag.Range.Start != (Position{Line: 1, Character: 4}) || diag.Message != "undefined variable b" {
+		t.Errorf("wrong compiler diagnostic. got=%+v", diag)
+	}
+
+	c.notify("textDocument/didChange", DidChangeTextDocumentParams{
+		TextDocument:   TextDocumentIdentifier{URI: uri},
+		ContentChanges: []TextDocumentContentChangeEvent{{Text: "let f = fn() {\n\tlet n = 1;\n\t2\n};\nf()"}},
+	})
+	diags = c.diagnostics()
+	if len(diags.Diagnostics) != 1 {
+		t.Fatalf("wrong number of diagnostics. got=%+v", diags.Diagnostics)
+	}
+	diag = diags.Diagnostics[0]
+	if diag.Severity != SeverityWarning || diag.Range.Start != (Position{Line: 1, Character: 5}) || diag.Message != "n declared and not used" {
+		t.Errorf("wrong warning. got=%+v", diag)
+	}
+
+	c.notify("textDocument/didClose", DidCloseTextDocumentParams{TextDocument: TextDocumentIdentifier{URI: uri}})
+	if diags := c.diagnostics(); len(diags.Diagnostics) != 0 {
+		t.Errorf("diagnostics not cleared on close. got=%+v", diags.Diagnostics)
+	}
+
+	resp = c.request("textDocument/hover", at(0, 0), nil)
+	if resp.Error == nil || resp.Error.Code != InvalidParams {
+		t.Errorf("hover on a closed document succeeded. got=%+v", resp)
+	}
+
+	resp = c.request("workspace/symbol", struct{}{}, nil)
+	if resp.Error == nil || resp.Error.Code != MethodNotFound {
+		t.Errorf("wrong response to unsupported method. got=%+v", resp)
+	}
+
+	c.request("shutdown", nil, nil)
+	c.notify("exit", nil)
+	if err := <-done; err != nil {
+		t.Errorf("server error: %s", err)
+	}
+}
+
+func completionLabels(items []CompletionItem) map[string]bool {
+	labels := map[string]bool{}
+	for _, item := range items {
+		labels[item.Label] = true
+	}
+	return labels
+}
@@ -2,6 +2,8 @@ package object
 
 import (
 	"fmt"
+	"io"
+	"os"
 	"strconv"
 )
 
@@ -22,19 +24,19 @@ var Builtins = []struct {
 }{
 	{
 		BuiltinFuncNameLen,
-		&Builtin{Fn: func(args ...Object) Object {
+		&Builtin{Fn: func(vm VM, args ...Object) Object {
 			if len(args) != 1 {
-				return newError("wrong number of arguments. got=%d, want=1",
+				return newErrorWithKind(ArityError, "wrong number of arguments. got=%d, want=1",
 					len(args))
 			}
 
 			switch arg := args[0].(type) {
 			case *Array:
-				return &Integer{Value: int64(len(arg.Elements))}
+				return NewInteger(int64(len(arg.Elements)))
 			case *String:
-				return &Integer{Value: int64(len(arg.Value))}
+				return NewInteger(int64(len(arg.Value)))
 			default:
-				return newError("argument to %q not supported, got %s",
+				return newErrorWithKind(TypeError, "argument to %q not supported, got %s",
 					BuiltinFuncNameLen, args[0].Type())
 			}
 		},
@@ -42,11 +44,16 @@ var Builtins = []struct {
 	},
 	{
 		BuiltinFuncNamePrint,
-		&Builtin{Fn: func(args ...Object) Object {
+		&Builtin{Fn: func(vm VM, args ...Object) Object {
+			var out io.Writer = os.Stdout
+			if vm != nil {
+				out = vm.Output()
+			}
+
 			for _, arg := range args {
-				fmt.Print(arg.Inspect())
+				fmt.Fprint(out, arg.Inspect())
 			}
-			fmt.Println()
+			fmt.Fprintln(out)
 
 			return nil
 		},
@@ -54,24 +61,24 @@ var Builtins = []struct {
 	},
 	{
 		BuiltinFuncNameInt,
-		&Builtin{Fn: func(args ...Object) Object {
+		&Builtin{Fn: func(vm VM, args ...Object) Object {
 			if len(args) != 1 {
-				return newError("wrong number of arguments. got=%d, want=1",
+				return newErrorWithKind(ArityError, "wrong number of arguments. got=%d, want=1",
 					len(args))
 			}
 			switch arg := args[0].(type) {
 			case *Integer:
 				return arg
 			case *Float:
-				return &Integer{Value: int64(arg.Value)}
+				return NewInteger(int64(arg.Value))
 			case *String:
 				val, err := strconv.ParseInt(arg.Value, 10, 64)
 				if err != nil {
-					return newError("Conversion to int failed!")
+					return newErrorWithKind(ValueError, "Conversion to int failed!")
 				}
-				return &Integer{Value: val}
+				return NewInteger(val)
 			default:
-				return newError("argument to %q not supported, got %s",
+				return newErrorWithKind(TypeError, "argument to %q not supported, got %s",
 					BuiltinFuncNameLen, args[0].Type())
 			}
 		},
@@ -79,9 +86,9 @@ var Builtins = []struct {
 	},
 	{
 		BuiltinFuncNameFloat,
-		&Builtin{Fn: func(args ...Object) Object {
+		&Builtin{Fn: func(vm VM, args ...Object) Object {
 			if len(args) != 1 {
-				return newError("wrong number of arguments. got=%d, want=1",
+				return newErrorWithKind(ArityError, "wrong number of arguments. got=%d, want=1",
 					len(args))
 			}
 			switch arg := args[0].(type) {
@@ -92,11 +99,11 @@ var Builtins = []struct {
 			case *String:
 				val, err := strconv.ParseFloat(arg.Value, 64)
 				if err != nil {
-					return newError("Conversion to float failed!")
+					return newErrorWithKind(ValueError, "Conversion to float failed!")
 				}
 				return &Float{Value: val}
 			default:
-				return newError("argument to %q not supported, got %s",
+				return newErrorWithKind(TypeError, "argument to %q not supported, got %s",
 					BuiltinFuncNameLen, args[0].Type())
 			}
 		},
@@ -104,9 +111,9 @@ var Builtins = []struct {
 	},
 	{
 		BuiltinFuncNameString,
-		&Builtin{Fn: func(args ...Object) Object {
+		&Builtin{Fn: func(vm VM, args ...Object) Object {
 			if len(args) != 1 {
-				return newError("wrong number of arguments. got=%d, want=1",
+				return newErrorWithKind(ArityError, "wrong number of arguments. got=%d, want=1",
 					len(args))
 			}
 			return &String{Value: args[0].String()}
@@ -115,25 +122,29 @@ var Builtins = []struct {
 	},
 	{
 		BuiltinFuncNameBytes,
-		&Builtin{Fn: func(args ...Object) Object {
+		&Builtin{Fn: func(vm VM, args ...Object) Object {
 			if len(args) != 1 {
-				return newError("wrong number of arguments. got=%d, want=1",
+				return newErrorWithKind(ArityError, "wrong number of arguments. got=%d, want=1",
 					len(args))
 			}
 			switch val := args[0].(type) {
 			case *String:
 				return &Bytes{Value: []byte(val.Value)}
 			}
-			return newError("couldn't convert to bytes!")
+			return newErrorWithKind(TypeError, "couldn't convert to bytes!")
 		},
 		},
 	},
 }
 
-func newError(format string, a ...interface{}) *Error {
-	return &Error{Message: fmt.Sprintf(format, a...)}
+func newErrorWithKind(kind ErrorKind, format string, a ...interface{}) *Error {
+	return &Error{Kind: kind, Message: fmt.Sprintf(format, a...)}
 }
 
 func NewError(format string, a ...interface{}) *Error {
-	return &Error{Message: fmt.Sprintf(format, a...)}
+	return newErrorWithKind(GenericError, format, a...)
+}
+
+func NewErrorWithKind(kind ErrorKind, format string, a ...interface{}) *Error {
+	return newErrorWithKind(kind, format, a...)
 }
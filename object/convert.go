@@ -0,0 +1,659 @@
+package object
+
+import (
+	"bytes"
+	"fmt"
+	"reflect"
+	"strings"
+	"time"
+)
+
+var (
+	objectType   = reflect.TypeOf((*Object)(nil)).Elem()
+	vmType       = reflect.TypeOf((*VM)(nil)).Elem()
+	errorType    = reflect.TypeOf((*error)(nil)).Elem()
+	timeType     = reflect.TypeOf(time.Time{})
+	durationType = reflect.TypeOf(time.Duration(0))
+)
+
+// FromGo converts the Go value v to an object:
+//
+//   - nil and nil pointers, slices, maps and functions become null and
+//     objects are kept as they are
+//   - booleans, numbers and strings become their Dreblang counterparts, and
+//     []byte becomes bytes holding a copy of it
+//   - time.Time becomes its RFC 3339 string, time.Duration its nanoseconds
+//   - errors become error objects
+//   - slices and arrays become arrays, maps become hashes
+//   - structs become hashes of their exported fields, keyed by the name in
+//     their `dreb` tag or else the field name. A tag of "-" skips the field
+//     and ",omitempty" skips it when it holds its zero value
+//   - pointers and interfaces become what they point to
+//   - functions and methods become builtins, see NewBuiltin
+//
+// Values that refer back to themselves, like a node pointing to its parent
+// that points back to it, can't be converted.
+func FromGo(v any) (Object, error) {
+	return fromGo(reflect.ValueOf(v))
+}
+
+func fromGo(v reflect.Value) (Object, error) {
+	return converting{}.fromGo(v)
+}
+
+// goRef identifies a pointer, map or slice being converted. Slices are told
+// apart by their length too, as a slice shares its pointer with its prefixes.
+type goRef struct {
+	ptr uintptr
+	typ reflect.Type
+	len int
+}
+
+// converting holds the references on the way from the value FromGo or ToGo
+// was given to the one being converted, to detect cycles.
+type converting map[goRef]bool
+
+// enter adds the reference v to c, failing if it is already being converted.
+func (c converting) enter(v reflect.Value) (goRef, error) {
+	ref := goRef{ptr: v.Pointer(), typ: v.Type()}
+	if v.Kind() == reflect.Slice {
+		ref.len = v.Len()
+	}
+	if c[ref] {
+		return ref, fmt.Errorf("cannot convert cyclic %s", v.Type())
+	}
+	c[ref] = true
+	return ref, nil
+}
+
+// enterObject adds the array or hash obj to c, failing if it is already
+// being converted.
+func (c converting) enterObject(obj Object) (goRef, error) {
+	ref := goRef{ptr: reflect.ValueOf(obj).Pointer(), typ: reflect.TypeOf(obj)}
+	if c[ref] {
+		return ref, fmt.Errorf("cannot convert cyclic %s", obj.Type())
+	}
+	c[ref] = true
+	return ref, nil
+}
+
+func (c converting) fromGo(v reflect.Value) (Object, error) {
+	if !v.IsValid() {
+		return NullValue, nil
+	}
+
+	if v.Type().Implements(objectType) {
+		if isNil(v) {
+			return NullValue, nil
+		}
+		return v.Interface().(Object), nil
+	}
+
+	switch v.Type() {
+	case timeType:
+		return &String{Value: v.Interface().(time.Time).Format(time.RFC3339Nano)}, nil
+	case durationType:
+		return NewInteger(v.Int()), nil
+	}
+
+	if v.Type().Implements(errorType) {
+		if isNil(v) {
+			return NullValue, nil
+		}
+		err := v.Interface().(error)
+		return &Error{Kind: GenericError, Message: err.Error(), Cause: err}, nil
+	}
+
+	switch v.Kind() {
+	case reflect.Bool:
+		return NativeBoolToBooleanObject(v.Bool()), nil
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64:
+		return NewInteger(v.Int()), nil
+	case reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64, reflect.Uintptr:
+		if v.Uint() > 1<<63-1 {
+			return nil, fmt.Errorf("%d overflows Integer", v.Uint())
+		}
+		return NewInteger(int64(v.Uint())), nil
+	case reflect.Float32, reflect.Float64:
+		return &Float{Value: v.Float()}, nil
+	case reflect.String:
+		return &String{Value: v.String()}, nil
+
+	case reflect.Slice:
+		if v.IsNil() {
+			return NullValue, nil
+		}
+		if v.Type().Elem().Kind() == reflect.Uint8 {
+			return &Bytes{Value: bytes.Clone(v.Bytes())}, nil
+		}
+		ref, err := c.enter(v)
+		if err != nil {
+			return nil, err
+		}
+		defer delete(c, ref)
+		return c.arrayFromGo(v)
+	case reflect.Array:
+		return c.arrayFromGo(v)
+	case reflect.Map:
+		if v.IsNil() {
+			return NullValue, nil
+		}
+		ref, err := c.enter(v)
+		if err != nil {
+			return nil, err
+		}
+		defer delete(c, ref)
+		return c.hashFromGo(v)
+	case reflect.Struct:
+		return c.structFromGo(v)
+
+	case reflect.Pointer:
+		if v.IsNil() {
+			return NullValue, nil
+		}
+		ref, err := c.enter(v)
+		if err != nil {
+			return nil, err
+		}
+		defer delete(c, ref)
+		return c.fromGo(v.Elem())
+	case reflect.Interface:
+		if v.IsNil() {
+			return NullValue, nil
+		}
+		return c.fromGo(v.Elem())
+	case reflect.Func:
+		if v.IsNil() {
+			return NullValue, nil
+		}
+		return newBuiltin(v)
+	}
+
+	return nil, fmt.Errorf("cannot convert %s", v.Type())
+}
+
+func isNil(v reflect.Value) bool {
+	switch v.Kind() {
+	case reflect.Pointer, reflect.Interface, reflect.Map, reflect.Slice, reflect.Func, reflect.Chan:
+		return v.IsNil()
+	}
+	return false
+}
+
+func (c converting) arrayFromGo(v reflect.Value) (Object, error) {
+	elements := make([]Object, v.Len())
+	for i := range elements {
+		obj, err := c.fromGo(v.Index(i))
+		if err != nil {
+			return nil, fmt.Errorf("[%d]: %w", i, err)
+		}
+		elements[i] = obj
+	}
+	return &Array{Elements: elements}, nil
+}
+
+func (c converting) hashFromGo(v reflect.Value) (Object, error) {
+	hash := &Hash{Pairs: make(map[HashKey]HashPair, v.Len())}
+
+	iter := v.MapRange()
+	for iter.Next() {
+		key, err := c.fromGo(iter.Key())
+		if err != nil {
+			return nil, err
+		}
+		value, err := c.fromGo(iter.Value())
+		if err != nil {
+			return nil, fmt.Errorf("[%s]: %w", key.Inspect(), err)
+		}
+		if err := hash.set(key, value); err != nil {
+			return nil, err
+		}
+	}
+	return hash, nil
+}
+
+func (c converting) structFromGo(v reflect.Value) (Object, error) {
+	hash := &Hash{Pairs: map[HashKey]HashPair{}}
+
+	for _, f := range structFields(v.Type()) {
+		field := v.FieldByIndex(f.index)
+		if f.omitEmpty && field.IsZero() {
+			continue
+		}
+
+		value, err := c.fromGo(field)
+		if err != nil {
+			return nil, fmt.Errorf("%s: %w", f.name, err)
+		}
+		hash.set(&String{Value: f.name}, value)
+	}
+	return hash, nil
+}
+
+// set adds the pair key, value to h.
+func (h *Hash) set(key, value Object) error {
+	hashable, ok := key.(Hashable)
+	if !ok {
+		return fmt.Errorf("unusable as hash key: %s", key.Type())
+	}
+	h.Pairs[hashable.HashKey()] = HashPair{Key: key, Value: value}
+	return nil
+}
+
+// structField is an exported field of a struct as scripts see it.
+type structField struct {
+	name      string
+	index     []int
+	omitEmpty bool
+}
+
+// structFields returns the fields of struct type t, with the fields of
+// embedded structs without a tag promoted like encoding/json does. Embedded
+// pointers are kept as fields.
+func structFields(t reflect.Type) []structField {
+	var fields []structField
+
+	for _, f := range reflect.VisibleFields(t) {
+		if !f.IsExported() || f.Anonymous && f.Tag.Get("dreb") == "" && f.Type.Kind() == reflect.Struct {
+			continue
+		}
+		if !promoted(t, f) {
+			continue
+		}
+
+		name, opts, _ := strings.Cut(f.Tag.Get("dreb"), ",")
+		if name == "-" {
+			continue
+		}
+		if name == "" {
+			name = f.Name
+		}
+		fields = append(fields, structField{name: name, index: f.Index, omitEmpty: opts == "omitempty"})
+	}
+	return fields
+}
+
+// promoted reports whether field f of t is reachable, that is, it isn't
+// inside an embedded struct that has a tag of its own.
+func promoted(t reflect.Type, f reflect.StructField) bool {
+	for i := 1; i < len(f.Index); i++ {
+		outer := t.FieldByIndex(f.Index[:i])
+		if outer.Tag.Get("dreb") != "" || outer.Type.Kind() != reflect.Struct {
+			return false
+		}
+	}
+	return true
+}
+
+// ToGo stores obj in the Go value target points to, converting it the
+// opposite way of FromGo. An interface target gets the natural Go value of
+// obj: int64, float64, string, bool, []byte, []any, map[any]any, nil for
+// null, or obj itself if it has no Go counterpart, e.g. for functions.
+//
+// Arrays and hashes that contain themselves can't be converted.
+func ToGo(obj Object, target any) error {
+	v := reflect.ValueOf(target)
+	if v.Kind() != reflect.Pointer || v.IsNil() {
+		return fmt.Errorf("target must be a non-nil pointer, got %T", target)
+	}
+	return toGo(obj, v.Elem())
+}
+
+func toGo(obj Object, v reflect.Value) error {
+	return converting{}.toGo(obj, v)
+}
+
+func (c converting) toGo(obj Object, v reflect.Value) error {
+	if obj == nil {
+		obj = NullValue
+	}
+	t := v.Type()
+
+	if t.Kind() == reflect.Interface {
+		if _, ok := obj.(*Null); ok {
+			v.SetZero()
+			return nil
+		}
+		natural, err := c.naturalGo(obj)
+		if err != nil {
+			return err
+		}
+		if natural := reflect.ValueOf(natural); natural.Type().AssignableTo(t) {
+			v.Set(natural)
+			return nil
+		}
+	}
+	if reflect.TypeOf(obj).AssignableTo(t) {
+		v.Set(reflect.ValueOf(obj))
+		return nil
+	}
+
+	switch t {
+	case timeType:
+		if s, ok := obj.(*String); ok {
+			tm, err := time.Parse(time.RFC3339Nano, s.Value)
+			if err != nil {
+				return err
+			}
+			v.Set(reflect.ValueOf(tm))
+			return nil
+		}
+	case durationType:
+		if s, ok := obj.(*String); ok {
+			d, err := time.ParseDuration(s.Value)
+			if err != nil {
+				return err
+			}
+			v.SetInt(int64(d))
+			return nil
+		}
+	}
+
+	switch t.Kind() {
+	case reflect.Pointer:
+		if _, ok := obj.(*Null); ok {
+			v.SetZero()
+			return nil
+		}
+		elem := reflect.New(t.Elem())
+		if err := c.toGo(obj, elem.Elem()); err != nil {
+			return err
+		}
+		v.Set(elem)
+		return nil
+
+	case reflect.Bool:
+		if b, ok := obj.(*Boolean); ok {
+			v.SetBool(b.Value)
+			return nil
+		}
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64:
+		if i, ok := obj.(*Integer); ok {
+			if v.OverflowInt(i.Value) {
+				return fmt.Errorf("%d overflows %s", i.Value, t)
+			}
+			v.SetInt(i.Value)
+			return nil
+		}
+	case reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64, reflect.Uintptr:
+		if i, ok := obj.(*Integer); ok {
+			if i.Value < 0 || v.OverflowUint(uint64(i.Value)) {
+				return fmt.Errorf("%d overflows %s", i.Value, t)
+			}
+			v.SetUint(uint64(i.Value))
+			return nil
+		}
+	case reflect.Float32, reflect.Float64:
+		switch n := obj.(type) {
+		case *Float:
+			v.SetFloat(n.Value)
+			return nil
+		case *Integer:
+			v.SetFloat(float64(n.Value))
+			return nil
+		}
+	case reflect.String:
+		if s, ok := obj.(*String); ok {
+			v.SetString(s.Value)
+			return nil
+		}
+
+	case reflect.Slice:
+		switch o := obj.(type) {
+		case *Null:
+			v.SetZero()
+			return nil
+		case *Bytes:
+			if t.Elem().Kind() == reflect.Uint8 {
+				v.SetBytes(append([]byte(nil), o.Value...))
+				return nil
+			}
+		case *String:
+			if t.Elem().Kind() == reflect.Uint8 {
+				v.SetBytes([]byte(o.Value))
+				return nil
+			}
+		case *Array:
+			ref, err := c.enterObject(o)
+			if err != nil {
+				return err
+			}
+			defer delete(c, ref)
+			s := reflect.MakeSlice(t, len(o.Elements), len(o.Elements))
+			if err := c.elementsToGo(o.Elements, s); err != nil {
+				return err
+			}
+			v.Set(s)
+			return nil
+		}
+	case reflect.Array:
+		if a, ok := obj.(*Array); ok {
+			if len(a.Elements) != t.Len() {
+				return fmt.Errorf("cannot convert Array of length %d to %s", len(a.Elements), t)
+			}
+			ref, err := c.enterObject(a)
+			if err != nil {
+				return err
+			}
+			defer delete(c, ref)
+			return c.elementsToGo(a.Elements, v)
+		}
+	case reflect.Map:
+		switch o := obj.(type) {
+		case *Null:
+			v.SetZero()
+			return nil
+		case *Hash:
+			ref, err := c.enterObject(o)
+			if err != nil {
+				return err
+			}
+			defer delete(c, ref)
+			return c.hashToGo(o, v)
+		}
+	case reflect.Struct:
+		if h, ok := obj.(*Hash); ok {
+			ref, err := c.enterObject(h)
+			if err != nil {
+				return err
+			}
+			defer delete(c, ref)
+			return c.structToGo(h, v)
+		}
+	}
+
+	return fmt.Errorf("cannot convert %s to %s", obj.Type(), t)
+}
+
+func (c converting) elementsToGo(elements []Object, v reflect.Value) error {
+	for i, e := range elements {
+		if err := c.toGo(e, v.Index(i)); err != nil {
+			return fmt.Errorf("[%d]: %w", i, err)
+		}
+	}
+	return nil
+}
+
+func (c converting) hashToGo(h *Hash, v reflect.Value) error {
+	t := v.Type()
+	m := reflect.MakeMapWithSize(t, len(h.Pairs))
+
+	for _, pair := range h.Pairs {
+		key := reflect.New(t.Key()).Elem()
+		if err := c.toGo(pair.Key, key); err != nil {
+			return err
+		}
+		value := reflect.New(t.Elem()).Elem()
+		if err := c.toGo(pair.Value, value); err != nil {
+			return fmt.Errorf("[%s]: %w", pair.Key.Inspect(), err)
+		}
+		m.SetMapIndex(key, value)
+	}
+
+	v.Set(m)
+	return nil
+}
+
+// structToGo sets the fields of v to the values of the matching keys of h.
+// Fields without a key keep their value.
+func (c converting) structToGo(h *Hash, v reflect.Value) error {
+	for _, f := range structFields(v.Type()) {
+		pair, ok := h.Pairs[(&String{Value: f.name}).HashKey()]
+		if !ok {
+			continue
+		}
+
+		if err := c.toGo(pair.Value, v.FieldByIndex(f.index)); err != nil {
+			return fmt.Errorf("%s: %w", f.name, err)
+		}
+	}
+	return nil
+}
+
+// naturalGo returns the Go value obj stands for, or obj itself if it has
+// none.
+func (c converting) naturalGo(obj Object) (any, error) {
+	switch obj := obj.(type) {
+	case *Integer:
+		return obj.Value, nil
+	case *Float:
+		return obj.Value, nil
+	case *Boolean:
+		return obj.Value, nil
+	case *String:
+		return obj.Value, nil
+	case *Bytes:
+		return obj.Value, nil
+	case *Array:
+		ref, err := c.enterObject(obj)
+		if err != nil {
+			return nil, err
+		}
+		defer delete(c, ref)
+		values := make([]any, len(obj.Elements))
+		for i, e := range obj.Elements {
+			value, err := c.goValue(e)
+			if err != nil {
+				return nil, fmt.Errorf("[%d]: %w", i, err)
+			}
+			values[i] = value
+		}
+		return values, nil
+	case *Hash:
+		ref, err := c.enterObject(obj)
+		if err != nil {
+			return nil, err
+		}
+		defer delete(c, ref)
+		values := make(map[any]any, len(obj.Pairs))
+		for _, pair := range obj.Pairs {
+			key, err := c.goValue(pair.Key)
+			if err != nil {
+				return nil, err
+			}
+			value, err := c.goValue(pair.Value)
+			if err != nil {
+				return nil, fmt.Errorf("[%s]: %w", pair.Key.Inspect(), err)
+			}
+			values[key] = value
+		}
+		return values, nil
+	default:
+		return obj, nil
+	}
+}
+
+// goValue is naturalGo with null as nil.
+func (c converting) goValue(obj Object) (any, error) {
+	if _, ok := obj.(*Null); ok {
+		return nil, nil
+	}
+	return c.naturalGo(obj)
+}
+
+// NewBuiltin wraps the Go function fn, which may be a method value, as a
+// builtin. Arguments are converted with ToGo to the types of the parameters
+// of fn, a variadic fn taking any number past the fixed ones. A first
+// parameter of type VM gets the calling VM instead of an argument.
+//
+// The results of fn are converted with FromGo: none is null, and with a
+// last result of type error, a non-nil error is raised and otherwise the
+// result before it, if any, is returned.
+func NewBuiltin(fn any) (*Builtin, error) {
+	v := reflect.ValueOf(fn)
+	if v.Kind() != reflect.Func || v.IsNil() {
+		return nil, fmt.Errorf("not a function: %T", fn)
+	}
+	return newBuiltin(v)
+}
+
+func newBuiltin(fn reflect.Value) (*Builtin, error) {
+	t := fn.Type()
+
+	numIn, withVM := t.NumIn(), t.NumIn() > 0 && t.In(0) == vmType
+	first := 0
+	if withVM {
+		first = 1
+	}
+
+	numOut, withErr := t.NumOut(), t.NumOut() > 0 && t.Out(t.NumOut()-1) == errorType
+	if withErr {
+		numOut--
+	}
+	if numOut > 1 {
+		return nil, fmt.Errorf("too many results: %s", t)
+	}
+
+	return &Builtin{Fn: func(vm VM, args ...Object) Object {
+		want := numIn - first
+		if t.IsVariadic() {
+			want--
+			if len(args) < want {
+				return newErrorWithKind(ArityError, "wrong number of arguments. got=%d, want at least %d",
+					len(args), want)
+			}
+		} else if len(args) != want {
+			return newErrorWithKind(ArityError, "wrong number of arguments. got=%d, want=%d",
+				len(args), want)
+		}
+
+		in := make([]reflect.Value, 0, first+len(args))
+		if withVM {
+			if vm == nil {
+				in = append(in, reflect.Zero(vmType))
+			} else {
+				in = append(in, reflect.ValueOf(vm))
+			}
+		}
+		for i, arg := range args {
+			var param reflect.Type
+			if t.IsVariadic() && first+i >= numIn-1 {
+				param = t.In(numIn - 1).Elem()
+			} else {
+				param = t.In(first + i)
+			}
+
+			value := reflect.New(param).Elem()
+			if err := toGo(arg, value); err != nil {
+				return newErrorWithKind(ArgumentError, "argument %d: %s", i+1, err)
+			}
+			in = append(in, value)
+		}
+
+		out := fn.Call(in)
+		if withErr {
+			if err := out[len(out)-1]; !err.IsNil() {
+				return AsError(err.Interface().(error))
+			}
+		}
+		if numOut == 0 {
+			return NullValue
+		}
+
+		result, err := fromGo(out[0])
+		if err != nil {
+			return newErrorWithKind(ValueError, "result: %s", err)
+		}
+		return result
+	}}, nil
+}
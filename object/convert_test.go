@@ -0,0 +1,329 @@
+package object
+
+import (
+	"errors"
+	"io"
+	"reflect"
+	"testing"
+	"time"
+)
+
+type base struct {
+	ID int
+}
+
+type person struct {
+	base
+	Name    string            `dreb:"name"`
+	Age     int               `dreb:"age,omitempty"`
+	Tags    []string          `dreb:"tags"`
+	Meta    map[string]string `dreb:"meta"`
+	Manager *person           `dreb:"manager"`
+	Secret  string            `dreb:"-"`
+	private int
+}
+
+func TestFromGo(t *testing.T) {
+	when := time.Date(2024, 5, 1, 12, 0, 0, 0, time.UTC)
+
+	tests := []struct {
+		input    any
+		expected string
+	}{
+		{nil, "null"},
+		{(*person)(nil), "null"},
+		{42, "42"},
+		{uint8(7), "7"},
+		{1.5, "1.500000"},
+		{"s", "s"},
+		{true, "true"},
+		{[]int{1, 2}, "[1, 2]"},
+		{[2]string{"a", "b"}, "[a, b]"},
+		{[]any{1, "a", nil}, "[1, a, null]"},
+		{map[string]int{"a": 1}, "{a: 1}"},
+		{&person{Name: "Ann", Age: 3}, ""},
+		{when, "2024-05-01T12:00:00Z"},
+		{time.Second, "1000000000"},
+		{errors.New("boom"), "ERROR: boom"},
+		{&Integer{Value: 3}, "3"},
+	}
+
+	for _, tt := range tests {
+		obj, err := FromGo(tt.input)
+		if err != nil {
+			t.Fatalf("FromGo(%#v) error: %s", tt.input, err)
+		}
+		if tt.expected == "" {
+			// Hashes with more than one pair print in any order
+			continue
+		}
+		if obj.Inspect() != tt.expected {
+			t.Errorf("FromGo(%#v) wrong result. want=%q, got=%q", tt.input, tt.expected, obj.Inspect())
+		}
+	}
+
+	for _, input := range []any{uint64(1 << 63), make(chan int), []any{make(chan int)}, func() (int, int, error) { return 0, 0, nil }} {
+		if _, err := FromGo(input); err == nil {
+			t.Errorf("FromGo(%T) expected error", input)
+		}
+	}
+
+	obj, err := FromGo(person{base: base{ID: 1}, Name: "Bob", Age: 30, Tags: []string{"x"}, Manager: &person{Name: "Ann"}})
+	if err != nil {
+		t.Fatalf("FromGo error: %s", err)
+	}
+	hash := obj.(*Hash)
+	if len(hash.Pairs) != 6 {
+		t.Errorf("wrong number of pairs. want=6, got=%d", len(hash.Pairs))
+	}
+	for key, expected := range map[string]string{"ID": "1", "name": "Bob", "age": "30", "tags": "[x]", "meta": "null"} {
+		pair, ok := hash.Pairs[(&String{Value: key}).HashKey()]
+		if !ok {
+			t.Errorf("missing key %s", key)
+			continue
+		}
+		if pair.Value.Inspect() != expected {
+			t.Errorf("wrong value for %s. want=%q, got=%q", key, expected, pair.Value.Inspect())
+		}
+	}
+	manager := hash.Pairs[(&String{Value: "manager"}).HashKey()].Value.(*Hash)
+	if _, ok := manager.Pairs[(&String{Value: "age"}).HashKey()]; ok {
+		t.Errorf("empty field not omitted")
+	}
+	for _, key := range []string{"Secret", "private", "base"} {
+		if _, ok := hash.Pairs[(&String{Value: key}).HashKey()]; ok {
+			t.Errorf("unexpected key %s", key)
+		}
+	}
+
+	// Values shared without a cycle convert, cycles fail
+	shared := &person{Name: "Ann"}
+	if _, err := FromGo([]*person{shared, shared, {Manager: shared}}); err != nil {
+		t.Errorf("FromGo error for shared values: %s", err)
+	}
+
+	self := &person{Name: "Bob"}
+	self.Manager = self
+	list := []any{1, nil}
+	list[1] = list
+	loop := map[string]any{}
+	loop["loop"] = loop
+	cycles := []struct {
+		input    any
+		expected string
+	}{
+		{self, "manager: cannot convert cyclic *object.person"},
+		{list, "[1]: cannot convert cyclic []interface {}"},
+		{loop, "[loop]: cannot convert cyclic map[string]interface {}"},
+	}
+	for _, tt := range cycles {
+		_, err := FromGo(tt.input)
+		if err == nil || err.Error() != tt.expected {
+			t.Errorf("FromGo(%T) wrong error. want=%q, got=%v", tt.input, tt.expected, err)
+		}
+	}
+
+	raw := []byte("ab")
+	obj, err = FromGo(raw)
+	if err != nil {
+		t.Fatalf("FromGo error: %s", err)
+	}
+	raw[0] = 'x'
+	if string(obj.(*Bytes).Value) != "ab" {
+		t.Errorf("bytes share memory with the Go slice. got=%q", obj.(*Bytes).Value)
+	}
+
+	errObj, _ := FromGo(io.EOF)
+	if !errors.Is(errObj.(*Error), io.EOF) {
+		t.Errorf("error object doesn't wrap the Go error")
+	}
+}
+
+func TestToGo(t *testing.T) {
+	hash := func(pairs ...Object) *Hash {
+		h := &Hash{Pairs: map[HashKey]HashPair{}}
+		for i := 0; i < len(pairs); i += 2 {
+			h.set(pairs[i], pairs[i+1])
+		}
+		return h
+	}
+	str := func(s string) *String { return &String{Value: s} }
+	array := func(elements ...Object) *Array { return &Array{Elements: elements} }
+	builtin := &Builtin{}
+
+	tests := []struct {
+		input    Object
+		target   any
+		expected any
+	}{
+		{NewInteger(5), new(int), 5},
+		{NewInteger(5), new(uint16), uint16(5)},
+		{NewInteger(5), new(float64), 5.0},
+		{&Float{Value: 1.5}, new(float32), float32(1.5)},
+		{str("a"), new(string), "a"},
+		{True, new(bool), true},
+		{str("ab"), new([]byte), []byte("ab")},
+		{array(NewInteger(1), NewInteger(2)), new([]int), []int{1, 2}},
+		{array(NewInteger(1), NewInteger(2)), new([2]int), [2]int{1, 2}},
+		{hash(str("a"), NewInteger(1)), new(map[string]int), map[string]int{"a": 1}},
+		{
+			hash(str("ID"), NewInteger(2), str("name"), str("Ann"), str("tags"), array(str("x")),
+				str("manager"), hash(str("name"), str("Bob")), str("Secret"), str("s"), str("extra"), True),
+			new(person),
+			person{base: base{ID: 2}, Name: "Ann", Tags: []string{"x"}, Manager: &person{Name: "Bob"}},
+		},
+		{NullValue, new(*person), (*person)(nil)},
+		{str("2024-05-01T12:00:00Z"), new(time.Time), time.Date(2024, 5, 1, 12, 0, 0, 0, time.UTC)},
+		{str("1.5s"), new(time.Duration), 1500 * time.Millisecond},
+		{NewInteger(10), new(time.Duration), time.Duration(10)},
+		{NewInteger(5), new(any), int64(5)},
+		{array(NewInteger(1), NullValue), new(any), []any{int64(1), nil}},
+		{hash(str("a"), True), new(any), map[any]any{"a": true}},
+		{NullValue, new(any), nil},
+		{builtin, new(any), builtin},
+		{builtin, new(Object), builtin},
+		{builtin, new(*Builtin), builtin},
+	}
+
+	for _, tt := range tests {
+		if err := ToGo(tt.input, tt.target); err != nil {
+			t.Fatalf("ToGo(%s, %T) error: %s", tt.input.Inspect(), tt.target, err)
+		}
+		got := reflect.ValueOf(tt.target).Elem().Interface()
+		if !reflect.DeepEqual(got, tt.expected) {
+			t.Errorf("ToGo(%s, %T) wrong result. want=%#v, got=%#v", tt.input.Inspect(), tt.target, tt.expected, got)
+		}
+	}
+
+	var errTarget error
+	boom := &Error{Kind: ValueError, Message: "boom"}
+	if err := ToGo(boom, &errTarget); err != nil || errTarget != boom {
+		t.Errorf("error not converted. got=%v, %v", errTarget, err)
+	}
+
+	failures := []struct {
+		input    Object
+		target   any
+		expected string
+	}{
+		{NewInteger(300), new(int8), "300 overflows int8"},
+		{NewInteger(-1), new(uint), "-1 overflows uint"},
+		{str("a"), new(int), "cannot convert String to int"},
+		{array(str("a")), new([]int), "[0]: cannot convert String to int"},
+		{array(NewInteger(1)), new([2]int), "cannot convert Array of length 1 to [2]int"},
+		{hash(str("age"), str("x")), new(person), "age: cannot convert String to int"},
+		{NewInteger(1), 5, "target must be a non-nil pointer, got int"},
+	}
+	for _, tt := range failures {
+		err := ToGo(tt.input, tt.target)
+		if err == nil || err.Error() != tt.expected {
+			t.Errorf("ToGo(%s, %T) wrong error. want=%q, got=%v", tt.input.Inspect(), tt.target, tt.expected, err)
+		}
+	}
+
+	// Objects shared without a cycle convert, cycles fail
+	shared := array(NewInteger(1))
+	if err := ToGo(array(shared, shared), new(any)); err != nil {
+		t.Errorf("ToGo error for shared values: %s", err)
+	}
+
+	selfArray := array(NullValue)
+	selfArray.Elements[0] = selfArray
+	selfHash := hash()
+	selfHash.set(str("self"), selfHash)
+	boss := hash(str("name"), str("Ann"))
+	boss.set(str("manager"), boss)
+	cycles := []struct {
+		input    Object
+		target   any
+		expected string
+	}{
+		{selfArray, new(any), "[0]: cannot convert cyclic Array"},
+		{selfArray, new([]any), "[0]: cannot convert cyclic Array"},
+		{selfHash, new(any), "[self]: cannot convert cyclic Hash"},
+		{selfHash, new(map[string]any), "[self]: cannot convert cyclic Hash"},
+		{boss, new(person), "manager: cannot convert cyclic Hash"},
+	}
+	for _, tt := range cycles {
+		err := ToGo(tt.input, tt.target)
+		if err == nil || err.Error() != tt.expected {
+			t.Errorf("ToGo(%s, %T) wrong error. want=%q, got=%v", tt.input.Type(), tt.target, tt.expected, err)
+		}
+	}
+}
+
+type counter struct {
+	n int
+}
+
+func (c *counter) Add(d int) int {
+	c.n += d
+	return c.n
+}
+
+// callVM is a VM that can only call builtins.
+type callVM struct{}
+
+func (callVM) Call(fn Object, args ...Object) (Object, error) {
+	result := fn.(*Builtin).Fn(callVM{}, args...)
+	if err, ok := result.(*Error); ok {
+		return nil, err
+	}
+	return result, nil
+}
+
+func (callVM) Output() io.Writer { return io.Discard }
+
+func (callVM) Allocate(size int) error { return nil }
+
+func TestNewBuiltin(t *testing.T) {
+	c := &counter{}
+	double := &Builtin{Fn: func(vm VM, args ...Object) Object {
+		return NewInteger(args[0].(*Integer).Value * 2)
+	}}
+
+	tests := []struct {
+		fn       any
+		args     []Object
+		expected string
+	}{
+		{func(a, b int) int { return a + b }, []Object{NewInteger(1), NewInteger(2)}, "3"},
+		{func() {}, nil, "null"},
+		{func(sep string, xs ...int) string { return sep + string(rune('0'+len(xs))) }, []Object{&String{Value: "n"}, NewInteger(1), NewInteger(2)}, "n2"},
+		{func(x float64) (float64, error) { return x / 2, nil }, []Object{NewInteger(3)}, "1.500000"},
+		{func() error { return errors.New("failed") }, nil, "ERROR: failed"},
+		{func(s string) (int, error) { return 0, &Error{Kind: ValueError, Message: "bad " + s} }, []Object{&String{Value: "x"}}, "ERROR: bad x"},
+		{func(vm VM, f Object) (Object, error) { return vm.Call(f, NewInteger(4)) }, []Object{double}, "8"},
+		{c.Add, []Object{NewInteger(5)}, "5"},
+		{c.Add, []Object{NewInteger(5)}, "10"},
+		{func(a int) int { return a }, nil, "ERROR: wrong number of arguments. got=0, want=1"},
+		{func(a string, b ...int) int { return 0 }, nil, "ERROR: wrong number of arguments. got=0, want at least 1"},
+		{func(a int) int { return a }, []Object{True}, "ERROR: argument 1: cannot convert Boolean to int"},
+	}
+
+	for i, tt := range tests {
+		builtin, err := NewBuiltin(tt.fn)
+		if err != nil {
+			t.Fatalf("tests[%d] - NewBuiltin error: %s", i, err)
+		}
+		result := builtin.Fn(callVM{}, tt.args...)
+		if result.Inspect() != tt.expected {
+			t.Errorf("tests[%d] - wrong result. want=%q, got=%q", i, tt.expected, result.Inspect())
+		}
+	}
+
+	if _, err := NewBuiltin(42); err == nil {
+		t.Errorf("expected error for a non-function")
+	}
+}
+
+func TestNativeWithoutGoValue(t *testing.T) {
+	builtin := &Builtin{}
+	hash := &Hash{Pairs: map[HashKey]HashPair{}}
+	hash.set(&String{Value: "f"}, builtin)
+
+	native := hash.Native().(map[interface{}]interface{})
+	if native["f"] != builtin {
+		t.Errorf("wrong native value. got=%#v", native["f"])
+	}
+}
@@ -0,0 +1,12 @@
+package object
+
+// Members lists the member names each type answers to in GetMember. Tools
+// such as editor completion use it; hash keys and scope exports are
+// dynamic and not listed.
+var Members = map[ObjectType][]string{
+	StringObj: {"ends_with", "length", "lower", "replace", "split", "starts_with", "strip", "sub", "upper"},
+	BytesObj:  {"ends_with", "length", "starts_with", "sub"},
+	ArrayObj:  {"length"},
+	HashObj:   {"length"},
+	ErrorObj:  {"kind", "message", "trace", "value"},
+}
@@ -37,6 +37,14 @@ type NativeObject interface {
 	Native() interface{}
 }
 
+// native returns the Go value of obj, or obj itself if it has none.
+func native(obj Object) interface{} {
+	if n, ok := obj.(NativeObject); ok {
+		return n.Native()
+	}
+	return obj
+}
+
 type InfixOperatorObject interface {
 	Object
 	InfixOperation(operator string, other Object) Object
@@ -38,20 +38,20 @@ func (ao *Array) MarshalJSON() (text []byte, err error) {
 func (obj *Array) GetMember(name string) Object {
 	switch name {
 	case "length":
-		return &Integer{Value: int64(len(obj.Elements))}
+		return NewInteger(int64(len(obj.Elements)))
 	}
 
-	return newError("No member named [%s]", name)
+	return newErrorWithKind(MemberError, "No member named [%s]", name)
 }
 
 func (obj *Array) SetMember(name string, value Object) Object {
-	return newError("No member named [%s]", name)
+	return newErrorWithKind(MemberError, "No member named [%s]", name)
 }
 
 func (obj *Array) Native() interface{} {
 	result := make([]interface{}, len(obj.Elements))
 	for i := range obj.Elements {
-		result[i] = obj.Elements[i].(NativeObject).Native()
+		result[i] = native(obj.Elements[i])
 	}
 	return result
 }
@@ -83,5 +83,5 @@ func (obj *Array) InfixOperation(operator string, other Object) Object {
 		}
 	}
 
-	return newError("%s: %s %s %s", unknownOperatorError, obj.Type(), operator, other.Type())
+	return newErrorWithKind(TypeError, "%s: %s %s %s", unknownOperatorError, obj.Type(), operator, other.Type())
 }
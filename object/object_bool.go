@@ -35,11 +35,11 @@ func (b *Boolean) MarshalJSON() (text []byte, err error) {
 }
 
 func (obj *Boolean) GetMember(name string) Object {
-	return newError("No member named [%s]", name)
+	return newErrorWithKind(MemberError, "No member named [%s]", name)
 }
 
 func (obj *Boolean) SetMember(name string, value Object) Object {
-	return newError("No member named [%s]", name)
+	return newErrorWithKind(MemberError, "No member named [%s]", name)
 }
 
 func (obj *Boolean) Equals(other Object) bool {
@@ -71,5 +71,5 @@ func (obj *Boolean) InfixOperation(operator string, other Object) Object {
 			return True
 		}
 	}
-	return newError("%s: %s %s %s", unknownOperatorError, obj.Type(), operator, other.Type())
+	return newErrorWithKind(TypeError, "%s: %s %s %s", unknownOperatorError, obj.Type(), operator, other.Type())
 }
@@ -18,7 +18,7 @@ func (s *Bytes) String() string   { return string(s.Value) }
 func (obj *Bytes) GetMember(name string) Object {
 	switch name {
 	case "length":
-		return &Integer{Value: int64(len(obj.Value))}
+		return NewInteger(int64(len(obj.Value)))
 
 	case "sub":
 		return &MemberFn{
@@ -39,11 +39,11 @@ func (obj *Bytes) GetMember(name string) Object {
 		}
 	}
 
-	return newError("No member named [%s]", name)
+	return newErrorWithKind(MemberError, "No member named [%s]", name)
 }
 
 func (obj *Bytes) SetMember(name string, value Object) Object {
-	return newError("No member named [%s]", name)
+	return newErrorWithKind(MemberError, "No member named [%s]", name)
 }
 
 func (obj *Bytes) Native() interface{} {
@@ -100,10 +100,10 @@ func (obj *Bytes) InfixOperation(operator string, other Object) Object {
 		}
 
 	}
-	return newError("%s: %s %s %s", unknownOperatorError, obj.Type(), operator, other.Type())
+	return newErrorWithKind(TypeError, "%s: %s %s %s", unknownOperatorError, obj.Type(), operator, other.Type())
 }
 
-func bytesSub(this Object, args ...Object) Object {
+func bytesSub(vm VM, this Object, args ...Object) Object {
 	str := this.(*Bytes)
 	switch len(args) {
 	case 0:
@@ -123,10 +123,10 @@ func bytesSub(this Object, args ...Object) Object {
 			}
 		}
 	}
-	return newError("Could not execute sub-string operation. Invalid arguments!")
+	return newErrorWithKind(ArgumentError, "Could not execute sub-string operation. Invalid arguments!")
 }
 
-func bytesStartsWith(this Object, args ...Object) Object {
+func bytesStartsWith(vm VM, this Object, args ...Object) Object {
 	str := this.(*Bytes)
 	switch len(args) {
 	case 1:
@@ -140,10 +140,10 @@ func bytesStartsWith(this Object, args ...Object) Object {
 		)
 	}
 
-	return newError("Invalid arguments!")
+	return newErrorWithKind(ArgumentError, "Invalid arguments!")
 }
 
-func bytesEndsWith(this Object, args ...Object) Object {
+func bytesEndsWith(vm VM, this Object, args ...Object) Object {
 	str := this.(*Bytes)
 	switch len(args) {
 	case 1:
@@ -157,5 +157,5 @@ func bytesEndsWith(this Object, args ...Object) Object {
 		)
 	}
 
-	return newError("Invalid arguments!")
+	return newErrorWithKind(ArgumentError, "Invalid arguments!")
 }
@@ -28,8 +28,8 @@ func (obj *Closure) Equals(other Object) bool {
 }
 
 func (obj *Closure) GetMember(name string) Object {
-	return newError("No member named [%s]", name)
+	return newErrorWithKind(MemberError, "No member named [%s]", name)
 }
 func (obj *Closure) SetMember(name string, value Object) Object {
-	return newError("No member named [%s]", name)
+	return newErrorWithKind(MemberError, "No member named [%s]", name)
 }
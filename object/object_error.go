@@ -1,21 +1,105 @@
 package object
 
-import "errors"
+import (
+	"errors"
+	"fmt"
 
+	"github.com/dreblang/core/token"
+)
+
+type ErrorKind string
+
+const (
+	GenericError        ErrorKind = "Error"
+	TypeError           ErrorKind = "TypeError"
+	ArityError          ErrorKind = "ArityError"
+	ArgumentError       ErrorKind = "ArgumentError"
+	IndexError          ErrorKind = "IndexError"
+	ValueError          ErrorKind = "ValueError"
+	MemberError         ErrorKind = "MemberError"
+	StackOverflowError  ErrorKind = "StackOverflowError"
+	FrameOverflowError  ErrorKind = "FrameOverflowError"
+	GlobalOverflowError ErrorKind = "GlobalOverflowError"
+
+	// The limits an embedder sets on a run. Scripts can't catch them.
+	CanceledError         ErrorKind = "CanceledError"
+	InstructionLimitError ErrorKind = "InstructionLimitError"
+	AllocationLimitError  ErrorKind = "AllocationLimitError"
+)
+
+// StackFrame describes a single active call at the time an error was raised.
+type StackFrame struct {
+	Function string
+	Pos      token.Position
+}
+
+func (sf StackFrame) String() string {
+	return fmt.Sprintf("%s (%s)", sf.Function, sf.Pos)
+}
+
+// Error is both a runtime value and a Go error, so it can be returned from
+// the VM as is and unpacked by embedders with errors.As.
 type Error struct {
+	Kind    ErrorKind
 	Message string
+
+	// Value holds the thrown object when a script throws something other
+	// than an error, Trace is filled in by the VM when the error is raised.
+	Value Object
+	Trace []StackFrame
+
+	// Cause is the Go error behind the error, if any, e.g. the error of the
+	// context a run was canceled with.
+	Cause error
+}
+
+// AsError returns the error object err is or wraps, so a native function
+// can return the error a call through VM.Call failed with.
+func AsError(err error) *Error {
+	var errObj *Error
+	if errors.As(err, &errObj) {
+		return errObj
+	}
+	return &Error{Kind: GenericError, Message: err.Error(), Cause: err}
 }
 
 func (e *Error) Type() ObjectType { return ErrorObj }
-func (e *Error) Inspect() string  { return "ERROR: " + e.Message }
 func (e *Error) String() string   { return e.Message }
+func (e *Error) Error() string    { return e.Message }
+func (e *Error) Unwrap() error    { return e.Cause }
+func (e *Error) Inspect() string  { return "ERROR: " + e.Message }
 
 func (obj *Error) GetMember(name string) Object {
-	return newError("No member named [%s]", name)
+	switch name {
+	case "message":
+		return &String{Value: obj.Message}
+
+	case "kind":
+		kind := obj.Kind
+		if kind == "" {
+			kind = GenericError
+		}
+		return &String{Value: string(kind)}
+
+	case "trace":
+		elements := make([]Object, len(obj.Trace))
+		for i, sf := range obj.Trace {
+			elements[i] = &String{Value: sf.String()}
+		}
+		return &Array{Elements: elements}
+
+	case "value":
+		if obj.Value != nil {
+			return obj.Value
+		}
+		return NullObject
+	}
+
+	return newErrorWithKind(MemberError, "No member named [%s]", name)
 }
 
 func (obj *Error) SetMember(name string, value Object) Object {
-	return newError("No member named [%s]", name)
+	return newErrorWithKind(MemberError, "No member named [%s]", name)
 }
 
 func (obj *Error) Native() interface{} {
@@ -24,7 +108,7 @@ func (obj *Error) Native() interface{} {
 
 func (obj *Error) Equals(other Object) bool {
 	if otherObj, ok := other.(*Error); ok {
-		return obj.Message == otherObj.Message
+		return obj.Kind == otherObj.Kind && obj.Message == otherObj.Message
 	}
 	return false
 }
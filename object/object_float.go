@@ -23,10 +23,10 @@ func (i *Float) HashKey() HashKey {
 }
 
 func (obj *Float) GetMember(name string) Object {
-	return newError("No member named [%s]", name)
+	return newErrorWithKind(MemberError, "No member named [%s]", name)
 }
 func (obj *Float) SetMember(name string, value Object) Object {
-	return newError("No member named [%s]", name)
+	return newErrorWithKind(MemberError, "No member named [%s]", name)
 }
 
 func (obj *Float) Native() interface{} {
@@ -147,5 +147,5 @@ func (obj *Float) InfixOperation(operator string, other Object) Object {
 			return True
 		}
 	}
-	return newError("%s: %s %s %s", typeMissMatchError, obj.Type(), operator, other.Type())
+	return newErrorWithKind(TypeError, "%s: %s %s %s", typeMissMatchError, obj.Type(), operator, other.Type())
 }
@@ -1,6 +1,26 @@
 package object
 
-type BuiltinFunction func(args ...Object) Object
+import "io"
+
+// VM is the machine a builtin or member function is called from. Natives
+// use it to call back into the script, e.g. to run a callback they were
+// given.
+type VM interface {
+	// Call calls fn with args and returns its result. The error of a call
+	// that fails wraps the *Error it raised.
+	Call(fn Object, args ...Object) (Object, error)
+
+	// Output is where the script prints to.
+	Output() io.Writer
+
+	// Allocate charges size bytes to the memory budget of the run and
+	// fails once it is used up. The VM charges the values natives return,
+	// so only natives whose result can be far larger than their arguments
+	// need to call it, before building the result.
+	Allocate(size int) error
+}
+
+type BuiltinFunction func(vm VM, args ...Object) Object
 
 type Builtin struct {
 	Fn BuiltinFunction
@@ -11,11 +31,11 @@ func (b *Builtin) Inspect() string  { return "builtin function" }
 func (b *Builtin) String() string   { return "builtin" }
 
 func (obj *Builtin) GetMember(name string) Object {
-	return newError("No member named [%s]", name)
+	return newErrorWithKind(MemberError, "No member named [%s]", name)
 }
 
 func (obj *Builtin) SetMember(name string, value Object) Object {
-	return newError("No member named [%s]", name)
+	return newErrorWithKind(MemberError, "No member named [%s]", name)
 }
 
 func (obj *Builtin) Equals(other Object) bool {
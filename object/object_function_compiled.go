@@ -10,6 +10,14 @@ type CompiledFunction struct {
 	Instructions  code.Instructions
 	NumLocals     int
 	NumParameters int
+
+	Name     string
+	Lines    code.LineTable
+	Handlers []code.Handler
+
+	// Debug info: symbol names indexed by local and free variable slot
+	LocalNames []string
+	FreeNames  []string
 }
 
 func (cf *CompiledFunction) Type() ObjectType { return CompiledFunctionObj }
@@ -19,11 +27,11 @@ func (cf *CompiledFunction) Inspect() string {
 func (cf *CompiledFunction) String() string { return "cfunc" }
 
 func (obj *CompiledFunction) GetMember(name string) Object {
-	return newError("No member named [%s]", name)
+	return newErrorWithKind(MemberError, "No member named [%s]", name)
 }
 
 func (obj *CompiledFunction) SetMember(name string, value Object) Object {
-	return newError("No member named [%s]", name)
+	return newErrorWithKind(MemberError, "No member named [%s]", name)
 }
 
 func (obj *CompiledFunction) Equals(other Object) bool {
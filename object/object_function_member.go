@@ -1,6 +1,6 @@
 package object
 
-type MemberFunction func(this Object, args ...Object) Object
+type MemberFunction func(vm VM, this Object, args ...Object) Object
 
 type MemberFn struct {
 	Obj Object
@@ -12,11 +12,11 @@ func (b *MemberFn) Inspect() string  { return "member function" }
 func (b *MemberFn) String() string   { return "member" }
 
 func (obj *MemberFn) GetMember(name string) Object {
-	return newError("No member named [%s]", name)
+	return newErrorWithKind(MemberError, "No member named [%s]", name)
 }
 
 func (obj *MemberFn) SetMember(name string, value Object) Object {
-	return newError("No member named [%s]", name)
+	return newErrorWithKind(MemberError, "No member named [%s]", name)
 }
 
 func (obj *MemberFn) Native() interface{} {
@@ -81,14 +81,14 @@ func (h *Hash) MarshalJSON() (text []byte, err error) {
 func (obj *Hash) GetMember(name string) Object {
 	switch name {
 	case "length":
-		return &Integer{Value: int64(len(obj.Pairs))}
+		return NewInteger(int64(len(obj.Pairs)))
 	}
 
 	if val, ok := obj.Pairs[(&String{Value: name}).HashKey()]; ok {
 		return val.Value
 	}
 
-	return newError("No member named [%s]", name)
+	return newErrorWithKind(MemberError, "No member named [%s]", name)
 }
 
 func (obj *Hash) SetMember(name string, value Object) Object {
@@ -111,7 +111,7 @@ func (obj *Hash) Equals(other Object) bool {
 func (obj *Hash) Native() interface{} {
 	result := map[interface{}]interface{}{}
 	for _, v := range obj.Pairs {
-		result[v.Key.(NativeObject).Native()] = v.Value.(NativeObject).Native()
+		result[native(v.Key)] = native(v.Value)
 	}
 	return result
 }
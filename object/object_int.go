@@ -11,6 +11,29 @@ type Integer struct {
 	Value int64
 }
 
+// Integers in [MinCachedInteger, MaxCachedInteger] are shared, so arithmetic
+// on small values doesn't allocate. Integers must never be modified.
+const (
+	MinCachedInteger = -256
+	MaxCachedInteger = 1024
+)
+
+var smallIntegers [MaxCachedInteger - MinCachedInteger + 1]Integer
+
+func init() {
+	for i := range smallIntegers {
+		smallIntegers[i].Value = int64(i) + MinCachedInteger
+	}
+}
+
+// NewInteger returns an Integer holding v.
+func NewInteger(v int64) *Integer {
+	if v >= MinCachedInteger && v <= MaxCachedInteger {
+		return &smallIntegers[v-MinCachedInteger]
+	}
+	return &Integer{Value: v}
+}
+
 func (i *Integer) Type() ObjectType { return IntegerObj }
 func (i *Integer) Inspect() string  { return fmt.Sprintf("%d", i.Value) }
 func (i *Integer) HashKey() HashKey {
@@ -23,11 +46,11 @@ func (i *Integer) MarshalJSON() (text []byte, err error) {
 }
 
 func (obj *Integer) GetMember(name string) Object {
-	return newError("No member named [%s]", name)
+	return newErrorWithKind(MemberError, "No member named [%s]", name)
 }
 
 func (obj *Integer) SetMember(name string, value Object) Object {
-	return newError("No member named [%s]", name)
+	return newErrorWithKind(MemberError, "No member named [%s]", name)
 }
 
 func (obj *Integer) Native() interface{} {
@@ -50,9 +73,7 @@ func (obj *Integer) InfixOperation(operator string, other Object) Object {
 	case token.Plus:
 		switch val := other.(type) {
 		case *Integer:
-			return &Integer{
-				Value: obj.Value + val.Value,
-			}
+			return NewInteger(obj.Value + val.Value)
 		case *Float:
 			return &Float{
 				Value: float64(obj.Value) + val.Value,
@@ -62,9 +83,7 @@ func (obj *Integer) InfixOperation(operator string, other Object) Object {
 	case token.Minus:
 		switch val := other.(type) {
 		case *Integer:
-			return &Integer{
-				Value: obj.Value - val.Value,
-			}
+			return NewInteger(obj.Value - val.Value)
 		case *Float:
 			return &Float{
 				Value: float64(obj.Value) - val.Value,
@@ -74,9 +93,7 @@ func (obj *Integer) InfixOperation(operator string, other Object) Object {
 	case token.Asterisk:
 		switch val := other.(type) {
 		case *Integer:
-			return &Integer{
-				Value: obj.Value * val.Value,
-			}
+			return NewInteger(obj.Value * val.Value)
 		case *Float:
 			return &Float{
 				Value: float64(obj.Value) * val.Value,
@@ -86,9 +103,10 @@ func (obj *Integer) InfixOperation(operator string, other Object) Object {
 	case token.Slash:
 		switch val := other.(type) {
 		case *Integer:
-			return &Integer{
-				Value: obj.Value / val.Value,
+			if val.Value == 0 {
+				return newErrorWithKind(ValueError, "division by zero")
 			}
+			return NewInteger(obj.Value / val.Value)
 		case *Float:
 			return &Float{
 				Value: float64(obj.Value) / val.Value,
@@ -98,9 +116,10 @@ func (obj *Integer) InfixOperation(operator string, other Object) Object {
 	case token.Percent:
 		switch val := other.(type) {
 		case *Integer:
-			return &Integer{
-				Value: obj.Value % val.Value,
+			if val.Value == 0 {
+				return newErrorWithKind(ValueError, "division by zero")
 			}
+			return NewInteger(obj.Value % val.Value)
 		}
 
 	case token.LessThan:
@@ -155,5 +174,5 @@ func (obj *Integer) InfixOperation(operator string, other Object) Object {
 			return True
 		}
 	}
-	return newError("%s: %s %s %s", typeMissMatchError, obj.Type(), operator, other.Type())
+	return newErrorWithKind(TypeError, "%s: %s %s %s", typeMissMatchError, obj.Type(), operator, other.Type())
 }
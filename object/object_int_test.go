@@ -124,3 +124,26 @@ func TestHelperFuncs(t *testing.T) {
 		t.Errorf("Expected error in operation")
 	}
 }
+
+func TestNewInteger(t *testing.T) {
+	for _, v := range []int64{MinCachedInteger, -1, 0, 1, MaxCachedInteger} {
+		i := NewInteger(v)
+		if i.Value != v {
+			t.Errorf("wrong value. want=%d, got=%d", v, i.Value)
+		}
+		if NewInteger(v) != i {
+			t.Errorf("%d is not cached", v)
+		}
+	}
+
+	for _, v := range []int64{MinCachedInteger - 1, MaxCachedInteger + 1} {
+		if NewInteger(v) == NewInteger(v) {
+			t.Errorf("%d is cached", v)
+		}
+	}
+
+	sum := NewInteger(40).InfixOperation("+", NewInteger(2))
+	if sum != NewInteger(42) {
+		t.Errorf("small arithmetic results are not cached")
+	}
+}
@@ -15,10 +15,10 @@ func (n *Null) MarshalJSON() (text []byte, err error) {
 var NullObject = &Null{}
 
 func (obj *Null) GetMember(name string) Object {
-	return newError("No member named [%s]", name)
+	return newErrorWithKind(MemberError, "No member named [%s]", name)
 }
 func (obj *Null) SetMember(name string, value Object) Object {
-	return newError("No member named [%s]", name)
+	return newErrorWithKind(MemberError, "No member named [%s]", name)
 }
 
 func (obj *Null) Native() interface{} {
@@ -50,5 +50,5 @@ func (obj *Null) InfixOperation(operator string, other Object) Object {
 			return True
 		}
 	}
-	return newError("%s: %s %s %s", unknownOperatorError, obj.Type(), operator, other.Type())
+	return newErrorWithKind(TypeError, "%s: %s %s %s", unknownOperatorError, obj.Type(), operator, other.Type())
 }
@@ -24,10 +24,10 @@ func (obj *Scope) GetMember(name string) Object {
 	if res, ok := obj.Exports[name]; ok {
 		return res
 	}
-	return newError("No member named [%s]", name)
+	return newErrorWithKind(MemberError, "No member named [%s]", name)
 }
 func (obj *Scope) SetMember(name string, value Object) Object {
-	return newError("No member named [%s]", name)
+	return newErrorWithKind(MemberError, "No member named [%s]", name)
 }
 
 func (obj *Scope) Native() interface{} {
@@ -35,7 +35,7 @@ func (obj *Scope) Native() interface{} {
 }
 
 func (obj *Scope) InfixOperation(operator string, other Object) Object {
-	return newError("%s: %s %s %s", unknownOperatorError, obj.Type(), operator, other.Type())
+	return newErrorWithKind(TypeError, "%s: %s %s %s", unknownOperatorError, obj.Type(), operator, other.Type())
 }
 
 func (obj *Scope) Equals(other Object) bool {
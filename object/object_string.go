@@ -24,7 +24,7 @@ func (s *String) MarshalJSON() (text []byte, err error) {
 func (obj *String) GetMember(name string) Object {
 	switch name {
 	case "length":
-		return &Integer{Value: int64(len(obj.Value))}
+		return NewInteger(int64(len(obj.Value)))
 
 	case "sub":
 		return &MemberFn{
@@ -75,11 +75,11 @@ func (obj *String) GetMember(name string) Object {
 		}
 	}
 
-	return newError("No member named [%s]", name)
+	return newErrorWithKind(MemberError, "No member named [%s]", name)
 }
 
 func (obj *String) SetMember(name string, value Object) Object {
-	return newError("No member named [%s]", name)
+	return newErrorWithKind(MemberError, "No member named [%s]", name)
 }
 
 func (obj *String) Native() interface{} {
@@ -143,33 +143,41 @@ func (obj *String) InfixOperation(operator string, other Object) Object {
 			return True
 		}
 	}
-	return newError("%s: %s %s %s", unknownOperatorError, obj.Type(), operator, other.Type())
+	return newErrorWithKind(TypeError, "%s: %s %s %s", unknownOperatorError, obj.Type(), operator, other.Type())
 }
 
-func stringSub(this Object, args ...Object) Object {
+// stringPartSize is the memory a part of a split string takes up besides
+// its bytes: a String and its slot in the array.
+const stringPartSize = 32
+
+func stringSub(vm VM, this Object, args ...Object) Object {
 	str := this.(*String)
-	switch len(args) {
-	case 0:
+	if len(args) == 0 {
 		return this
-	case 1:
-		if startIdx, ok := args[0].(*Integer); ok {
-			return &String{
-				Value: str.Value[startIdx.Value:],
-			}
-		}
-	case 2:
-		if startIdx, ok := args[0].(*Integer); ok {
-			if endIdx, ok := args[1].(*Integer); ok {
-				return &String{
-					Value: str.Value[startIdx.Value:endIdx.Value],
-				}
-			}
+	}
+	if len(args) > 2 {
+		return newErrorWithKind(ArgumentError, "Could not execute sub-string operation. Invalid arguments!")
+	}
+
+	bounds := [2]int64{0, int64(len(str.Value))}
+	for i, arg := range args {
+		idx, ok := arg.(*Integer)
+		if !ok {
+			return newErrorWithKind(ArgumentError, "Could not execute sub-string operation. Invalid arguments!")
 		}
+		bounds[i] = idx.Value
+	}
+
+	start, end := bounds[0], bounds[1]
+	if start < 0 || start > end || end > int64(len(str.Value)) {
+		return newErrorWithKind(IndexError, "substring bounds out of range [%d:%d] with length %d", start, end, len(str.Value))
+	}
+	return &String{
+		Value: str.Value[start:end],
 	}
-	return newError("Could not execute sub-string operation. Invalid arguments!")
 }
 
-func stringUpper(this Object, args ...Object) Object {
+func stringUpper(vm VM, this Object, args ...Object) Object {
 	str := this.(*String)
 	switch len(args) {
 	case 0:
@@ -177,10 +185,10 @@ func stringUpper(this Object, args ...Object) Object {
 			Value: strings.ToUpper(str.Value),
 		}
 	}
-	return newError("Could not execute string upper operation. Invalid arguments!")
+	return newErrorWithKind(ArgumentError, "Could not execute string upper operation. Invalid arguments!")
 }
 
-func stringLower(this Object, args ...Object) Object {
+func stringLower(vm VM, this Object, args ...Object) Object {
 	str := this.(*String)
 	switch len(args) {
 	case 0:
@@ -188,25 +196,31 @@ func stringLower(this Object, args ...Object) Object {
 			Value: strings.ToLower(str.Value),
 		}
 	}
-	return newError("Could not execute string lower operation. Invalid arguments!")
+	return newErrorWithKind(ArgumentError, "Could not execute string lower operation. Invalid arguments!")
 }
 
-func stringReplace(this Object, args ...Object) Object {
+func stringReplace(vm VM, this Object, args ...Object) Object {
 	str := this.(*String)
 	switch len(args) {
 	case 2:
 		if search, ok := args[0].(*String); ok {
 			if replace, ok := args[1].(*String); ok {
+				// The result can be far larger than the string, so it is
+				// charged before it is built
+				n := strings.Count(str.Value, search.Value)
+				if err := vm.Allocate(len(str.Value) + n*(len(replace.Value)-len(search.Value))); err != nil {
+					return AsError(err)
+				}
 				return &String{
 					Value: strings.ReplaceAll(str.Value, search.Value, replace.Value),
 				}
 			}
 		}
 	}
-	return newError("Could not execute string replace operation. Invalid arguments!")
+	return newErrorWithKind(ArgumentError, "Could not execute string replace operation. Invalid arguments!")
 }
 
-func stringStrip(this Object, args ...Object) Object {
+func stringStrip(vm VM, this Object, args ...Object) Object {
 	str := this.(*String)
 	switch len(args) {
 	case 0:
@@ -214,20 +228,29 @@ func stringStrip(this Object, args ...Object) Object {
 			Value: strings.Trim(str.Value, " \t\n"),
 		}
 	}
-	return newError("Invalid arguments!")
+	return newErrorWithKind(ArgumentError, "Invalid arguments!")
 }
 
-func stringSplit(this Object, args ...Object) Object {
+func stringSplit(vm VM, this Object, args ...Object) Object {
 	str := this.(*String)
-	var values []string
+	sep := " "
 	switch len(args) {
 	case 0:
-		values = strings.Split(str.Value, " ")
 	case 1:
-		values = strings.Split(str.Value, args[0].(*String).Value)
+		arg, ok := args[0].(*String)
+		if !ok {
+			return newErrorWithKind(TypeError, "split separator must be String, got %s", args[0].Type())
+		}
+		sep = arg.Value
 	default:
-		return newError("Invalid arguments!")
+		return newErrorWithKind(ArgumentError, "Invalid arguments!")
+	}
+
+	n := strings.Count(str.Value, sep) + 1
+	if err := vm.Allocate(len(str.Value) + n*stringPartSize); err != nil {
+		return AsError(err)
 	}
+	values := strings.Split(str.Value, sep)
 
 	valueObjs := make([]Object, len(values))
 	for i, v := range values {
@@ -239,11 +262,14 @@ func stringSplit(this Object, args ...Object) Object {
 	}
 }
 
-func stringStartsWith(this Object, args ...Object) Object {
+func stringStartsWith(vm VM, this Object, args ...Object) Object {
 	str := this.(*String)
 	switch len(args) {
 	case 1:
-		other := args[0].(*String)
+		other, ok := args[0].(*String)
+		if !ok {
+			return newErrorWithKind(TypeError, "argument must be String, got %s", args[0].Type())
+		}
 		if len(other.Value) > len(str.Value) {
 			return False
 		}
@@ -253,14 +279,17 @@ func stringStartsWith(this Object, args ...Object) Object {
 		)
 	}
 
-	return newError("Invalid arguments!")
+	return newErrorWithKind(ArgumentError, "Invalid arguments!")
 }
 
-func stringEndsWith(this Object, args ...Object) Object {
+func stringEndsWith(vm VM, this Object, args ...Object) Object {
 	str := this.(*String)
 	switch len(args) {
 	case 1:
-		other := args[0].(*String)
+		other, ok := args[0].(*String)
+		if !ok {
+			return newErrorWithKind(TypeError, "argument must be String, got %s", args[0].Type())
+		}
 		if len(other.Value) > len(str.Value) {
 			return False
 		}
@@ -270,5 +299,5 @@ func stringEndsWith(this Object, args ...Object) Object {
 		)
 	}
 
-	return newError("Invalid arguments!")
+	return newErrorWithKind(ArgumentError, "Invalid arguments!")
 }
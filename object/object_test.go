@@ -14,3 +14,56 @@ func TestStringHashKey(t *testing.T) {
 		t.Errorf("strings with same content have different hash keys")
 	}
 }
+
+func TestErrorKinds(t *testing.T) {
+	tests := []struct {
+		result   Object
+		expected ErrorKind
+	}{
+		{(&Integer{Value: 1}).InfixOperation("+", True), TypeError},
+		{(&Integer{Value: 1}).GetMember("something"), MemberError},
+		{(&String{Value: "a"}).InfixOperation("-", True), TypeError},
+		{Builtins[0].Builtin.Fn(nil), ArityError},
+		{Builtins[2].Builtin.Fn(nil, &String{Value: "abc"}), ValueError},
+		{stringUpper(nil, &String{Value: "a"}, True), ArgumentError},
+	}
+
+	for i, tt := range tests {
+		err, ok := tt.result.(*Error)
+		if !ok {
+			t.Fatalf("tests[%d] - result is not Error. got=%T (%+v)", i, tt.result, tt.result)
+		}
+
+		if err.Kind != tt.expected {
+			t.Errorf("tests[%d] - wrong kind. want=%q, got=%q", i, tt.expected, err.Kind)
+		}
+	}
+
+	err := NewErrorWithKind(IndexError, "index out of bounds")
+	if err.Inspect() != "ERROR: index out of bounds" {
+		t.Errorf("wrong inspect result. got=%q", err.Inspect())
+	}
+}
+
+func TestMembers(t *testing.T) {
+	samples := map[ObjectType]Object{
+		StringObj: &String{Value: "a"},
+		BytesObj:  &Bytes{Value: []byte("a")},
+		ArrayObj:  &Array{},
+		HashObj:   &Hash{Pairs: map[HashKey]HashPair{}},
+		ErrorObj:  NewError("boom"),
+	}
+
+	for typ, names := range Members {
+		sample, ok := samples[typ]
+		if !ok {
+			t.Fatalf("no sample for %s", typ)
+		}
+
+		for _, name := range names {
+			if err, ok := sample.GetMember(name).(*Error); ok && err.Kind == MemberError {
+				t.Errorf("%s has no member %s", typ, name)
+			}
+		}
+	}
+}
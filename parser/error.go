@@ -0,0 +1,21 @@
+package parser
+
+import (
+	"fmt"
+
+	"github.com/dreblang/core/token"
+)
+
+// Error is a syntax error at Pos, where the parser found the token Found.
+type Error struct {
+	Pos token.Position
+	// Token types that would have been accepted, empty when anything that
+	// starts an expression would have been
+	Expected []token.TokenType
+	Found    token.Token
+	Msg      string
+}
+
+func (e *Error) Error() string {
+	return fmt.Sprintf("%s: %s", e.Pos, e.Msg)
+}
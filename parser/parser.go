@@ -49,8 +49,17 @@ type (
 )
 
 type Parser struct {
-	l      *lexer.Lexer
-	errors []string
+	l        *lexer.Lexer
+	errors   []*Error
+	comments []*ast.Comment
+
+	// Set from the first error in a statement until the parser has skipped
+	// to the next one, so a single mistake is reported once
+	recovering bool
+	// Brackets opened before currentToken and not closed yet, and how many
+	// were open when the last error was found
+	brackets   []token.TokenType
+	errorDepth int
 
 	currentToken token.Token
 	peekToken    token.Token
@@ -60,7 +69,7 @@ type Parser struct {
 }
 
 func New(l *lexer.Lexer) *Parser {
-	p := &Parser{l: l, errors: []string{}}
+	p := &Parser{l: l, errors: []*Error{}}
 
 	p.prefixParseFns = make(map[token.TokenType]prefixParseFn)
 	p.registerPrefix(token.Identifier, p.parseIdentifier)
@@ -77,6 +86,8 @@ func New(l *lexer.Lexer) *Parser {
 	p.registerPrefix(token.String, p.parseStringLiteral)
 	p.registerPrefix(token.LeftBracket, p.parseArrayLiteral)
 	p.registerPrefix(token.LeftBrace, p.parseHashLiteral)
+	p.registerPrefix(token.Try, p.parseTryExpression)
+	p.registerPrefix(token.Throw, p.parseThrowExpression)
 
 	p.infixParseFns = make(map[token.TokenType]infixParseFn)
 	p.registerInfix(token.Assign, p.parseInfixExpression)
@@ -108,34 +119,96 @@ func (p *Parser) ParseProgram() *ast.Program {
 	program.Statements = []ast.Statement{}
 
 	for p.currentToken.Type != token.EOF {
-		stmt := p.parseStatement()
-
-		if stmt != nil {
-			switch stmtT := stmt.(type) {
-			case *ast.IterStatement:
-				stmts := stmtT.ConvertToLoop()
-				program.Statements = append(program.Statements, stmts...)
-
-			case *ast.ClassDefinition:
-				stmts := stmtT.ConvertToFunc()
-				program.Statements = append(program.Statements, stmts...)
-
-			default:
-				program.Statements = append(program.Statements, stmtT)
-			}
+		if stmt, ok := p.parseStatementOrSkip(); ok {
+			program.Statements = append(program.Statements, stmt)
 		}
-		p.nextToken()
 	}
 
+	program.Comments = p.comments
 	return program
 }
 
-func (p *Parser) Errors() []string {
+func (p *Parser) Errors() []*Error {
 	return p.errors
 }
 
 // Statements
 
+// parseStatementOrSkip parses one statement and moves on to the token after
+// it. Empty statements and statements with errors are skipped and reported
+// as not ok.
+func (p *Parser) parseStatementOrSkip() (ast.Statement, bool) {
+	if p.currentTokenIs(token.Semicolon) {
+		// Such as the one in "iter x over a { ... };"
+		p.nextToken()
+		return nil, false
+	}
+
+	start, depth := p.currentToken, len(p.brackets)
+
+	stmt := p.parseStatement()
+	if p.recovering {
+		p.synchronize(start, depth)
+		return nil, false
+	}
+
+	p.nextToken()
+	return stmt, stmt != nil
+}
+
+// synchronize skips the rest of a statement that started with the token
+// start, inside depth brackets. It stops at the start of the next statement:
+// after a semicolon, at a keyword that begins a statement, or at the brace
+// closing the enclosing block. Brackets left open before the error are
+// given up on, those opened after it have to be closed first.
+func (p *Parser) synchronize(start token.Token, depth int) {
+	p.recovering = false
+	limit := p.errorDepth
+
+	// The token the statement failed on may begin the next one
+	if p.currentToken != start && len(p.brackets) <= limit && startsStatement(p.currentToken.Type) {
+		return
+	}
+
+	for {
+		p.nextToken()
+
+		switch {
+		case p.currentTokenIs(token.EOF):
+			return
+		case p.currentTokenIs(token.RightBrace):
+			if p.openBrace() < depth {
+				return
+			}
+		case len(p.brackets) > limit:
+			continue
+		case p.currentTokenIs(token.Semicolon):
+			p.nextToken()
+			return
+		case startsStatement(p.currentToken.Type):
+			return
+		}
+	}
+}
+
+// openBrace returns the index in brackets of the innermost open {, or -1.
+func (p *Parser) openBrace() int {
+	for i := len(p.brackets) - 1; i >= 0; i-- {
+		if p.brackets[i] == token.LeftBrace {
+			return i
+		}
+	}
+	return -1
+}
+
+func startsStatement(t token.TokenType) bool {
+	switch t {
+	case token.Let, token.Return, token.Scope, token.Class, token.Export, token.Load, token.Iter:
+		return true
+	}
+	return false
+}
+
 func (p *Parser) parseStatement() ast.Statement {
 	switch p.currentToken.Type {
 	case token.Let:
@@ -152,8 +225,6 @@ func (p *Parser) parseStatement() ast.Statement {
 		return p.parseLoadStatement()
 	case token.Iter:
 		return p.parseIterStatement()
-	case token.DoubleSlash:
-		return nil
 	}
 	return p.parseExpressionStatement()
 }
@@ -167,6 +238,13 @@ func (p *Parser) parseLetStatement() *ast.LetStatement {
 
 	stmt.Name = &ast.Identifier{Token: p.currentToken, Value: p.currentToken.Literal}
 
+	if p.peekTokenIs(token.Colon) {
+		p.nextToken()
+		if stmt.Type = p.parseTypeAnnotation(); stmt.Type == nil {
+			return nil
+		}
+	}
+
 	if !p.expectPeek(token.Assign) {
 		return nil
 	}
@@ -175,6 +253,10 @@ func (p *Parser) parseLetStatement() *ast.LetStatement {
 	p.nextToken()
 	stmt.Value = p.parseExpression(Lowest)
 
+	if fl, ok := stmt.Value.(*ast.FunctionLiteral); ok {
+		fl.Name = stmt.Name.Value
+	}
+
 	if p.peekTokenIs(token.Semicolon) {
 		p.nextToken()
 	}
@@ -400,6 +482,66 @@ func (p *Parser) parseIfExpression() ast.Expression {
 	return expression
 }
 
+func (p *Parser) parseTryExpression() ast.Expression {
+	expression := &ast.TryExpression{Token: p.currentToken}
+
+	if !p.expectPeek(token.LeftBrace) {
+		return nil
+	}
+
+	expression.Block = p.parseBlockStatement()
+
+	if p.peekTokenIs(token.Catch) {
+		p.nextToken()
+
+		if p.peekTokenIs(token.LeftParen) {
+			p.nextToken()
+
+			if !p.expectPeek(token.Identifier) {
+				return nil
+			}
+			expression.Parameter = &ast.Identifier{Token: p.currentToken, Value: p.currentToken.Literal}
+
+			if !p.expectPeek(token.RightParen) {
+				return nil
+			}
+		}
+
+		if !p.expectPeek(token.LeftBrace) {
+			return nil
+		}
+
+		expression.Catch = p.parseBlockStatement()
+	}
+
+	if p.peekTokenIs(token.Finally) {
+		p.nextToken()
+
+		if !p.expectPeek(token.LeftBrace) {
+			return nil
+		}
+
+		expression.Finally = p.parseBlockStatement()
+	}
+
+	if expression.Catch == nil && expression.Finally == nil {
+		p.missingHandlerError()
+		return nil
+	}
+
+	return expression
+}
+
+func (p *Parser) parseThrowExpression() ast.Expression {
+	expression := &ast.ThrowExpression{Token: p.currentToken}
+
+	// get value
+	p.nextToken()
+	expression.Value = p.parseExpression(Lowest)
+
+	return expression
+}
+
 func (p *Parser) parseBlockStatement() *ast.BlockStatement {
 	block := &ast.BlockStatement{Token: p.currentToken}
 	block.Statements = []ast.Statement{}
@@ -407,13 +549,12 @@ func (p *Parser) parseBlockStatement() *ast.BlockStatement {
 	p.nextToken()
 
 	for !p.currentTokenIs(token.RightBrace) && !p.currentTokenIs(token.EOF) {
-		stmt := p.parseStatement()
-		if stmt != nil {
+		if stmt, ok := p.parseStatementOrSkip(); ok {
 			block.Statements = append(block.Statements, stmt)
 		}
-		p.nextToken()
 	}
 
+	block.End = p.currentToken.Pos
 	return block
 }
 
@@ -424,7 +565,14 @@ func (p *Parser) parseFunctionLiteral() ast.Expression {
 		return nil
 	}
 
-	lit.Parameters = p.parseFunctionParameters()
+	lit.Parameters, lit.ParameterTypes = p.parseFunctionParameters()
+
+	if p.peekTokenIs(token.Arrow) {
+		p.nextToken()
+		if lit.ReturnType = p.parseTypeAnnotation(); lit.ReturnType == nil {
+			return nil
+		}
+	}
 
 	if !p.expectPeek(token.LeftBrace) {
 		return nil
@@ -435,31 +583,58 @@ func (p *Parser) parseFunctionLiteral() ast.Expression {
 	return lit
 }
 
-func (p *Parser) parseFunctionParameters() []*ast.Identifier {
+// parseFunctionParameters returns the parameters and, if any of them is
+// annotated, the annotation of each.
+func (p *Parser) parseFunctionParameters() ([]*ast.Identifier, []*ast.TypeAnnotation) {
 	var identifiers []*ast.Identifier
+	var types []*ast.TypeAnnotation
+	annotated := false
 
 	if p.peekTokenIs(token.RightParen) {
 		p.nextToken()
-		return identifiers
+		return identifiers, nil
 	}
 
-	p.nextToken()
-
-	identifier := &ast.Identifier{Token: p.currentToken, Value: p.currentToken.Literal}
-	identifiers = append(identifiers, identifier)
-
-	for p.peekTokenIs(token.Comma) {
-		p.nextToken()
+	for {
 		p.nextToken()
 		identifier := &ast.Identifier{Token: p.currentToken, Value: p.currentToken.Literal}
 		identifiers = append(identifiers, identifier)
+
+		var t *ast.TypeAnnotation
+		if p.peekTokenIs(token.Colon) {
+			p.nextToken()
+			if t = p.parseTypeAnnotation(); t == nil {
+				return nil, nil
+			}
+			annotated = true
+		}
+		types = append(types, t)
+
+		if !p.peekTokenIs(token.Comma) {
+			break
+		}
+		p.nextToken()
 	}
 
 	if !p.expectPeek(token.RightParen) {
-		return nil
+		return nil, nil
 	}
 
-	return identifiers
+	if !annotated {
+		types = nil
+	}
+	return identifiers, types
+}
+
+// parseTypeAnnotation parses the type name after a : or ->. Type names are
+// identifiers, fn or scope.
+func (p *Parser) parseTypeAnnotation() *ast.TypeAnnotation {
+	if p.peekTokenIs(token.Function) || p.peekTokenIs(token.Scope) {
+		p.nextToken()
+	} else if !p.expectPeek(token.Identifier) {
+		return nil
+	}
+	return &ast.TypeAnnotation{Token: p.currentToken, Name: p.currentToken.Literal}
 }
 
 func (p *Parser) parseStringLiteral() ast.Expression {
@@ -470,6 +645,7 @@ func (p *Parser) parseArrayLiteral() ast.Expression {
 	array := &ast.ArrayLiteral{Token: p.currentToken}
 
 	array.Elements = p.parseExpressionList(token.RightBracket)
+	array.End = p.currentToken.Pos
 
 	return array
 }
@@ -554,6 +730,7 @@ func (p *Parser) parseHashLiteral() ast.Expression {
 		value := p.parseExpression(Lowest)
 
 		hash.Pairs[key] = value
+		hash.Keys = append(hash.Keys, key)
 
 		if !p.peekTokenIs(token.RightBrace) && !p.expectPeek(token.Comma) {
 			return nil
@@ -561,6 +738,7 @@ func (p *Parser) parseHashLiteral() ast.Expression {
 	}
 
 	p.expectPeek(token.RightBrace)
+	hash.End = p.currentToken.Pos
 	return hash
 }
 
@@ -576,6 +754,14 @@ func (p *Parser) parseInfixExpression(left ast.Expression) ast.Expression {
 	precedence := p.currentPrecedence()
 	p.nextToken()
 	expression.Right = p.parseExpression(precedence)
+
+	if expression.Operator == token.Assign {
+		ident, isIdent := left.(*ast.Identifier)
+		fl, isFn := expression.Right.(*ast.FunctionLiteral)
+		if isIdent && isFn {
+			fl.Name = ident.Value
+		}
+	}
 	return expression
 }
 
@@ -611,9 +797,38 @@ func (p *Parser) parseCallExpression(function ast.Expression) ast.Expression {
 
 // Token
 
+// nextToken advances to the next token, setting comments aside so they can
+// appear anywhere without the grammar knowing about them
 func (p *Parser) nextToken() {
+	switch p.currentToken.Type {
+	case token.LeftParen, token.LeftBrace, token.LeftBracket:
+		p.brackets = append(p.brackets, p.currentToken.Type)
+	case token.RightParen:
+		p.closeBracket(token.LeftParen)
+	case token.RightBrace:
+		p.closeBracket(token.LeftBrace)
+	case token.RightBracket:
+		p.closeBracket(token.LeftBracket)
+	}
+
 	p.currentToken = p.peekToken
 	p.peekToken = p.l.NextToken()
+
+	for p.peekToken.Type == token.DoubleSlash {
+		p.comments = append(p.comments, &ast.Comment{Token: p.peekToken, Text: p.peekToken.Literal})
+		p.peekToken = p.l.NextToken()
+	}
+}
+
+// closeBracket pops the innermost open bracket of type open, along with any
+// left unclosed inside it. Stray closing brackets are ignored.
+func (p *Parser) closeBracket(open token.TokenType) {
+	for i := len(p.brackets) - 1; i >= 0; i-- {
+		if p.brackets[i] == open {
+			p.brackets = p.brackets[:i]
+			return
+		}
+	}
 }
 
 func (p *Parser) expectPeek(t token.TokenType) bool {
@@ -636,6 +851,16 @@ func (p *Parser) peekTokenIs(t token.TokenType) bool {
 
 // Precedence
 
+// Precedence returns how tightly an infix operator binds, or Lowest if
+// operator isn't one.
+func Precedence(operator string) int {
+	if p, ok := precedences[token.TokenType(operator)]; ok {
+		return p
+	}
+
+	return Lowest
+}
+
 func (p *Parser) peekPrecedence() int {
 	if p, ok := precedences[p.peekToken.Type]; ok {
 		return p
@@ -654,14 +879,34 @@ func (p *Parser) currentPrecedence() int {
 
 // Error
 
+// addError records an error unless the statement already has one.
+func (p *Parser) addError(found token.Token, expected []token.TokenType, format string, args ...interface{}) {
+	if p.recovering {
+		return
+	}
+	p.recovering = true
+	p.errorDepth = len(p.brackets)
+
+	p.errors = append(p.errors, &Error{
+		Pos:      found.Pos,
+		Expected: expected,
+		Found:    found,
+		Msg:      fmt.Sprintf(format, args...),
+	})
+}
+
 func (p *Parser) peekError(t token.TokenType) {
-	msg := fmt.Sprintf("expected next token to be %s, got %s instead", t, p.peekToken.Type)
-	p.errors = append(p.errors, msg)
+	p.addError(p.peekToken, []token.TokenType{t},
+		"expected next token to be %s, got %s instead", t, p.peekToken.Type)
+}
+
+func (p *Parser) missingHandlerError() {
+	p.addError(p.peekToken, []token.TokenType{token.Catch, token.Finally},
+		"expected next token to be %s or %s, got %s instead", token.Catch, token.Finally, p.peekToken.Type)
 }
 
 func (p *Parser) noPrefixParseFnError(t token.TokenType) {
-	msg := fmt.Sprintf("no prefix parse function for %s found", t)
-	p.errors = append(p.errors, msg)
+	p.addError(p.currentToken, nil, "no prefix parse function for %s found", t)
 }
 
 // Operators
@@ -2,10 +2,12 @@ package parser
 
 import (
 	"fmt"
+	"strings"
 	"testing"
 
 	"github.com/dreblang/core/ast"
 	"github.com/dreblang/core/lexer"
+	"github.com/dreblang/core/token"
 )
 
 func TestLetStatement(t *testing.T) {
@@ -446,6 +448,89 @@ func TestLoopExpression(t *testing.T) {
 	}
 }
 
+func TestTryExpression(t *testing.T) {
+	input := `try { x } catch (e) { y } finally { z }`
+
+	program := createParseProgram(input, t)
+
+	if len(program.Statements) != 1 {
+		t.Fatalf("program.Body does not contain %d statements. got=%d\n",
+			1, len(program.Statements))
+	}
+
+	stmt, ok := program.Statements[0].(*ast.ExpressionStatement)
+	if !ok {
+		t.Fatalf("program.Statements[0] is not %T. got=%T", &ast.ExpressionStatement{},
+			program.Statements[0])
+	}
+
+	exp, ok := stmt.Expression.(*ast.TryExpression)
+	if !ok {
+		t.Fatalf("stmt.Expression is not %T. got=%T", &ast.TryExpression{}, stmt.Expression)
+	}
+
+	blocks := []struct {
+		block    *ast.BlockStatement
+		expected string
+	}{
+		{exp.Block, "x"},
+		{exp.Catch, "y"},
+		{exp.Finally, "z"},
+	}
+	for _, tt := range blocks {
+		if tt.block == nil || len(tt.block.Statements) != 1 {
+			t.Fatalf("block for %q does not contain 1 statement", tt.expected)
+		}
+
+		stmt, ok := tt.block.Statements[0].(*ast.ExpressionStatement)
+		if !ok {
+			t.Fatalf("Statements[0] is not %T. got=%T", &ast.ExpressionStatement{},
+				tt.block.Statements[0])
+		}
+
+		if !testIdentifier(t, stmt.Expression, tt.expected) {
+			return
+		}
+	}
+
+	if !testIdentifier(t, exp.Parameter, "e") {
+		return
+	}
+
+	program = createParseProgram(`try { x } catch { y }; try { x } finally { z }`, t)
+	if len(program.Statements) != 2 {
+		t.Fatalf("program.Body does not contain %d statements. got=%d\n",
+			2, len(program.Statements))
+	}
+
+	exp = program.Statements[0].(*ast.ExpressionStatement).Expression.(*ast.TryExpression)
+	if exp.Parameter != nil || exp.Catch == nil || exp.Finally != nil {
+		t.Errorf("wrong try expression parsed. got=%q", exp.String())
+	}
+
+	exp = program.Statements[1].(*ast.ExpressionStatement).Expression.(*ast.TryExpression)
+	if exp.Catch != nil || exp.Finally == nil {
+		t.Errorf("wrong try expression parsed. got=%q", exp.String())
+	}
+}
+
+func TestThrowExpression(t *testing.T) {
+	program := createParseProgram(`throw x + 1`, t)
+
+	stmt, ok := program.Statements[0].(*ast.ExpressionStatement)
+	if !ok {
+		t.Fatalf("program.Statements[0] is not %T. got=%T", &ast.ExpressionStatement{},
+			program.Statements[0])
+	}
+
+	exp, ok := stmt.Expression.(*ast.ThrowExpression)
+	if !ok {
+		t.Fatalf("stmt.Expression is not %T. got=%T", &ast.ThrowExpression{}, stmt.Expression)
+	}
+
+	testInfixExpression(t, exp.Value, "x", "+", 1)
+}
+
 func TestFunctionLiteralParsing(t *testing.T) {
 	input := `fn(x, y) { x + y; }`
 
@@ -510,6 +595,54 @@ func TestFunctionParameterParsing(t *testing.T) {
 	}
 }
 
+func TestTypeAnnotations(t *testing.T) {
+	tests := []struct {
+		input    string
+		expected string
+	}{
+		{"let a: int = 1;", "let a: int = 1;"},
+		{"let f = fn(a: int, b, c: string) -> bool { a };", "let f = fn(a: int, b, c: string) -> bool a;"},
+		{"let g: fn = fn(f: fn) -> fn { f };", "let g: fn = fn(f: fn) -> fn f;"},
+		{"fn(a, b) { a }", "fn(a, b)a"},
+	}
+
+	for _, tt := range tests {
+		program := createParseProgram(tt.input, t)
+		if program.String() != tt.expected {
+			t.Errorf("wrong program. want=%q, got=%q", tt.expected, program.String())
+		}
+	}
+
+	program := createParseProgram("fn(a, b: float) -> array {}", t)
+	function := program.Statements[0].(*ast.ExpressionStatement).Expression.(*ast.FunctionLiteral)
+	if len(function.ParameterTypes) != 2 || function.ParameterType(0) != nil || function.ParameterType(1).Name != "float" {
+		t.Errorf("wrong parameter types. got=%v", function.ParameterTypes)
+	}
+	if function.ReturnType == nil || function.ReturnType.Name != "array" || function.ReturnType.Pos().Column != 20 {
+		t.Errorf("wrong return type. got=%+v", function.ReturnType)
+	}
+
+	program = createParseProgram("fn(a) {}", t)
+	function = program.Statements[0].(*ast.ExpressionStatement).Expression.(*ast.FunctionLiteral)
+	if function.ParameterTypes != nil || function.ReturnType != nil {
+		t.Errorf("unannotated function has types. got=%v, %v", function.ParameterTypes, function.ReturnType)
+	}
+
+	p := New(lexer.New("let a: = 1;\nlet f = fn(x: 1) {}"))
+	p.ParseProgram()
+	if len(p.Errors()) != 2 {
+		t.Fatalf("wrong number of errors. got=%v", p.Errors())
+	}
+	for i, expected := range []string{
+		"1:8: expected next token to be Identifier, got = instead",
+		"2:15: expected next token to be Identifier, got Int instead",
+	} {
+		if p.Errors()[i].Error() != expected {
+			t.Errorf("wrong error. want=%q, got=%q", expected, p.Errors()[i].Error())
+		}
+	}
+}
+
 func TestCallExpressionParsing(t *testing.T) {
 	input := "add(1, 2 * 3, 4 + 5);"
 
@@ -844,6 +977,197 @@ func TestErrorCases(t *testing.T) {
 		return
 	}
 
+	for _, input := range []string{"try { x }", "try { x } catch (5) { y }", "try x"} {
+		l = lexer.New(input)
+		p = New(l)
+		p.ParseProgram()
+
+		if len(p.errors) == 0 {
+			t.Errorf("Expected error in program %q", input)
+			return
+		}
+	}
+
+}
+
+func TestErrorPositions(t *testing.T) {
+	tests := []struct {
+		input    string
+		expected string
+	}{
+		{"let a = 1;\nlet 5 = 10", "main.dreb:2:5: expected next token to be Identifier, got Int instead"},
+		{"let a = 1;\n  let b = ;", "main.dreb:2:11: no prefix parse function for ; found"},
+		{"if (x < y {\n x }", "main.dreb:1:11: expected next token to be ), got { instead"},
+	}
+
+	for _, tt := range tests {
+		l := lexer.NewWithFilename(tt.input, "main.dreb")
+		p := New(l)
+		p.ParseProgram()
+
+		if len(p.Errors()) == 0 {
+			t.Fatalf("expected error for %q", tt.input)
+		}
+
+		if p.Errors()[0].Error() != tt.expected {
+			t.Errorf("wrong error. want=%q, got=%q", tt.expected, p.Errors()[0])
+		}
+	}
+}
+
+func TestErrorRecovery(t *testing.T) {
+	tests := []struct {
+		input      string
+		errors     []string
+		statements []string
+	}{
+		{
+			"let = 1;\nlet b = ;\nlet c = 3;",
+			[]string{
+				"1:5: expected next token to be Identifier, got = instead",
+				"2:9: no prefix parse function for ; found",
+			},
+			[]string{"let c = 3;"},
+		},
+		{
+			"let a = (1 + 2;\nlet b = 2;",
+			[]string{"1:15: expected next token to be ), got ; instead"},
+			[]string{"let b = 2;"},
+		},
+		{
+			"let f = fn(x) {\n\tlet = x;\n\tx\n};\nf(1)",
+			[]string{"2:6: expected next token to be Identifier, got = instead"},
+			[]string{"let f = fn(x)x;", "f(1)"},
+		},
+		{
+			"let a = { 1: };\nlet b = 2\na b",
+			[]string{"1:14: no prefix parse function for } found"},
+			[]string{"let b = 2;", "a", "b"},
+		},
+		{
+			"let a = foo(1,\nlet b = 2;",
+			[]string{"2:1: no prefix parse function for Let found"},
+			[]string{"let b = 2;"},
+		},
+		{"if (x) { y };;\nz", nil, []string{"ifx y", "z"}},
+	}
+
+	for _, tt := range tests {
+		p := New(lexer.New(tt.input))
+		program := p.ParseProgram()
+
+		errors := []string{}
+		for _, err := range p.Errors() {
+			errors = append(errors, err.Error())
+		}
+		if strings.Join(errors, "\n") != strings.Join(tt.errors, "\n") {
+			t.Errorf("wrong errors for %q.\nwant=%q\ngot =%q", tt.input, tt.errors, errors)
+		}
+
+		statements := []string{}
+		for _, stmt := range program.Statements {
+			statements = append(statements, stmt.String())
+		}
+		if strings.Join(statements, "\n") != strings.Join(tt.statements, "\n") {
+			t.Errorf("wrong statements for %q.\nwant=%q\ngot =%q", tt.input, tt.statements, statements)
+		}
+	}
+}
+
+func TestStructuredErrors(t *testing.T) {
+	p := New(lexer.NewWithFilename("if (x < y {\n x }", "main.dreb"))
+	p.ParseProgram()
+
+	if len(p.Errors()) != 1 {
+		t.Fatalf("wrong number of errors. got=%d", len(p.Errors()))
+	}
+	err := p.Errors()[0]
+	if err.Pos.Filename != "main.dreb" || err.Pos.Line != 1 || err.Pos.Column != 11 {
+		t.Errorf("wrong position. got=%s", err.Pos)
+	}
+	if len(err.Expected) != 1 || err.Expected[0] != token.RightParen {
+		t.Errorf("wrong expected tokens. got=%v", err.Expected)
+	}
+	if err.Found.Type != token.LeftBrace || err.Found.Literal != "{" {
+		t.Errorf("wrong found token. got=%+v", err.Found)
+	}
+	if err.Msg != "expected next token to be ), got { instead" {
+		t.Errorf("wrong message. got=%q", err.Msg)
+	}
+}
+
+func TestNodePositions(t *testing.T) {
+	input := `let a = 1;
+let add = fn(x, y) {
+	x + y
+};`
+	program := createParseProgram(input, t)
+
+	if len(program.Statements) != 2 {
+		t.Fatalf("program.Statements does not contain 2 statements. got=%d", len(program.Statements))
+	}
+
+	let := program.Statements[1].(*ast.LetStatement)
+	if pos := let.Pos(); pos.Line != 2 || pos.Column != 1 {
+		t.Errorf("let statement at wrong position. got=%s", pos)
+	}
+
+	fn := let.Value.(*ast.FunctionLiteral)
+	if pos := fn.Pos(); pos.Line != 2 || pos.Column != 11 {
+		t.Errorf("function literal at wrong position. got=%s", pos)
+	}
+
+	stmt := fn.Body.Statements[0].(*ast.ExpressionStatement)
+	infix := stmt.Expression.(*ast.InfixExpression)
+	if pos := infix.Pos(); pos.Line != 3 || pos.Column != 4 {
+		t.Errorf("infix expression at wrong position. got=%s", pos)
+	}
+	if pos := infix.Right.Pos(); pos.Line != 3 || pos.Column != 6 {
+		t.Errorf("identifier at wrong position. got=%s", pos)
+	}
+	if pos := fn.Body.End; pos.Line != 4 || pos.Column != 1 {
+		t.Errorf("block ends at wrong position. got=%s", pos)
+	}
+}
+
+func TestComments(t *testing.T) {
+	input := `// leading
+let a = [1, // inside
+	2]; // trailing
+let h = {"b": 1, "a": 2};`
+	program := createParseProgram(input, t)
+
+	if len(program.Statements) != 2 {
+		t.Fatalf("program.Statements does not contain 2 statements. got=%d", len(program.Statements))
+	}
+
+	expected := []struct {
+		text string
+		line int
+	}{
+		{" leading", 1},
+		{" inside", 2},
+		{" trailing", 3},
+	}
+	if len(program.Comments) != len(expected) {
+		t.Fatalf("wrong number of comments. want=%d, got=%d", len(expected), len(program.Comments))
+	}
+	for i, tt := range expected {
+		c := program.Comments[i]
+		if c.Text != tt.text || c.Pos().Line != tt.line {
+			t.Errorf("wrong comment %d. want=%q at line %d, got=%q at %s", i, tt.text, tt.line, c.Text, c.Pos())
+		}
+	}
+
+	array := program.Statements[0].(*ast.LetStatement).Value.(*ast.ArrayLiteral)
+	if pos := array.End; pos.Line != 3 || pos.Column != 3 {
+		t.Errorf("array ends at wrong position. got=%s", pos)
+	}
+
+	hash := program.Statements[1].(*ast.LetStatement).Value.(*ast.HashLiteral)
+	if hash.String() != `{b:1, a:2}` {
+		t.Errorf("hash keys not in source order. got=%s", hash.String())
+	}
 }
 
 func createParseProgram(input string, t *testing.T) *ast.Program {
@@ -1205,7 +1529,7 @@ func checkParserErrors(t *testing.T, p *Parser) {
 
 	t.Errorf("parser has %d erros", len(errors))
 	for _, msg := range errors {
-		t.Errorf("parser error: %q", msg)
+		t.Errorf("parser error: %q", msg.Error())
 	}
 	t.FailNow()
 }
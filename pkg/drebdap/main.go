@@ -0,0 +1,39 @@
+package drebdap
+
+import (
+	"fmt"
+	"os"
+
+	"github.com/dreblang/core/dap"
+)
+
+func Main() {
+	// stdout carries the protocol, so anything the script prints is
+	// captured and forwarded to the editor as output events
+	protocol := os.Stdout
+	r, w, err := os.Pipe()
+	if err != nil {
+		fmt.Fprintln(os.Stderr, "Error:", err)
+		os.Exit(1)
+	}
+	os.Stdout = w
+
+	server := dap.NewServer(os.Stdin, protocol)
+	go func() {
+		buf := make([]byte, 4096)
+		for {
+			n, err := r.Read(buf)
+			if n > 0 {
+				server.Output("stdout", string(buf[:n]))
+			}
+			if err != nil {
+				return
+			}
+		}
+	}()
+
+	if err := server.Serve(); err != nil {
+		fmt.Fprintln(os.Stderr, "Error:", err)
+		os.Exit(1)
+	}
+}
@@ -0,0 +1,65 @@
+package drebdbg
+
+import (
+	"fmt"
+	"io/ioutil"
+	"os"
+
+	"github.com/dreblang/core/compiler"
+	"github.com/dreblang/core/debugger"
+	"github.com/dreblang/core/lexer"
+	"github.com/dreblang/core/parser"
+	"github.com/dreblang/core/vm"
+)
+
+func Main() {
+	if len(os.Args) != 2 {
+		fmt.Println("usage: drebdbg file.dreb")
+		os.Exit(2)
+	}
+	filename := os.Args[1]
+
+	text, err := ioutil.ReadFile(filename)
+	if err != nil {
+		fmt.Println("Error:", err)
+		os.Exit(1)
+	}
+
+	l := lexer.NewWithFilename(string(text), filename)
+	p := parser.New(l)
+	program := p.ParseProgram()
+	if len(p.Errors()) != 0 {
+		for _, msg := range p.Errors() {
+			fmt.Println("Parse error:", msg)
+		}
+		os.Exit(1)
+	}
+
+	comp := compiler.New()
+	err = comp.Compile(program)
+	if err != nil {
+		fmt.Println("Compile error:", err)
+		os.Exit(1)
+	}
+
+	machine := vm.New(comp.Bytecode())
+
+	d := debugger.New(machine)
+	d.StopOnEntry = true
+	debugger.NewConsole(d, filename, os.Stdin, os.Stdout)
+
+	err = d.Run()
+	switch {
+	case err == debugger.ErrTerminated:
+		fmt.Println("Program terminated")
+	case err != nil:
+		if rerr, ok := err.(*vm.RuntimeError); ok {
+			fmt.Printf("Runtime error: %s: %s\n", rerr.Kind, rerr.Message)
+			fmt.Print(rerr.StackTrace())
+		} else {
+			fmt.Println("Runtime error:", err)
+		}
+	default:
+		fmt.Println("Program finished")
+	}
+}
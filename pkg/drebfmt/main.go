@@ -0,0 +1,103 @@
+package drebfmt
+
+import (
+	"bytes"
+	"flag"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+
+	"github.com/dreblang/core/format"
+)
+
+const usage = `usage: drebfmt [-w | -check] [path ...]
+
+Formats Dreblang source. Without paths it formats standard input, and
+directories are searched for .dreb files.
+
+`
+
+func Main() {
+	flags := flag.NewFlagSet("drebfmt", flag.ExitOnError)
+	flags.Usage = func() {
+		fmt.Fprint(flags.Output(), usage)
+		flags.PrintDefaults()
+	}
+	write := flags.Bool("w", false, "write the result back to the source files")
+	check := flags.Bool("check", false, "list files that are not formatted and exit with status 1 if there are any")
+	flags.Parse(os.Args[1:])
+
+	if *write && *check {
+		flags.Usage()
+		os.Exit(2)
+	}
+
+	if flags.NArg() == 0 {
+		if *write {
+			fmt.Fprintln(os.Stderr, "Error: cannot use -w with standard input")
+			os.Exit(2)
+		}
+		src, err := ioutil.ReadAll(os.Stdin)
+		if err != nil {
+			fmt.Fprintln(os.Stderr, "Error:", err)
+			os.Exit(1)
+		}
+		os.Exit(process("<standard input>", src, *write, *check))
+	}
+
+	status := 0
+	for _, path := range flags.Args() {
+		err := filepath.Walk(path, func(file string, info os.FileInfo, err error) error {
+			if err != nil {
+				return err
+			}
+			// Only look for sources inside directories, files named on the
+			// command line are formatted whatever their extension
+			if info.IsDir() || file != path && filepath.Ext(file) != ".dreb" {
+				return nil
+			}
+
+			src, err := ioutil.ReadFile(file)
+			if err != nil {
+				return err
+			}
+			if s := process(file, src, *write, *check); s > status {
+				status = s
+			}
+			return nil
+		})
+		if err != nil {
+			fmt.Fprintln(os.Stderr, "Error:", err)
+			status = 2
+		}
+	}
+	os.Exit(status)
+}
+
+// process formats one file and returns the exit status it calls for.
+func process(filename string, src []byte, write, check bool) int {
+	output, err := format.Source(filename, src)
+	if err != nil {
+		fmt.Fprintln(os.Stderr, err)
+		return 2
+	}
+
+	switch {
+	case check:
+		if !bytes.Equal(src, output) {
+			fmt.Println(filename)
+			return 1
+		}
+	case write:
+		if !bytes.Equal(src, output) {
+			if err := ioutil.WriteFile(filename, output, 0644); err != nil {
+				fmt.Fprintln(os.Stderr, "Error:", err)
+				return 2
+			}
+		}
+	default:
+		os.Stdout.Write(output)
+	}
+	return 0
+}
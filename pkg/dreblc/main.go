@@ -1,37 +1,135 @@
 package dreblc
 
 import (
+	"flag"
 	"fmt"
 	"io/ioutil"
 	"os"
 
 	"github.com/dreblang/core/compiler"
 	"github.com/dreblang/core/lexer"
-	"github.com/dreblang/core/object"
 	"github.com/dreblang/core/parser"
 	"github.com/dreblang/core/vm"
 )
 
+const usage = `usage:
+  dreblc file.dreb               compile and run a source file
+  dreblc -o out.drebc file.dreb  compile a source file to bytecode
+  dreblc run file                run a source or bytecode file
+  dreblc disasm file             print the bytecode of a source or bytecode file
+
+Add -O before the file to optimize the bytecode compiled from source,
+and -vm register to compile it for the experimental register VM.
+Bytecode files run on the VM they were compiled for.
+`
+
 func Main() {
-	filename := os.Args[1]
+	flags := flag.NewFlagSet("dreblc", flag.ExitOnError)
+	flags.Usage = func() { fmt.Fprint(flags.Output(), usage) }
+	output := flags.String("o", "", "write compiled bytecode to `file` instead of running it")
+	optimize := flags.Bool("O", false, "fold constants and remove unreachable code")
+	backend := flags.String("vm", "stack", "compile source for the `stack` or register VM")
+	flags.Parse(os.Args[1:])
+
+	args := flags.Args()
+	command := "run"
+	if len(args) == 2 && (args[0] == "run" || args[0] == "disasm") {
+		command, args = args[0], args[1:]
+	}
+	if len(args) != 1 || (*backend != "stack" && *backend != "register") {
+		flags.Usage()
+		os.Exit(2)
+	}
+
+	code := load(args[0], *optimize, *backend == "register")
+	if code == nil {
+		os.Exit(1)
+	}
+
+	if command == "disasm" {
+		fmt.Print(code.Disassemble())
+		return
+	}
+
+	if *output != "" {
+		data, err := code.MarshalBinary()
+		if err == nil {
+			err = ioutil.WriteFile(*output, data, 0644)
+		}
+		if err != nil {
+			fmt.Println("Write error:", err)
+			os.Exit(1)
+		}
+		return
+	}
+
+	var err error
+	if code.Registers > 0 {
+		err = vm.NewRegister(code).Run()
+	} else {
+		err = vm.New(code).Run()
+	}
+	if err != nil {
+		if rerr, ok := err.(*vm.RuntimeError); ok {
+			fmt.Printf("Runtime error: %s: %s\n", rerr.Kind, rerr.Message)
+			fmt.Print(rerr.StackTrace())
+		} else {
+			fmt.Println("Runtime error:", err)
+		}
+	}
+}
+
+// load reads filename as serialized bytecode, or compiles it if it is a
+// source file. Errors are printed and reported as a nil result.
+func load(filename string, optimize, register bool) *compiler.Bytecode {
+	text, err := ioutil.ReadFile(filename)
+	if err != nil {
+		fmt.Println("Error:", err)
+		return nil
+	}
 
-	text, _ := ioutil.ReadFile(filename)
-	l := lexer.New(string(text))
+	if compiler.IsBytecode(text) {
+		code, err := compiler.Unmarshal(text)
+		if err != nil {
+			fmt.Println("Load error:", err)
+			return nil
+		}
+		return code
+	}
+	return compileSource(string(text), filename, optimize, register)
+}
+
+func compileSource(text, filename string, optimize, register bool) *compiler.Bytecode {
+	l := lexer.NewWithFilename(text, filename)
 	p := parser.New(l)
 	program := p.ParseProgram()
-	constants := []object.Object{}
-	globals := make([]object.Object, vm.GlobalSize)
-	symbolTable := compiler.NewSymbolTable()
+	if len(p.Errors()) != 0 {
+		for _, msg := range p.Errors() {
+			fmt.Println("Parse error:", msg)
+		}
+		return nil
+	}
+
+	if register {
+		comp := compiler.NewRegister()
+		if optimize {
+			comp.Optimize()
+		}
+		if err := comp.Compile(program); err != nil {
+			fmt.Println("Compile error:", err)
+			return nil
+		}
+		return comp.Bytecode()
+	}
 
-	comp := compiler.NewWithState(symbolTable, constants)
+	comp := compiler.New()
+	if optimize {
+		comp.Optimize()
+	}
 	err := comp.Compile(program)
 	if err != nil {
 		fmt.Println("Compile error:", err)
-		return
+		return nil
 	}
-	code := comp.Bytecode()
-	constants = code.Constants
-
-	machine := vm.NewWithGlobalsStore(code, globals)
-	machine.Run()
+	return comp.Bytecode()
 }
@@ -0,0 +1,129 @@
+package dreblint
+
+import (
+	"encoding/json"
+	"flag"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"github.com/dreblang/core/lint"
+)
+
+const usage = `usage: dreblint [-json] [-rules name,...] [path ...]
+
+Reports likely mistakes in Dreblang source. Without paths it checks
+standard input, and directories are searched for .dreb files. The exit
+status is 1 if problems were found and 2 on errors.
+
+`
+
+// problem is a diagnostic as printed by -json
+type problem struct {
+	File    string `json:"file"`
+	Line    int    `json:"line"`
+	Column  int    `json:"column"`
+	Rule    string `json:"rule"`
+	Message string `json:"message"`
+}
+
+func Main() {
+	flags := flag.NewFlagSet("dreblint", flag.ExitOnError)
+	flags.Usage = func() {
+		fmt.Fprint(flags.Output(), usage)
+		flags.PrintDefaults()
+	}
+	asJSON := flags.Bool("json", false, "print the problems as a JSON array")
+	ruleNames := flags.String("rules", "", "comma separated rules to run instead of all of them")
+	list := flags.Bool("list", false, "list the rules and exit")
+	flags.Parse(os.Args[1:])
+
+	if *list {
+		for _, rule := range lint.Rules {
+			fmt.Printf("%-20s %s\n", rule.Name, rule.Doc)
+		}
+		return
+	}
+
+	rules := lint.Rules
+	if *ruleNames != "" {
+		rules = nil
+		for _, name := range strings.Split(*ruleNames, ",") {
+			rule, ok := lint.Lookup(strings.TrimSpace(name))
+			if !ok {
+				fmt.Fprintf(os.Stderr, "Error: unknown rule %s\n", name)
+				os.Exit(2)
+			}
+			rules = append(rules, rule)
+		}
+	}
+
+	problems := []problem{}
+	status := 0
+	check := func(filename string, src []byte) {
+		diagnostics, err := lint.Source(filename, src, rules)
+		if err != nil {
+			fmt.Fprintln(os.Stderr, err)
+			status = 2
+			return
+		}
+		for _, d := range diagnostics {
+			problems = append(problems, problem{
+				File:    d.Pos.Filename,
+				Line:    d.Pos.Line,
+				Column:  d.Pos.Column,
+				Rule:    d.Rule,
+				Message: d.Msg,
+			})
+			if !*asJSON {
+				fmt.Println(d)
+			}
+		}
+	}
+
+	if flags.NArg() == 0 {
+		src, err := ioutil.ReadAll(os.Stdin)
+		if err != nil {
+			fmt.Fprintln(os.Stderr, "Error:", err)
+			os.Exit(2)
+		}
+		check("<standard input>", src)
+	}
+
+	for _, path := range flags.Args() {
+		err := filepath.Walk(path, func(file string, info os.FileInfo, err error) error {
+			if err != nil {
+				return err
+			}
+			// Only look for sources inside directories, files named on the
+			// command line are checked whatever their extension
+			if info.IsDir() || file != path && filepath.Ext(file) != ".dreb" {
+				return nil
+			}
+
+			src, err := ioutil.ReadFile(file)
+			if err != nil {
+				return err
+			}
+			check(file, src)
+			return nil
+		})
+		if err != nil {
+			fmt.Fprintln(os.Stderr, "Error:", err)
+			status = 2
+		}
+	}
+
+	if *asJSON {
+		enc := json.NewEncoder(os.Stdout)
+		enc.SetEscapeHTML(false)
+		enc.SetIndent("", "  ")
+		enc.Encode(problems)
+	}
+	if status == 0 && len(problems) > 0 {
+		status = 1
+	}
+	os.Exit(status)
+}
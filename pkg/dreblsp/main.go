@@ -0,0 +1,19 @@
+package dreblsp
+
+import (
+	"fmt"
+	"os"
+
+	"github.com/dreblang/core/lsp"
+)
+
+func Main() {
+	// stdout carries the protocol, keep stray prints off it
+	protocol := os.Stdout
+	os.Stdout = os.Stderr
+
+	if err := lsp.NewServer(os.Stdin, protocol).Serve(); err != nil {
+		fmt.Fprintln(os.Stderr, "Error:", err)
+		os.Exit(1)
+	}
+}
@@ -19,7 +19,7 @@ func Start(in io.Reader, out io.Writer) {
 	scanner := bufio.NewScanner(in)
 
 	constants := []object.Object{}
-	globals := make([]object.Object, vm.GlobalSize)
+	globals := []object.Object{}
 	symbolTable := compiler.NewSymbolTable()
 	for i, v := range object.Builtins {
 		symbolTable.DefineBuiltin(i, v.Name)
@@ -56,9 +56,13 @@ func Start(in io.Reader, out io.Writer) {
 
 		machine := vm.NewWithGlobalsStore(code, globals)
 		err = machine.Run()
+		globals = machine.GlobalsStore()
 		if err != nil {
 			fmt.Printf("%s", chalk.Red)
 			fmt.Fprintf(out, "Woops! Executing bytecode failed:\n %s\n", err)
+			if rerr, ok := err.(*vm.RuntimeError); ok {
+				io.WriteString(out, rerr.StackTrace())
+			}
 			fmt.Printf("%s", chalk.ResetColor)
 			continue
 		}
@@ -75,8 +79,8 @@ func Start(in io.Reader, out io.Writer) {
 	}
 }
 
-func printParseErrors(out io.Writer, errors []string) {
+func printParseErrors(out io.Writer, errors []*parser.Error) {
 	for _, msg := range errors {
-		io.WriteString(out, "\t"+msg+"\n")
+		io.WriteString(out, "\t"+msg.Error()+"\n")
 	}
 }
@@ -1,10 +1,37 @@
 package token
 
+import "fmt"
+
 type TokenType string
 
 type Token struct {
 	Type    TokenType
 	Literal string
+	Pos     Position
+}
+
+// Position describes a location in a source file. Line and Column are 1-based,
+// a zero Line means the position is unknown.
+type Position struct {
+	Filename string
+	Line     int
+	Column   int
+}
+
+func (p Position) IsValid() bool { return p.Line > 0 }
+
+func (p Position) String() string {
+	if !p.IsValid() {
+		if p.Filename != "" {
+			return p.Filename
+		}
+		return "-"
+	}
+
+	if p.Filename == "" {
+		return fmt.Sprintf("%d:%d", p.Line, p.Column)
+	}
+	return fmt.Sprintf("%s:%d:%d", p.Filename, p.Line, p.Column)
 }
 
 const (
@@ -38,6 +65,7 @@ const (
 	Semicolon   = ";"
 	Colon       = ":"
 	DoubleColon = "::"
+	Arrow       = "->"
 	Dot         = "."
 	DoubleSlash = "//"
 
@@ -63,23 +91,31 @@ const (
 	Iter     = "Iter"
 	Over     = "Over"
 	Class    = "Class"
+	Try      = "Try"
+	Catch    = "Catch"
+	Finally  = "Finally"
+	Throw    = "Throw"
 )
 
 var keywords = map[string]TokenType{
-	"fn":     Function,
-	"let":    Let,
-	"true":   True,
-	"false":  False,
-	"if":     If,
-	"else":   Else,
-	"return": Return,
-	"loop":   Loop,
-	"scope":  Scope,
-	"export": Export,
-	"load":   Load,
-	"iter":   Iter,
-	"over":   Over,
-	"class":  Class,
+	"fn":      Function,
+	"let":     Let,
+	"true":    True,
+	"false":   False,
+	"if":      If,
+	"else":    Else,
+	"return":  Return,
+	"loop":    Loop,
+	"scope":   Scope,
+	"export":  Export,
+	"load":    Load,
+	"iter":    Iter,
+	"over":    Over,
+	"class":   Class,
+	"try":     Try,
+	"catch":   Catch,
+	"finally": Finally,
+	"throw":   Throw,
 }
 
 func LookupIdentifierType(identifier string) TokenType {
@@ -0,0 +1,354 @@
+package types
+
+import (
+	"fmt"
+	"sort"
+
+	"github.com/dreblang/core/ast"
+	"github.com/dreblang/core/token"
+)
+
+// variable is a name in scope with its declared type, and its signature
+// while it is known to hold a particular function
+type variable struct {
+	typ Type
+	fn  *signature
+}
+
+type scope struct {
+	outer *scope
+	vars  map[string]*variable
+	// The function whose body this is, nil outside functions
+	fn *signature
+}
+
+type checker struct {
+	scope      *scope
+	signatures map[*ast.FunctionLiteral]*signature
+	errors     []*Error
+}
+
+// Check returns the type errors in program, in source order.
+func Check(program *ast.Program) []*Error {
+	c := &checker{
+		scope:      &scope{vars: map[string]*variable{}},
+		signatures: map[*ast.FunctionLiteral]*signature{},
+	}
+	c.statements(program.Statements)
+
+	sort.SliceStable(c.errors, func(i, j int) bool {
+		a, b := c.errors[i].Pos, c.errors[j].Pos
+		return a.Line < b.Line || a.Line == b.Line && a.Column < b.Column
+	})
+	return c.errors
+}
+
+func (c *checker) errorf(pos token.Position, format string, args ...interface{}) {
+	c.errors = append(c.errors, &Error{Pos: pos, Msg: fmt.Sprintf(format, args...)})
+}
+
+func (c *checker) enter(fn *signature) {
+	c.scope = &scope{outer: c.scope, vars: map[string]*variable{}, fn: fn}
+}
+
+func (c *checker) leave() {
+	c.scope = c.scope.outer
+}
+
+func (c *checker) lookup(name string) *variable {
+	for s := c.scope; s != nil; s = s.outer {
+		if v, ok := s.vars[name]; ok {
+			return v
+		}
+	}
+	return nil
+}
+
+func (c *checker) define(name string, typ Type) *variable {
+	v := &variable{typ: typ}
+	c.scope.vars[name] = v
+	return v
+}
+
+// annotation returns the type an annotation names, Dynamic if there is no
+// annotation.
+func (c *checker) annotation(a *ast.TypeAnnotation) Type {
+	if a == nil {
+		return Dynamic
+	}
+	t, ok := Lookup(a.Name)
+	if !ok {
+		c.errorf(a.Pos(), "unknown type %s", a.Name)
+		return Dynamic
+	}
+	return t
+}
+
+// signature returns the signature of a function literal, checking its
+// annotations the first time.
+func (c *checker) signature(fn *ast.FunctionLiteral) *signature {
+	if sig, ok := c.signatures[fn]; ok {
+		return sig
+	}
+
+	sig := &signature{params: make([]Type, len(fn.Parameters)), result: c.annotation(fn.ReturnType)}
+	for i := range fn.Parameters {
+		sig.params[i] = c.annotation(fn.ParameterType(i))
+	}
+	c.signatures[fn] = sig
+	return sig
+}
+
+func (c *checker) check(pos token.Position, got, want Type, context string) {
+	if !got.AssignableTo(want) {
+		c.errorf(pos, "cannot use %s value as %s in %s", got, want, context)
+	}
+}
+
+// statements checks a list of statements and returns the type of the value
+// they leave, which is that of a final expression statement.
+func (c *checker) statements(stmts []ast.Statement) Type {
+	result := Dynamic
+	for _, stmt := range stmts {
+		result = c.statement(stmt)
+	}
+	return result
+}
+
+func (c *checker) statement(stmt ast.Statement) Type {
+	switch stmt := stmt.(type) {
+	case *ast.ExpressionStatement:
+		return c.expression(stmt.Expression)
+
+	case *ast.LetStatement:
+		v := c.define(stmt.Name.Value, c.annotation(stmt.Type))
+		if fn, ok := stmt.Value.(*ast.FunctionLiteral); ok {
+			// Known before the body is checked, for recursive calls
+			v.fn = c.signature(fn)
+		}
+		got := c.expression(stmt.Value)
+		c.check(stmt.Name.Pos(), got, v.typ, "let "+stmt.Name.Value)
+
+	case *ast.ReturnStatement:
+		got := c.expression(stmt.ReturnValue)
+		if c.scope.fn != nil && stmt.ReturnValue != nil {
+			c.check(stmt.ReturnValue.Pos(), got, c.scope.fn.result, "return")
+		}
+
+	case *ast.ScopeDefinition:
+		c.enter(nil)
+		c.statements(stmt.Block.Statements)
+		c.leave()
+		c.define(stmt.Name.Value, Scope)
+
+	case *ast.ClassDefinition:
+		v := c.define(stmt.Name.Value, Func)
+		v.fn = &signature{result: Scope}
+		c.enter(nil)
+		c.statements(stmt.Block.Statements)
+		c.leave()
+
+	case *ast.IterStatement:
+		c.expression(stmt.Expression)
+		c.define(stmt.Identifier.Value, Dynamic)
+		c.statements(stmt.Statements.Statements)
+
+	case *ast.LoadStatement:
+		c.define(stmt.Identifier.Value, Dynamic)
+	}
+	return Dynamic
+}
+
+func (c *checker) expression(expr ast.Expression) Type {
+	switch expr := expr.(type) {
+	case *ast.IntegerLiteral:
+		return Int
+	case *ast.FloatLiteral:
+		return Float
+	case *ast.StringLiteral:
+		return String
+	case *ast.Boolean:
+		return Bool
+
+	case *ast.ArrayLiteral:
+		for _, el := range expr.Elements {
+			c.expression(el)
+		}
+		return Array
+
+	case *ast.HashLiteral:
+		for _, k := range expr.Keys {
+			// Identifier keys are names or strings, depending on scope
+			if _, ok := k.(*ast.Identifier); !ok {
+				c.expression(k)
+			}
+			c.expression(expr.Pairs[k])
+		}
+		return Hash
+
+	case *ast.Identifier:
+		if v := c.lookup(expr.Value); v != nil {
+			if v.fn != nil {
+				return Func
+			}
+			return v.typ
+		}
+		if _, ok := builtins[expr.Value]; ok {
+			return Func
+		}
+		return Dynamic
+
+	case *ast.PrefixExpression:
+		right := c.expression(expr.Right)
+		if expr.Operator == token.Bang {
+			return Bool
+		}
+		if right.numeric() {
+			return right
+		}
+		if right.known() {
+			c.errorf(expr.Pos(), "invalid operation: %s%s", expr.Operator, right)
+		}
+		return Dynamic
+
+	case *ast.InfixExpression:
+		switch expr.Operator {
+		case token.Assign:
+			return c.assign(expr)
+		case token.Dot, token.DoubleColon:
+			c.expression(expr.Left)
+			return Dynamic
+		}
+
+		left, right := c.expression(expr.Left), c.expression(expr.Right)
+		t, ok := binary(expr.Operator, left, right)
+		if !ok {
+			c.errorf(expr.Pos(), "invalid operation: %s %s %s", left, expr.Operator, right)
+		}
+		return t
+
+	case *ast.IfExpression:
+		c.expression(expr.Condition)
+		consequence := c.statements(expr.Consequence.Statements)
+		if expr.Alternative == nil {
+			return Dynamic
+		}
+		if alternative := c.statements(expr.Alternative.Statements); alternative == consequence {
+			return consequence
+		}
+		return Dynamic
+
+	case *ast.LoopExpression:
+		c.expression(expr.Condition)
+		c.statements(expr.Consequence.Statements)
+		return Dynamic
+
+	case *ast.TryExpression:
+		c.statements(expr.Block.Statements)
+		if expr.Catch != nil {
+			if expr.Parameter != nil {
+				c.define(expr.Parameter.Value, Dynamic)
+			}
+			c.statements(expr.Catch.Statements)
+		}
+		if expr.Finally != nil {
+			c.statements(expr.Finally.Statements)
+		}
+		return Dynamic
+
+	case *ast.ThrowExpression:
+		c.expression(expr.Value)
+		return Dynamic
+
+	case *ast.IndexExpression:
+		c.expression(expr.Left)
+		for _, e := range []ast.Expression{expr.Index, expr.IndexUpper, expr.IndexSkip} {
+			if e != nil {
+				c.expression(e)
+			}
+		}
+		return Dynamic
+
+	case *ast.CallExpression:
+		return c.call(expr)
+
+	case *ast.FunctionLiteral:
+		sig := c.signature(expr)
+		c.enter(sig)
+		for i, p := range expr.Parameters {
+			c.define(p.Value, sig.params[i])
+		}
+		result := c.statements(expr.Body.Statements)
+		if n := len(expr.Body.Statements); n > 0 {
+			if last, ok := expr.Body.Statements[n-1].(*ast.ExpressionStatement); ok {
+				c.check(last.Pos(), result, sig.result, "return")
+			}
+		}
+		c.leave()
+		return Func
+	}
+	return Dynamic
+}
+
+// assign checks an assignment against the declared type of the name it
+// assigns to, defining the name if it is new.
+func (c *checker) assign(expr *ast.InfixExpression) Type {
+	ident, ok := expr.Left.(*ast.Identifier)
+	if !ok {
+		c.expression(expr.Left)
+		return c.expression(expr.Right)
+	}
+
+	v := c.lookup(ident.Value)
+	if v == nil {
+		v = c.define(ident.Value, Dynamic)
+	}
+	got := c.expression(expr.Right)
+	c.check(ident.Pos(), got, v.typ, "assignment to "+ident.Value)
+
+	// The name may no longer hold the function it was bound to
+	v.fn = nil
+	if fn, ok := expr.Right.(*ast.FunctionLiteral); ok {
+		v.fn = c.signature(fn)
+	}
+	return got
+}
+
+func (c *checker) call(expr *ast.CallExpression) Type {
+	callee := c.expression(expr.Function)
+	args := make([]Type, len(expr.Arguments))
+	for i, arg := range expr.Arguments {
+		args[i] = c.expression(arg)
+	}
+
+	if callee.known() && callee != Func {
+		c.errorf(expr.Function.Pos(), "cannot call %s value", callee)
+		return Dynamic
+	}
+
+	name := "function"
+	var sig *signature
+	switch fn := expr.Function.(type) {
+	case *ast.Identifier:
+		name = fn.Value
+		if v := c.lookup(fn.Value); v != nil {
+			sig = v.fn
+		} else {
+			sig = builtins[fn.Value]
+		}
+	case *ast.FunctionLiteral:
+		sig = c.signature(fn)
+	}
+	if sig == nil {
+		return Dynamic
+	}
+
+	if !sig.variadic {
+		for i, arg := range args {
+			if i < len(sig.params) {
+				c.check(expr.Arguments[i].Pos(), arg, sig.params[i], fmt.Sprintf("argument %d to %s", i+1, name))
+			}
+		}
+	}
+	return sig.result
+}
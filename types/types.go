@@ -0,0 +1,155 @@
+// Package types checks the optional type annotations of Dreblang programs,
+// as in
+//
+//	let add = fn(a: int, b: int) -> int { a + b };
+//
+// The types of literals, builtins and annotated names are known, everything
+// else is dynamic and accepted anywhere, so unannotated code only gets
+// errors for operations that are sure to fail.
+package types
+
+import (
+	"fmt"
+
+	"github.com/dreblang/core/object"
+	"github.com/dreblang/core/token"
+)
+
+type Type string
+
+const (
+	// Dynamic is the type of expressions whose type is only known at run
+	// time
+	Dynamic Type = ""
+
+	Any    Type = "any"
+	Int    Type = "int"
+	Float  Type = "float"
+	String Type = "string"
+	Bool   Type = "bool"
+	Array  Type = "array"
+	Hash   Type = "hash"
+	Bytes  Type = "bytes"
+	Func   Type = "fn"
+	Scope  Type = "scope"
+	Null   Type = "null"
+)
+
+var names = map[string]Type{}
+
+func init() {
+	for _, t := range []Type{Any, Int, Float, String, Bool, Array, Hash, Bytes, Func, Scope, Null} {
+		names[string(t)] = t
+	}
+}
+
+// Lookup returns the type an annotation names.
+func Lookup(name string) (Type, bool) {
+	t, ok := names[name]
+	return t, ok
+}
+
+// Of returns the type of a run time value.
+func Of(obj object.Object) Type {
+	switch obj.Type() {
+	case object.IntegerObj:
+		return Int
+	case object.FloatObj:
+		return Float
+	case object.StringObj:
+		return String
+	case object.BooleanObj:
+		return Bool
+	case object.ArrayObj:
+		return Array
+	case object.HashObj:
+		return Hash
+	case object.BytesObj:
+		return Bytes
+	case object.NullObj:
+		return Null
+	case object.ClosureObj, object.CompiledFunctionObj, object.BuiltinObj, object.FunctionObj:
+		return Func
+	case object.ScopeObj, object.ClassObj:
+		return Scope
+	}
+	return Dynamic
+}
+
+// AssignableTo reports whether a value of type t may be used where want is
+// expected.
+func (t Type) AssignableTo(want Type) bool {
+	return t == want || t == Dynamic || t == Any || want == Dynamic || want == Any
+}
+
+func (t Type) known() bool {
+	return t != Dynamic && t != Any
+}
+
+func (t Type) numeric() bool {
+	return t == Int || t == Float
+}
+
+// Error is a type error at Pos.
+type Error struct {
+	Pos token.Position
+	Msg string
+}
+
+func (e *Error) Error() string {
+	return fmt.Sprintf("%s: %s", e.Pos, e.Msg)
+}
+
+// signature is the type of a function
+type signature struct {
+	params   []Type
+	variadic bool
+	result   Type
+}
+
+var builtins = map[string]*signature{
+	object.BuiltinFuncNameLen:    {params: []Type{Dynamic}, result: Int},
+	object.BuiltinFuncNamePrint:  {variadic: true, result: Null},
+	object.BuiltinFuncNameInt:    {params: []Type{Dynamic}, result: Int},
+	object.BuiltinFuncNameFloat:  {params: []Type{Dynamic}, result: Float},
+	object.BuiltinFuncNameString: {params: []Type{Dynamic}, result: String},
+	object.BuiltinFuncNameBytes:  {params: []Type{Dynamic}, result: Bytes},
+}
+
+// binary returns the type of an infix operation, and false if it can't
+// work on its operands.
+func binary(op string, left, right Type) (Type, bool) {
+	switch op {
+	case token.Equal, token.NotEqual:
+		return Bool, true
+	}
+	if !left.known() || !right.known() {
+		switch op {
+		case token.LessThan, token.LessOrEqual, token.GreaterThan, token.GreaterOrEqual:
+			return Bool, true
+		}
+		return Dynamic, true
+	}
+
+	switch op {
+	case token.Plus, token.Minus, token.Asterisk, token.Slash:
+		if left.numeric() && right.numeric() {
+			if left == Float || right == Float {
+				return Float, true
+			}
+			return Int, true
+		}
+		if op == token.Plus && left == right && (left == String || left == Array) {
+			return left, true
+		}
+	case token.Percent:
+		if left == Int && right == Int {
+			return Int, true
+		}
+	case token.LessThan, token.LessOrEqual, token.GreaterThan, token.GreaterOrEqual:
+		if left.numeric() && right.numeric() || left == String && right == String {
+			return Bool, true
+		}
+	}
+	return Dynamic, false
+}
@@ -0,0 +1,106 @@
+package types
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/dreblang/core/lexer"
+	"github.com/dreblang/core/object"
+	"github.com/dreblang/core/parser"
+)
+
+func TestCheck(t *testing.T) {
+	tests := []struct {
+		input    string
+		expected []string
+	}{
+		// Unannotated code is dynamic
+		{"let a = 1; a = \"x\"; let f = fn(x) { x + 1 }; f(\"s\")", nil},
+		{"let a: int = 1; let b: float = 1.5; let c: string = \"s\"; let d: bool = 1 < 2", nil},
+		{"let e: array = [1] + [2]; let h: hash = {}; let g: fn = len; let x: any = 1", nil},
+		{
+			"let a: int = \"one\"; let b: string = 1 + 2; let c: bool = [1]",
+			[]string{
+				"1:5: cannot use string value as int in let a",
+				"1:25: cannot use int value as string in let b",
+				"1:48: cannot use array value as bool in let c",
+			},
+		},
+		{"let n: int = 1; n = 2; n = 2.5", []string{"1:24: cannot use float value as int in assignment to n"}},
+		{"let n: number = 1", []string{"1:8: unknown type number"}},
+		{
+			"let add = fn(a: int, b: int) -> int { a + b }; add(1, 2); add(\"x\", 2.5); let s: string = add(1, 2)",
+			[]string{
+				"1:63: cannot use string value as int in argument 1 to add",
+				"1:68: cannot use float value as int in argument 2 to add",
+				"1:78: cannot use int value as string in let s",
+			},
+		},
+		{
+			"let f = fn(a) -> string { if (a) { return 1 }; \"x\" }; let g = fn() -> int { \"x\" }",
+			[]string{
+				"1:43: cannot use int value as string in return",
+				"1:77: cannot use string value as int in return",
+			},
+		},
+		{"let a: int = len(\"abc\") + int(\"1\"); let s: string = string(1) + \"x\"; print(1, \"x\")", nil},
+		{"let f: float = float(1) * 2", nil},
+		{
+			"1 + \"a\"; \"a\" - \"b\"; 1.5 % 2; -\"a\"; [1] < [2]; 1 == \"a\"",
+			[]string{
+				"1:3: invalid operation: int + string",
+				"1:14: invalid operation: string - string",
+				"1:25: invalid operation: float % int",
+				"1:30: invalid operation: -string",
+				"1:40: invalid operation: array < array",
+			},
+		},
+		{"let n = 5; n(1); let m: int = 2; m(1)", []string{"1:34: cannot call int value"}},
+		// Rebinding forgets the signature
+		{"let f = fn(a: int) { a }; f = fn(a) { a }; f(\"s\")", nil},
+		{"let fact = fn(n: int) -> int { if (n < 2) { 1 } else { n * fact(n - 1) } }; fact(\"3\")",
+			[]string{"1:82: cannot use string value as int in argument 1 to fact"}},
+		{"class A { n = 0; get = fn() -> int { n } }; let s: scope = A(); scope t { }; let u: scope = t", nil},
+		{"let f = fn(a: int) { a }; fn(x: string) { x }(1)", []string{"1:47: cannot use int value as string in argument 1 to function"}},
+	}
+
+	for _, tt := range tests {
+		p := parser.New(lexer.New(tt.input))
+		program := p.ParseProgram()
+		if len(p.Errors()) != 0 {
+			t.Fatalf("parser errors for %q: %v", tt.input, p.Errors())
+		}
+
+		got := []string{}
+		for _, err := range Check(program) {
+			got = append(got, err.Error())
+		}
+		if strings.Join(got, "\n") != strings.Join(tt.expected, "\n") {
+			t.Errorf("wrong errors for %q.\nwant=%q\ngot =%q", tt.input, tt.expected, got)
+		}
+	}
+}
+
+func TestOf(t *testing.T) {
+	tests := []struct {
+		obj      object.Object
+		expected Type
+	}{
+		{&object.Integer{Value: 1}, Int},
+		{&object.Float{Value: 1}, Float},
+		{&object.String{Value: "s"}, String},
+		{object.True, Bool},
+		{&object.Array{}, Array},
+		{object.NullObject, Null},
+		{object.Builtins[0].Builtin, Func},
+	}
+
+	for _, tt := range tests {
+		if got := Of(tt.obj); got != tt.expected {
+			t.Errorf("wrong type of %s. want=%q, got=%q", tt.obj.Inspect(), tt.expected, got)
+		}
+		if _, ok := Lookup(string(tt.expected)); !ok {
+			t.Errorf("type %q has no name", tt.expected)
+		}
+	}
+}
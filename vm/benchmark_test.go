@@ -0,0 +1,102 @@
+package vm
+
+import "testing"
+
+var benchmarks = []struct {
+	name  string
+	input string
+}{
+	{"IntegerLoop", `
+let f = fn(n) {
+	let i = 0;
+	let sum = 0;
+	loop (i < n) {
+		sum = sum + i * 2 - 1;
+		i = i + 1;
+	}
+	sum
+};
+f(1000)`},
+	{"Sum", `
+let f = fn(n) {
+	let i = 0;
+	let sum = 0;
+	loop (i < n) {
+		sum = i + sum;
+		i = i + 1;
+	}
+	sum
+};
+f(100000)`},
+	{"FloatArithmetic", `
+let f = fn(n) {
+	let i = 0;
+	let x = 0.5;
+	loop (i < n) {
+		x = x * 1.5 / 1.25 + i - 0.5;
+		i = i + 1;
+	}
+	x
+};
+f(1000)`},
+	{"Comparisons", `
+let f = fn(n) {
+	let i = 0;
+	let c = 0;
+	loop (i < n) {
+		if (i % 3 == 0 == (i > n / 2)) { c = c + 1 }
+		i = i + 1;
+	}
+	c
+};
+f(1000)`},
+	{"Indexing", `
+let f = fn(n) {
+	let a = [1, 2, 3, 4, 5, 6, 7, 8];
+	let i = 0;
+	let sum = 0;
+	loop (i < n) {
+		sum = sum + a[i % 8];
+		a[i % 8] = i;
+		i = i + 1;
+	}
+	sum
+};
+f(1000)`},
+	{"Calls", `
+let add = fn(a, b) { a + b };
+let f = fn(n) {
+	let i = 0;
+	let sum = 0;
+	loop (i < n) {
+		sum = add(sum, i);
+		i = i + 1;
+	}
+	sum
+};
+f(1000)`},
+}
+
+// BenchmarkRun compares the backends on the same programs, as
+// BenchmarkRun/<backend>/<program>.
+func BenchmarkRun(b *testing.B) {
+	for _, be := range backends {
+		b.Run(be.name, func(b *testing.B) {
+			for _, bm := range benchmarks {
+				bytecode, err := be.compile(parse(bm.input))
+				if err != nil {
+					b.Fatalf("compiler error: %s", err)
+				}
+
+				b.Run(bm.name, func(b *testing.B) {
+					b.ReportAllocs()
+					for i := 0; i < b.N; i++ {
+						if _, err := be.run(bytecode); err != nil {
+							b.Fatalf("vm error: %s", err)
+						}
+					}
+				})
+			}
+		})
+	}
+}
@@ -0,0 +1,122 @@
+package vm
+
+import (
+	"testing"
+
+	"github.com/dreblang/core/compiler"
+	"github.com/dreblang/core/object"
+)
+
+// caller is the part of VM and RegisterVM these tests use.
+type caller interface {
+	object.VM
+	Run() error
+	GlobalsStore() []object.Object
+}
+
+// callers runs input on the stack and the register VM and returns the VMs.
+func callers(t *testing.T, input string) map[string]caller {
+	t.Helper()
+
+	comp := compiler.New()
+	if err := comp.Compile(parse(input)); err != nil {
+		t.Fatalf("compiler error: %s", err)
+	}
+	reg := compiler.NewRegister()
+	if err := reg.Compile(parse(input)); err != nil {
+		t.Fatalf("compiler error: %s", err)
+	}
+
+	vms := map[string]caller{
+		"stack":    New(comp.Bytecode()),
+		"register": NewRegister(reg.Bytecode()),
+	}
+	for name, vm := range vms {
+		if err := vm.Run(); err != nil {
+			t.Fatalf("vm error (%s): %s", name, err)
+		}
+	}
+	return vms
+}
+
+func TestCall(t *testing.T) {
+	input := `
+let add = fn(a, b) { a + b };
+let apply = fn(f, x) { f(x) + 1 };
+let double = fn(x) { x * 2 };
+let fail = fn() { throw "boom" };
+let guard = fn(f) { try { f() } catch (e) { "caught " + e.message } };
+`
+
+	for name, vm := range callers(t, input) {
+		globals := vm.GlobalsStore()
+		add, apply, double, fail, guard := globals[0], globals[1], globals[2], globals[3], globals[4]
+
+		result, err := vm.Call(add, &object.Integer{Value: 1}, &object.Integer{Value: 2})
+		if err != nil {
+			t.Fatalf("call error (%s): %s", name, err)
+		}
+		if err := testIntegerObject(3, result); err != nil {
+			t.Errorf("wrong result (%s): %s", name, err)
+		}
+
+		// A builtin called by the script calls back into the same VM
+		doubler := &object.Builtin{Fn: func(vm object.VM, args ...object.Object) object.Object {
+			result, err := vm.Call(double, args...)
+			if err != nil {
+				return object.AsError(err)
+			}
+			return result
+		}}
+		result, err = vm.Call(apply, doubler, &object.Integer{Value: 20})
+		if err != nil {
+			t.Fatalf("call error (%s): %s", name, err)
+		}
+		if err := testIntegerObject(41, result); err != nil {
+			t.Errorf("wrong result (%s): %s", name, err)
+		}
+
+		_, err = vm.Call(fail)
+		rerr, ok := err.(*RuntimeError)
+		if !ok {
+			t.Fatalf("expected RuntimeError (%s). got=%T (%+v)", name, err, err)
+		}
+		if rerr.Message != "boom" {
+			t.Errorf("wrong error message (%s). got=%q", name, rerr.Message)
+		}
+
+		// An error a callback fails with can be caught by the script
+		failing := &object.Builtin{Fn: func(vm object.VM, args ...object.Object) object.Object {
+			result, err := vm.Call(fail)
+			if err != nil {
+				return object.AsError(err)
+			}
+			return result
+		}}
+		result, err = vm.Call(guard, failing)
+		if err != nil {
+			t.Fatalf("call error (%s): %s", name, err)
+		}
+		if err := testStringObject("caught boom", result); err != nil {
+			t.Errorf("wrong result (%s): %s", name, err)
+		}
+
+		_, err = vm.Call(add, &object.Integer{Value: 1})
+		rerr, ok = err.(*RuntimeError)
+		if !ok {
+			t.Fatalf("expected RuntimeError (%s). got=%T (%+v)", name, err, err)
+		}
+		if rerr.Kind != object.ArityError {
+			t.Errorf("wrong error kind (%s). got=%q", name, rerr.Kind)
+		}
+
+		// The VM is still usable after the failed calls
+		result, err = vm.Call(add, &object.Integer{Value: 4}, &object.Integer{Value: 5})
+		if err != nil {
+			t.Fatalf("call error (%s): %s", name, err)
+		}
+		if err := testIntegerObject(9, result); err != nil {
+			t.Errorf("wrong result (%s): %s", name, err)
+		}
+	}
+}
@@ -0,0 +1,125 @@
+package vm
+
+import (
+	"io"
+	"os"
+
+	"github.com/dreblang/core/object"
+)
+
+// Config sets the sizes the stack, the frame stack and the globals of a VM
+// start with, and the limits they grow to on demand. Zero fields take their
+// value from DefaultConfig.
+//
+// MaxInstructions and MaxAllocations bound the instructions a run executes
+// and the strings, bytes, arrays, hashes and closures it creates, counting
+// the calls made during the run. MaxMemory bounds the bytes those objects
+// take up, adding up every object created even once it is no longer used.
+// Zero means no limit.
+//
+// Calls in tail position reuse the caller's frame, so recursion like
+// let a = fn() { a() }; a() never overflows MaxFrames. Like an endless loop
+// it runs until MaxInstructions or the context passed to RunContext stops
+// it, and forever under DefaultConfig.
+//
+// Output is where print writes, os.Stdout if nil.
+type Config struct {
+	InitialStack int
+	MaxStack     int
+
+	InitialFrames int
+	MaxFrames     int
+
+	InitialGlobals int
+	MaxGlobals     int
+
+	MaxInstructions int
+	MaxAllocations  int
+	MaxMemory       int
+
+	Output io.Writer
+}
+
+// DefaultConfig returns the configuration of New. The limits are the fixed
+// sizes the VM had before they could be configured.
+func DefaultConfig() Config {
+	return Config{
+		InitialStack:   256,
+		MaxStack:       StackSize,
+		InitialFrames:  64,
+		MaxFrames:      MaxFrames,
+		InitialGlobals: 64,
+		MaxGlobals:     GlobalSize,
+		Output:         os.Stdout,
+	}
+}
+
+// SandboxConfig returns a configuration for running untrusted scripts, with
+// print writing to out and tight bounds on the memory and the time a run
+// may use. Services may tune the limits to their needs.
+func SandboxConfig(out io.Writer) Config {
+	return Config{
+		InitialStack:    256,
+		MaxStack:        1024,
+		InitialFrames:   64,
+		MaxFrames:       256,
+		InitialGlobals:  64,
+		MaxGlobals:      1024,
+		MaxInstructions: 10_000_000,
+		MaxAllocations:  100_000,
+		MaxMemory:       64 << 20,
+		Output:          out,
+	}
+}
+
+// withDefaults fills the zero fields of c and keeps every initial size
+// within its limit.
+func (c Config) withDefaults() Config {
+	d := DefaultConfig()
+	fill := func(v *int, def int) {
+		if *v <= 0 {
+			*v = def
+		}
+	}
+	fill(&c.MaxStack, d.MaxStack)
+	fill(&c.MaxFrames, d.MaxFrames)
+	fill(&c.MaxGlobals, d.MaxGlobals)
+	fill(&c.InitialStack, min(d.InitialStack, c.MaxStack))
+	fill(&c.InitialFrames, min(d.InitialFrames, c.MaxFrames))
+	fill(&c.InitialGlobals, min(d.InitialGlobals, c.MaxGlobals))
+	if c.Output == nil {
+		c.Output = d.Output
+	}
+
+	c.InitialStack = min(c.InitialStack, c.MaxStack)
+	c.InitialFrames = min(c.InitialFrames, c.MaxFrames)
+	c.InitialGlobals = min(c.InitialGlobals, c.MaxGlobals)
+	return c
+}
+
+func (c Config) stackOverflow() error {
+	return newError(object.StackOverflowError, "stack overflow: more than %d stack slots", c.MaxStack)
+}
+
+func (c Config) frameOverflow() error {
+	return newError(object.FrameOverflowError, "frame overflow: more than %d frames", c.MaxFrames)
+}
+
+func (c Config) globalOverflow() error {
+	return newError(object.GlobalOverflowError, "global overflow: more than %d globals", c.MaxGlobals)
+}
+
+// grow returns s with a length of at least n, doubling it up to limit. It
+// reports false if n is over limit.
+func grow[T any](s []T, n, limit int) ([]T, bool) {
+	if n <= len(s) {
+		return s, true
+	}
+	if n > limit {
+		return s, false
+	}
+
+	grown := make([]T, min(max(len(s)*2, n), limit))
+	copy(grown, s)
+	return grown, true
+}
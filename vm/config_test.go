@@ -0,0 +1,189 @@
+package vm
+
+import (
+	"bytes"
+	"context"
+	"testing"
+
+	"github.com/dreblang/core/compiler"
+	"github.com/dreblang/core/object"
+)
+
+// runWithConfig runs input on the stack and the register VM configured with
+// config.
+func runWithConfig(t *testing.T, input string, config Config) map[string]error {
+	t.Helper()
+	return runWithContext(t, context.Background(), input, config)
+}
+
+// runWithContext is runWithConfig running with ctx.
+func runWithContext(t *testing.T, ctx context.Context, input string, config Config) map[string]error {
+	t.Helper()
+
+	errs := map[string]error{}
+
+	comp := compiler.New()
+	if err := comp.Compile(parse(input)); err != nil {
+		t.Fatalf("compiler error: %s", err)
+	}
+	errs["stack"] = NewWithConfig(comp.Bytecode(), config).RunContext(ctx)
+
+	reg := compiler.NewRegister()
+	if err := reg.Compile(parse(input)); err != nil {
+		t.Fatalf("compiler error: %s", err)
+	}
+	errs["register"] = NewRegisterWithConfig(reg.Bytecode(), config).RunContext(ctx)
+
+	return errs
+}
+
+func TestConfigGrowth(t *testing.T) {
+	input := `
+let depth = fn(n) { if (n == 0) { 0 } else { 1 + depth(n - 1) } };
+let a = 1; let b = 2; let c = 3; let d = 4;
+depth(1000) + a + b + c + d`
+
+	config := Config{InitialStack: 1, InitialFrames: 1, InitialGlobals: 1}
+	for name, err := range runWithConfig(t, input, config) {
+		if err != nil {
+			t.Errorf("vm error (%s): %s", name, err)
+		}
+	}
+
+	comp := compiler.New()
+	if err := comp.Compile(parse(input)); err != nil {
+		t.Fatalf("compiler error: %s", err)
+	}
+	vm := NewWithConfig(comp.Bytecode(), config)
+	if err := vm.Run(); err != nil {
+		t.Fatalf("vm error: %s", err)
+	}
+	if err := testIntegerObject(1010, vm.LastPoppedStackElem()); err != nil {
+		t.Errorf("wrong result: %s", err)
+	}
+	if len(vm.frames) < 1001 || len(vm.frames) > MaxFrames {
+		t.Errorf("frames did not grow as needed. got=%d", len(vm.frames))
+	}
+}
+
+func TestDefaultConfig(t *testing.T) {
+	comp := compiler.New()
+	if err := comp.Compile(parse(`let a = 1; a`)); err != nil {
+		t.Fatalf("compiler error: %s", err)
+	}
+	vm := New(comp.Bytecode())
+
+	config := DefaultConfig()
+	if len(vm.stack) != config.InitialStack {
+		t.Errorf("wrong initial stack. want=%d, got=%d", config.InitialStack, len(vm.stack))
+	}
+	if len(vm.frames) != config.InitialFrames {
+		t.Errorf("wrong initial frames. want=%d, got=%d", config.InitialFrames, len(vm.frames))
+	}
+	if len(vm.globals) != config.InitialGlobals {
+		t.Errorf("wrong initial globals. want=%d, got=%d", config.InitialGlobals, len(vm.globals))
+	}
+}
+
+func TestConfigLimits(t *testing.T) {
+	tests := []struct {
+		input    string
+		config   Config
+		kind     object.ErrorKind
+		expected string
+	}{
+		{
+			`let f = fn(n) { f(n + 1) + 1 }; f(0)`,
+			Config{MaxFrames: 10},
+			object.FrameOverflowError,
+			"frame overflow: more than 10 frames",
+		},
+		{
+			`let f = fn(n) { let a = 1; let b = 2; f(n + 1) + a + b }; f(0)`,
+			Config{MaxStack: 64},
+			object.StackOverflowError,
+			"stack overflow: more than 64 stack slots",
+		},
+		{
+			// The scope object is pushed onto a full stack
+			`scope s {}`,
+			Config{MaxStack: 1},
+			object.StackOverflowError,
+			"stack overflow: more than 1 stack slots",
+		},
+		{
+			`let a = 1; let b = 2; let c = 3;`,
+			Config{MaxGlobals: 2},
+			object.GlobalOverflowError,
+			"global overflow: more than 2 globals",
+		},
+	}
+
+	for _, tt := range tests {
+		for name, err := range runWithConfig(t, tt.input, tt.config) {
+			rerr, ok := err.(*RuntimeError)
+			if !ok {
+				t.Fatalf("expected RuntimeError (%s). got=%T (%+v)", name, err, err)
+			}
+			if rerr.Kind != tt.kind {
+				t.Errorf("wrong error kind (%s). want=%q, got=%q", name, tt.kind, rerr.Kind)
+			}
+			if rerr.Message != tt.expected {
+				t.Errorf("wrong error message (%s). want=%q, got=%q", name, tt.expected, rerr.Message)
+			}
+		}
+	}
+}
+
+func TestConfigMemberAtLimit(t *testing.T) {
+	// The member access runs with both its operands filling the stack
+	comp := compiler.New()
+	if err := comp.Compile(parse(`"abc".length`)); err != nil {
+		t.Fatalf("compiler error: %s", err)
+	}
+	vm := NewWithConfig(comp.Bytecode(), Config{MaxStack: 2})
+	if err := vm.Run(); err != nil {
+		t.Fatalf("vm error: %s", err)
+	}
+	if err := testIntegerObject(3, vm.LastPoppedStackElem()); err != nil {
+		t.Errorf("wrong result: %s", err)
+	}
+
+	vm = NewWithConfig(comp.Bytecode(), Config{MaxStack: 1})
+	err := vm.Run()
+	if rerr, ok := err.(*RuntimeError); !ok || rerr.Kind != object.StackOverflowError {
+		t.Errorf("expected stack overflow. got=%T (%+v)", err, err)
+	}
+}
+
+func TestConfigOutput(t *testing.T) {
+	var out bytes.Buffer
+	for name, err := range runWithConfig(t, `print("a", 1); print()`, Config{Output: &out}) {
+		if err != nil {
+			t.Errorf("vm error (%s): %s", name, err)
+		}
+	}
+
+	if out.String() != "a1\n\na1\n\n" {
+		t.Errorf("wrong output. got=%q", out.String())
+	}
+}
+
+func TestSandboxConfig(t *testing.T) {
+	var out bytes.Buffer
+	config := SandboxConfig(&out)
+
+	for name, err := range runWithConfig(t, `print("start"); loop (true) {}`, config) {
+		rerr, ok := err.(*RuntimeError)
+		if !ok {
+			t.Fatalf("expected RuntimeError (%s). got=%T (%+v)", name, err, err)
+		}
+		if rerr.Kind != object.InstructionLimitError {
+			t.Errorf("wrong error kind (%s). got=%q", name, rerr.Kind)
+		}
+	}
+
+	if out.String() != "start\nstart\n" {
+		t.Errorf("wrong output. got=%q", out.String())
+	}
+}
@@ -0,0 +1,94 @@
+package vm
+
+import (
+	"strings"
+
+	"github.com/dreblang/core/object"
+)
+
+// Hook observes the execution of a VM. Its methods are called synchronously
+// from Run, so a hook that blocks pauses the VM. A non-nil error stops Run
+// and is returned from it as is.
+//
+// While a hook is installed, calls in tail position push a frame like any
+// other call, so Depth grows and OnCall and OnReturn see them. Deep tail
+// recursion can overflow the frames when debugged.
+type Hook interface {
+	// BeforeInstruction is called before the instruction at frame.IP() runs
+	BeforeInstruction(vm *VM, frame *Frame) error
+	// OnCall is called once a closure call has pushed its frame
+	OnCall(vm *VM, frame *Frame) error
+	// OnReturn is called once frame has returned value to its caller
+	OnReturn(vm *VM, frame *Frame, value object.Object) error
+}
+
+// SetHook installs h on the VM; nil removes the current hook.
+func (vm *VM) SetHook(h Hook) {
+	vm.hook = h
+}
+
+// Variable is a named value as seen by a debugger.
+type Variable struct {
+	Name  string
+	Value object.Object
+}
+
+// Depth returns the number of active frames.
+func (vm *VM) Depth() int {
+	return vm.framesIndex
+}
+
+// Frames returns the active frames, innermost first.
+func (vm *VM) Frames() []*Frame {
+	frames := make([]*Frame, 0, vm.framesIndex)
+	for i := vm.framesIndex - 1; i >= 0; i-- {
+		frames = append(frames, vm.frames[i])
+	}
+	return frames
+}
+
+// Locals returns the local variables of an active frame, named from the
+// compiler's debug info.
+func (vm *VM) Locals(f *Frame) []Variable {
+	vars := []Variable{}
+	for i, name := range f.cl.Fn.LocalNames {
+		if hiddenName(name) || f.basePointer+i >= vm.sp {
+			continue
+		}
+
+		value := vm.stack[f.basePointer+i]
+		if value == nil {
+			value = Null
+		}
+		vars = append(vars, Variable{Name: name, Value: value})
+	}
+	return vars
+}
+
+// FreeVariables returns the variables captured by the closure of f.
+func (vm *VM) FreeVariables(f *Frame) []Variable {
+	vars := []Variable{}
+	for i, name := range f.cl.Fn.FreeNames {
+		if i < len(f.cl.Free) {
+			vars = append(vars, Variable{Name: name, Value: f.cl.Free[i]})
+		}
+	}
+	return vars
+}
+
+// Globals returns the global variables that have been assigned so far.
+func (vm *VM) Globals() []Variable {
+	vars := []Variable{}
+	for i, name := range vm.globalNames {
+		if hiddenName(name) || i >= len(vm.globals) || vm.globals[i] == nil {
+			continue
+		}
+		vars = append(vars, Variable{Name: name, Value: vm.globals[i]})
+	}
+	return vars
+}
+
+// hiddenName reports whether name is a compiler generated symbol
+func hiddenName(name string) bool {
+	return name == "" || strings.HasPrefix(name, "$")
+}
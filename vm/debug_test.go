@@ -0,0 +1,185 @@
+package vm
+
+import (
+	"errors"
+	"fmt"
+	"sort"
+	"strings"
+	"testing"
+
+	"github.com/dreblang/core/compiler"
+	"github.com/dreblang/core/object"
+)
+
+type recordingHook struct {
+	events []string
+	stopAt string
+	vars   []Variable
+}
+
+func (h *recordingHook) BeforeInstruction(vm *VM, frame *Frame) error {
+	if h.stopAt != "" && frame.Function().Name == h.stopAt && vm.Depth() > 1 {
+		h.vars = append(vm.Locals(frame), vm.FreeVariables(frame)...)
+		h.vars = append(h.vars, vm.Globals()...)
+		return errors.New("stopped")
+	}
+	return nil
+}
+
+func (h *recordingHook) OnCall(vm *VM, frame *Frame) error {
+	h.events = append(h.events, fmt.Sprintf("call %s depth=%d", frame.Function().Name, vm.Depth()))
+	return nil
+}
+
+func (h *recordingHook) OnReturn(vm *VM, frame *Frame, value object.Object) error {
+	h.events = append(h.events, fmt.Sprintf("return %s %s", frame.Function().Name, value.Inspect()))
+	return nil
+}
+
+func TestHooks(t *testing.T) {
+	input := `let double = fn(x) { x * 2 };
+let quad = fn(x) { double(double(x)) };
+quad(3) + len("ab")`
+
+	comp := compiler.New()
+	if err := comp.Compile(parse(input)); err != nil {
+		t.Fatalf("compiler error: %s", err)
+	}
+
+	hook := &recordingHook{}
+	vm := New(comp.Bytecode())
+	vm.SetHook(hook)
+	if err := vm.Run(); err != nil {
+		t.Fatalf("vm error: %s", err)
+	}
+	testExpectedObject(t, 14, vm.LastPoppedStackElem())
+
+	expected := []string{
+		"call quad depth=2",
+		"call double depth=3",
+		"return double 6",
+		"call double depth=3",
+		"return double 12",
+		"return quad 12",
+	}
+	if strings.Join(hook.events, "\n") != strings.Join(expected, "\n") {
+		t.Errorf("wrong hook events.\nwant=%q\ngot=%q", expected, hook.events)
+	}
+}
+
+func TestHookVariables(t *testing.T) {
+	input := `let base = 10;
+let outer = fn(a) {
+	let b = a + 1;
+	let inner = fn(c) { a + b + c + base };
+	inner(5)
+};
+outer(1)`
+
+	comp := compiler.New()
+	if err := comp.Compile(parse(input)); err != nil {
+		t.Fatalf("compiler error: %s", err)
+	}
+
+	hook := &recordingHook{stopAt: "inner"}
+	vm := New(comp.Bytecode())
+	vm.SetHook(hook)
+	err := vm.Run()
+	if err == nil || err.Error() != "stopped" {
+		t.Fatalf("hook error was not returned from Run. got=%v", err)
+	}
+
+	expected := map[string]int64{"c": 5, "a": 1, "b": 2, "base": 10}
+	found := map[string]bool{}
+	for _, v := range hook.vars {
+		want, ok := expected[v.Name]
+		if !ok {
+			continue
+		}
+		found[v.Name] = true
+		if err := testIntegerObject(want, v.Value); err != nil {
+			t.Errorf("variable %s: %s", v.Name, err)
+		}
+	}
+	for name := range expected {
+		if !found[name] {
+			t.Errorf("variable %s not reported. got=%v", name, hook.vars)
+		}
+	}
+
+	frames := vm.Frames()
+	names := []string{}
+	for _, f := range frames {
+		names = append(names, f.Function().Name)
+	}
+	if strings.Join(names, ",") != "inner,outer,<main>" {
+		t.Errorf("wrong frames. got=%v", names)
+	}
+}
+
+// localsHook records the names of the locals of every frame it sees
+type localsHook struct {
+	names map[string]bool
+}
+
+func (h *localsHook) BeforeInstruction(vm *VM, frame *Frame) error {
+	if vm.Depth() > 1 {
+		for _, v := range vm.Locals(frame) {
+			h.names[v.Name] = true
+		}
+	}
+	return nil
+}
+
+func (h *localsHook) OnCall(vm *VM, frame *Frame) error { return nil }
+
+func (h *localsHook) OnReturn(vm *VM, frame *Frame, value object.Object) error { return nil }
+
+func TestHookIterLocals(t *testing.T) {
+	input := `let sum = fn(rows) {
+	let total = 0;
+	iter row over rows {
+		iter x over row { total = total + x }
+	};
+	total
+};
+sum([[1, 2], [3]])`
+
+	compile := func() *compiler.Bytecode {
+		comp := compiler.New()
+		if err := comp.Compile(parse(input)); err != nil {
+			t.Fatalf("compiler error: %s", err)
+		}
+		return comp.Bytecode()
+	}
+
+	hook := &localsHook{names: map[string]bool{}}
+	vm := New(compile())
+	vm.SetHook(hook)
+	if err := vm.Run(); err != nil {
+		t.Fatalf("vm error: %s", err)
+	}
+	testExpectedObject(t, 6, vm.LastPoppedStackElem())
+
+	names := []string{}
+	for name := range hook.names {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	if strings.Join(names, ",") != "row,rows,total,x" {
+		t.Errorf("wrong locals. got=%v", names)
+	}
+
+	// The hidden counters are named the same on every compile
+	localNames := func(b *compiler.Bytecode) string {
+		for _, c := range b.Constants {
+			if fn, ok := c.(*object.CompiledFunction); ok {
+				return strings.Join(fn.LocalNames, ",")
+			}
+		}
+		return ""
+	}
+	if first, second := localNames(compile()), localNames(compile()); first != second {
+		t.Errorf("local names differ between compiles. got=%q and %q", first, second)
+	}
+}
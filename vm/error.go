@@ -0,0 +1,127 @@
+package vm
+
+import (
+	"bytes"
+	"errors"
+
+	"github.com/dreblang/core/object"
+	"github.com/dreblang/core/token"
+)
+
+// RuntimeError is returned by Run when the execution of the bytecode fails.
+// Trace holds the active frames, innermost first. The underlying *object.Error
+// can be retrieved with errors.As.
+type RuntimeError struct {
+	Kind    object.ErrorKind
+	Message string
+	Pos     token.Position
+	Trace   []object.StackFrame
+
+	Err error
+}
+
+func (e *RuntimeError) Error() string { return e.Message }
+func (e *RuntimeError) Unwrap() error { return e.Err }
+
+func (e *RuntimeError) StackTrace() string {
+	var out bytes.Buffer
+
+	for _, sf := range e.Trace {
+		out.WriteString("\tat " + sf.String() + "\n")
+	}
+
+	return out.String()
+}
+
+func newError(kind object.ErrorKind, format string, a ...interface{}) error {
+	return object.NewErrorWithKind(kind, format, a...)
+}
+
+func newRuntimeError(err *object.Error) *RuntimeError {
+	rerr := &RuntimeError{
+		Kind:    err.Kind,
+		Message: err.Message,
+		Trace:   err.Trace,
+		Err:     err,
+	}
+
+	if rerr.Kind == "" {
+		rerr.Kind = object.GenericError
+	}
+
+	if len(err.Trace) > 0 {
+		rerr.Pos = err.Trace[0].Pos
+	}
+	return rerr
+}
+
+// raise turns err into an error object and transfers control to the innermost
+// handler above depth protecting the failing instruction, unwinding frames as
+// needed. If no handler is found, or the error is for a limit of the run, the
+// error is returned as a *RuntimeError.
+func (vm *VM) raise(err error, depth int) error {
+	errObj := raisedError(err, vm.stackTrace)
+	if fatal(errObj) {
+		return newRuntimeError(errObj)
+	}
+
+	for i := vm.framesIndex - 1; i >= depth; i-- {
+		frame := vm.frames[i]
+
+		handler, ok := frame.handler()
+		if !ok {
+			continue
+		}
+
+		vm.framesIndex = i + 1
+		vm.curFrame = frame
+		vm.sp = frame.trySP[handler.Slot]
+		frame.ip = handler.Target - 1
+
+		return vm.push(errObj)
+	}
+
+	return newRuntimeError(errObj)
+}
+
+// raisedError turns err into the error object raised for it, recording the
+// stack trace unless it already has one.
+func raisedError(err error, stackTrace func() []object.StackFrame) *object.Error {
+	var errObj *object.Error
+	if !errors.As(err, &errObj) {
+		errObj = object.NewErrorWithKind(object.GenericError, "%s", err)
+	}
+
+	if errObj.Trace == nil {
+		// Errors may be shared (e.g. stack overflow), never record on them
+		raised := *errObj
+		raised.Trace = stackTrace()
+		errObj = &raised
+	}
+	return errObj
+}
+
+// thrownError wraps a value thrown by a script in an error object
+func thrownError(value object.Object) *object.Error {
+	if errObj, ok := value.(*object.Error); ok {
+		return errObj
+	}
+
+	errObj := object.NewErrorWithKind(object.GenericError, "%s", value.Inspect())
+	errObj.Value = value
+	return errObj
+}
+
+func (vm *VM) stackTrace() []object.StackFrame {
+	trace := make([]object.StackFrame, 0, vm.framesIndex)
+
+	for i := vm.framesIndex - 1; i >= 0; i-- {
+		frame := vm.frames[i]
+		trace = append(trace, object.StackFrame{
+			Function: frame.cl.Fn.Name,
+			Pos:      frame.Pos(),
+		})
+	}
+
+	return trace
+}
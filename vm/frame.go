@@ -3,6 +3,7 @@ package vm
 import (
 	"github.com/dreblang/core/code"
 	"github.com/dreblang/core/object"
+	"github.com/dreblang/core/token"
 )
 
 type Frame struct {
@@ -11,6 +12,9 @@ type Frame struct {
 	basePointer int
 
 	instructions code.Instructions
+
+	// Stack pointer recorded by each OpTry, indexed by handler slot
+	trySP []int
 }
 
 func NewFrame(cl *object.Closure, basePointer int) *Frame {
@@ -22,3 +26,43 @@ func NewFrame(cl *object.Closure, basePointer int) *Frame {
 		instructions: cl.Fn.Instructions,
 	}
 }
+
+// Function returns the compiled function executed by the frame
+func (f *Frame) Function() *object.CompiledFunction {
+	return f.cl.Fn
+}
+
+// IP returns the offset of the instruction currently executed by the frame
+func (f *Frame) IP() int {
+	return f.ip
+}
+
+// Pos returns the source position of the instruction currently executed by the frame
+func (f *Frame) Pos() token.Position {
+	ip := f.ip
+	if ip < 0 {
+		ip = 0
+	}
+	return f.cl.Fn.Lines.Lookup(ip)
+}
+
+func (f *Frame) setTrySP(slot int, sp int) {
+	if f.trySP == nil {
+		f.trySP = make([]int, len(f.cl.Fn.Handlers))
+	}
+	f.trySP[slot] = sp
+}
+
+// handler returns the innermost handler protecting the current instruction
+func (f *Frame) handler() (code.Handler, bool) {
+	if f.trySP == nil {
+		return code.Handler{}, false
+	}
+
+	for _, h := range f.cl.Fn.Handlers {
+		if h.Start <= f.ip && f.ip < h.End {
+			return h, true
+		}
+	}
+	return code.Handler{}, false
+}
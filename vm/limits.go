@@ -0,0 +1,136 @@
+package vm
+
+import (
+	"context"
+
+	"github.com/dreblang/core/object"
+)
+
+// checkInterval is the number of instructions run between two checks of
+// the context of a run.
+const checkInterval = 1024
+
+// limits keeps the budgets of a run and the context it can be canceled
+// with. The budgets are shared by the calls made during the run.
+type limits struct {
+	ctx context.Context
+
+	maxInstructions int
+	maxAllocations  int
+	maxMemory       int
+
+	instructions int
+	allocations  int
+	memory       int
+	nextCheck    int
+}
+
+func newLimits(config Config) limits {
+	return limits{
+		maxInstructions: config.MaxInstructions,
+		maxAllocations:  config.MaxAllocations,
+		maxMemory:       config.MaxMemory,
+	}
+}
+
+// start resets the budgets for a new run.
+func (l *limits) start() {
+	l.instructions = 0
+	l.allocations = 0
+	l.memory = 0
+	l.nextCheck = 0
+}
+
+// step counts an instruction, checking the limits every checkInterval
+// instructions and once the instruction budget may be used up.
+func (l *limits) step() error {
+	l.instructions++
+	if l.instructions < l.nextCheck {
+		return nil
+	}
+	return l.check()
+}
+
+func (l *limits) check() error {
+	if l.maxInstructions > 0 && l.instructions > l.maxInstructions {
+		return newError(object.InstructionLimitError,
+			"instruction limit exceeded: more than %d instructions", l.maxInstructions)
+	}
+	if l.ctx != nil {
+		if err := l.ctx.Err(); err != nil {
+			errObj := object.NewErrorWithKind(object.CanceledError, "execution canceled: %s", err)
+			errObj.Cause = err
+			return errObj
+		}
+	}
+
+	l.nextCheck = l.instructions + checkInterval
+	if l.maxInstructions > 0 {
+		l.nextCheck = min(l.nextCheck, l.maxInstructions+1)
+	}
+	return nil
+}
+
+// allocate counts obj against the allocation budget and charges its size
+// to the memory budget if it is one of the objects a script can build up
+// without bound: strings, bytes, arrays, hashes and closures.
+func (l *limits) allocate(obj object.Object) error {
+	if l.maxAllocations == 0 && l.maxMemory == 0 {
+		return nil
+	}
+
+	size, ok := objectSize(obj)
+	if !ok {
+		return nil
+	}
+
+	l.allocations++
+	if l.maxAllocations > 0 && l.allocations > l.maxAllocations {
+		return newError(object.AllocationLimitError,
+			"allocation limit exceeded: more than %d objects", l.maxAllocations)
+	}
+	return l.charge(size)
+}
+
+// charge charges size bytes to the memory budget.
+func (l *limits) charge(size int) error {
+	if l.maxMemory == 0 {
+		return nil
+	}
+
+	l.memory += size
+	if l.memory > l.maxMemory {
+		return newError(object.AllocationLimitError,
+			"memory limit exceeded: more than %d bytes", l.maxMemory)
+	}
+	return nil
+}
+
+// objectSize estimates the bytes obj takes up, not counting the objects it
+// refers to, which are charged when they are created. It reports false for
+// objects that aren't charged.
+func objectSize(obj object.Object) (int, bool) {
+	switch obj := obj.(type) {
+	case *object.String:
+		return 16 + len(obj.Value), true
+	case *object.Bytes:
+		return 24 + len(obj.Value), true
+	case *object.Array:
+		return 24 + 16*len(obj.Elements), true
+	case *object.Hash:
+		return 48 + 64*len(obj.Pairs), true
+	case *object.Closure:
+		return 32 + 16*len(obj.Free), true
+	}
+	return 0, false
+}
+
+// fatal reports whether errObj is raised for a limit of the run, which no
+// handler in the script may catch.
+func fatal(errObj *object.Error) bool {
+	switch errObj.Kind {
+	case object.CanceledError, object.InstructionLimitError, object.AllocationLimitError:
+		return true
+	}
+	return false
+}
@@ -0,0 +1,256 @@
+package vm
+
+import (
+	"context"
+	"errors"
+	"io"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/dreblang/core/code"
+	"github.com/dreblang/core/compiler"
+	"github.com/dreblang/core/object"
+)
+
+func TestRunLimits(t *testing.T) {
+	canceled, cancel := context.WithCancel(context.Background())
+	cancel()
+
+	tests := []struct {
+		input    string
+		ctx      context.Context
+		config   Config
+		kind     object.ErrorKind
+		expected string
+	}{
+		{
+			`loop (true) {}`,
+			canceled,
+			Config{},
+			object.CanceledError,
+			"execution canceled: context canceled",
+		},
+		{
+			`let i = 0; loop (true) { i = i + 1 }`,
+			context.Background(),
+			Config{MaxInstructions: 1000},
+			object.InstructionLimitError,
+			"instruction limit exceeded: more than 1000 instructions",
+		},
+		{
+			// Scripts can't catch their way out of a limit
+			`let f = fn() { loop (true) {} }; loop (true) { try { f() } catch { 1 } }`,
+			context.Background(),
+			Config{MaxInstructions: 1000},
+			object.InstructionLimitError,
+			"instruction limit exceeded: more than 1000 instructions",
+		},
+		{
+			// Tail calls don't grow the frame stack, only the instruction count
+			`let a = fn() { a() }; a()`,
+			context.Background(),
+			Config{MaxInstructions: 1000},
+			object.InstructionLimitError,
+			"instruction limit exceeded: more than 1000 instructions",
+		},
+		{
+			`let a = []; loop (true) { a = a + [1] }`,
+			context.Background(),
+			Config{MaxAllocations: 100},
+			object.AllocationLimitError,
+			"allocation limit exceeded: more than 100 objects",
+		},
+		{
+			`let s = ""; loop (true) { s = s + "a" }`,
+			context.Background(),
+			Config{MaxAllocations: 100},
+			object.AllocationLimitError,
+			"allocation limit exceeded: more than 100 objects",
+		},
+	}
+
+	for _, tt := range tests {
+		for name, err := range runWithContext(t, tt.ctx, tt.input, tt.config) {
+			rerr, ok := err.(*RuntimeError)
+			if !ok {
+				t.Fatalf("expected RuntimeError (%s). got=%T (%+v)", name, err, err)
+			}
+			if rerr.Kind != tt.kind {
+				t.Errorf("wrong error kind (%s). want=%q, got=%q", name, tt.kind, rerr.Kind)
+			}
+			if rerr.Message != tt.expected {
+				t.Errorf("wrong error message (%s). want=%q, got=%q", name, tt.expected, rerr.Message)
+			}
+		}
+	}
+}
+
+func TestHostileInputs(t *testing.T) {
+	sandbox := SandboxConfig(io.Discard)
+	small := SandboxConfig(io.Discard)
+	small.MaxMemory = 1 << 20
+
+	tests := []struct {
+		input    string
+		config   Config
+		kind     object.ErrorKind
+		expected string
+	}{
+		{
+			`let s = "ab"; loop (true) { s = s + s }`,
+			sandbox,
+			object.AllocationLimitError,
+			"memory limit exceeded: more than 67108864 bytes",
+		},
+		{
+			// A replace whose result would take 4GB fails before building it
+			`let s = "a"; let i = 0; loop (i < 16) { s = s + s; i = i + 1 }; s.replace("a", s)`,
+			small,
+			object.AllocationLimitError,
+			"memory limit exceeded: more than 1048576 bytes",
+		},
+		{
+			`let s = "a"; let i = 0; loop (i < 16) { s = s + s; i = i + 1 }; s.split("")`,
+			small,
+			object.AllocationLimitError,
+			"memory limit exceeded: more than 1048576 bytes",
+		},
+		{`[1, 2, 3][0, 3, 0]`, sandbox, object.IndexError, "slice step must be positive, got 0"},
+		{`1 / 0`, sandbox, object.ValueError, "division by zero"},
+		{`1 % 0`, sandbox, object.ValueError, "division by zero"},
+		{`[1, 2, 3][-10, 2]`, sandbox, object.IndexError, "slice bounds out of range with length 3"},
+		{`[1, 2, 3][0, "x"]`, sandbox, object.TypeError, "slice bound must be Integer, got String"},
+		{`[1, 2, 3][0, 10]`, sandbox, object.IndexError, "slice bounds out of range with length 3"},
+		{`"ab".sub(5, 1)`, sandbox, object.IndexError, "substring bounds out of range [5:1] with length 2"},
+		{`"ab".sub(-1)`, sandbox, object.IndexError, "substring bounds out of range [-1:2] with length 2"},
+		{`"a b".split(1)`, sandbox, object.TypeError, "split separator must be String, got Integer"},
+		{`"ab".starts_with(1)`, sandbox, object.TypeError, "argument must be String, got Integer"},
+		{`"ab".ends_with([])`, sandbox, object.TypeError, "argument must be String, got Array"},
+	}
+
+	for _, tt := range tests {
+		for name, err := range runWithConfig(t, tt.input, tt.config) {
+			rerr, ok := err.(*RuntimeError)
+			if !ok {
+				t.Fatalf("expected RuntimeError for %q (%s). got=%T (%+v)", tt.input, name, err, err)
+			}
+			if rerr.Kind != tt.kind || rerr.Message != tt.expected {
+				t.Errorf("wrong error for %q (%s). want=%s: %s, got=%s: %s",
+					tt.input, name, tt.kind, tt.expected, rerr.Kind, rerr.Message)
+			}
+		}
+	}
+}
+
+func TestHostileBytecode(t *testing.T) {
+	compiler.RegisterLib("hostilecore", func() *object.Scope {
+		return &object.Scope{Exports: map[string]object.Object{}}
+	})
+
+	comp := compiler.New()
+	if err := comp.Compile(parse(`load hostilecore; 1`)); err != nil {
+		t.Fatalf("compiler error: %s", err)
+	}
+	data, err := comp.Bytecode().MarshalBinary()
+	if err != nil {
+		t.Fatalf("marshal error: %s", err)
+	}
+
+	// Bytecode compiled outside a sandbox can't load modules into one
+	_, err = compiler.UnmarshalWithSandbox(data, compiler.Sandbox{})
+	if err == nil || !strings.HasSuffix(err.Error(), "module hostilecore is not allowed") {
+		t.Errorf("expected sandbox error. got=%v", err)
+	}
+
+	bytecode, err := compiler.UnmarshalWithSandbox(data, compiler.Sandbox{Modules: []string{"hostilecore"}})
+	if err != nil {
+		t.Fatalf("unmarshal error: %s", err)
+	}
+	if err := NewWithConfig(bytecode, SandboxConfig(io.Discard)).Run(); err != nil {
+		t.Errorf("vm error: %s", err)
+	}
+
+	// Verified bytecode may still export under a name that isn't a string
+	var ins code.Instructions
+	ins = append(ins, code.Make(code.OpNull)...)
+	ins = append(ins, code.Make(code.OpNull)...)
+	ins = append(ins, code.Make(code.OpExport)...)
+	export := &compiler.Bytecode{Instructions: ins}
+	data, err = export.MarshalBinary()
+	if err != nil {
+		t.Fatalf("marshal error: %s", err)
+	}
+	bytecode, err = compiler.UnmarshalWithSandbox(data, compiler.Sandbox{})
+	if err != nil {
+		t.Fatalf("unmarshal error: %s", err)
+	}
+	err = NewWithConfig(bytecode, SandboxConfig(io.Discard)).Run()
+	if rerr, ok := err.(*RuntimeError); !ok || rerr.Kind != object.TypeError {
+		t.Errorf("expected TypeError. got=%T (%+v)", err, err)
+	}
+}
+
+func TestRunWithinLimits(t *testing.T) {
+	input := `let f = fn(x) { [x, x + 1] }; let i = 0; loop (i < 10) { f(i); i = i + 1 }`
+	config := Config{MaxInstructions: 1000, MaxAllocations: 100}
+
+	for name, err := range runWithContext(t, context.Background(), input, config) {
+		if err != nil {
+			t.Errorf("vm error (%s): %s", name, err)
+		}
+	}
+}
+
+func TestRunContextDeadline(t *testing.T) {
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Millisecond)
+	defer cancel()
+
+	for _, input := range []string{`loop (true) {}`, `let a = fn() { a() }; a()`} {
+		for name, err := range runWithContext(t, ctx, input, Config{}) {
+			if !errors.Is(err, context.DeadlineExceeded) {
+				t.Errorf("expected deadline exceeded for %q (%s). got=%T (%+v)", input, name, err, err)
+			}
+		}
+	}
+}
+
+func TestLimitsInCallbacks(t *testing.T) {
+	// apply is a builtin calling back into the VM
+	symbols := compiler.NewSymbolTable()
+	symbols.Define("apply")
+	apply := &object.Builtin{Fn: func(vm object.VM, args ...object.Object) object.Object {
+		result, err := vm.Call(args[0])
+		if err != nil {
+			return object.AsError(err)
+		}
+		return result
+	}}
+
+	comp := compiler.NewWithState(symbols, []object.Object{})
+	input := `let spin = fn() { loop (true) {} }; try { apply(spin) } catch { 1 }`
+	if err := comp.Compile(parse(input)); err != nil {
+		t.Fatalf("compiler error: %s", err)
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Millisecond)
+	defer cancel()
+
+	vm := NewWithGlobalsStore(comp.Bytecode(), []object.Object{apply})
+	err := vm.RunContext(ctx)
+	if !errors.Is(err, context.DeadlineExceeded) {
+		t.Fatalf("expected deadline exceeded. got=%T (%+v)", err, err)
+	}
+
+	config := Config{MaxInstructions: 1000}
+	vm = NewWithConfig(comp.Bytecode(), config)
+	vm.globals[0] = apply
+	err = vm.Run()
+	rerr, ok := err.(*RuntimeError)
+	if !ok {
+		t.Fatalf("expected RuntimeError. got=%T (%+v)", err, err)
+	}
+	if rerr.Kind != object.InstructionLimitError {
+		t.Errorf("wrong error kind. want=%q, got=%q", object.InstructionLimitError, rerr.Kind)
+	}
+}
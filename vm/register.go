@@ -0,0 +1,543 @@
+package vm
+
+import (
+	"context"
+	"io"
+
+	"github.com/dreblang/core/code"
+	"github.com/dreblang/core/compiler"
+	"github.com/dreblang/core/object"
+)
+
+// RegisterVM runs bytecode compiled by compiler.RegisterCompiler. It is an
+// experimental alternative to VM: instructions name the registers holding
+// their operands and result, so locals are used in place instead of being
+// pushed and popped around every operation.
+//
+// The registers of all frames share one register file, which grows like the
+// stack of VM does, and a callee's registers start right after the register
+// holding it in its caller, where the arguments already are.
+type RegisterVM struct {
+	constants   []object.Object
+	globals     []object.Object
+	registers   []object.Object
+	frames      []registerFrame
+	framesIndex int
+
+	// Value of the last top level expression statement
+	result object.Object
+
+	config Config
+	limits limits
+}
+
+type registerFrame struct {
+	cl   *object.Closure
+	ip   int
+	base int
+}
+
+// registerWidths holds the width of every instruction, operands included.
+var registerWidths [256]int
+
+func init() {
+	for op := 0; op < len(registerWidths); op++ {
+		if def, err := code.Lookup(byte(op)); err == nil {
+			registerWidths[op] = 1
+			for _, w := range def.OperandWidths {
+				registerWidths[op] += w
+			}
+		}
+	}
+}
+
+func NewRegister(bytecode *compiler.Bytecode) *RegisterVM {
+	return NewRegisterWithConfig(bytecode, DefaultConfig())
+}
+
+// NewRegisterWithConfig returns a RegisterVM whose registers, frames and
+// globals start at and grow up to the sizes in config. The stack sizes
+// apply to the register file.
+func NewRegisterWithConfig(bytecode *compiler.Bytecode, config Config) *RegisterVM {
+	config = config.withDefaults()
+
+	mainFn := &object.CompiledFunction{
+		Instructions: bytecode.Instructions,
+		NumLocals:    bytecode.Registers,
+		Name:         MainFunctionName,
+		Lines:        bytecode.Lines,
+		Handlers:     bytecode.Handlers,
+	}
+	mainClosure := &object.Closure{Fn: mainFn, Exports: map[string]object.Object{}}
+
+	frames := make([]registerFrame, config.InitialFrames)
+	frames[0] = registerFrame{cl: mainClosure}
+
+	return &RegisterVM{
+		constants:   bytecode.Constants,
+		globals:     make([]object.Object, initialGlobals(bytecode, config)),
+		registers:   make([]object.Object, config.InitialStack),
+		frames:      frames,
+		framesIndex: 1,
+		config:      config,
+		limits:      newLimits(config),
+	}
+}
+
+func NewRegisterWithGlobalsStore(bytecode *compiler.Bytecode, s []object.Object) *RegisterVM {
+	vm := NewRegister(bytecode)
+	vm.globals = s
+	return vm
+}
+
+// GlobalsStore returns the globals of the VM, like VM.GlobalsStore.
+func (vm *RegisterVM) GlobalsStore() []object.Object {
+	return vm.globals
+}
+
+// Result returns the value of the last expression statement run at the top
+// level, like LastPoppedStackElem does for VM.
+func (vm *RegisterVM) Result() object.Object {
+	return vm.result
+}
+
+func (vm *RegisterVM) Run() error {
+	vm.limits.start()
+	if err := vm.growRegisters(vm.frames[0].cl.Fn.NumLocals); err != nil {
+		return vm.raise(err, 0)
+	}
+
+	_, err := vm.run(0)
+	return err
+}
+
+// RunContext is like Run, but stops once ctx is done, like VM.RunContext.
+func (vm *RegisterVM) RunContext(ctx context.Context) error {
+	prev := vm.limits.ctx
+	vm.limits.ctx = ctx
+	defer func() { vm.limits.ctx = prev }()
+
+	return vm.Run()
+}
+
+// run executes instructions until the main frame ends or, when called for a
+// nested call, until the frame above depth returns, whose result it returns.
+// Errors are only handled by the frames above depth.
+func (vm *RegisterVM) run(depth int) (object.Object, error) {
+	frame := &vm.frames[vm.framesIndex-1]
+	ins := frame.cl.Fn.Instructions
+	regs := vm.registers[frame.base:]
+
+	for frame.ip < len(ins) {
+		ip := frame.ip
+		op := code.Opcode(ins[ip])
+		next := ip + registerWidths[op]
+
+		if err := vm.limits.step(); err != nil {
+			return nil, vm.raise(err, depth)
+		}
+
+		var result object.Object
+		var err error
+
+		switch op {
+		case code.OpRegLoadConst:
+			regs[operand(ins, ip+1)] = vm.constants[operand(ins, ip+3)]
+		case code.OpRegLoadTrue:
+			regs[operand(ins, ip+1)] = True
+		case code.OpRegLoadFalse:
+			regs[operand(ins, ip+1)] = False
+		case code.OpRegLoadNull:
+			regs[operand(ins, ip+1)] = Null
+		case code.OpRegMove:
+			regs[operand(ins, ip+1)] = regs[operand(ins, ip+3)]
+		case code.OpRegGetGlobal:
+			i := operand(ins, ip+3)
+			if err = vm.growGlobals(i); err == nil {
+				regs[operand(ins, ip+1)] = vm.globals[i]
+			}
+		case code.OpRegSetGlobal:
+			i := operand(ins, ip+1)
+			if err = vm.growGlobals(i); err == nil {
+				vm.globals[i] = regs[operand(ins, ip+3)]
+			}
+		case code.OpRegGetFree:
+			regs[operand(ins, ip+1)] = frame.cl.Free[ins[ip+3]]
+		case code.OpRegSetFree:
+			frame.cl.Free[ins[ip+1]] = regs[operand(ins, ip+2)]
+		case code.OpRegGetBuiltin:
+			regs[operand(ins, ip+1)] = object.Builtins[ins[ip+3]].Builtin
+
+		case code.OpRegAdd, code.OpRegSub, code.OpRegMul, code.OpRegDiv, code.OpRegMod:
+			result, err = vm.binaryOperation(registerOperator(op), regs[operand(ins, ip+3)], regs[operand(ins, ip+5)])
+			if err == nil {
+				regs[operand(ins, ip+1)] = result
+			}
+		case code.OpRegAddConst:
+			result, err = vm.binaryOperation("+", regs[operand(ins, ip+3)], vm.constants[operand(ins, ip+5)])
+			if err == nil {
+				regs[operand(ins, ip+1)] = result
+			}
+		case code.OpRegEqual, code.OpRegNotEqual, code.OpRegGreaterThan, code.OpRegGreaterOrEqual, code.OpRegLessThan, code.OpRegLessOrEqual:
+			result, err = comparison(registerOperator(op), regs[operand(ins, ip+3)], regs[operand(ins, ip+5)])
+			if err == nil {
+				regs[operand(ins, ip+1)] = result
+			}
+		case code.OpRegMinus:
+			result, err = negate(regs[operand(ins, ip+3)])
+			if err == nil {
+				regs[operand(ins, ip+1)] = result
+			}
+		case code.OpRegBang:
+			regs[operand(ins, ip+1)] = bang(regs[operand(ins, ip+3)])
+
+		case code.OpRegJump:
+			next = operand(ins, ip+1)
+		case code.OpRegJumpNotTruthy:
+			if !isTruthy(regs[operand(ins, ip+3)]) {
+				next = operand(ins, ip+1)
+			}
+		case code.OpRegJumpIfGreaterOrEqual, code.OpRegJumpIfGreaterThan, code.OpRegJumpIfLessOrEqual, code.OpRegJumpIfLessThan:
+			var holds bool
+			holds, err = fusedHolds(fusedComparison(op), regs[operand(ins, ip+3)], regs[operand(ins, ip+5)])
+			if err == nil && !holds {
+				next = operand(ins, ip+1)
+			}
+
+		case code.OpRegArray:
+			b := operand(ins, ip+3)
+			result, err = vm.allocated(newArray(regs[b:b+operand(ins, ip+5)]), nil)
+			if err == nil {
+				regs[operand(ins, ip+1)] = result
+			}
+		case code.OpRegHash:
+			b := operand(ins, ip+3)
+			result, err = vm.allocated(newHash(regs[b : b+operand(ins, ip+5)]))
+			if err == nil {
+				regs[operand(ins, ip+1)] = result
+			}
+		case code.OpRegIndex:
+			result, err = indexSimple(regs[operand(ins, ip+3)], regs[operand(ins, ip+5)])
+			if err == nil {
+				regs[operand(ins, ip+1)] = result
+			}
+		case code.OpRegIndexRange:
+			i := operand(ins, ip+5)
+			result, err = indexExpression(regs[operand(ins, ip+3)], regs[i], regs[i+1], regs[i+3], regs[i+2], regs[i+4])
+			if err == nil {
+				regs[operand(ins, ip+1)] = result
+			}
+		case code.OpRegIndexSet:
+			i := operand(ins, ip+3)
+			err = indexSet(regs[operand(ins, ip+1)], regs[i], regs[i+1], regs[i+3], regs[i+2], regs[i+4], regs[operand(ins, ip+5)])
+		case code.OpRegMember, code.OpRegScopeResolve:
+			regs[operand(ins, ip+1)] = regs[operand(ins, ip+3)].GetMember(regs[operand(ins, ip+5)].String())
+		case code.OpRegMemberSet:
+			result, err = memberSet(regs[operand(ins, ip+3)], regs[operand(ins, ip+5)], regs[operand(ins, ip+7)])
+			if err == nil {
+				regs[operand(ins, ip+1)] = result
+			}
+
+		case code.OpRegCall, code.OpRegTailCall:
+			callee := frame.base + operand(ins, ip+3)
+			if op == code.OpRegTailCall {
+				if cl, ok := vm.registers[callee].(*object.Closure); ok {
+					err = vm.tailCall(cl, callee, int(ins[ip+5]))
+					if err == nil {
+						ins = cl.Fn.Instructions
+						regs = vm.registers[frame.base:]
+						continue
+					}
+					break
+				}
+			}
+
+			result, err = vm.call(callee, int(ins[ip+5]))
+			if err == nil && result == nil {
+				// Run the callee, the caller continues past the call on
+				// return
+				frame = &vm.frames[vm.framesIndex-1]
+				ins = frame.cl.Fn.Instructions
+				regs = vm.registers[frame.base:]
+				continue
+			}
+			if err == nil {
+				// A native calling back into the VM may have grown the
+				// registers and frames
+				frame = &vm.frames[vm.framesIndex-1]
+				regs = vm.registers[frame.base:]
+				regs[operand(ins, ip+1)] = result
+			}
+		case code.OpRegReturn, code.OpRegReturnNull:
+			var value object.Object = Null
+			if op == code.OpRegReturn {
+				value = regs[operand(ins, ip+1)]
+			}
+			if vm.framesIndex == 1 {
+				return value, nil
+			}
+
+			vm.framesIndex--
+			if vm.framesIndex == depth {
+				return value, nil
+			}
+
+			frame = &vm.frames[vm.framesIndex-1]
+			ins = frame.cl.Fn.Instructions
+			regs = vm.registers[frame.base:]
+
+			regs[operand(ins, frame.ip+1)] = value
+			frame.ip += registerWidths[code.OpRegCall]
+			continue
+		case code.OpRegClosure:
+			fn := vm.constants[operand(ins, ip+3)].(*object.CompiledFunction)
+			b := operand(ins, ip+5)
+			free := make([]object.Object, ins[ip+7])
+			copy(free, regs[b:])
+			result, err = vm.allocated(&object.Closure{Fn: fn, Free: free, Exports: map[string]object.Object{}}, nil)
+			if err == nil {
+				regs[operand(ins, ip+1)] = result
+			}
+		case code.OpRegScope:
+			regs[operand(ins, ip+1)] = &object.Scope{Exports: frame.cl.Exports}
+		case code.OpRegExport:
+			name := vm.constants[operand(ins, ip+1)].(*object.String)
+			frame.cl.Exports[name.Value] = regs[operand(ins, ip+3)]
+		case code.OpRegThrow:
+			err = thrownError(regs[operand(ins, ip+1)])
+		case code.OpRegResult:
+			vm.result = regs[operand(ins, ip+1)]
+		}
+
+		if err != nil {
+			err = vm.raise(err, depth)
+			if err != nil {
+				return nil, err
+			}
+			frame = &vm.frames[vm.framesIndex-1]
+			ins = frame.cl.Fn.Instructions
+			regs = vm.registers[frame.base:]
+			continue
+		}
+		frame.ip = next
+	}
+
+	return nil, nil
+}
+
+// operand reads the two byte operand at offset at of ins.
+func operand(ins code.Instructions, at int) int {
+	return int(ins[at])<<8 | int(ins[at+1])
+}
+
+// registerOperator returns the operator of an arithmetic or comparison
+// instruction.
+func registerOperator(op code.Opcode) string {
+	switch op {
+	case code.OpRegAdd:
+		return "+"
+	case code.OpRegSub:
+		return "-"
+	case code.OpRegMul:
+		return "*"
+	case code.OpRegDiv:
+		return "/"
+	case code.OpRegMod:
+		return "%"
+	case code.OpRegEqual:
+		return "=="
+	case code.OpRegNotEqual:
+		return "!="
+	case code.OpRegGreaterThan:
+		return ">"
+	case code.OpRegGreaterOrEqual:
+		return ">="
+	case code.OpRegLessThan:
+		return "<"
+	default:
+		return "<="
+	}
+}
+
+// call calls the value in register callee with the numArgs registers
+// following it. A closure gets a new frame and a nil result, anything else
+// returns its result right away.
+func (vm *RegisterVM) call(callee, numArgs int) (object.Object, error) {
+	args := vm.registers[callee+1 : callee+1+numArgs]
+
+	switch fn := vm.registers[callee].(type) {
+	case *object.Closure:
+		if numArgs != fn.Fn.NumParameters {
+			return nil, newError(object.ArityError, "wrong number of arguments: want=%d, got=%d",
+				fn.Fn.NumParameters, numArgs)
+		}
+		frames, ok := grow(vm.frames, vm.framesIndex+1, vm.config.MaxFrames)
+		if !ok {
+			return nil, vm.config.frameOverflow()
+		}
+		vm.frames = frames
+		base := callee + 1
+		if err := vm.growRegisters(base + fn.Fn.NumLocals); err != nil {
+			return nil, err
+		}
+
+		vm.frames[vm.framesIndex] = registerFrame{cl: fn, base: base}
+		vm.framesIndex++
+		return nil, nil
+	case *object.Builtin:
+		return vm.allocated(nativeResult(fn.Fn(vm, args...)))
+	case *object.MemberFn:
+		return vm.allocated(nativeResult(fn.Fn(vm, fn.Obj, args...)))
+	default:
+		return nil, newError(object.TypeError, "calling non-function and non-built-in")
+	}
+}
+
+// Call calls fn with args and returns its result, like VM.Call. The callee
+// goes in the first register past the current frame.
+func (vm *RegisterVM) Call(fn object.Object, args ...object.Object) (object.Object, error) {
+	depth := vm.framesIndex
+	frame := &vm.frames[depth-1]
+	callee := frame.base + frame.cl.Fn.NumLocals
+
+	err := vm.growRegisters(callee + 1 + len(args))
+	if err == nil {
+		vm.registers[callee] = fn
+		copy(vm.registers[callee+1:], args)
+
+		var result object.Object
+		result, err = vm.call(callee, len(args))
+		if err == nil && result == nil {
+			result, err = vm.run(depth)
+		}
+		if err == nil {
+			return result, nil
+		}
+	}
+
+	if _, ok := err.(*RuntimeError); !ok {
+		err = newRuntimeError(raisedError(err, vm.stackTrace))
+	}
+	vm.framesIndex = depth
+	return nil, err
+}
+
+// CallContext is Call stopping once ctx is done, like VM.CallContext.
+func (vm *RegisterVM) CallContext(ctx context.Context, fn object.Object, args ...object.Object) (object.Object, error) {
+	prev := vm.limits.ctx
+	vm.limits.ctx = ctx
+	defer func() { vm.limits.ctx = prev }()
+
+	return vm.Call(fn, args...)
+}
+
+// Output returns the writer print writes to.
+func (vm *RegisterVM) Output() io.Writer {
+	return vm.config.Output
+}
+
+// Allocate charges size bytes to the memory budget of the run.
+func (vm *RegisterVM) Allocate(size int) error {
+	return vm.limits.charge(size)
+}
+
+// tailCall runs cl in the frame of the current function, moving the callee
+// and its arguments down to where the current function and its parameters
+// are.
+func (vm *RegisterVM) tailCall(cl *object.Closure, callee, numArgs int) error {
+	if numArgs != cl.Fn.NumParameters {
+		return newError(object.ArityError, "wrong number of arguments: want=%d, got=%d",
+			cl.Fn.NumParameters, numArgs)
+	}
+
+	frame := &vm.frames[vm.framesIndex-1]
+	if err := vm.growRegisters(frame.base + cl.Fn.NumLocals); err != nil {
+		return err
+	}
+	copy(vm.registers[frame.base-1:], vm.registers[callee:callee+1+numArgs])
+
+	frame.cl = cl
+	frame.ip = 0
+	return nil
+}
+
+// allocated passes on the result of an operation, counting it against the
+// allocation budget if it succeeded.
+func (vm *RegisterVM) allocated(result object.Object, err error) (object.Object, error) {
+	if err != nil {
+		return nil, err
+	}
+	if err := vm.limits.allocate(result); err != nil {
+		return nil, err
+	}
+	return result, nil
+}
+
+// binaryOperation applies the binary operator op to left and right.
+func (vm *RegisterVM) binaryOperation(op string, left, right object.Object) (object.Object, error) {
+	// Numbers don't count against the allocation budget
+	if result := arithmetic(op, left, right); result != nil {
+		return result, nil
+	}
+	return vm.allocated(infixOperation(op, left, right))
+}
+
+// growRegisters makes room for n registers.
+func (vm *RegisterVM) growRegisters(n int) error {
+	registers, ok := grow(vm.registers, n, vm.config.MaxStack)
+	if !ok {
+		return vm.config.stackOverflow()
+	}
+	vm.registers = registers
+	return nil
+}
+
+// growGlobals makes room for the global at index i.
+func (vm *RegisterVM) growGlobals(i int) error {
+	globals, ok := grow(vm.globals, i+1, vm.config.MaxGlobals)
+	if !ok {
+		return vm.config.globalOverflow()
+	}
+	vm.globals = globals
+	return nil
+}
+
+// raise transfers control to the innermost handler protecting the failing
+// instruction, storing the error in the register the handler names. If no
+// handler is found, or the error is for a limit of the run, the error is
+// returned as a *RuntimeError.
+func (vm *RegisterVM) raise(err error, depth int) error {
+	errObj := raisedError(err, vm.stackTrace)
+	if fatal(errObj) {
+		return newRuntimeError(errObj)
+	}
+
+	for i := vm.framesIndex - 1; i >= depth; i-- {
+		frame := &vm.frames[i]
+
+		for _, h := range frame.cl.Fn.Handlers {
+			if h.Start <= frame.ip && frame.ip < h.End {
+				vm.framesIndex = i + 1
+				vm.registers[frame.base+h.Slot] = errObj
+				frame.ip = h.Target
+				return nil
+			}
+		}
+	}
+
+	return newRuntimeError(errObj)
+}
+
+func (vm *RegisterVM) stackTrace() []object.StackFrame {
+	trace := make([]object.StackFrame, 0, vm.framesIndex)
+
+	for i := vm.framesIndex - 1; i >= 0; i-- {
+		frame := &vm.frames[i]
+		trace = append(trace, object.StackFrame{
+			Function: frame.cl.Fn.Name,
+			Pos:      frame.cl.Fn.Lines.Lookup(frame.ip),
+		})
+	}
+
+	return trace
+}
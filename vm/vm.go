@@ -1,8 +1,8 @@
 package vm
 
 import (
-	"errors"
-	"fmt"
+	"context"
+	"io"
 
 	"github.com/dreblang/core/code"
 	"github.com/dreblang/core/compiler"
@@ -13,12 +13,14 @@ const StackSize = 4096
 const GlobalSize = 65536
 const MaxFrames = 2048
 
+const MainFunctionName = "<main>"
+
 var True = object.True
 var False = object.False
 var Null = object.NullValue
 
-var stackOverflowErr = errors.New("stack overflow")
-var frameOverflowErr = errors.New("frame overflow")
+// one is the step of an index without one
+var one = &object.Integer{Value: 1}
 
 type VM struct {
 	constants   []object.Object
@@ -29,47 +31,98 @@ type VM struct {
 	framesIndex int
 
 	curFrame *Frame
-}
 
-var currentVM *VM
+	globalNames []string
+	hook        Hook
 
-func GetCurrentVM() *VM {
-	return currentVM
+	config Config
+	limits limits
 }
 
 func New(bytecode *compiler.Bytecode) *VM {
-	mainFn := &object.CompiledFunction{Instructions: bytecode.Instructions}
+	return NewWithConfig(bytecode, DefaultConfig())
+}
+
+// NewWithConfig returns a VM whose stack, frames and globals start at and
+// grow up to the sizes in config.
+func NewWithConfig(bytecode *compiler.Bytecode, config Config) *VM {
+	config = config.withDefaults()
+
+	mainFn := &object.CompiledFunction{
+		Instructions: bytecode.Instructions,
+		Name:         MainFunctionName,
+		Lines:        bytecode.Lines,
+		Handlers:     bytecode.Handlers,
+	}
 	mainClosure := &object.Closure{Fn: mainFn, Exports: map[string]object.Object{}}
 	mainFrame := NewFrame(mainClosure, 0)
 
-	frames := make([]*Frame, MaxFrames)
+	frames := make([]*Frame, config.InitialFrames)
 	frames[0] = mainFrame
 
 	return &VM{
 		constants:   bytecode.Constants,
-		stack:       make([]object.Object, StackSize),
+		stack:       make([]object.Object, config.InitialStack),
 		sp:          0,
-		globals:     make([]object.Object, GlobalSize),
+		globals:     make([]object.Object, initialGlobals(bytecode, config)),
 		frames:      frames,
 		framesIndex: 1,
 		curFrame:    mainFrame,
+		globalNames: bytecode.GlobalNames,
+		config:      config,
+		limits:      newLimits(config),
 	}
 }
 
+// initialGlobals returns the number of globals a VM starts with, enough for
+// every global the bytecode defines if the limit allows.
+func initialGlobals(bytecode *compiler.Bytecode, config Config) int {
+	return min(max(config.InitialGlobals, len(bytecode.GlobalNames)), config.MaxGlobals)
+}
+
 func NewWithGlobalsStore(bytecode *compiler.Bytecode, s []object.Object) *VM {
-	vm := New(bytecode)
+	return NewWithConfigAndGlobalsStore(bytecode, DefaultConfig(), s)
+}
+
+// NewWithConfigAndGlobalsStore returns a VM configured by config running
+// with the globals s, like NewWithGlobalsStore.
+func NewWithConfigAndGlobalsStore(bytecode *compiler.Bytecode, config Config, s []object.Object) *VM {
+	vm := NewWithConfig(bytecode, config)
 	vm.globals = s
 	return vm
 }
 
+// GlobalsStore returns the globals of the VM. The store given to
+// NewWithGlobalsStore is replaced by a larger copy if the globals have to
+// grow, so callers sharing globals between runs should take them from here.
+func (vm *VM) GlobalsStore() []object.Object {
+	return vm.globals
+}
+
 func (vm *VM) Run() error {
+	vm.limits.start()
+	return vm.run(0)
+}
+
+// RunContext is like Run, but stops with a CanceledError once ctx is done.
+// Calls made during the run, like those of builtins through Call, stop too.
+func (vm *VM) RunContext(ctx context.Context) error {
+	prev := vm.limits.ctx
+	vm.limits.ctx = ctx
+	defer func() { vm.limits.ctx = prev }()
+
+	return vm.Run()
+}
+
+// run executes instructions until the main frame ends or, when called for a
+// nested call, until the frames above depth have returned. Errors are only
+// handled by the frames above depth.
+func (vm *VM) run(depth int) error {
 	var ip int
 	var ins code.Instructions
 	var op code.Opcode
 
-	currentVM = vm
-
-	for vm.curFrame.ip < len(vm.curFrame.instructions)-1 {
+	for vm.framesIndex > depth && vm.curFrame.ip < len(vm.curFrame.instructions)-1 {
 		var err error
 		vm.curFrame.ip++
 
@@ -77,6 +130,16 @@ func (vm *VM) Run() error {
 		ins = vm.curFrame.instructions
 		op = code.Opcode(ins[ip])
 
+		if err := vm.limits.step(); err != nil {
+			return vm.raise(err, depth)
+		}
+
+		if vm.hook != nil {
+			if err := vm.hook.BeforeInstruction(vm, vm.curFrame); err != nil {
+				return err
+			}
+		}
+
 		switch op {
 		case code.OpConstant:
 			constIndex := code.ReadUint16(ins[ip+1:])
@@ -112,6 +175,10 @@ func (vm *VM) Run() error {
 			err = vm.executeComparison(">")
 		case code.OpGreaterOrEqual:
 			err = vm.executeComparison(">=")
+		case code.OpLessThan:
+			err = vm.executeComparison("<")
+		case code.OpLessOrEqual:
+			err = vm.executeComparison("<=")
 		case code.OpBang:
 			err = vm.executeBangOperator()
 		case code.OpMinus:
@@ -127,6 +194,15 @@ func (vm *VM) Run() error {
 		case code.OpJump:
 			pos := int(code.ReadUint16(ins[ip+1:]))
 			vm.curFrame.ip = pos - 1
+		case code.OpJumpIfGreaterOrEqual, code.OpJumpIfGreaterThan, code.OpJumpIfLessOrEqual, code.OpJumpIfLessThan:
+			pos := int(code.ReadUint16(ins[ip+1:]))
+			vm.curFrame.ip += 2
+
+			var holds bool
+			holds, err = vm.executeFusedComparison(op)
+			if err == nil && !holds {
+				vm.curFrame.ip = pos - 1
+			}
 		case code.OpNull:
 			err = vm.push(Null)
 		case code.OpSetGlobal:
@@ -134,13 +210,19 @@ func (vm *VM) Run() error {
 			vm.curFrame.ip += 2
 
 			val := vm.pop()
-			vm.globals[globalIndex] = val
-			err = vm.push(val)
+			err = vm.growGlobals(int(globalIndex))
+			if err == nil {
+				vm.globals[globalIndex] = val
+				err = vm.push(val)
+			}
 		case code.OpGetGlobal:
 			globalIndex := code.ReadUint16(ins[ip+1:])
 			vm.curFrame.ip += 2
 
-			err = vm.push(vm.globals[globalIndex])
+			err = vm.growGlobals(int(globalIndex))
+			if err == nil {
+				err = vm.push(vm.globals[globalIndex])
+			}
 		case code.OpArray:
 			numElements := int(code.ReadUint16(ins[ip+1:]))
 			vm.curFrame.ip += 2
@@ -148,18 +230,17 @@ func (vm *VM) Run() error {
 			array := vm.buildArray(vm.sp-numElements, vm.sp)
 			vm.sp = vm.sp - numElements
 
-			err = vm.push(array)
+			err = vm.pushAllocated(array)
 		case code.OpHash:
 			numElements := int(code.ReadUint16(ins[ip+1:]))
 			vm.curFrame.ip += 2
 
-			hash, err := vm.buildHash(vm.sp-numElements, vm.sp)
-			if err != nil {
-				return err
+			var hash object.Object
+			hash, err = vm.buildHash(vm.sp-numElements, vm.sp)
+			if err == nil {
+				vm.sp = vm.sp - numElements
+				err = vm.pushAllocated(hash)
 			}
-			vm.sp = vm.sp - numElements
-
-			err = vm.push(hash)
 		case code.OpIndex:
 			hasSkip := vm.pop()
 			indexSkip := vm.pop()
@@ -169,6 +250,11 @@ func (vm *VM) Run() error {
 			left := vm.pop()
 			err = vm.executeIndexExpression(left, index, indexUpper, indexSkip, hasUpper, hasSkip)
 
+		case code.OpIndexSimple:
+			index := vm.pop()
+			left := vm.pop()
+			err = vm.executeIndexSimple(left, index)
+
 		case code.OpIndexSet:
 			hasSkip := vm.pop()
 			indexSkip := vm.pop()
@@ -178,12 +264,30 @@ func (vm *VM) Run() error {
 			left := vm.pop()
 			right := vm.pop()
 			err = vm.executeIndexSetExpression(left, index, indexUpper, indexSkip, hasUpper, hasSkip, right)
+			if err == nil {
+				err = vm.push(right)
+			}
 
-		case code.OpCall:
+		case code.OpCall, code.OpTailCall:
 			numArgs := code.ReadUint8(ins[ip+1:])
 			vm.curFrame.ip++
 
+			// With a hook installed every call keeps its frame, so the
+			// debugger sees the whole call stack
+			if op == code.OpTailCall && vm.hook == nil {
+				if cl, ok := vm.stack[vm.sp-1-int(numArgs)].(*object.Closure); ok {
+					err = vm.tailCallClosure(cl, int(numArgs))
+					break
+				}
+			}
+
+			depth := vm.framesIndex
 			err = vm.executeCall(int(numArgs))
+			if err == nil && vm.hook != nil && vm.framesIndex > depth {
+				if err := vm.hook.OnCall(vm, vm.curFrame); err != nil {
+					return err
+				}
+			}
 		case code.OpReturnValue:
 			returnValue := vm.pop()
 
@@ -191,11 +295,21 @@ func (vm *VM) Run() error {
 			vm.sp = frame.basePointer - 1
 
 			err = vm.push(returnValue)
+			if err == nil && vm.hook != nil {
+				if err := vm.hook.OnReturn(vm, frame, returnValue); err != nil {
+					return err
+				}
+			}
 		case code.OpReturn:
 			frame := vm.popFrame()
 			vm.sp = frame.basePointer - 1
 
 			err = vm.push(Null)
+			if err == nil && vm.hook != nil {
+				if err := vm.hook.OnReturn(vm, frame, Null); err != nil {
+					return err
+				}
+			}
 		case code.OpSetLocal:
 			localIndex := code.ReadUint8(ins[ip+1:])
 			vm.curFrame.ip++
@@ -211,6 +325,13 @@ func (vm *VM) Run() error {
 			frame := vm.curFrame
 
 			err = vm.push(vm.stack[frame.basePointer+int(localIndex)])
+		case code.OpAddLocalConst:
+			localIndex := code.ReadUint8(ins[ip+1:])
+			constIndex := code.ReadUint16(ins[ip+2:])
+			vm.curFrame.ip += 3
+
+			left := vm.stack[vm.curFrame.basePointer+int(localIndex)]
+			err = vm.binaryOperation("+", left, vm.constants[constIndex])
 		case code.OpGetBuiltin:
 			builtinIndex := code.ReadUint8(ins[ip+1:])
 			vm.curFrame.ip++
@@ -245,35 +366,103 @@ func (vm *VM) Run() error {
 			scopeObj := &object.Scope{
 				Exports: vm.curFrame.cl.Exports,
 			}
-			vm.push(scopeObj)
+			err = vm.push(scopeObj)
 
 		case code.OpScopeResolve:
 			name := vm.pop()
 			scope := vm.pop()
 			callable := scope.GetMember(name.String())
-			vm.push(callable)
+			err = vm.push(callable)
 
 		case code.OpExport:
 			val := vm.pop()
-			name := vm.pop()
-			vm.curFrame.cl.Exports[name.(*object.String).Value] = val
+			if name, ok := vm.pop().(*object.String); ok {
+				vm.curFrame.cl.Exports[name.Value] = val
+			} else {
+				err = newError(object.TypeError, "export name must be String")
+			}
+
+		case code.OpTry:
+			slot := int(code.ReadUint16(ins[ip+1:]))
+			vm.curFrame.ip += 2
+
+			vm.curFrame.setTrySP(slot, vm.sp)
+
+		case code.OpThrow:
+			err = thrownError(vm.pop())
 		}
 
 		if err != nil {
-			return err
+			err = vm.raise(err, depth)
+			if err != nil {
+				return err
+			}
 		}
 	}
 
 	return nil
 }
 
+// Call calls fn with args and returns its result. Calls made while vm runs,
+// by a builtin or member function, run in nested frames on the same stack.
+// An error the call doesn't handle is returned as a *RuntimeError.
+func (vm *VM) Call(fn object.Object, args ...object.Object) (object.Object, error) {
+	sp, depth, frame := vm.sp, vm.framesIndex, vm.curFrame
+
+	err := vm.push(fn)
+	for i := 0; err == nil && i < len(args); i++ {
+		err = vm.push(args[i])
+	}
+	if err == nil {
+		err = vm.executeCall(len(args))
+	}
+	if err != nil {
+		err = newRuntimeError(raisedError(err, vm.stackTrace))
+	} else if vm.framesIndex > depth {
+		if vm.hook != nil {
+			err = vm.hook.OnCall(vm, vm.curFrame)
+		}
+		if err == nil {
+			err = vm.run(depth)
+		}
+	}
+
+	if err != nil {
+		vm.sp, vm.framesIndex, vm.curFrame = sp, depth, frame
+		return nil, err
+	}
+	return vm.pop(), nil
+}
+
+// CallContext is Call stopping with a CanceledError once ctx is done, like
+// RunContext.
+func (vm *VM) CallContext(ctx context.Context, fn object.Object, args ...object.Object) (object.Object, error) {
+	prev := vm.limits.ctx
+	vm.limits.ctx = ctx
+	defer func() { vm.limits.ctx = prev }()
+
+	return vm.Call(fn, args...)
+}
+
+// Output returns the writer print writes to.
+func (vm *VM) Output() io.Writer {
+	return vm.config.Output
+}
+
+// Allocate charges size bytes to the memory budget of the run.
+func (vm *VM) Allocate(size int) error {
+	return vm.limits.charge(size)
+}
+
 func (vm *VM) LastPoppedStackElem() object.Object {
 	return vm.stack[vm.sp]
 }
 
 func (vm *VM) push(o object.Object) error {
-	if vm.sp >= StackSize {
-		return stackOverflowErr
+	if vm.sp >= len(vm.stack) {
+		if err := vm.growStack(vm.sp + 1); err != nil {
+			return err
+		}
 	}
 
 	vm.stack[vm.sp] = o
@@ -282,6 +471,35 @@ func (vm *VM) push(o object.Object) error {
 	return nil
 }
 
+// pushAllocated pushes an object the VM just created, counting it against
+// the allocation budget.
+func (vm *VM) pushAllocated(o object.Object) error {
+	if err := vm.limits.allocate(o); err != nil {
+		return err
+	}
+	return vm.push(o)
+}
+
+// growStack makes room for n stack slots.
+func (vm *VM) growStack(n int) error {
+	stack, ok := grow(vm.stack, n, vm.config.MaxStack)
+	if !ok {
+		return vm.config.stackOverflow()
+	}
+	vm.stack = stack
+	return nil
+}
+
+// growGlobals makes room for the global at index i.
+func (vm *VM) growGlobals(i int) error {
+	globals, ok := grow(vm.globals, i+1, vm.config.MaxGlobals)
+	if !ok {
+		return vm.config.globalOverflow()
+	}
+	vm.globals = globals
+	return nil
+}
+
 func (vm *VM) pop() object.Object {
 	if vm.sp == 0 {
 		return object.NullObject
@@ -295,18 +513,116 @@ func (vm *VM) pop() object.Object {
 func (vm *VM) executeBinaryOperation(op string) error {
 	right := vm.pop()
 	left := vm.pop()
+	return vm.binaryOperation(op, left, right)
+}
+
+func (vm *VM) binaryOperation(op string, left, right object.Object) error {
+	// Numbers don't count against the allocation budget
+	if result := arithmetic(op, left, right); result != nil {
+		return vm.push(result)
+	}
+
+	result, err := infixOperation(op, left, right)
+	if err != nil {
+		return err
+	}
+	return vm.pushAllocated(result)
+}
+
+func infixOperation(op string, left, right object.Object) (object.Object, error) {
+	leftOp, ok := left.(object.InfixOperatorObject)
+	if !ok {
+		return nil, newError(object.TypeError, "%s: %s %s %s", "unknown eval operator", left.Type(), op, right.Type())
+	}
+
+	result := leftOp.InfixOperation(op, right)
+	if errObj, ok := result.(*object.Error); ok {
+		return nil, errObj
+	}
+	return result, nil
+}
+
+// arithmetic returns the result of an arithmetic operation on two numbers,
+// or nil if the operands aren't numbers or the operation has no fast path.
+func arithmetic(op string, left, right object.Object) object.Object {
+	switch l := left.(type) {
+	case *object.Integer:
+		switch r := right.(type) {
+		case *object.Integer:
+			switch op {
+			case "+":
+				return object.NewInteger(l.Value + r.Value)
+			case "-":
+				return object.NewInteger(l.Value - r.Value)
+			case "*":
+				return object.NewInteger(l.Value * r.Value)
+			}
+		case *object.Float:
+			return floatArithmetic(op, float64(l.Value), r.Value)
+		}
+	case *object.Float:
+		switch r := right.(type) {
+		case *object.Integer:
+			return floatArithmetic(op, l.Value, float64(r.Value))
+		case *object.Float:
+			return floatArithmetic(op, l.Value, r.Value)
+		}
+	}
+	return nil
+}
+
+func floatArithmetic(op string, l, r float64) object.Object {
+	switch op {
+	case "+":
+		return &object.Float{Value: l + r}
+	case "-":
+		return &object.Float{Value: l - r}
+	case "*":
+		return &object.Float{Value: l * r}
+	case "/":
+		return &object.Float{Value: l / r}
+	}
+	return nil
+}
 
-	if leftOp, ok := left.(object.InfixOperatorObject); ok {
-		result := leftOp.InfixOperation(op, right)
-		if result.Type() != object.ErrorObj {
-			vm.push(result)
-			return nil
-		} else {
-			return fmt.Errorf(result.(*object.Error).Message)
+// compareNumbers compares two numbers. It reports false if the operands
+// aren't numbers.
+func compareNumbers(op string, left, right object.Object) (holds bool, ok bool) {
+	switch l := left.(type) {
+	case *object.Integer:
+		switch r := right.(type) {
+		case *object.Integer:
+			return compare(op, l.Value, r.Value)
+		case *object.Float:
+			return compare(op, float64(l.Value), r.Value)
+		}
+	case *object.Float:
+		switch r := right.(type) {
+		case *object.Integer:
+			return compare(op, l.Value, float64(r.Value))
+		case *object.Float:
+			return compare(op, l.Value, r.Value)
 		}
-	} else {
-		return fmt.Errorf("%s: %s %s %s", "unknown eval operator", left.Type(), op, right.Type())
 	}
+	return false, false
+}
+
+func compare[T int64 | float64](op string, l, r T) (bool, bool) {
+	switch op {
+	case "==":
+		return l == r, true
+	case "!=":
+		return l != r, true
+	case "<":
+		return l < r, true
+	case "<=":
+		return l <= r, true
+	case ">":
+		return l > r, true
+	case ">=":
+		return l >= r, true
+	}
+	return false, false
 }
 
 func (vm *VM) executeMemberOperation(op code.Opcode) error {
@@ -314,8 +630,7 @@ func (vm *VM) executeMemberOperation(op code.Opcode) error {
 	left := vm.pop()
 
 	result := left.GetMember(right.String())
-	vm.push(result)
-	return nil
+	return vm.push(result)
 }
 
 func (vm *VM) executeMemberSetOperation(op code.Opcode) error {
@@ -323,86 +638,188 @@ func (vm *VM) executeMemberSetOperation(op code.Opcode) error {
 	member := vm.pop()
 	right := vm.pop()
 
-	result := left.SetMember(member.String(), right)
-	if result.Type() != object.ErrorObj {
-		vm.push(result)
-		return nil
+	result, err := memberSet(left, member, right)
+	if err != nil {
+		return err
 	}
+	return vm.push(result)
+}
 
-	return fmt.Errorf(result.(*object.Error).Message)
+// memberSet sets the member of left named by member to right.
+func memberSet(left, member, right object.Object) (object.Object, error) {
+	result := left.SetMember(member.String(), right)
+	if errObj, ok := result.(*object.Error); ok {
+		return nil, errObj
+	}
+	return result, nil
 }
 
 func (vm *VM) executeComparison(op string) error {
 	right := vm.pop()
 	left := vm.pop()
 
-	if leftOp, ok := left.(object.InfixOperatorObject); ok {
-		result := leftOp.InfixOperation(op, right)
-		if result.Type() != object.ErrorObj {
-			vm.push(result)
-			return nil
-		} else {
-			return fmt.Errorf(result.(*object.Error).Message)
-		}
-	} else {
-		return fmt.Errorf("%s: %s %s %s", "unknownOperatorError", left.Type(), op, right.Type())
+	result, err := comparison(op, left, right)
+	if err != nil {
+		return err
+	}
+	return vm.push(result)
+}
+
+// comparison applies the comparison operator op to left and right.
+func comparison(op string, left, right object.Object) (object.Object, error) {
+	if holds, ok := compareNumbers(op, left, right); ok {
+		return object.NativeBoolToBooleanObject(holds), nil
 	}
+
+	// a < b is b > a, so other types only implement > and >=, and report
+	// mismatched operands the same way for every comparison
+	switch op {
+	case "<":
+		return infixOperation(">", right, left)
+	case "<=":
+		return infixOperation(">=", right, left)
+	}
+	return infixOperation(op, left, right)
+}
+
+// fusedComparison returns the comparison fused into a conditional jump.
+func fusedComparison(op code.Opcode) string {
+	switch op {
+	case code.OpJumpIfGreaterOrEqual, code.OpRegJumpIfGreaterOrEqual:
+		return "<"
+	case code.OpJumpIfGreaterThan, code.OpRegJumpIfGreaterThan:
+		return "<="
+	case code.OpJumpIfLessOrEqual, code.OpRegJumpIfLessOrEqual:
+		return ">"
+	default:
+		return ">="
+	}
+}
+
+// executeFusedComparison pops the operands of the comparison fused into the
+// jump op and reports whether the comparison holds.
+func (vm *VM) executeFusedComparison(op code.Opcode) (bool, error) {
+	right := vm.pop()
+	left := vm.pop()
+	return fusedHolds(fusedComparison(op), left, right)
+}
+
+// fusedHolds reports whether the comparison operator holds for left and
+// right.
+func fusedHolds(operator string, left, right object.Object) (bool, error) {
+	if holds, ok := compareNumbers(operator, left, right); ok {
+		return holds, nil
+	}
+
+	result, err := comparison(operator, left, right)
+	if err != nil {
+		return false, err
+	}
+	return isTruthy(result), nil
 }
 
 func (vm *VM) executeBangOperator() error {
-	operand := vm.pop()
+	return vm.push(bang(vm.pop()))
+}
 
+func bang(operand object.Object) object.Object {
 	switch operand {
 	case True:
-		return vm.push(False)
+		return False
 	case False:
-		return vm.push(True)
+		return True
 	case Null:
-		return vm.push(True)
+		return True
 	default:
-		return vm.push(False)
+		return False
 	}
 }
 
 func (vm *VM) executeMinusOperator() error {
-	operand := vm.pop()
+	result, err := negate(vm.pop())
+	if err != nil {
+		return err
+	}
+	return vm.push(result)
+}
 
+func negate(operand object.Object) (object.Object, error) {
 	switch val := operand.(type) {
 	case *object.Integer:
-		return vm.push(&object.Integer{Value: -val.Value})
+		return object.NewInteger(-val.Value), nil
 	case *object.Float:
-		return vm.push(&object.Float{Value: -val.Value})
+		return &object.Float{Value: -val.Value}, nil
 	}
 
-	return fmt.Errorf("unsupported type for negation: %s", operand.Type())
+	return nil, newError(object.TypeError, "unsupported type for negation: %s", operand.Type())
 }
 
 func (vm *VM) executeIndexExpression(left, index, indexUpper, indexSkip, hasUpper, hasSkip object.Object) error {
+	result, err := indexExpression(left, index, indexUpper, indexSkip, hasUpper, hasSkip)
+	if err != nil {
+		return err
+	}
+	return vm.push(result)
+}
+
+func indexExpression(left, index, indexUpper, indexSkip, hasUpper, hasSkip object.Object) (object.Object, error) {
 	switch {
 	case left.Type() == object.ArrayObj:
-		return vm.executeArrayIndex(left, index, indexUpper, indexSkip, hasUpper, hasSkip)
+		return arrayIndex(left, index, indexUpper, indexSkip, hasUpper, hasSkip)
 
 	case left.Type() == object.HashObj:
-		return vm.executeHashIndex(left, index)
+		return hashIndex(left, index)
 	default:
-		return fmt.Errorf("index operator not supported: %s", left.Type())
+		return nil, newError(object.TypeError, "index operator not supported: %s", left.Type())
+	}
+}
+
+// executeIndexSimple indexes left with a single index, as in a[i].
+func (vm *VM) executeIndexSimple(left, index object.Object) error {
+	result, err := indexSimple(left, index)
+	if err != nil {
+		return err
+	}
+	return vm.push(result)
+}
+
+func indexSimple(left, index object.Object) (object.Object, error) {
+	if array, ok := left.(*object.Array); ok {
+		if i, ok := index.(*object.Integer); ok {
+			idx, max := i.Value, int64(len(array.Elements))
+			if idx < 0 {
+				idx += max
+			}
+			if idx < 0 || idx >= max {
+				return Null, nil
+			}
+			return array.Elements[idx], nil
+		}
 	}
+	return indexExpression(left, index, object.NullObject, one, False, False)
 }
 
 func (vm *VM) executeIndexSetExpression(left, index, indexUpper, indexSkip, hasUpper, hasSkip, right object.Object) error {
+	return indexSet(left, index, indexUpper, indexSkip, hasUpper, hasSkip, right)
+}
+
+func indexSet(left, index, indexUpper, indexSkip, hasUpper, hasSkip, right object.Object) error {
 	switch {
 	case left.Type() == object.ArrayObj:
-		return vm.executeArrayIndexSet(left, index, indexUpper, indexSkip, hasUpper, hasSkip, right)
+		return arrayIndexSet(left, index, indexUpper, indexSkip, hasUpper, hasSkip, right)
 
 	case left.Type() == object.HashObj:
-		return vm.executeHashIndexSet(left, index, right)
+		return hashIndexSet(left, index, right)
 	default:
-		return fmt.Errorf("index set operator not supported: %s", left.Type())
+		return newError(object.TypeError, "index set operator not supported: %s", left.Type())
 	}
 }
 
-func (vm *VM) executeArrayIndex(array, index, indexUpper, indexSkip, hasUpper, hasSkip object.Object) error {
+func arrayIndex(array, index, indexUpper, indexSkip, hasUpper, hasSkip object.Object) (object.Object, error) {
 	arrayObject := array.(*object.Array)
+	if index.Type() != object.IntegerObj {
+		return nil, newError(object.TypeError, "array index must be Integer, got %s", index.Type())
+	}
 	idx := index.(*object.Integer).Value
 	max := int64(len(arrayObject.Elements))
 
@@ -414,22 +831,36 @@ func (vm *VM) executeArrayIndex(array, index, indexUpper, indexSkip, hasUpper, h
 
 	if !isTruthy(hasUpper) {
 		if idx < 0 || idx >= max {
-			return vm.push(Null)
+			return Null, nil
 		}
 
-		return vm.push(arrayObject.Elements[idx])
+		return arrayObject.Elements[idx], nil
 	}
 
 	if indexUpper != object.NullObject {
-		idxUpper = indexUpper.(*object.Integer).Value
+		upper, ok := indexUpper.(*object.Integer)
+		if !ok {
+			return nil, newError(object.TypeError, "slice bound must be Integer, got %s", indexUpper.Type())
+		}
+		idxUpper = upper.Value
 	}
 	if idxUpper < 0 {
 		idxUpper += max
 	}
 
 	var inc int64 = 1
-	if isTruthy(hasSkip) {
-		inc = indexSkip.(*object.Integer).Value
+	if isTruthy(hasSkip) && indexSkip != object.NullObject {
+		skip, ok := indexSkip.(*object.Integer)
+		if !ok {
+			return nil, newError(object.TypeError, "slice step must be Integer, got %s", indexSkip.Type())
+		}
+		inc = skip.Value
+	}
+	if inc <= 0 {
+		return nil, newError(object.IndexError, "slice step must be positive, got %d", inc)
+	}
+	if idx < 0 || idx > max || idxUpper < 0 || idxUpper > max {
+		return nil, newError(object.IndexError, "slice bounds out of range with length %d", max)
 	}
 
 	elements := make([]object.Object, 0)
@@ -437,13 +868,16 @@ func (vm *VM) executeArrayIndex(array, index, indexUpper, indexSkip, hasUpper, h
 	for i := idx; i < idxUpper; i += inc {
 		elements = append(elements, arrayObject.Elements[i])
 	}
-	return vm.push(&object.Array{
+	return &object.Array{
 		Elements: elements,
-	})
+	}, nil
 }
 
-func (vm *VM) executeArrayIndexSet(array, index, indexUpper, indexSkip, hasUpper, hasSkip, right object.Object) error {
+func arrayIndexSet(array, index, indexUpper, indexSkip, hasUpper, hasSkip, right object.Object) error {
 	arrayObject := array.(*object.Array)
+	if index.Type() != object.IntegerObj {
+		return newError(object.TypeError, "array index must be Integer, got %s", index.Type())
+	}
 	idx := index.(*object.Integer).Value
 	max := int64(len(arrayObject.Elements))
 
@@ -454,13 +888,13 @@ func (vm *VM) executeArrayIndexSet(array, index, indexUpper, indexSkip, hasUpper
 	}
 
 	if !isTruthy(hasUpper) {
-		if idx >= max {
-			return fmt.Errorf("index out of bounds")
+		if idx < 0 || idx >= max {
+			return newError(object.IndexError, "index out of bounds")
 		}
 		arrayObject.Elements[idx] = right
 		return nil
 	}
-	return fmt.Errorf("Cannot assign range!")
+	return newError(object.IndexError, "Cannot assign range!")
 	// TODO: Implement setting of array
 	// if indexUpper != object.NullObject {
 	// 	idxUpper = indexUpper.(*object.Integer).Value
@@ -484,28 +918,28 @@ func (vm *VM) executeArrayIndexSet(array, index, indexUpper, indexSkip, hasUpper
 	// })
 }
 
-func (vm *VM) executeHashIndex(hash, index object.Object) error {
+func hashIndex(hash, index object.Object) (object.Object, error) {
 	hashObject := hash.(*object.Hash)
 
 	key, ok := index.(object.Hashable)
 	if !ok {
-		return fmt.Errorf("unusable as hash key: %s", index.Type())
+		return nil, newError(object.TypeError, "unusable as hash key: %s", index.Type())
 	}
 
 	pair, ok := hashObject.Pairs[key.HashKey()]
 	if !ok {
-		return vm.push(Null)
+		return Null, nil
 	}
 
-	return vm.push(pair.Value)
+	return pair.Value, nil
 }
 
-func (vm *VM) executeHashIndexSet(hash, index, right object.Object) error {
+func hashIndexSet(hash, index, right object.Object) error {
 	hashObject := hash.(*object.Hash)
 
 	key, ok := index.(object.Hashable)
 	if !ok {
-		return fmt.Errorf("unusable as hash key: %s", index.Type())
+		return newError(object.TypeError, "unusable as hash key: %s", index.Type())
 	}
 
 	hashObject.Pairs[key.HashKey()] = object.HashPair{
@@ -516,27 +950,33 @@ func (vm *VM) executeHashIndexSet(hash, index, right object.Object) error {
 }
 
 func (vm *VM) buildArray(startIndex, endIndex int) object.Object {
-	elements := make([]object.Object, endIndex-startIndex)
-
-	for i := startIndex; i < endIndex; i++ {
-		elements[i-startIndex] = vm.stack[i]
-	}
+	return newArray(vm.stack[startIndex:endIndex])
+}
 
+// newArray returns an array holding a copy of values.
+func newArray(values []object.Object) object.Object {
+	elements := make([]object.Object, len(values))
+	copy(elements, values)
 	return &object.Array{Elements: elements}
 }
 
 func (vm *VM) buildHash(startIndex, endIndex int) (object.Object, error) {
+	return newHash(vm.stack[startIndex:endIndex])
+}
+
+// newHash returns a hash of the alternating keys and values in values.
+func newHash(values []object.Object) (object.Object, error) {
 	hashedPairs := make(map[object.HashKey]object.HashPair)
 
-	for i := startIndex; i < endIndex; i += 2 {
-		key := vm.stack[i]
-		value := vm.stack[i+1]
+	for i := 0; i < len(values); i += 2 {
+		key := values[i]
+		value := values[i+1]
 
 		pair := object.HashPair{Key: key, Value: value}
 
 		hashKey, ok := key.(object.Hashable)
 		if !ok {
-			return nil, fmt.Errorf("unusable as hash key: %s", key.Type())
+			return nil, newError(object.TypeError, "unusable as hash key: %s", key.Type())
 		}
 
 		hashedPairs[hashKey.HashKey()] = pair
@@ -550,9 +990,11 @@ func (vm *VM) currentFrame() *Frame {
 }
 
 func (vm *VM) pushFrame(f *Frame) error {
-	if vm.framesIndex >= MaxFrames {
-		return frameOverflowErr
+	frames, ok := grow(vm.frames, vm.framesIndex+1, vm.config.MaxFrames)
+	if !ok {
+		return vm.config.frameOverflow()
 	}
+	vm.frames = frames
 	vm.frames[vm.framesIndex] = f
 	vm.framesIndex++
 
@@ -577,13 +1019,13 @@ func (vm *VM) executeCall(numArgs int) error {
 	case *object.MemberFn:
 		return vm.callMember(callee, numArgs)
 	default:
-		return fmt.Errorf("calling non-function and non-built-in")
+		return newError(object.TypeError, "calling non-function and non-built-in")
 	}
 }
 
 func (vm *VM) callClosure(cl *object.Closure, numArgs int) error {
 	if numArgs != cl.Fn.NumParameters {
-		return fmt.Errorf("wrong number of arguments: want=%d, got=%d",
+		return newError(object.ArityError, "wrong number of arguments: want=%d, got=%d",
 			cl.Fn.NumParameters, numArgs)
 	}
 
@@ -593,39 +1035,71 @@ func (vm *VM) callClosure(cl *object.Closure, numArgs int) error {
 		return err
 	}
 
+	err = vm.growStack(frame.basePointer + cl.Fn.NumLocals)
+	if err != nil {
+		vm.popFrame()
+		return err
+	}
 	vm.sp = frame.basePointer + cl.Fn.NumLocals
 
 	return nil
 }
 
+// tailCallClosure runs cl in the frame of the current function, which has
+// nothing left to do but return the result of the call.
+func (vm *VM) tailCallClosure(cl *object.Closure, numArgs int) error {
+	if numArgs != cl.Fn.NumParameters {
+		return newError(object.ArityError, "wrong number of arguments: want=%d, got=%d",
+			cl.Fn.NumParameters, numArgs)
+	}
+
+	frame := vm.curFrame
+	if err := vm.growStack(frame.basePointer + cl.Fn.NumLocals); err != nil {
+		return err
+	}
+	copy(vm.stack[frame.basePointer-1:], vm.stack[vm.sp-1-numArgs:vm.sp])
+
+	frame.cl = cl
+	frame.ip = -1
+	frame.instructions = cl.Fn.Instructions
+	frame.trySP = nil
+
+	vm.sp = frame.basePointer + cl.Fn.NumLocals
+	return nil
+}
+
 func (vm *VM) callBuiltin(builtin *object.Builtin, numArgs int) error {
 	args := vm.stack[vm.sp-numArgs : vm.sp]
 
-	result := builtin.Fn(args...)
+	result, err := nativeResult(builtin.Fn(vm, args...))
 	vm.sp = vm.sp - numArgs - 1
-
-	if result != nil {
-		vm.push(result)
-	} else {
-		vm.push(Null)
+	if err != nil {
+		return err
 	}
-
-	return nil
+	return vm.pushAllocated(result)
 }
 
 func (vm *VM) callMember(memberfn *object.MemberFn, numArgs int) error {
 	args := vm.stack[vm.sp-numArgs : vm.sp]
 
-	result := memberfn.Fn(memberfn.Obj, args...)
+	result, err := nativeResult(memberfn.Fn(vm, memberfn.Obj, args...))
 	vm.sp = vm.sp - numArgs - 1
-
-	if result != nil {
-		vm.push(result)
-	} else {
-		vm.push(Null)
+	if err != nil {
+		return err
 	}
+	return vm.pushAllocated(result)
+}
 
-	return nil
+// nativeResult turns what a builtin or member function returned into the
+// value of the call or the error it raises.
+func nativeResult(result object.Object) (object.Object, error) {
+	if errObj, ok := result.(*object.Error); ok {
+		return nil, errObj
+	}
+	if result == nil {
+		return Null, nil
+	}
+	return result, nil
 }
 
 func (vm *VM) pushClosure(constIndex, numFree int) error {
@@ -639,32 +1113,19 @@ func (vm *VM) pushClosure(constIndex, numFree int) error {
 	vm.sp = vm.sp - numFree
 
 	closure := &object.Closure{Fn: function, Free: free, Exports: map[string]object.Object{}}
-	return vm.push(closure)
+	return vm.pushAllocated(closure)
 }
 
+// ExecClosure calls closure with args and returns its result, or the error
+// object the call failed with.
+//
+// Deprecated: Use Call.
 func (vm *VM) ExecClosure(closure *object.Closure, args ...object.Object) object.Object {
-	nvm := NewWithGlobalsStore(
-		&compiler.Bytecode{
-			Instructions: code.Instructions{},
-			Constants:    vm.constants,
-		},
-		vm.globals,
-	)
-	nvm.push(Null)
-	for _, arg := range args {
-		nvm.push(arg)
-	}
-	err := nvm.callClosure(closure, len(args))
-
+	result, err := vm.Call(closure, args...)
 	if err != nil {
-		return object.NewError("Error calling closure: %s", err)
-	}
-
-	nvm.Run()
-	if nvm.sp >= 0 {
-		return nvm.stack[nvm.sp-1]
+		return object.AsError(err)
 	}
-	return Null
+	return result
 }
 
 func isTruthy(obj object.Object) bool {
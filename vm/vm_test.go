@@ -1,6 +1,7 @@
 package vm
 
 import (
+	"errors"
 	"fmt"
 	"testing"
 
@@ -16,6 +17,58 @@ type vmTestCase struct {
 	expected interface{}
 }
 
+// backend compiles and runs programs one way. run returns the value of the
+// last expression statement.
+type backend struct {
+	name    string
+	compile func(program *ast.Program) (*compiler.Bytecode, error)
+	run     func(bytecode *compiler.Bytecode) (object.Object, error)
+}
+
+func runStack(bytecode *compiler.Bytecode) (object.Object, error) {
+	vm := New(bytecode)
+	if err := vm.Run(); err != nil {
+		return nil, err
+	}
+	return vm.LastPoppedStackElem(), nil
+}
+
+// Every program has to give the same result on each backend
+var backends = []backend{
+	{
+		name: "stack",
+		compile: func(program *ast.Program) (*compiler.Bytecode, error) {
+			comp := compiler.New()
+			err := comp.Compile(program)
+			return comp.Bytecode(), err
+		},
+		run: runStack,
+	},
+	{
+		name: "optimized",
+		compile: func(program *ast.Program) (*compiler.Bytecode, error) {
+			comp := compiler.New()
+			comp.Optimize()
+			err := comp.Compile(program)
+			return comp.Bytecode(), err
+		},
+		run: runStack,
+	},
+	{
+		name: "register",
+		compile: func(program *ast.Program) (*compiler.Bytecode, error) {
+			comp := compiler.NewRegister()
+			err := comp.Compile(program)
+			return comp.Bytecode(), err
+		},
+		run: func(bytecode *compiler.Bytecode) (object.Object, error) {
+			vm := NewRegister(bytecode)
+			err := vm.Run()
+			return vm.Result(), err
+		},
+	},
+}
+
 func TestIntegerArithmetic(t *testing.T) {
 	tests := []vmTestCase{
 		{"1", 1},
@@ -100,6 +153,18 @@ func TestLoop(t *testing.T) {
 	tests := []vmTestCase{
 		{"i=0; s=0; loop(i<10) { s = s + i; i = i + 1; }; s;", 45},
 		{"i=10; s=0; loop(i>0) { s = s + i; i = i - 1; }; s;", 55},
+		{"i=0; s=0; loop(i<=10) { s = s + i; i = i + 1; }; s;", 55},
+		{"i=10; s=0; loop(i>=1) { s = s + i; i = i - 1; }; s;", 55},
+		{"let f = fn(n) { let i = 0.5; let c = 0; loop (i < n) { i = i + 1; c = c + 1 }; c }; f(3)", 3},
+		{`let f = fn(s) { loop (s < "aaa") { s = s + "a" }; s }; f("")`, "aaa"},
+		{"let f = fn(n) { let s = 5; let i = 0; loop (i < n) { i = i + 1 }; s }; f(3)", 5},
+		{"let f = fn() { let i = 0; loop (i < 3) { i = i + 1 } }; f()", Null},
+		{"let a = 7; [1, loop (false) { 2 }, 3][1]", Null},
+		{"let a = 7; let b = [1, loop (false) { 2 }, 3]; b[0] + b[2]", 4},
+		{"x = loop (1 > 2) {}; x", Null},
+		{"let f = fn() { [1, loop (true) { return 5 }, 3] }; f()", 5},
+		{`let f = fn() { let i = 1 < 2; let s = "x"; let g = 0.5; [i, s + "y", g + 1] }; f()[1]`, "xy"},
+		{`let f = fn(n) { if (n <= 0) { "none" } else { if (n >= 10) { "many" } else { "some" } } }; f(0) + f(5) + f(10)`, "nonesomemany"},
 	}
 
 	runVmTests(t, tests)
@@ -108,6 +173,7 @@ func TestLoop(t *testing.T) {
 func TestIter(t *testing.T) {
 	tests := []vmTestCase{
 		{"a = [0,1,2,3,4,5]; s = 0; iter i over a { s = s + i }; s", 15},
+		{"let sum = fn(a) { let s = 0; iter i over a { s = s + i }; s }; sum([1, 2, 3])", 6},
 	}
 
 	runVmTests(t, tests)
@@ -126,6 +192,7 @@ func TestGlobalLetStatements(t *testing.T) {
 		{"let one = 1; one", 1},
 		{"let one = 1; let two = 2; one + two", 3},
 		{"let one = 1; let two = one + one; one + two", 3},
+		{"let one: int = 1; let add = fn(a: int, b) -> int { a + b }; add(one, 2)", 3},
 	}
 
 	runVmTests(t, tests)
@@ -237,6 +304,8 @@ func TestSettingOnIndex(t *testing.T) {
 		{"let a = {0: 'hello'}; a[0] = 'world'; a[0]", "world"},
 		{"let a = {0: 'hello'}; a[1] = 'world'; a[1]", "world"},
 		{"let a = {0: 'hello'}; a[1] = 'world'; a[0]", "hello"},
+		{"let a = [1,2,3]; a[1] = 20", 20},
+		{"let f = fn() { let a = [1,2]; let s = 5; a[0] = 3; s }; f()", 5},
 	}
 
 	runVmTests(t, tests)
@@ -481,22 +550,20 @@ func TestCallingFunctionsWithWrongArguments(t *testing.T) {
 	}
 
 	for _, tt := range tests {
-		program := parse(tt.input)
-
-		comp := compiler.New()
-		err := comp.Compile(program)
-		if err != nil {
-			t.Fatalf("compiler error: %s", err)
-		}
+		for _, b := range backends {
+			bytecode, err := b.compile(parse(tt.input))
+			if err != nil {
+				t.Fatalf("compiler error: %s", err)
+			}
 
-		vm := New(comp.Bytecode())
-		err = vm.Run()
-		if err == nil {
-			t.Fatalf("expected VM error but resulted in none.")
-		}
+			_, err = b.run(bytecode)
+			if err == nil {
+				t.Fatalf("expected VM error but resulted in none (%s).", b.name)
+			}
 
-		if err.Error() != tt.expected {
-			t.Fatalf("wrong VM error: want=%q, got=%q", tt.expected, err.Error())
+			if err.Error() != tt.expected {
+				t.Fatalf("wrong VM error (%s): want=%q, got=%q", b.name, tt.expected, err.Error())
+			}
 		}
 	}
 }
@@ -506,18 +573,6 @@ func TestBuiltinFunctions(t *testing.T) {
 		{`len("")`, 0},
 		{`len("four")`, 4},
 		{`len("hello world")`, 11},
-		{
-			`len(1)`,
-			&object.Error{
-				Message: fmt.Sprintf("argument to %q not supported, got %s", object.BuiltinFuncNameLen, object.IntegerObj),
-			},
-		},
-		{
-			`len("one", "two")`,
-			&object.Error{
-				Message: "wrong number of arguments. got=2, want=1",
-			},
-		},
 		{`len([1, 2, 3])`, 3},
 		{`len([])`, 0},
 		{`print("hello", "world!")`, Null},
@@ -651,6 +706,28 @@ func TestRecursiveFibonacci(t *testing.T) {
 	runVmTests(t, tests)
 }
 
+func TestTailCalls(t *testing.T) {
+	tests := []vmTestCase{
+		{`let count = fn(n) { if (n == 0) { return "done" } return count(n - 1) }; count(100000)`, "done"},
+		{`let sum = fn(n, acc) { if (n == 0) { acc } else { sum(n - 1, acc + n) } }; sum(10000, 0)`, 50005000},
+		{`let sum = fn(n, acc) { if (n > 0) { sum(n - 1, acc + n) } else { acc } }; sum(10000, 0)`, 50005000},
+		{`
+			let odd = 0;
+			let even = fn(n) { if (n == 0) { return true } return odd(n - 1) };
+			odd = fn(n) { if (n == 0) { return false } return even(n - 1) };
+			even(10001)`, false},
+		// Callees with more locals, free variables and natives
+		{`let f = fn(n) { let a = 1; let b = 2; n + a + b }; let g = fn(n) { f(n) }; g(1)`, 4},
+		{`let k = 10; let f = fn(n) { if (n == 0) { k } else { f(n - 1) } }; f(5000)`, 10},
+		{`let f = fn(a) { len(a) }; f([1, 2, 3])`, 3},
+		// A call protected by a handler keeps its frame
+		{`let f = fn(n) { try { if (n == 0) { throw "x" } f(n - 1) } catch (e) { n } }; f(3)`, 0},
+		{`let g = fn() { 3 }; let f = fn() { try { 1 } catch (e) { 2 }; g() }; f()`, 3},
+	}
+
+	runVmTests(t, tests)
+}
+
 func TestTypeConversions(t *testing.T) {
 	tests := []vmTestCase{
 		{input: `int(10)`, expected: 10},
@@ -675,6 +752,14 @@ func TestErrors(t *testing.T) {
 			input:    `a = fn(){100;}; a < 10`,
 			expected: `type mismatch: Integer > Closure`,
 		},
+		{
+			input:    `a = fn(){100;}; 10 < a`,
+			expected: `unknown eval operator: Closure > Integer`,
+		},
+		{
+			input:    `a = fn(){100;}; loop (a <= 10) { 1 }`,
+			expected: `type mismatch: Integer >= Closure`,
+		},
 		{
 			input:    `a = fn(){100;}; a + 10`,
 			expected: `unknown eval operator: Closure + Integer`,
@@ -700,12 +785,12 @@ func TestErrors(t *testing.T) {
 			expected: `unusable as hash key: Closure`,
 		},
 		{
-			input:    `let a = fn() { a() }; a();`,
-			expected: "frame overflow",
+			input:    `let a = fn() { a(); 0 }; a();`,
+			expected: "frame overflow: more than 2048 frames",
 		},
 		{
-			input:    `let a = fn() { let b = 10; let c = 100; let d = 200; let e = 1000; a() }; a();`,
-			expected: "stack overflow",
+			input:    `let a = fn() { let b = 10; let c = 100; let d = 200; let e = 1000; a() + 1 }; a();`,
+			expected: "stack overflow: more than 4096 stack slots",
 		},
 		{
 			input:    `let a = 10; a[0] = 20`,
@@ -719,50 +804,317 @@ func TestErrors(t *testing.T) {
 			input:    `let a = {}; let b = fn() { 0; } a[b] = 20`,
 			expected: "unusable as hash key: Closure",
 		},
+		{
+			input:    `len(1)`,
+			expected: fmt.Sprintf("argument to %q not supported, got %s", object.BuiltinFuncNameLen, object.IntegerObj),
+		},
+		{
+			input:    `len("one", "two")`,
+			expected: "wrong number of arguments. got=2, want=1",
+		},
+		{
+			input:    `"abc".upper(1)`,
+			expected: "Could not execute string upper operation. Invalid arguments!",
+		},
+		{
+			input:    `throw "boom"`,
+			expected: "boom",
+		},
 	}
 
 	for _, tt := range tests {
-		program := parse(tt.input)
+		for _, b := range backends {
+			bytecode, err := b.compile(parse(tt.input))
+			if err != nil {
+				t.Fatalf("compiler error: %s", err)
+			}
+
+			_, err = b.run(bytecode)
+			if err == nil {
+				t.Fatalf("expected VM error but resulted in none (%s).", b.name)
+			}
+
+			if err.Error() != tt.expected {
+				t.Fatalf("wrong VM error (%s): want=%q, got=%q", b.name, tt.expected, err.Error())
+			}
+		}
+	}
+}
+
+func TestTryCatch(t *testing.T) {
+	tests := []vmTestCase{
+		{`try { 1 } catch (e) { 2 }`, 1},
+		{`try { int("abc") } catch (e) { 2 }`, 2},
+		{`try { throw "boom" } catch (e) { e.message }`, "boom"},
+		{`try { 1 + "a" } catch (e) { e.kind }`, "TypeError"},
+		{`try { [1][5] = 2 } catch (e) { e.kind }`, "IndexError"},
+		{`try { throw {"code": 42} } catch (e) { e.value["code"] }`, 42},
+		{`try { throw int("x") } catch (e) { e.kind }`, "ValueError"},
+		{`try { 1 / 0 } catch (e) { e.kind + ": " + e.message }`, "ValueError: division by zero"},
+		{`try { 1 % 0 } catch (e) { e.kind + ": " + e.message }`, "ValueError: division by zero"},
+		{`try { [1, 2, 3][0, 3, 0] } catch (e) { e.kind + ": " + e.message }`, "IndexError: slice step must be positive, got 0"},
+		{`try { [1, 2, 3][-10, 2] } catch (e) { e.kind + ": " + e.message }`, "IndexError: slice bounds out of range with length 3"},
+		{`try { [1, 2, 3][0, 10] } catch (e) { e.kind + ": " + e.message }`, "IndexError: slice bounds out of range with length 3"},
+		{`try { [1, 2, 3][0, "x"] } catch (e) { e.kind + ": " + e.message }`, "TypeError: slice bound must be Integer, got String"},
+		{`try { [1, 2, 3][0, 3, "x"] } catch (e) { e.kind + ": " + e.message }`, "TypeError: slice step must be Integer, got String"},
+		{`try { [1, 2, 3]["x"] } catch (e) { e.kind + ": " + e.message }`, "TypeError: array index must be Integer, got String"},
+		{`try { } catch { 5 }`, Null},
+		{`let a = 0; try { a = 1 } finally { a = a + 10 }; a`, 11},
+		{`let a = 0; try { throw 1 } catch { a = 1 } finally { a = a + 10 }; a`, 11},
+		{`let a = 0; try { try { throw 1 } finally { a = 10 } } catch { a = a + 1 }; a`, 11},
+		{`let a = 0; try { try { throw 1 } catch { throw 2 } finally { a = 10 } } catch (e) { a + e.value }`, 12},
+		{`let f = fn() { throw "inner" }; let g = fn() { f() + 1 }; try { g() } catch (e) { e.message }`, "inner"},
+		{`let f = fn(n) { try { if (n > 0) { throw n } n } catch (e) { e.value * 2 } }; f(0) + f(5)`, 10},
+		{`[1, 2, try { throw 3 } catch (e) { e.value }][2]`, 3},
+		{`let s = 0; let i = 0; loop (i < 5) { s = s + try { if (i % 2 == 0) { throw i } 0 } catch (e) { e.value }; i = i + 1 }; s`, 6},
+		{`let log = []; let f = fn() { try { return 1 } finally { log = log + [2] } }; f() + log[0]`, 3},
+		{`let f = fn() { try { throw 1 } catch { return 5 } finally { let x = 100 } }; f()`, 5},
+		{`let f = fn() { try { throw "x" } catch (e) { e.trace } }; len(f())`, 2},
+	}
+
+	runVmTests(t, tests)
+}
+
+func TestSerializedBytecode(t *testing.T) {
+	tests := []vmTestCase{
+		{`1.5 + 2`, 3.5},
+		{`"dreb" + "lang"`, "dreblang"},
+		{`let a = [1, 2, 3, 4]; a[1, 3][0] + a[,,2][1]`, 5},
+		{`let adder = fn(x) { fn(y) { x + y } }; adder(2)(3)`, 5},
+		{`let fib = fn(n) { if (n < 2) { n } else { fib(n - 1) + fib(n - 2) } }; fib(10)`, 55},
+		{`try { throw "boom" } catch (e) { e.message }`, "boom"},
+	}
+
+	for _, tt := range tests {
+		for _, b := range backends {
+			compiled, err := b.compile(parse(tt.input))
+			if err != nil {
+				t.Fatalf("compiler error: %s", err)
+			}
+
+			data, err := compiled.MarshalBinary()
+			if err != nil {
+				t.Fatalf("marshal error: %s", err)
+			}
+			bytecode, err := compiler.Unmarshal(data)
+			if err != nil {
+				t.Fatalf("unmarshal error: %s", err)
+			}
+
+			result, err := b.run(bytecode)
+			if err != nil {
+				t.Fatalf("vm error (%s): %s", b.name, err)
+			}
+			testExpectedObject(t, tt.expected, result)
+		}
+	}
+}
+
+func TestUncaughtThrowStackTrace(t *testing.T) {
+	input := `let check = fn(n) {
+	if (n > 1) {
+		throw "too big"
+	}
+	n
+};
+try { check(5) } finally { 1 };`
+
+	for _, b := range backends {
+		l := lexer.NewWithFilename(input, "main.dreb")
+		p := parser.New(l)
+		program := p.ParseProgram()
 
-		comp := compiler.New()
-		err := comp.Compile(program)
+		bytecode, err := b.compile(program)
 		if err != nil {
 			t.Fatalf("compiler error: %s", err)
 		}
 
-		vm := New(comp.Bytecode())
-		err = vm.Run()
-		if err == nil {
-			t.Fatalf("expected VM error but resulted in none.")
+		_, err = b.run(bytecode)
+
+		var rerr *RuntimeError
+		if !errors.As(err, &rerr) {
+			t.Fatalf("error is not RuntimeError (%s). got=%T (%+v)", b.name, err, err)
 		}
 
-		if err.Error() != tt.expected {
-			t.Fatalf("wrong VM error: want=%q, got=%q", tt.expected, err.Error())
+		if rerr.Message != "too big" || rerr.Kind != object.GenericError {
+			t.Errorf("wrong error (%s). got=%s: %s", b.name, rerr.Kind, rerr.Message)
+		}
+
+		expected := "\tat check (main.dreb:3:3)\n" +
+			"\tat <main> (main.dreb:7:12)\n"
+		if rerr.StackTrace() != expected {
+			t.Errorf("wrong stack trace (%s).\nwant=%q\ngot=%q", b.name, expected, rerr.StackTrace())
 		}
 	}
 }
 
-func runVmTests(t *testing.T, tests []vmTestCase) {
-	t.Helper()
+func TestErrorKinds(t *testing.T) {
+	tests := []struct {
+		input    string
+		expected object.ErrorKind
+	}{
+		{`a = 10; a(100)`, object.TypeError},
+		{`1 + "a"`, object.TypeError},
+		{`fn(a) { a }()`, object.ArityError},
+		{`let a = [0]; a[1] = 20`, object.IndexError},
+		{`let a = [0]; a["x"]`, object.TypeError},
+		{`let a = fn() { a(); 0 }; a();`, object.FrameOverflowError},
+		{`let a = fn() { let b = 10; let c = 100; let d = 200; let e = 1000; a() + 1 }; a();`, object.StackOverflowError},
+	}
 
 	for _, tt := range tests {
-		program := parse(tt.input)
+		for _, b := range backends {
+			bytecode, err := b.compile(parse(tt.input))
+			if err != nil {
+				t.Fatalf("compiler error: %s", err)
+			}
 
-		comp := compiler.New()
-		err := comp.Compile(program)
+			_, err = b.run(bytecode)
+			if err == nil {
+				t.Fatalf("expected VM error but resulted in none (%s).", b.name)
+			}
+
+			var rerr *RuntimeError
+			if !errors.As(err, &rerr) {
+				t.Fatalf("error is not RuntimeError (%s). got=%T (%+v)", b.name, err, err)
+			}
+			if rerr.Kind != tt.expected {
+				t.Errorf("wrong error kind for %q (%s). want=%q, got=%q", tt.input, b.name, tt.expected, rerr.Kind)
+			}
+
+			var objErr *object.Error
+			if !errors.As(err, &objErr) {
+				t.Fatalf("error does not wrap object.Error (%s). got=%T (%+v)", b.name, err, err)
+			}
+			if objErr.Kind != tt.expected {
+				t.Errorf("wrong object error kind for %q (%s). want=%q, got=%q", tt.input, b.name, tt.expected, objErr.Kind)
+			}
+		}
+	}
+}
+
+func TestRuntimeErrorStackTrace(t *testing.T) {
+	input := `let add = fn(a, b) {
+	a + b
+};
+let wrap = fn(x) {
+	return add(x, fn() {}) + 1;
+};
+wrap(1);`
+
+	for _, b := range backends {
+		l := lexer.NewWithFilename(input, "main.dreb")
+		p := parser.New(l)
+		program := p.ParseProgram()
+
+		bytecode, err := b.compile(program)
 		if err != nil {
 			t.Fatalf("compiler error: %s", err)
 		}
 
-		vm := New(comp.Bytecode())
-		err = vm.Run()
+		_, err = b.run(bytecode)
+		if err == nil {
+			t.Fatalf("expected VM error but resulted in none (%s).", b.name)
+		}
+
+		rerr, ok := err.(*RuntimeError)
+		if !ok {
+			t.Fatalf("error is not RuntimeError (%s). got=%T (%+v)", b.name, err, err)
+		}
+
+		if rerr.Error() != "type mismatch: Integer + Closure" {
+			t.Errorf("wrong error message (%s). got=%q", b.name, rerr.Error())
+		}
+
+		if rerr.Pos.String() != "main.dreb:2:4" {
+			t.Errorf("wrong error position (%s). got=%q", b.name, rerr.Pos)
+		}
+
+		expected := "\tat add (main.dreb:2:4)\n" +
+			"\tat wrap (main.dreb:5:12)\n" +
+			"\tat <main> (main.dreb:7:5)\n"
+		if rerr.StackTrace() != expected {
+			t.Errorf("wrong stack trace (%s).\nwant=%q\ngot=%q", b.name, expected, rerr.StackTrace())
+		}
+	}
+}
+
+func TestBackendEquivalence(t *testing.T) {
+	inputs := []string{
+		`1 + 2 * 3 - 4 / 2 % 3`,
+		`2.5 * 4 - 1 + 3 / 2.0`,
+		`"dreb" + "lang" == "dreblang"`,
+		`1 < 2 == !(2 <= 1) != false`,
+		`-(-5) + -2.5`,
+		`[0, -(-(8.5 - 8.5))]`,
+		`[0.0, -0.0, 1, 1.0]`,
+		`if (1 > 2) { 10 } else { 20 }`,
+		`if ("") { 1 }`,
+		`if (!true) { let x = 5 }; x`,
+		`let f = fn() { return 1; 2 }; f()`,
+		`let f = fn(n) { if (n) { if (n > 1) { 1 } else { 2 } } else { 3 } }; [f(0), f(1), f(2)]`,
+		`let f = fn() { let i = 0; loop (true) { i = i + 1; if (i > 3) { return i } } }; f()`,
+		`let i = 0; loop (1 > 2) { i = 100 }; i`,
+		`let f = fn() { try { if (true) { throw "x" } 1 } catch (e) { e.message } }; f()`,
+		`let f = fn() { if (false) { return 1 } else { throw "no" } }; f()`,
+		`if (false) { try { 1 } catch (e) { 2 } }; try { throw "x" } catch (e) { e.message }`,
+		`let f = fn() { try { throw "a"; try { 2 } catch (e) { 3 } } catch (e) { 4 }; try { throw "y" } catch (e) { e.message } }; f()`,
+		`1 + "a"`,
+		"let f = fn() {\n\tif (false) { 1 }\n\t[1][5]\n}; f()",
+	}
+
+	run := func(input string, b backend) string {
+		p := parser.New(lexer.NewWithFilename(input, "main.dreb"))
+		program := p.ParseProgram()
+		if len(p.Errors()) != 0 {
+			t.Fatalf("parser errors for %q: %v", input, p.Errors())
+		}
+
+		bytecode, err := b.compile(program)
 		if err != nil {
-			t.Fatalf("vm error: %s", err)
+			return "compiler error: " + err.Error()
 		}
 
-		stackElem := vm.LastPoppedStackElem()
+		result, err := b.run(bytecode)
+		if rerr, ok := err.(*RuntimeError); ok {
+			return "vm error: " + rerr.Error() + "\n" + rerr.StackTrace()
+		}
+		if err != nil {
+			return "vm error: " + err.Error()
+		}
+		if result == nil {
+			return "<nil>"
+		}
+		return result.Inspect()
+	}
+
+	for _, input := range inputs {
+		want := run(input, backends[0])
+		for _, b := range backends[1:] {
+			if got := run(input, b); want != got {
+				t.Errorf("%s %q differs.\nwant=%q\ngot =%q", b.name, input, want, got)
+			}
+		}
+	}
+}
+
+func runVmTests(t *testing.T, tests []vmTestCase) {
+	t.Helper()
 
-		testExpectedObject(t, tt.expected, stackElem)
+	for _, tt := range tests {
+		for _, b := range backends {
+			bytecode, err := b.compile(parse(tt.input))
+			if err != nil {
+				t.Fatalf("compiler error: %s", err)
+			}
+
+			result, err := b.run(bytecode)
+			if err != nil {
+				t.Fatalf("vm error (%s): %s", b.name, err)
+			}
+
+			testExpectedObject(t, tt.expected, result)
+		}
 	}
 }
 